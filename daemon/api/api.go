@@ -8,7 +8,7 @@ const (
 	ServiceName = "Daemon"
 
 	// Version is the API version. It should be bumped any time the API changes.
-	Version = -97
+	Version = -95
 )
 
 // Basic requests.
@@ -48,7 +48,7 @@ type NextCmdSeqResponse struct {
 }
 
 func (c *Client) NextCmdSeq() (int, error) {
-	req := &NextCmdRequest{}
+	req := &NextCmdSeqRequest{}
 	res := &NextCmdSeqResponse{}
 	err := c.CallDaemon("NextCmdSeq", req, res)
 	return res.Seq, err
@@ -100,6 +100,34 @@ func (c *Client) Cmds(from, upto int) ([]string, error) {
 	return res.Cmds, err
 }
 
+type CmdsWithSeqRequest struct {
+	From int
+	Upto int
+}
+
+type CmdsWithSeqResponse struct {
+	Cmds []storedefs.Cmd
+}
+
+func (c *Client) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
+	req := &CmdsWithSeqRequest{from, upto}
+	res := &CmdsWithSeqResponse{}
+	err := c.CallDaemon("CmdsWithSeq", req, res)
+	return res.Cmds, err
+}
+
+type DelCmdRequest struct {
+	Seq int
+}
+
+type DelCmdResponse struct{}
+
+func (c *Client) DelCmd(seq int) error {
+	req := &DelCmdRequest{seq}
+	res := &DelCmdResponse{}
+	return c.CallDaemon("DelCmd", req, res)
+}
+
 type NextCmdRequest struct {
 	From   int
 	Prefix string
@@ -134,6 +162,34 @@ func (c *Client) PrevCmd(upto int, prefix string) (int, string, error) {
 	return res.Seq, res.Text, err
 }
 
+type SetCmdMetaRequest struct {
+	Meta storedefs.CmdMeta
+}
+
+type SetCmdMetaResponse struct{}
+
+func (c *Client) SetCmdMeta(meta storedefs.CmdMeta) error {
+	req := &SetCmdMetaRequest{meta}
+	res := &SetCmdMetaResponse{}
+	return c.CallDaemon("SetCmdMeta", req, res)
+}
+
+type CmdMetasRequest struct {
+	From int
+	Upto int
+}
+
+type CmdMetasResponse struct {
+	Metas []storedefs.CmdMeta
+}
+
+func (c *Client) CmdMetas(from, upto int) ([]storedefs.CmdMeta, error) {
+	req := &CmdMetasRequest{from, upto}
+	res := &CmdMetasResponse{}
+	err := c.CallDaemon("CmdMetas", req, res)
+	return res.Metas, err
+}
+
 // Dir requests.
 
 type AddDirRequest struct {
@@ -202,7 +258,7 @@ type DelSharedVarRequest struct {
 type DelSharedVarResponse struct{}
 
 func (c *Client) DelSharedVar(name string) error {
-	req := &DelSharedVarRequest{}
+	req := &DelSharedVarRequest{name}
 	res := &DelSharedVarResponse{}
 	return c.CallDaemon("DelSharedVar", req, res)
 }
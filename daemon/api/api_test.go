@@ -1,7 +1,132 @@
 package api
 
-import "testing"
+import (
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeService is a minimal daemon service serving the given API version.
+type fakeService struct {
+	version int
+	deleted []string
+}
+
+func (s *fakeService) Version(req *VersionRequest, res *VersionResponse) error {
+	res.Version = s.version
+	return nil
+}
+
+func (s *fakeService) Pid(req *PidRequest, res *PidResponse) error {
+	res.Pid = 42
+	return nil
+}
+
+func (s *fakeService) NextCmdSeq(req *NextCmdSeqRequest, res *NextCmdSeqResponse) error {
+	res.Seq = 1
+	return nil
+}
+
+func (s *fakeService) DelSharedVar(req *DelSharedVarRequest, res *DelSharedVarResponse) error {
+	s.deleted = append(s.deleted, req.Name)
+	return nil
+}
+
+func serveFake(t *testing.T, sockPath string, version int) net.Listener {
+	return serveService(t, sockPath, &fakeService{version: version})
+}
+
+func serveService(t *testing.T, sockPath string, service *fakeService) net.Listener {
+	server := rpc.NewServer()
+	server.RegisterName(ServiceName, service)
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(listener)
+	return listener
+}
+
+func tempSockPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "elvish-api-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "sock"), func() { os.RemoveAll(dir) }
+}
 
 func TestAPI(t *testing.T) {
-	// TODO(xiaq): Add tests.
+	sockPath, cleanup := tempSockPath(t)
+	defer cleanup()
+	listener := serveFake(t, sockPath, Version)
+	defer listener.Close()
+
+	cl := NewClient(sockPath)
+	defer cl.Close()
+	if v, err := cl.Version(); v != Version || err != nil {
+		t.Errorf("Version() -> (%v, %v), want (%v, nil)", v, err, Version)
+	}
+	if seq, err := cl.NextCmdSeq(); seq != 1 || err != nil {
+		t.Errorf("NextCmdSeq() -> (%v, %v), want (1, nil)", seq, err)
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	sockPath, cleanup := tempSockPath(t)
+	defer cleanup()
+	listener := serveFake(t, sockPath, Version-1)
+	defer listener.Close()
+
+	cl := NewClient(sockPath)
+	defer cl.Close()
+	if v, err := cl.Version(); v != Version-1 || err != nil {
+		t.Errorf("Version() -> (%v, %v), want (%v, nil)", v, err, Version-1)
+	}
+	if pid, err := cl.Pid(); pid != 42 || err != nil {
+		t.Errorf("Pid() -> (%v, %v), want (42, nil)", pid, err)
+	}
+	if _, err := cl.NextCmdSeq(); err != ErrVersionMismatch {
+		t.Errorf("NextCmdSeq() -> error %v, want %v", err, ErrVersionMismatch)
+	}
+}
+
+func TestSpawnOnDemand(t *testing.T) {
+	sockPath, cleanup := tempSockPath(t)
+	defer cleanup()
+
+	var listener net.Listener
+	cl := NewClient(sockPath)
+	cl.SetSpawner(func() error {
+		listener = serveFake(t, sockPath, Version)
+		return nil
+	})
+	defer cl.Close()
+	if seq, err := cl.NextCmdSeq(); seq != 1 || err != nil {
+		t.Errorf("NextCmdSeq() -> (%v, %v), want (1, nil)", seq, err)
+	}
+	if listener == nil {
+		t.Errorf("spawner not called")
+	} else {
+		listener.Close()
+	}
+}
+
+func TestDelSharedVar(t *testing.T) {
+	sockPath, cleanup := tempSockPath(t)
+	defer cleanup()
+	service := &fakeService{version: Version}
+	listener := serveService(t, sockPath, service)
+	defer listener.Close()
+
+	cl := NewClient(sockPath)
+	defer cl.Close()
+	if err := cl.DelSharedVar("foo"); err != nil {
+		t.Errorf("DelSharedVar -> error %v", err)
+	}
+	if len(service.deleted) != 1 || service.deleted[0] != "foo" {
+		t.Errorf("DelSharedVar deleted %v, want [foo]", service.deleted)
+	}
 }
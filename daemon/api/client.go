@@ -4,18 +4,45 @@ import (
 	"errors"
 	"net/rpc"
 	"sync"
+	"time"
 )
 
-var ErrDaemonOffline = errors.New("daemon offline")
+var (
+	ErrDaemonOffline = errors.New("daemon offline")
+	// ErrVersionMismatch is returned by calls to a daemon that serves a
+	// different API version. Only the Version and Pid calls are allowed in
+	// that case, so that the caller can find and replace the daemon.
+	ErrVersionMismatch = errors.New("daemon serving a different API version")
+)
+
+const (
+	spawnWaitOneLoop = 10 * time.Millisecond
+	spawnWaitLoops   = 100
+)
 
 type Client struct {
 	sockPath  string
 	rpcClient *rpc.Client
 	waits     sync.WaitGroup
+
+	// Protects rpcClient, versionOK and spawn.
+	mutex sync.Mutex
+	// Whether the connected daemon serves the same API version.
+	versionOK bool
+	// If not nil, called to start the daemon when it cannot be connected to.
+	spawn func() error
 }
 
 func NewClient(sockPath string) *Client {
-	return &Client{sockPath, nil, sync.WaitGroup{}}
+	return &Client{sockPath: sockPath}
+}
+
+// SetSpawner sets the function that is called to start the daemon on demand,
+// when it cannot be connected to.
+func (c *Client) SetSpawner(spawn func() error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.spawn = spawn
 }
 
 func (c *Client) SockPath() string {
@@ -27,28 +54,66 @@ func (c *Client) Waits() *sync.WaitGroup {
 }
 
 func (c *Client) CallDaemon(f string, req, res interface{}) error {
+	c.mutex.Lock()
 	err := c.connect()
+	rpcClient, versionOK := c.rpcClient, c.versionOK
+	c.mutex.Unlock()
 	if err != nil {
 		return err
 	}
-	err = c.rpcClient.Call(ServiceName+"."+f, req, res)
+	if !versionOK && f != "Version" && f != "Pid" {
+		return ErrVersionMismatch
+	}
+	err = rpcClient.Call(ServiceName+"."+f, req, res)
 	if err == rpc.ErrShutdown {
 		// Clear rpcClient so as to reconnect next time
-		c.rpcClient = nil
+		c.mutex.Lock()
+		if c.rpcClient == rpcClient {
+			c.rpcClient = nil
+		}
+		c.mutex.Unlock()
 	}
 	return err
 }
 
 func (c *Client) Close() error {
 	c.waits.Wait()
-	return c.rpcClient.Close()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.rpcClient == nil {
+		return nil
+	}
+	err := c.rpcClient.Close()
+	c.rpcClient = nil
+	return err
 }
 
+// connect connects to the daemon if not already connected, spawning it if
+// needed and possible, and checks the API version it serves. It must be called
+// with the mutex held.
 func (c *Client) connect() error {
+	if c.rpcClient != nil {
+		return nil
+	}
 	rpcClient, err := rpc.Dial("unix", c.sockPath)
+	if err != nil && c.spawn != nil {
+		if c.spawn() == nil {
+			for i := 0; i < spawnWaitLoops && err != nil; i++ {
+				time.Sleep(spawnWaitOneLoop)
+				rpcClient, err = rpc.Dial("unix", c.sockPath)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	res := &VersionResponse{}
+	err = rpcClient.Call(ServiceName+".Version", &VersionRequest{}, res)
 	if err != nil {
+		rpcClient.Close()
 		return err
 	}
 	c.rpcClient = rpcClient
+	c.versionOK = res.Version == Version
 	return nil
 }
@@ -9,8 +9,6 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
-	"strings"
-	"syscall"
 
 	"github.com/elves/elvish/util"
 )
@@ -51,14 +49,14 @@ func (d *Daemon) Main(serve func(string, string)) int {
 			return 2
 		}
 
-		syscall.Umask(0077)
+		setUmask(0077)
 		return d.pseudoFork(
 			&os.ProcAttr{
 				// cd to /
 				Dir: "/",
 				// empty environment
 				Env: nil,
-				Sys: &syscall.SysProcAttr{Setsid: true},
+				Sys: detachedProcAttr(),
 			})
 	case 1:
 		return d.pseudoFork(&os.ProcAttr{})
@@ -80,7 +78,7 @@ func (d *Daemon) Spawn() error {
 			binPath = os.Args[0]
 		} else {
 			// Find elvish in PATH
-			paths := strings.Split(os.Getenv("PATH"), ":")
+			paths := filepath.SplitList(os.Getenv("PATH"))
 			result, err := util.Search(paths, "elvish")
 			if err != nil {
 				return errors.New("cannot find elvish: " + err.Error())
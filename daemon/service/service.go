@@ -103,6 +103,16 @@ func (s *Service) Cmds(req *api.CmdsRequest, res *api.CmdsResponse) error {
 	return err
 }
 
+func (s *Service) CmdsWithSeq(req *api.CmdsWithSeqRequest, res *api.CmdsWithSeqResponse) error {
+	cmds, err := s.store.CmdsWithSeq(req.From, req.Upto)
+	res.Cmds = cmds
+	return err
+}
+
+func (s *Service) DelCmd(req *api.DelCmdRequest, res *api.DelCmdResponse) error {
+	return s.store.DelCmd(req.Seq)
+}
+
 func (s *Service) NextCmd(req *api.NextCmdRequest, res *api.NextCmdResponse) error {
 	seq, text, err := s.store.NextCmd(req.From, req.Prefix)
 	res.Seq, res.Text = seq, text
@@ -115,6 +125,16 @@ func (s *Service) PrevCmd(req *api.PrevCmdRequest, res *api.PrevCmdResponse) err
 	return err
 }
 
+func (s *Service) SetCmdMeta(req *api.SetCmdMetaRequest, res *api.SetCmdMetaResponse) error {
+	return s.store.SetCmdMeta(req.Meta)
+}
+
+func (s *Service) CmdMetas(req *api.CmdMetasRequest, res *api.CmdMetasResponse) error {
+	metas, err := s.store.CmdMetas(req.From, req.Upto)
+	res.Metas = metas
+	return err
+}
+
 func (s *Service) AddDir(req *api.AddDirRequest, res *api.AddDirResponse) error {
 	return s.store.AddDir(req.Dir, req.IncFactor)
 }
@@ -0,0 +1,15 @@
+// +build !windows
+
+package daemon
+
+import "syscall"
+
+func setUmask(mask int) {
+	syscall.Umask(mask)
+}
+
+// detachedProcAttr returns the attributes of the daemon process, which is
+// started in a session of its own.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
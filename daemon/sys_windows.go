@@ -0,0 +1,17 @@
+package daemon
+
+import "syscall"
+
+// detachedProcess is the DETACHED_PROCESS process creation flag, which is
+// missing from the syscall package.
+const detachedProcess = 0x00000008
+
+// setUmask does nothing, as Windows has no umask.
+func setUmask(mask int) {}
+
+// detachedProcAttr returns the attributes of the daemon process, which is
+// started without a console and in a process group of its own.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
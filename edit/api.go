@@ -8,6 +8,7 @@ import (
 
 	"github.com/elves/elvish/edit/ui"
 	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
 )
 
 // This file implements types and functions for interactions with the
@@ -38,6 +39,25 @@ func (bf *BuiltinFn) Repr(int) string {
 	return "$" + bf.name
 }
 
+// editorOf returns the Editor of ec, throwing errEditorInvalid if there is
+// none.
+func editorOf(ec *eval.EvalCtx) *Editor {
+	ed, ok := ec.Editor.(*Editor)
+	if !ok {
+		throw(errEditorInvalid)
+	}
+	return ed
+}
+
+// checkExternal returns an error unless the restriction of ev, if any, allows
+// running the external command name found at path. Code that runs external
+// commands on behalf of the user must call it first.
+func checkExternal(ev *eval.Evaler, name, path string) error {
+	return util.PCall(func() {
+		eval.NewTopEvalCtx(ev, "[editor]", "", nil).CheckExternal(name, path)
+	})
+}
+
 // Call calls a builtin function.
 func (bf *BuiltinFn) Call(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
 	eval.TakeNoOpt(opts)
@@ -62,7 +82,8 @@ func installModules(modules map[string]eval.Namespace, ed *Editor) {
 	binding := &eval.Struct{
 		[]string{
 			modeInsert, modeCommand, modeCompletion, modeNavigation, modeHistory,
-			modeHistoryListing, modeLocation, modeLastCmd, modeListing},
+			modeHistoryListing, modeLocation, modeLastCmd, modePager, modeListing,
+			modeMinibuffer},
 		[]eval.Variable{
 			eval.NewRoVariable(BindingTable{keyBindings[modeInsert]}),
 			eval.NewRoVariable(BindingTable{keyBindings[modeCommand]}),
@@ -72,7 +93,9 @@ func installModules(modules map[string]eval.Namespace, ed *Editor) {
 			eval.NewRoVariable(BindingTable{keyBindings[modeHistoryListing]}),
 			eval.NewRoVariable(BindingTable{keyBindings[modeLocation]}),
 			eval.NewRoVariable(BindingTable{keyBindings[modeLastCmd]}),
+			eval.NewRoVariable(BindingTable{keyBindings[modePager]}),
 			eval.NewRoVariable(BindingTable{keyBindings[modeListing]}),
+			eval.NewRoVariable(BindingTable{keyBindings[modeMinibuffer]}),
 		},
 	}
 	ns["binding"] = eval.NewRoVariable(binding)
@@ -115,13 +138,22 @@ func installModules(modules map[string]eval.Namespace, ed *Editor) {
 	for _, bac := range argCompletersData {
 		ns[eval.FnPrefix+bac.name] = eval.NewRoVariable(bac)
 	}
+	ns[eval.FnPrefix+bashArgCompleter.name] = eval.NewRoVariable(bashArgCompleter)
+	ns[eval.FnPrefix+helpArgCompleter.name] = eval.NewRoVariable(helpArgCompleter)
 
 	// Functions.
 	eval.AddBuiltinFns(ns,
 		&eval.BuiltinFn{"edit:complete-getopt", complGetopt},
 		&eval.BuiltinFn{"edit:complex-candidate", outputComplexCandidate},
 		&eval.BuiltinFn{"edit:styled", styled},
+		&eval.BuiltinFn{"edit:page", page},
+		&eval.BuiltinFn{"edit:git-info", gitInfoFn},
+		&eval.BuiltinFn{"edit:history-entries", historyEntries},
+		&eval.BuiltinFn{"edit:history-prune", historyPrune},
+		&eval.BuiltinFn{"edit:history-export", historyExport},
+		&eval.BuiltinFn{"edit:history-import", historyImport},
 		&eval.BuiltinFn{"edit:-dump-buf", _dumpBuf},
+		&eval.BuiltinFn{"edit:key-bindings", keyBindingsFn},
 	)
 
 	modules["edit"] = ns
@@ -131,11 +163,13 @@ func installModules(modules map[string]eval.Namespace, ed *Editor) {
 			modules["edit:"+module] = makeNamespaceFromBuiltins(builtins)
 		}
 	}
+	modules["edit:"+modeMinibuffer][eval.FnPrefix+"start"] = eval.NewRoVariable(
+		&eval.BuiltinFn{"edit:minibuffer:start", minibufferStart})
 }
 
 // CallFn calls an Fn, displaying its outputs and possible errors as editor
-// notifications. It is the preferred way to call a Fn while the editor is
-// active.
+// notifications, or its outputs in the pager if there are many of them. It is
+// the preferred way to call a Fn while the editor is active.
 func (ed *Editor) CallFn(fn eval.CallableValue, args ...eval.Value) {
 	if b, ok := fn.(*BuiltinFn); ok {
 		// Builtin function: quick path.
@@ -149,7 +183,16 @@ func (ed *Editor) CallFn(fn eval.CallableValue, args ...eval.Value) {
 	}
 
 	// Goroutines to collect output.
-	var wg sync.WaitGroup
+	var (
+		wg      sync.WaitGroup
+		outputs []string
+		mutex   sync.Mutex
+	)
+	addOutput := func(s string) {
+		mutex.Lock()
+		outputs = append(outputs, s)
+		mutex.Unlock()
+	}
 	wg.Add(2)
 	go func() {
 		rd := bufio.NewReader(rout)
@@ -158,14 +201,14 @@ func (ed *Editor) CallFn(fn eval.CallableValue, args ...eval.Value) {
 			if err != nil {
 				break
 			}
-			ed.Notify("[bytes output] %s", line[:len(line)-1])
+			addOutput("[bytes output] " + line[:len(line)-1])
 		}
 		rout.Close()
 		wg.Done()
 	}()
 	go func() {
 		for v := range chanOut {
-			ed.Notify("[value output] %s", v.Repr(eval.NoPretty))
+			addOutput("[value output] " + v.Repr(eval.NoPretty))
 		}
 		wg.Done()
 	}()
@@ -179,6 +222,7 @@ func (ed *Editor) CallFn(fn eval.CallableValue, args ...eval.Value) {
 
 	eval.ClosePorts(ports)
 	wg.Wait()
+	ed.showOutputs(outputs)
 	ed.refresh(true, true)
 }
 
@@ -278,6 +322,9 @@ func outputComplexCandidate(ec *eval.EvalCtx, a []eval.Value, o map[string]eval.
 		eval.Opt{"code-suffix", &c.codeSuffix, eval.String("")},
 		eval.Opt{"display-suffix", &c.displaySuffix, eval.String("")},
 		eval.Opt{"style", &style, eval.String("")},
+		eval.Opt{"code", &c.code, eval.String("")},
+		eval.Opt{"display", &c.display, eval.String("")},
+		eval.Opt{"annotation", &c.annotation, eval.String("")},
 	)
 	if style != "" {
 		c.style = ui.StylesFromString(style)
@@ -2,6 +2,7 @@ package edit
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/elves/elvish/eval"
 )
@@ -26,6 +27,7 @@ var (
 	argCompletersData = map[string]*builtinArgCompleter{
 		"":     {"complete-filename", complFilename},
 		"sudo": {"complete-sudo", complSudo},
+		"cd":   {"complete-dir", complDir},
 	}
 )
 
@@ -51,13 +53,25 @@ func completeArg(words []string, ev *eval.Evaler) ([]rawCandidate, error) {
 	if m.HasKey(eval.String(words[0])) {
 		v = m.IndexOne(eval.String(words[0]))
 	} else {
+		// Commands without a completer may get flags from their --help
+		// output.
+		if len(words) > 1 && strings.HasPrefix(words[len(words)-1], "-") &&
+			ev.Editor.(*Editor).helpCompletionEnabled(words[0]) {
+			if cands, err := complHelp(words, ev); err == nil && len(cands) > 0 {
+				return cands, nil
+			}
+		}
 		v = m.IndexOne(eval.String(""))
 	}
 	fn, ok := v.(eval.CallableValue)
 	if !ok {
 		return nil, ErrCompleterMustBeFn
 	}
-	return callArgCompleter(fn, ev, words)
+	cands, err := callArgCompleter(fn, ev, words)
+	if err != nil {
+		return nil, err
+	}
+	return withHistoryArgs(ev.Editor.(*Editor), words, cands), nil
 }
 
 type builtinArgCompleter struct {
@@ -100,6 +114,24 @@ func complFilename(words []string, ev *eval.Evaler) ([]rawCandidate, error) {
 	return complFilenameInner(words[len(words)-1], false)
 }
 
+// complDir is like complFilename, but only completes directories.
+func complDir(words []string, ev *eval.Evaler) ([]rawCandidate, error) {
+	if len(words) < 1 {
+		return nil, ErrTooFewArguments
+	}
+	all, err := complFilenameInner(words[len(words)-1], false)
+	if err != nil {
+		return nil, err
+	}
+	var cands []rawCandidate
+	for _, cand := range all {
+		if c, ok := cand.(*complexCandidate); ok && c.codeSuffix == "/" {
+			cands = append(cands, cand)
+		}
+	}
+	return cands, nil
+}
+
 func complSudo(words []string, ev *eval.Evaler) ([]rawCandidate, error) {
 	if len(words) < 2 {
 		return nil, ErrTooFewArguments
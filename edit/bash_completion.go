@@ -0,0 +1,125 @@
+package edit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/elves/elvish/eval"
+)
+
+// A bridge to bash completion scripts. The edit:complete-bash completer runs
+// bash, loads the completion script for the command and outputs what the
+// completion function puts in COMPREPLY. It is not installed by default; to
+// use it for a command, put it in $edit:completer:
+//
+//     edit:completer[foo] = $edit:&complete-bash
+
+var bashArgCompleter = &builtinArgCompleter{"complete-bash", complBash}
+
+// Errors returned by the bash completion bridge.
+var (
+	ErrNoBashCompletion = errors.New("no bash completion")
+	ErrBashTimeout      = errors.New("bash completion timed out")
+)
+
+// bashTimeout is how long completion scripts may run before bash is killed.
+// Completion runs while the user waits, and some scripts are slow.
+var bashTimeout = 2 * time.Second
+
+// Candidate locations of the main bash-completion script.
+var bashCompletionScripts = []string{
+	"/usr/share/bash-completion/bash_completion",
+	"/usr/local/share/bash-completion/bash_completion",
+	"/etc/bash_completion",
+}
+
+// bashBridgeScript is run with the path of the bash-completion script and the
+// words of the command as arguments. It follows what bash itself does when
+// calling a completion function specified with complete -F.
+const bashBridgeScript = `
+[ -n "$1" ] && . "$1" >/dev/null 2>&1
+shift
+cmd=$1
+COMP_WORDS=("$@")
+COMP_CWORD=$(( ${#COMP_WORDS[@]} - 1 ))
+COMP_LINE="${COMP_WORDS[*]}"
+COMP_POINT=${#COMP_LINE}
+spec=$(complete -p "$cmd" 2>/dev/null)
+if [ -z "$spec" ] && type _completion_loader >/dev/null 2>&1; then
+	_completion_loader "$cmd" >/dev/null 2>&1
+	spec=$(complete -p "$cmd" 2>/dev/null)
+fi
+func=$(printf '%s\n' "$spec" | sed -n 's/.*-F \([^ ]*\).*/\1/p')
+[ -n "$func" ] || exit 3
+"$func" "$cmd" "${COMP_WORDS[COMP_CWORD]}" "${COMP_WORDS[COMP_CWORD-1]}" >/dev/null 2>&1
+printf '%s\n' "${COMPREPLY[@]}"
+`
+
+func complBash(words []string, ev *eval.Evaler) ([]rawCandidate, error) {
+	script := ""
+	for _, path := range bashCompletionScripts {
+		if _, err := os.Stat(path); err == nil {
+			script = path
+			break
+		}
+	}
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		return nil, fmt.Errorf("cannot run bash: %v", err)
+	}
+	if err := checkExternal(ev, "bash", bash); err != nil {
+		return nil, err
+	}
+	return complBashInner(bash, script, words)
+}
+
+// complBashInner runs the bash at the given path to complete the words with
+// the completion function that the script defines.
+func complBashInner(bash, script string, words []string) ([]rawCandidate, error) {
+	if len(words) < 2 {
+		return nil, ErrTooFewArguments
+	}
+	args := append([]string{"-c", bashBridgeScript, "bash", script}, words...)
+	var out bytes.Buffer
+	cmd := exec.Command(bash, args...)
+	cmd.Stdout = &out
+	// Completion functions may start commands of their own; they are all
+	// killed with bash.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot run bash: %v", err)
+	}
+	timer := time.AfterFunc(bashTimeout, func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+	err := cmd.Wait()
+	if !timer.Stop() {
+		return nil, ErrBashTimeout
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			// The bridge script exits with a non-zero status when there is
+			// no completion function for the command.
+			return nil, ErrNoBashCompletion
+		}
+		return nil, fmt.Errorf("cannot run bash: %v", err)
+	}
+
+	var cands []rawCandidate
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out.String(), "\n") {
+		line = strings.TrimRight(line, " ")
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		cands = append(cands, plainCandidate(line))
+	}
+	return cands, nil
+}
@@ -0,0 +1,73 @@
+package edit
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+)
+
+const testBashCompletionScript = `
+_foo() {
+	COMPREPLY=($(compgen -W "alpha beta gamma" -- "$2"))
+}
+complete -F _foo foo
+_slow() {
+	sleep 10
+}
+complete -F _slow slow
+`
+
+func TestComplBashInner(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	f, err := ioutil.TempFile("", "elvish-bash-completion-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testBashCompletionScript)
+	f.Close()
+
+	cands, err := complBashInner("bash", f.Name(), []string{"foo", "x", "b"})
+	want := []rawCandidate{plainCandidate("beta")}
+	if err != nil || !reflect.DeepEqual(cands, want) {
+		t.Errorf("complBashInner => (%v, %v), want (%v, nil)", cands, err, want)
+	}
+
+	_, err = complBashInner("bash", f.Name(), []string{"bar", ""})
+	if err != ErrNoBashCompletion {
+		t.Errorf("complBashInner for command without completion => %v, want %v",
+			err, ErrNoBashCompletion)
+	}
+
+	saved := bashTimeout
+	bashTimeout = 100 * time.Millisecond
+	defer func() { bashTimeout = saved }()
+	start := time.Now()
+	_, err = complBashInner("bash", f.Name(), []string{"slow", ""})
+	if err != ErrBashTimeout || time.Since(start) > 5*time.Second {
+		t.Errorf("complBashInner for slow completion => %v after %v, want %v",
+			err, time.Since(start), ErrBashTimeout)
+	}
+}
+
+func TestComplBashRestricted(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ev.Restriction = &eval.Restriction{RestrictExternals: true}
+	_, err := complBash([]string{"foo", ""}, ev)
+	if err == nil || !strings.Contains(err.Error(), eval.ErrRestricted.Error()) {
+		t.Errorf("complBash in restricted mode => %v, want %v", err, eval.ErrRestricted)
+	}
+}
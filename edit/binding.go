@@ -1,6 +1,8 @@
 package edit
 
 import (
+	"sort"
+
 	"github.com/elves/elvish/edit/ui"
 	"github.com/elves/elvish/eval"
 	"github.com/elves/elvish/parse"
@@ -57,3 +59,35 @@ func (bt BindingTable) IndexSet(idx, v eval.Value) {
 	}
 	bt.inner[key] = f
 }
+
+// keyBindingsFn outputs a map for each key binding, with the mode, the key and
+// the function bound to it, sorted by mode and then key.
+func keyBindingsFn(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoArg(args)
+	eval.TakeNoOpt(opts)
+
+	modes := make([]string, 0, len(keyBindings))
+	for mode := range keyBindings {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+
+	out := ec.OutputChan()
+	for _, mode := range modes {
+		bindings := keyBindings[mode]
+		keys := make([]string, 0, len(bindings))
+		fns := make(map[string]eval.CallableValue, len(bindings))
+		for k, fn := range bindings {
+			keys = append(keys, k.String())
+			fns[k.String()] = fn
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out <- eval.NewMap(map[eval.Value]eval.Value{
+				eval.String("mode"): eval.String(mode),
+				eval.String("key"):  eval.String(k),
+				eval.String("fn"):   fns[k],
+			})
+		}
+	}
+}
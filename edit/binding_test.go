@@ -0,0 +1,39 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/eval"
+)
+
+func TestKeyBindingsFn(t *testing.T) {
+	n := 0
+	for _, bindings := range keyBindings {
+		n += len(bindings)
+	}
+	ch := make(chan eval.Value, n)
+	ec := eval.NewTopEvalCtx(&eval.Evaler{}, "[test]", "",
+		[]*eval.Port{nil, {Chan: ch}})
+	keyBindingsFn(ec, nil, nil)
+	close(ch)
+
+	var lastMode, lastKey string
+	got := 0
+	for v := range ch {
+		m := v.(eval.Map)
+		mode := eval.ToString(m.IndexOne(eval.String("mode")))
+		key := eval.ToString(m.IndexOne(eval.String("key")))
+		if mode < lastMode || (mode == lastMode && key <= lastKey) {
+			t.Errorf("binding %s %s output after %s %s", mode, key, lastMode, lastKey)
+		}
+		if fn := m.IndexOne(eval.String("fn")); fn != keyBindings[mode][ui.ToKey(eval.String(key))] {
+			t.Errorf("binding %s %s => %s, want the bound function", mode, key, fn.Repr(eval.NoPretty))
+		}
+		lastMode, lastKey = mode, key
+		got++
+	}
+	if got != n {
+		t.Errorf("got %d bindings, want %d", got, n)
+	}
+}
@@ -7,8 +7,9 @@ import (
 )
 
 type candidate struct {
-	code string    // This is what will be substitued on the command line.
-	menu ui.Styled // This is what is displayed in the completion menu.
+	code       string    // This is what will be substitued on the command line.
+	menu       ui.Styled // This is what is displayed in the completion menu.
+	annotation string    // This is displayed in a dim column after the menu.
 }
 
 // rawCandidate is what can be converted to a candidate.
@@ -32,10 +33,13 @@ func (p plainCandidate) cook(q parse.PrimaryType) *candidate {
 }
 
 type complexCandidate struct {
-	stem          string    // Used in the code and the menu.
+	stem          string    // Used in the code and the menu, and for matching.
 	codeSuffix    string    // Appended to the code.
 	displaySuffix string    // Appended to the display.
 	style         ui.Styles // Used in the menu.
+	code          string    // Inserted verbatim instead of the quoted stem if not empty.
+	display       string    // Displayed instead of the stem if not empty.
+	annotation    string    // Displayed in a dim column after the display.
 }
 
 func (c *complexCandidate) Kind() string    { return "map" }
@@ -44,10 +48,18 @@ func (c *complexCandidate) Repr(int) string { return "<complex candidate>" }
 func (c *complexCandidate) text() string { return c.stem }
 
 func (c *complexCandidate) cook(q parse.PrimaryType) *candidate {
-	quoted, _ := parse.QuoteAs(c.stem, q)
+	code := c.code
+	if code == "" {
+		code, _ = parse.QuoteAs(c.stem, q)
+	}
+	display := c.display
+	if display == "" {
+		display = c.stem
+	}
 	return &candidate{
-		code: quoted + c.codeSuffix,
-		menu: ui.Styled{c.stem + c.displaySuffix, c.style},
+		code:       code + c.codeSuffix,
+		menu:       ui.Styled{display + c.displaySuffix, c.style},
+		annotation: c.annotation,
 	}
 }
 
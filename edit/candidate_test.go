@@ -0,0 +1,52 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/parse"
+)
+
+var cookTests = []struct {
+	raw  rawCandidate
+	want *candidate
+}{
+	{plainCandidate("a b"), &candidate{code: "'a b'", menu: ui.Unstyled("a b")}},
+	{&complexCandidate{stem: "a b", codeSuffix: " ", displaySuffix: "/"},
+		&candidate{code: "'a b' ", menu: ui.Styled{"a b/", nil}}},
+	{&complexCandidate{stem: "--all", annotation: "show all"},
+		&candidate{code: "--all", menu: ui.Styled{"--all", nil},
+			annotation: "show all"}},
+	{&complexCandidate{stem: "a", code: "$a", display: "<a>"},
+		&candidate{code: "$a", menu: ui.Styled{"<a>", nil}}},
+}
+
+func TestCook(t *testing.T) {
+	for _, test := range cookTests {
+		got := test.raw.cook(parse.Bareword)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("cook %v => %v, want %v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestCompletionListRenderAnnotations(t *testing.T) {
+	c := &completion{filtered: []*candidate{
+		{code: "ab", menu: ui.Unstyled("ab"), annotation: "x"},
+		{code: "c", menu: ui.Unstyled("c"), annotation: "yz"},
+	}, selected: -1}
+	b := c.ListRender(20, 5)
+	var lines []string
+	for _, line := range b.lines {
+		var s string
+		for _, cell := range line {
+			s += cell.string
+		}
+		lines = append(lines, s)
+	}
+	want := []string{" ab x  c yz "}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("ListRender => %q, want %q", lines, want)
+	}
+}
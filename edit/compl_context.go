@@ -0,0 +1,261 @@
+package edit
+
+import (
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+)
+
+// complContextType is the type of a complContext.
+type complContextType int
+
+// Possible values of complContextType.
+const (
+	noComplContext complContextType = iota
+	// A variable name after "$", as in "echo $fo".
+	variableComplContext
+	// A key of a map or index of a list, as in "echo $m[fo".
+	indexComplContext
+	// The head of a form, as in "fo" or "ls | fo".
+	commandComplContext
+	// The filename of a redirection, as in "ls > fo".
+	redirComplContext
+	// An argument of a form, as in "ls fo".
+	argComplContext
+	// An element of a list literal or a value in a map literal, as in
+	// "put [a fo" or "put [&k=fo".
+	literalComplContext
+)
+
+// complContext describes the syntactic context of the cursor, which
+// completers use to find candidates.
+type complContext struct {
+	typ complContextType
+	// The range of source text that will be replaced by a candidate.
+	begin, end int
+	// The text that is being completed, unquoted, and the quoting style it is
+	// written in. For variableComplContext, current is the part of the
+	// variable name after the namespace.
+	current string
+	quote   parse.PrimaryType
+
+	// The namespace part of the variable name including the trailing colon,
+	// as in "edit:". Valid for variableComplContext.
+	ns string
+	// The primary being indexed, and the indices before the one being
+	// completed. Valid for indexComplContext.
+	indexee *parse.Primary
+	indices []string
+	// The form the argument belongs to. Valid for argComplContext.
+	form *parse.Form
+}
+
+// findComplContext finds the completion context of a leaf node. It returns
+// nil if the node is not in any context supported by completion.
+func findComplContext(n parse.Node) *complContext {
+	for _, find := range []func(parse.Node) *complContext{
+		findVariableContext,
+		findIndexContext,
+		findFormHeadContext,
+		findRedirContext,
+		findArgContext,
+		findLiteralContext,
+	} {
+		if ctx := find(n); ctx != nil {
+			return ctx
+		}
+	}
+	return nil
+}
+
+func findVariableContext(n parse.Node) *complContext {
+	primary := parse.GetPrimary(n)
+	if primary == nil || primary.Type != parse.Variable {
+		return nil
+	}
+
+	// The starting position of "what we are completing". First move past "$".
+	begin := n.Begin() + 1
+
+	// XXX Repeats eval.ParseVariable.
+	explode, qname := eval.ParseVariableSplice(primary.Value)
+	nsPart, nameHead := eval.ParseVariableQName(qname)
+	begin += len(explode) + len(nsPart) // Move past "@" and "ns:".
+
+	return &complContext{
+		typ: variableComplContext, begin: begin, end: n.End(),
+		current: nameHead, quote: parse.Bareword, ns: nsPart}
+}
+
+// Indices before the one being completed must be simple, e.g. $a[x][<Tab> is
+// supported but $a[(f)][<Tab> is not.
+func findIndexContext(n parse.Node) *complContext {
+	// indexContext makes a context for completing the index that begins after
+	// pos.
+	indexContext := func(begin, end int, current string, q parse.PrimaryType, indexing *parse.Indexing, pos int) *complContext {
+		var indices []string
+		for _, array := range indexing.Indicies {
+			if array.End() > pos {
+				break
+			}
+			if len(array.Compounds) != 1 {
+				return nil
+			}
+			ok, index, _ := simpleCompound(array.Compounds[0], nil)
+			if !ok {
+				return nil
+			}
+			indices = append(indices, index)
+		}
+		return &complContext{
+			typ: indexComplContext, begin: begin, end: end,
+			current: current, quote: q, indexee: indexing.Head, indices: indices}
+	}
+	if parse.IsSep(n) {
+		if parse.IsIndexing(n.Parent()) {
+			// We are just after an opening bracket.
+			indexing := parse.GetIndexing(n.Parent())
+			return indexContext(n.End(), n.End(), "", parse.Bareword, indexing, n.Begin())
+		}
+		if parse.IsArray(n.Parent()) {
+			array := n.Parent()
+			if parse.IsIndexing(array.Parent()) {
+				// We are after an existing index and spaces.
+				indexing := parse.GetIndexing(array.Parent())
+				return indexContext(n.End(), n.End(), "", parse.Bareword, indexing, array.Begin())
+			}
+		}
+	}
+
+	if parse.IsPrimary(n) {
+		primary := parse.GetPrimary(n)
+		compound, current := primaryInSimpleCompound(primary)
+		if compound != nil {
+			if parse.IsArray(compound.Parent()) {
+				array := compound.Parent()
+				if parse.IsIndexing(array.Parent()) {
+					// We are just after an incomplete index.
+					indexing := parse.GetIndexing(array.Parent())
+					return indexContext(compound.Begin(), compound.End(), current, primary.Type, indexing, array.Begin())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func findFormHeadContext(n parse.Node) *complContext {
+	// Determine if we are starting a new command. There are 3 cases:
+	// 1. The whole chunk is empty (nothing entered at all): the leaf is a
+	//    Chunk.
+	// 2. Just after a newline or semicolon: the leaf is a Sep and its parent is
+	//    a Chunk.
+	// 3. Just after a pipe: the leaf is a Sep and its parent is a Pipeline.
+	if parse.IsChunk(n) {
+		return &complContext{
+			typ: commandComplContext, begin: n.End(), end: n.End(),
+			quote: parse.Bareword}
+	}
+	if parse.IsSep(n) {
+		parent := n.Parent()
+		if parse.IsChunk(parent) || parse.IsPipeline(parent) {
+			return &complContext{
+				typ: commandComplContext, begin: n.End(), end: n.End(),
+				quote: parse.Bareword}
+		}
+	}
+
+	if primary, ok := n.(*parse.Primary); ok {
+		if compound, head := primaryInSimpleCompound(primary); compound != nil {
+			if form, ok := compound.Parent().(*parse.Form); ok {
+				if form.Head == compound {
+					return &complContext{
+						typ: commandComplContext, begin: compound.Begin(), end: compound.End(),
+						current: head, quote: primary.Type}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findRedirContext(n parse.Node) *complContext {
+	if parse.IsSep(n) {
+		if parse.IsRedir(n.Parent()) {
+			return &complContext{
+				typ: redirComplContext, begin: n.End(), end: n.End(),
+				quote: parse.Bareword}
+		}
+	}
+	if primary, ok := n.(*parse.Primary); ok {
+		if compound, head := primaryInSimpleCompound(primary); compound != nil {
+			if parse.IsRedir(compound.Parent()) {
+				return &complContext{
+					typ: redirComplContext, begin: compound.Begin(), end: compound.End(),
+					current: head, quote: primary.Type}
+			}
+		}
+	}
+	return nil
+}
+
+func findArgContext(n parse.Node) *complContext {
+	if sep, ok := n.(*parse.Sep); ok {
+		if form, ok := sep.Parent().(*parse.Form); ok {
+			return &complContext{
+				typ: argComplContext, begin: n.End(), end: n.End(),
+				quote: parse.Bareword, form: form}
+		}
+	}
+	if primary, ok := n.(*parse.Primary); ok {
+		if compound, head := primaryInSimpleCompound(primary); compound != nil {
+			if form, ok := compound.Parent().(*parse.Form); ok {
+				if form.Head != compound {
+					return &complContext{
+						typ: argComplContext, begin: compound.Begin(), end: compound.End(),
+						current: head, quote: primary.Type, form: form}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findLiteralContext(n parse.Node) *complContext {
+	if parse.IsSep(n) {
+		parent := n.Parent()
+		if isListLiteralArray(parent) || parse.IsMapPair(parent) {
+			// We are just after the opening bracket or a space in a list
+			// literal, or after & or = in a map literal. Only values of map
+			// pairs are completed.
+			if mapPair := parse.GetMapPair(parent); mapPair != nil &&
+				(mapPair.Key == nil || n.Begin() < mapPair.Key.End()) {
+				return nil
+			}
+			return &complContext{
+				typ: literalComplContext, begin: n.End(), end: n.End(),
+				quote: parse.Bareword}
+		}
+	}
+	if primary, ok := n.(*parse.Primary); ok {
+		if compound, head := primaryInSimpleCompound(primary); compound != nil {
+			parent := compound.Parent()
+			if isListLiteralArray(parent) ||
+				(parse.IsMapPair(parent) && parse.GetMapPair(parent).Value == compound) {
+				return &complContext{
+					typ: literalComplContext, begin: compound.Begin(), end: compound.End(),
+					current: head, quote: primary.Type}
+			}
+		}
+	}
+	return nil
+}
+
+// isListLiteralArray returns whether a node is the Array of a list literal.
+func isListLiteralArray(n parse.Node) bool {
+	if !parse.IsArray(n) {
+		return false
+	}
+	primary := parse.GetPrimary(n.Parent())
+	return primary != nil && primary.Type == parse.List
+}
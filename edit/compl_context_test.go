@@ -0,0 +1,56 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/parse"
+)
+
+var complContextTests = []struct {
+	src     string
+	typ     complContextType
+	begin   int
+	current string
+	quote   parse.PrimaryType
+}{
+	{"", commandComplContext, 0, "", parse.Bareword},
+	{"ls; ", commandComplContext, 4, "", parse.Bareword},
+	{"ls | gr", commandComplContext, 5, "gr", parse.Bareword},
+	{"ls 'fo", argComplContext, 3, "fo", parse.SingleQuoted},
+	{"ls x ", argComplContext, 5, "", parse.Bareword},
+	{"ls > \"a", redirComplContext, 5, "a", parse.DoubleQuoted},
+	{"echo $edit:fo", variableComplContext, 11, "fo", parse.Bareword},
+	{"echo $m[k", indexComplContext, 8, "k", parse.Bareword},
+	{"echo $m[", indexComplContext, 8, "", parse.Bareword},
+	{"echo $m[a][k", indexComplContext, 11, "k", parse.Bareword},
+	{"echo $m[a][", indexComplContext, 11, "", parse.Bareword},
+	{"put [a 'fo", literalComplContext, 7, "fo", parse.SingleQuoted},
+	{"put [a ", literalComplContext, 7, "", parse.Bareword},
+	{"put [&k=fo", literalComplContext, 8, "fo", parse.Bareword},
+	{"put [&k=", literalComplContext, 8, "", parse.Bareword},
+}
+
+func TestFindComplContext(t *testing.T) {
+	for _, test := range complContextTests {
+		n, _ := parse.Parse("[test]", test.src)
+		ctx := findComplContext(findLeafNode(n, len(test.src)))
+		if ctx == nil {
+			t.Errorf("findComplContext(%q) returns nil", test.src)
+			continue
+		}
+		if ctx.typ != test.typ || ctx.begin != test.begin || ctx.end != len(test.src) ||
+			ctx.current != test.current || ctx.quote != test.quote {
+			t.Errorf("findComplContext(%q) returns %v, want type %v, range %d-%d, current %q and quote %v",
+				test.src, ctx, test.typ, test.begin, len(test.src), test.current, test.quote)
+		}
+	}
+}
+
+func TestFindComplContextNoContext(t *testing.T) {
+	for _, src := range []string{"put [&", "put [&k"} {
+		n, _ := parse.Parse("[test]", src)
+		if ctx := findComplContext(findLeafNode(n, len(src))); ctx != nil {
+			t.Errorf("findComplContext(%q) returns %v, want nil", src, ctx)
+		}
+	}
+}
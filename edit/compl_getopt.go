@@ -93,14 +93,14 @@ func complGetopt(ec *eval.EvalCtx, a []eval.Value, o map[string]eval.Value) {
 	putShortOpt := func(opt *getopt.Option) {
 		c := &complexCandidate{stem: "-" + string(opt.Short)}
 		if d, ok := desc[opt]; ok {
-			c.displaySuffix = " (" + d + ")"
+			c.annotation = d
 		}
 		out <- c
 	}
 	putLongOpt := func(opt *getopt.Option) {
 		c := &complexCandidate{stem: "--" + string(opt.Long)}
 		if d, ok := desc[opt]; ok {
-			c.displaySuffix = " (" + d + ")"
+			c.annotation = d
 		}
 		out <- c
 	}
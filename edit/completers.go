@@ -16,15 +16,13 @@ import (
 )
 
 var (
-	errCompletionUnapplicable = errors.New("completion unapplicable")
-	errCannotEvalIndexee      = errors.New("cannot evaluate indexee")
-	errCannotIterateKey       = errors.New("indexee does not support iterating keys")
+	errCannotEvalIndexee = errors.New("cannot evaluate indexee")
+	errCannotIterateKey  = errors.New("indexee does not support iterating keys")
 )
 
-// completer takes the current Node (always a leaf in the AST) and an Editor and
-// returns a compl. If the completer does not apply to the type of the current
-// Node, it should return an error of ErrCompletionUnapplicable.
-type completer func(parse.Node, *eval.Evaler) (*compl, error)
+// completer takes the completion context of the current Node (always a leaf in
+// the AST) and an Evaler and returns a compl.
+type completer func(*complContext, *eval.Evaler) (*compl, error)
 
 // compl is the result of a completer, meaning that any of the candidates can
 // replace the text in the interval [begin, end).
@@ -36,46 +34,35 @@ type compl struct {
 
 // completers is the list of all completers.
 // TODO(xiaq): Make this list programmable.
-var completers = []struct {
+var completers = map[complContextType]struct {
 	name string
 	completer
 }{
-	{"variable", complVariable},
-	{"index", complIndex},
-	{"command name", complFormHead},
-	{"redir", complRedir},
-	{"argument", complArg},
+	variableComplContext: {"variable", complVariable},
+	indexComplContext:    {"index", complIndex},
+	commandComplContext:  {"command name", complFormHead},
+	redirComplContext:    {"redir", complRedir},
+	argComplContext:      {"argument", complArg},
+	literalComplContext:  {"literal", complLiteral},
 }
 
-// complete takes a Node and Evaler and tries all completers. It returns the
-// name of the completer, and the result and error it gave. If no completer is
-// available, it returns an empty completer name.
+// complete takes a Node and Evaler, finds the completion context of the Node
+// and calls the corresponding completer. It returns the name of the completer,
+// and the result and error it gave. If no completer is available, it returns
+// an empty completer name.
 func complete(n parse.Node, ev *eval.Evaler) (string, *compl, error) {
-	for _, item := range completers {
-		compl, err := item.completer(n, ev)
-		if compl != nil {
-			return item.name, compl, nil
-		} else if err != nil && err != errCompletionUnapplicable {
-			return item.name, nil, err
-		}
+	ctx := findComplContext(n)
+	if ctx == nil {
+		return "", nil, nil
 	}
-	return "", nil, nil
+	item := completers[ctx.typ]
+	compl, err := item.completer(ctx, ev)
+	return item.name, compl, err
 }
 
 // TODO(xiaq): Rewrite this to use cookCandidates
-func complVariable(n parse.Node, ev *eval.Evaler) (*compl, error) {
-	primary := parse.GetPrimary(n)
-	if primary == nil || primary.Type != parse.Variable {
-		return nil, errCompletionUnapplicable
-	}
-
-	// The starting position of "what we are completing". First move past "$".
-	begin := n.Begin() + 1
-
-	// XXX Repeats eval.ParseVariable.
-	explode, qname := eval.ParseVariableSplice(primary.Value)
-	nsPart, nameHead := eval.ParseVariableQName(qname)
-	begin += len(explode) + len(nsPart) // Move past "@" and "ns:".
+func complVariable(ctx *complContext, ev *eval.Evaler) (*compl, error) {
+	nsPart, nameHead := ctx.ns, ctx.current
 	ns := nsPart
 	if len(ns) > 0 {
 		ns = ns[:len(ns)-1]
@@ -110,7 +97,7 @@ func complVariable(n parse.Node, ev *eval.Evaler) (*compl, error) {
 		}
 	}
 
-	return &compl{begin, n.End(), cands}, nil
+	return &compl{ctx.begin, ctx.end, cands}, nil
 }
 
 func hasProperPrefix(s, p string) bool {
@@ -139,14 +126,11 @@ func iterateVariables(ev *eval.Evaler, ns string, f func(string)) {
 	}
 }
 
-func complIndex(n parse.Node, ev *eval.Evaler) (*compl, error) {
-	begin, end, current, q, indexee := findIndexContext(n)
-
-	if begin == -1 {
-		return nil, errCompletionUnapplicable
+func complIndex(ctx *complContext, ev *eval.Evaler) (*compl, error) {
+	indexeeValue := purelyEvalPrimary(ctx.indexee, ev)
+	for _, index := range ctx.indices {
+		indexeeValue = purelyIndex(indexeeValue, eval.String(index))
 	}
-
-	indexeeValue := purelyEvalPrimary(indexee, ev)
 	if indexeeValue == nil {
 		return nil, errCannotEvalIndexee
 	}
@@ -157,53 +141,7 @@ func complIndex(n parse.Node, ev *eval.Evaler) (*compl, error) {
 
 	cands := complIndexInner(m)
 	match := ev.Editor.(*Editor).matcher()
-	return &compl{begin, end, cookCandidates(cands, current, match, q)}, nil
-}
-
-// Find context information for complIndex. It returns the begin and end for
-// compl, the current text of this index and its type, and the indexee node.
-//
-// Right now we only support cases where there is only one level of indexing,
-// e.g. $a[<Tab> is supported but $a[x][<Tab> is not.
-func findIndexContext(n parse.Node) (int, int, string, parse.PrimaryType, *parse.Primary) {
-	if parse.IsSep(n) {
-		if parse.IsIndexing(n.Parent()) {
-			// We are just after an opening bracket.
-			indexing := parse.GetIndexing(n.Parent())
-			if len(indexing.Indicies) == 1 {
-				return n.End(), n.End(), "", parse.Bareword, indexing.Head
-			}
-		}
-		if parse.IsArray(n.Parent()) {
-			array := n.Parent()
-			if parse.IsIndexing(array.Parent()) {
-				// We are after an existing index and spaces.
-				indexing := parse.GetIndexing(array.Parent())
-				if len(indexing.Indicies) == 1 {
-					return n.End(), n.End(), "", parse.Bareword, indexing.Head
-				}
-			}
-		}
-	}
-
-	if parse.IsPrimary(n) {
-		primary := parse.GetPrimary(n)
-		compound, current := primaryInSimpleCompound(primary)
-		if compound != nil {
-			if parse.IsArray(compound.Parent()) {
-				array := compound.Parent()
-				if parse.IsIndexing(array.Parent()) {
-					// We are just after an incomplete index.
-					indexing := parse.GetIndexing(array.Parent())
-					if len(indexing.Indicies) == 1 {
-						return compound.Begin(), compound.End(), current, primary.Type, indexing.Head
-					}
-				}
-			}
-		}
-	}
-
-	return -1, -1, "", 0, nil
+	return &compl{ctx.begin, ctx.end, cookCandidates(cands, ctx.current, match, ctx.quote)}, nil
 }
 
 func complIndexInner(m eval.IterateKeyer) []rawCandidate {
@@ -218,47 +156,14 @@ func complIndexInner(m eval.IterateKeyer) []rawCandidate {
 	return keys
 }
 
-func complFormHead(n parse.Node, ev *eval.Evaler) (*compl, error) {
-	begin, end, head, q := findFormHeadContext(n)
-	if begin == -1 {
-		return nil, errCompletionUnapplicable
-	}
-	cands, err := complFormHeadInner(head, ev)
+func complFormHead(ctx *complContext, ev *eval.Evaler) (*compl, error) {
+	cands, err := complFormHeadInner(ctx.current, ev)
 	if err != nil {
 		return nil, err
 	}
 
 	match := ev.Editor.(*Editor).matcher()
-	return &compl{begin, end, cookCandidates(cands, head, match, q)}, nil
-}
-
-func findFormHeadContext(n parse.Node) (int, int, string, parse.PrimaryType) {
-	// Determine if we are starting a new command. There are 3 cases:
-	// 1. The whole chunk is empty (nothing entered at all): the leaf is a
-	//    Chunk.
-	// 2. Just after a newline or semicolon: the leaf is a Sep and its parent is
-	//    a Chunk.
-	// 3. Just after a pipe: the leaf is a Sep and its parent is a Pipeline.
-	if parse.IsChunk(n) {
-		return n.End(), n.End(), "", parse.Bareword
-	}
-	if parse.IsSep(n) {
-		parent := n.Parent()
-		if parse.IsChunk(parent) || parse.IsPipeline(parent) {
-			return n.End(), n.End(), "", parse.Bareword
-		}
-	}
-
-	if primary, ok := n.(*parse.Primary); ok {
-		if compound, head := primaryInSimpleCompound(primary); compound != nil {
-			if form, ok := compound.Parent().(*parse.Form); ok {
-				if form.Head == compound {
-					return compound.Begin(), compound.End(), head, primary.Type
-				}
-			}
-		}
-	}
-	return -1, -1, "", 0
+	return &compl{ctx.begin, ctx.end, cookCandidates(cands, ctx.current, match, ctx.quote)}, nil
 }
 
 func complFormHeadInner(head string, ev *eval.Evaler) ([]rawCandidate, error) {
@@ -270,14 +175,22 @@ func complFormHeadInner(head string, ev *eval.Evaler) ([]rawCandidate, error) {
 	got := func(s string) {
 		commands = append(commands, plainCandidate(s))
 	}
+	gotAnnotated := func(s, annotation string) {
+		commands = append(commands, &complexCandidate{stem: s, annotation: annotation})
+	}
 	for special := range eval.IsBuiltinSpecial {
-		got(special)
+		gotAnnotated(special, "special")
 	}
 	explode, ns, _ := eval.ParseVariable(head)
 	if !explode {
 		iterateVariables(ev, ns, func(varname string) {
 			if strings.HasPrefix(varname, eval.FnPrefix) {
-				got(eval.MakeVariableName(false, ns, varname[len(eval.FnPrefix):]))
+				name := eval.MakeVariableName(false, ns, varname[len(eval.FnPrefix):])
+				if ns == "" && ev.Builtin[varname] != nil {
+					gotAnnotated(name, "builtin")
+				} else {
+					got(name)
+				}
 			} else {
 				got(eval.MakeVariableName(false, ns, varname) + "=")
 			}
@@ -304,47 +217,35 @@ type plainCandidates []rawCandidate
 
 func (pc plainCandidates) Len() int { return len(pc) }
 func (pc plainCandidates) Less(i, j int) bool {
-	return pc[i].(plainCandidate) < pc[j].(plainCandidate)
+	return pc[i].text() < pc[j].text()
 }
 func (pc plainCandidates) Swap(i, j int) { pc[i], pc[j] = pc[j], pc[i] }
 
 // complRedir completes redirection RHS.
-func complRedir(n parse.Node, ev *eval.Evaler) (*compl, error) {
-	begin, end, current, q := findRedirContext(n)
-	if begin == -1 {
-		return nil, errCompletionUnapplicable
-	}
-	cands, err := complFilenameInner(current, false)
+func complRedir(ctx *complContext, ev *eval.Evaler) (*compl, error) {
+	cands, err := complFilenameInner(ctx.current, false)
 	if err != nil {
 		return nil, err
 	}
 	match := ev.Editor.(*Editor).matcher()
-	return &compl{begin, end, cookCandidates(cands, current, match, q)}, nil
+	return &compl{ctx.begin, ctx.end, cookCandidates(cands, ctx.current, match, ctx.quote)}, nil
 }
 
-func findRedirContext(n parse.Node) (int, int, string, parse.PrimaryType) {
-	if parse.IsSep(n) {
-		if parse.IsRedir(n.Parent()) {
-			return n.End(), n.End(), "", parse.Bareword
-		}
-	}
-	if primary, ok := n.(*parse.Primary); ok {
-		if compound, head := primaryInSimpleCompound(primary); compound != nil {
-			if parse.IsRedir(compound.Parent()) {
-				return compound.Begin(), compound.End(), head, primary.Type
-			}
-		}
+// complLiteral completes elements of list literals and values of map literals
+// as filenames.
+func complLiteral(ctx *complContext, ev *eval.Evaler) (*compl, error) {
+	cands, err := complFilenameInner(ctx.current, false)
+	if err != nil {
+		return nil, err
 	}
-	return -1, -1, "", 0
+	match := ev.Editor.(*Editor).matcher()
+	return &compl{ctx.begin, ctx.end, cookCandidates(cands, ctx.current, match, ctx.quote)}, nil
 }
 
-// complArg completes arguments. It identifies the context and then delegates
-// the actual completion work to a suitable completer.
-func complArg(n parse.Node, ev *eval.Evaler) (*compl, error) {
-	begin, end, current, q, form := findArgContext(n)
-	if begin == -1 {
-		return nil, errCompletionUnapplicable
-	}
+// complArg completes arguments. It finds out the head and preceding arguments
+// and then delegates the actual completion work to a suitable completer.
+func complArg(ctx *complContext, ev *eval.Evaler) (*compl, error) {
+	begin, current, form := ctx.begin, ctx.current, ctx.form
 
 	// Find out head of the form and preceding arguments.
 	// If Form.Head is not a simple compound, head will be "", just what we want.
@@ -371,25 +272,7 @@ func complArg(n parse.Node, ev *eval.Evaler) (*compl, error) {
 		return nil, err
 	}
 	match := ev.Editor.(*Editor).matcher()
-	return &compl{begin, end, cookCandidates(cands, current, match, q)}, nil
-}
-
-func findArgContext(n parse.Node) (int, int, string, parse.PrimaryType, *parse.Form) {
-	if sep, ok := n.(*parse.Sep); ok {
-		if form, ok := sep.Parent().(*parse.Form); ok {
-			return n.End(), n.End(), "", parse.Bareword, form
-		}
-	}
-	if primary, ok := n.(*parse.Primary); ok {
-		if compound, head := primaryInSimpleCompound(primary); compound != nil {
-			if form, ok := compound.Parent().(*parse.Form); ok {
-				if form.Head != compound {
-					return compound.Begin(), compound.End(), head, primary.Type, form
-				}
-			}
-		}
-	}
-	return -1, -1, "", 0, nil
+	return &compl{ctx.begin, ctx.end, cookCandidates(cands, ctx.current, match, ctx.quote)}, nil
 }
 
 // TODO: getStyle does redundant stats.
@@ -25,6 +25,23 @@ func TestComplIndexInner(t *testing.T) {
 	}
 }
 
+func TestPurelyIndex(t *testing.T) {
+	m := eval.NewMap(map[eval.Value]eval.Value{
+		eval.String("foo"): eval.NewList(eval.String("bar")),
+	})
+	if got := purelyIndex(m, eval.String("foo")); got == nil {
+		t.Errorf("purelyIndex(%v, foo) => nil, want the list", m)
+	}
+	for _, index := range []string{"lorem", "0"} {
+		if got := purelyIndex(m, eval.String(index)); got != nil {
+			t.Errorf("purelyIndex(%v, %s) => %v, want nil", m, index, got)
+		}
+	}
+	if got := purelyIndex(nil, eval.String("foo")); got != nil {
+		t.Errorf("purelyIndex(nil, foo) => %v, want nil", got)
+	}
+}
+
 var (
 	fileStyle = ui.StylesFromString("1")
 	exeStyle  = ui.StylesFromString("2")
@@ -69,6 +86,15 @@ func TestComplFilenameInner(t *testing.T) {
 		mkdir("Documents", 0700)
 		mkdir(".elvish", 0700)
 
+		// complDir only completes directories.
+		cands, err := complDir([]string{"cd", ""}, nil)
+		wantDirs := []rawCandidate{
+			&complexCandidate{stem: "Documents", codeSuffix: "/", style: dirStyle},
+		}
+		if err != nil || !reflect.DeepEqual(cands, wantDirs) {
+			t.Errorf("complDir returns (%v, %v), want (%v, nil)", cands, err, wantDirs)
+		}
+
 		for _, test := range complFilenameInnerTests {
 			cands, err := complFilenameInner(test.head, test.executableOnly)
 			if err != nil {
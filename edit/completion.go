@@ -2,6 +2,7 @@ package edit
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -43,9 +44,42 @@ func init() {
 	})
 }
 
+// $edit:completion-auto-menu is the number of consecutive presses of
+// compl:smart-start that are needed to show the candidates once there is no
+// longer a common prefix to insert; 2 mimics readline. When
+// $edit:completion-cycle is true, candidates are cycled through on the
+// command line instead of being shown in a menu.
+
+var _ = registerVariable("completion-auto-menu", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.String("1"), eval.ShouldBePositiveInt)
+})
+
+func (ed *Editor) completionAutoMenu() int {
+	n, _ := strconv.Atoi(string(ed.variables["completion-auto-menu"].Get().(eval.String)))
+	return n
+}
+
+var _ = registerVariable("completion-cycle", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.Bool(false), eval.ShouldBeBool)
+})
+
+func (ed *Editor) completionCycle() bool {
+	return bool(ed.variables["completion-cycle"].Get().(eval.Bool).Bool())
+}
+
+// smartStartPresses records consecutive presses of compl:smart-start that
+// neither inserted a prefix nor showed the candidates.
+type smartStartPresses struct {
+	line string
+	dot  int
+	n    int
+}
+
 type completion struct {
 	compl
 	completer string
+	// Whether candidates are cycled through without showing a menu.
+	cycle bool
 
 	filtering       bool
 	filter          string
@@ -54,6 +88,9 @@ type completion struct {
 	firstShown      int
 	lastShownInFull int
 	height          int
+	// The right edges of the columns when the listing was last rendered. Used
+	// for mapping mouse clicks to candidates.
+	colEnds []int
 }
 
 func (*completion) Binding(k ui.Key) eval.CallableValue {
@@ -61,6 +98,9 @@ func (*completion) Binding(k ui.Key) eval.CallableValue {
 }
 
 func (c *completion) needScrollbar() bool {
+	if c.cycle {
+		return false
+	}
 	return c.firstShown > 0 || c.lastShownInFull < len(c.filtered)-1
 }
 
@@ -220,10 +260,23 @@ func startCompletionInner(ed *Editor, acceptPrefix bool) {
 				ed.dot = compl.begin + len(prefix)
 				return
 			}
+			if len(compl.candidates) > 1 {
+				p := &ed.smartStartPresses
+				if p.line != ed.line || p.dot != ed.dot {
+					*p = smartStartPresses{ed.line, ed.dot, 0}
+				}
+				p.n++
+				if p.n < ed.completionAutoMenu() {
+					ed.addTip("%d candidates, press again to show them", len(compl.candidates))
+					return
+				}
+				*p = smartStartPresses{}
+			}
 		}
 		ed.completion = completion{
 			completer: completer,
 			compl:     *compl,
+			cycle:     ed.completionCycle(),
 			filtered:  compl.candidates,
 		}
 		ed.mode = &ed.completion
@@ -250,24 +303,35 @@ const (
 	completionColMarginTotal = completionColMarginLeft + completionColMarginRight
 )
 
-// maxWidth finds the maximum wcwidth of display texts of candidates [lo, hi).
-// hi may be larger than the number of candidates, in which case it is truncated
-// to the number of candidates.
-func (c *completion) maxWidth(lo, hi int) int {
+// widths finds the maximum wcwidths of display texts and annotations of
+// candidates [lo, hi). hi may be larger than the number of candidates, in
+// which case it is truncated to the number of candidates.
+func (c *completion) widths(lo, hi int) (menu, annotation int) {
 	if hi > len(c.filtered) {
 		hi = len(c.filtered)
 	}
-	width := 0
 	for i := lo; i < hi; i++ {
-		w := util.Wcswidth(c.filtered[i].menu.Text)
-		if width < w {
-			width = w
-		}
+		menu = max(menu, util.Wcswidth(c.filtered[i].menu.Text))
+		annotation = max(annotation, util.Wcswidth(c.filtered[i].annotation))
+	}
+	return menu, annotation
+}
+
+// maxWidth finds the width needed to show candidates [lo, hi) in one column.
+// Annotations, if any, are shown in a column of their own, separated by a
+// space.
+func (c *completion) maxWidth(lo, hi int) int {
+	menu, annotation := c.widths(lo, hi)
+	if annotation > 0 {
+		return menu + 1 + annotation
 	}
-	return width
+	return menu
 }
 
 func (c *completion) ListRender(width, maxHeight int) *buffer {
+	if c.cycle {
+		return nil
+	}
 	b := newBuffer(width)
 	cands := c.filtered
 	if len(cands) == 0 {
@@ -311,6 +375,7 @@ func (c *completion) ListRender(width, maxHeight int) *buffer {
 	}
 	c.height = height
 	c.firstShown = first
+	c.colEnds = c.colEnds[:0]
 
 	var i, j int
 	remainedWidth := width
@@ -319,11 +384,14 @@ func (c *completion) ListRender(width, maxHeight int) *buffer {
 	for i = first; i < len(cands); i += height {
 		// Determine the width of the column (without the margin)
 		colWidth := c.maxWidth(i, min(i+height, len(cands)))
+		menuWidth, _ := c.widths(i, min(i+height, len(cands)))
 		totalColWidth := colWidth + completionColMarginTotal
 		if totalColWidth > remainedWidth {
 			totalColWidth = remainedWidth
 			colWidth = totalColWidth - completionColMarginTotal
 			trimmed = true
+			// Trim the annotations first.
+			menuWidth = min(menuWidth, colWidth)
 		}
 
 		col := newBuffer(totalColWidth)
@@ -340,7 +408,17 @@ func (c *completion) ListRender(width, maxHeight int) *buffer {
 				if j == c.selected {
 					s = append(s, styleForSelectedCompletion.String())
 				}
-				col.writes(util.ForceWcwidth(cands[j].menu.Text, colWidth), s.String())
+				col.writes(util.ForceWcwidth(cands[j].menu.Text, menuWidth), s.String())
+				if pad := colWidth - menuWidth; pad > 0 {
+					s := ui.JoinStyles(styleForCompletion, styleForCompletionAnnotation)
+					if j == c.selected {
+						s = append(s, styleForSelectedCompletion.String())
+					}
+					col.writePadding(1, s.String())
+					if pad > 1 {
+						col.writes(util.ForceWcwidth(cands[j].annotation, pad-1), s.String())
+					}
+				}
 				col.writePadding(completionColMarginRight, styleForCompletion.String())
 				if !trimmed {
 					c.lastShownInFull = j
@@ -349,6 +427,7 @@ func (c *completion) ListRender(width, maxHeight int) *buffer {
 		}
 
 		b.extendRight(col, 0)
+		c.colEnds = append(c.colEnds, width-remainedWidth+totalColWidth)
 		remainedWidth -= totalColWidth
 		if remainedWidth <= completionColMarginTotal {
 			break
@@ -369,6 +448,21 @@ func (c *completion) ListRender(width, maxHeight int) *buffer {
 	return b
 }
 
+// click selects the candidate shown at the given position of the listing.
+func (c *completion) click(line, col, width, height int) {
+	if line >= c.height {
+		return
+	}
+	for k, end := range c.colEnds {
+		if col < end {
+			if i := c.firstShown + k*c.height + line; i < len(c.filtered) {
+				c.selected = i
+			}
+			return
+		}
+	}
+}
+
 func (c *completion) changeFilter(f string) {
 	c.filter = f
 	if f == "" {
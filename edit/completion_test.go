@@ -0,0 +1,60 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+)
+
+// newComplTestEditor returns an Editor with the line "echo $ab", for which
+// $abc and $abd are candidates.
+func newComplTestEditor() *Editor {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Global["abc"] = eval.NewPtrVariable(eval.String(""))
+	ev.Global["abd"] = eval.NewPtrVariable(eval.String(""))
+	ed := &Editor{evaler: ev, variables: makeVariables()}
+	ev.Editor = ed
+	ed.line = "echo $ab"
+	ed.dot = len(ed.line)
+	ed.chunk, _ = parse.Parse("[test]", ed.line)
+	ed.mode = &ed.insert
+	return ed
+}
+
+func TestComplSmartStartAutoMenu(t *testing.T) {
+	ed := newComplTestEditor()
+	ed.variables["completion-auto-menu"].Set(eval.String("2"))
+
+	complSmartStart(ed)
+	if ed.mode == &ed.completion {
+		t.Errorf("first smart-start started completion, want it to wait")
+	}
+	if len(ed.tips) == 0 {
+		t.Errorf("first smart-start added no tip")
+	}
+	complSmartStart(ed)
+	if ed.mode != &ed.completion {
+		t.Errorf("second smart-start did not start completion")
+	}
+}
+
+func TestComplSmartStartCycle(t *testing.T) {
+	ed := newComplTestEditor()
+	ed.variables["completion-cycle"].Set(eval.Bool(true))
+
+	complSmartStart(ed)
+	if ed.mode != &ed.completion || !ed.completion.cycle {
+		t.Fatalf("smart-start did not start completion in cycle mode")
+	}
+	if b := ed.completion.ListRender(20, 5); b != nil {
+		t.Errorf("ListRender in cycle mode => %v, want nil", b)
+	}
+	complDownCycle(ed)
+	complDownCycle(ed)
+	if ed.completion.selected != 0 {
+		t.Errorf("selected %d after cycling through 2 candidates, want 0",
+			ed.completion.selected)
+	}
+}
@@ -0,0 +1,46 @@
+package edit
+
+import (
+	"strings"
+
+	"github.com/elves/elvish/sys"
+)
+
+// Confirm asks a yes/no question in the minibuffer while a command is running,
+// and returns whether the answer starts with y. It implements
+// eval.Confirmer. Nothing is asked while the editor is reading a line, since
+// the command asking cannot wait for the answer then. When the input is not a
+// terminal, the answer is read as a line.
+func (ed *Editor) Confirm(question string) bool {
+	var answer string
+	if sys.IsATTY(int(ed.in.Fd())) {
+		var err error
+		answer, err = ed.ReadMinibuffer(question + " [y/n]")
+		if err != nil {
+			return false
+		}
+	} else {
+		ed.activeMutex.Lock()
+		defer ed.activeMutex.Unlock()
+		if ed.active {
+			return false
+		}
+		ed.out.WriteString(question + " [y/n] ")
+		answer = readAnswer(ed)
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y")
+}
+
+// readAnswer reads one line from the input. It reads one byte at a time, so
+// that input after the line is left for the commands that follow.
+func readAnswer(ed *Editor) string {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := ed.in.Read(buf)
+		if err != nil || n == 0 || buf[0] == '\n' {
+			return string(line)
+		}
+		line = append(line, buf[0])
+	}
+}
@@ -0,0 +1,110 @@
+package edit
+
+import (
+	"os"
+	"strings"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+)
+
+// Confirmation before running dangerous commands. When a line is accepted,
+// each command form in it is checked against $edit:confirm-before-run, a list
+// whose elements are either command names or predicates. A predicate is
+// called with the words of the form as they are written, before evaluation,
+// and the form matches if it outputs a true value. If any form matches, the
+// user is asked in the minibuffer whether to run the line; answering no keeps
+// it in the editor.
+
+var _ = registerVariable("confirm-before-run", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.NewList(), eval.ShouldBeList)
+})
+
+func (ed *Editor) confirmBeforeRun() eval.List {
+	return ed.variables["confirm-before-run"].Get().(eval.List)
+}
+
+// formsToConfirm returns the source text of the forms in src that match
+// $edit:confirm-before-run.
+func (ed *Editor) formsToConfirm(src string) []string {
+	patterns := ed.confirmBeforeRun()
+	if patterns.Len() == 0 {
+		return nil
+	}
+	n, err := parse.Parse("[interactive]", src)
+	if err != nil {
+		return nil
+	}
+	var matched []string
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		if form, ok := n.(*parse.Form); ok && form.Head != nil {
+			if ed.formMatches(formWords(form), patterns) {
+				matched = append(matched, form.SourceText())
+			}
+		}
+		for _, ch := range n.Children() {
+			walk(ch)
+		}
+	}
+	walk(n)
+	return matched
+}
+
+// formWords returns the source text of the head and arguments of a form.
+func formWords(form *parse.Form) []string {
+	words := []string{form.Head.SourceText()}
+	for _, arg := range form.Args {
+		words = append(words, arg.SourceText())
+	}
+	return words
+}
+
+func (ed *Editor) formMatches(words []string, patterns eval.List) bool {
+	matched := false
+	patterns.Iterate(func(v eval.Value) bool {
+		switch v := v.(type) {
+		case eval.String:
+			matched = string(v) == words[0]
+		case eval.CallableValue:
+			matched = callPredicate(ed.evaler, v, words)
+		}
+		return !matched
+	})
+	return matched
+}
+
+// callPredicate calls fn with words, and returns whether it outputs a true
+// value. Errors are treated as a match, so that a broken predicate errs on the
+// side of asking.
+func callPredicate(ev *eval.Evaler, fn eval.CallableValue, words []string) bool {
+	ports := []*eval.Port{
+		eval.DevNullClosedChan, {File: os.Stdout}, {File: os.Stderr}}
+	args := make([]eval.Value, len(words))
+	for i, word := range words {
+		args[i] = eval.String(word)
+	}
+	// XXX There is no source to pass to NewTopEvalCtx.
+	ec := eval.NewTopEvalCtx(ev, "[editor confirm-before-run]", "", ports)
+	values, err := ec.PCaptureOutput(fn, args, eval.NoOpts)
+	if err != nil {
+		return true
+	}
+	for _, v := range values {
+		if eval.ToBool(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmReturnLine asks whether to run the line, which contains forms that
+// need confirmation, and returns it if the answer is yes.
+func (ed *Editor) confirmReturnLine(forms []string) {
+	question := "run " + strings.Join(forms, "; ") + "? [y/n]"
+	ed.startMinibuffer(question, func(ed *Editor, answer string, ok bool) {
+		if ok && strings.HasPrefix(strings.ToLower(answer), "y") {
+			ed.nextAction = action{typ: exitReadLine, returnLine: ed.line}
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/eval"
+)
+
+func TestConfirmBeforeRun(t *testing.T) {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ed := &Editor{variables: makeVariables(), evaler: ev}
+	ed.mode = &ed.insert
+
+	if forms := ed.formsToConfirm("rm -rf /*"); forms != nil {
+		t.Errorf("forms to confirm with no patterns => %v, want nil", forms)
+	}
+
+	err := ev.SourceText("[test]",
+		"pred = [@w]{ for x $w { if (eq $x -rf) { put (eq $w[0] rm) } } }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pred := ev.Global["pred"].Get()
+	ed.variables["confirm-before-run"].Set(
+		eval.NewList(eval.String("shutdown"), pred))
+
+	for _, test := range []struct {
+		src  string
+		want []string
+	}{
+		{"ls; rm -f a", nil},
+		{"shutdown -h now", []string{"shutdown -h now"}},
+		{"ls; rm -rf /*", []string{"rm -rf /*"}},
+		{"echo (rm -rf a)", []string{"rm -rf a"}},
+		{"rm -rf (", nil},
+	} {
+		if forms := ed.formsToConfirm(test.src); !reflect.DeepEqual(forms, test.want) {
+			t.Errorf("forms to confirm in %q => %v, want %v", test.src, forms, test.want)
+		}
+	}
+
+	press := func(k ui.Key) {
+		ed.lastKey = k
+		ed.CallFn(ed.mode.Binding(k))
+	}
+
+	// Answering no keeps the line in the editor.
+	ed.line = "shutdown"
+	returnLine(ed)
+	press(ui.Key{'n', 0})
+	press(ui.Key{ui.Enter, 0})
+	if ed.nextAction.typ != noAction || ed.mode != &ed.insert {
+		t.Errorf("after answering no, action is %v and mode is %v",
+			ed.nextAction, ed.mode)
+	}
+
+	// Answering yes returns the line.
+	returnLine(ed)
+	press(ui.Key{'y', 0})
+	press(ui.Key{ui.Enter, 0})
+	if ed.nextAction.typ != exitReadLine || ed.nextAction.returnLine != "shutdown" {
+		t.Errorf("after answering yes, action is %v", ed.nextAction)
+	}
+}
@@ -0,0 +1,51 @@
+package edit
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var confirmTests = []struct {
+	input string
+	want  bool
+}{
+	{"y\n", true},
+	{"Yes\n", true},
+	{"n\n", false},
+	{"\n", false},
+	{"", false},
+}
+
+func TestConfirm(t *testing.T) {
+	for _, test := range confirmTests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := ioutil.TempFile("", "elvishtest.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.WriteString(test.input + "rest")
+		w.Close()
+
+		ed := &Editor{in: r, out: out}
+		if got := ed.Confirm("go?"); got != test.want {
+			t.Errorf("Confirm with input %q => %v, want %v", test.input, got, test.want)
+		}
+		out.Seek(0, 0)
+		if prompt, _ := ioutil.ReadAll(out); string(prompt) != "go? [y/n] " {
+			t.Errorf("Confirm wrote %q, want %q", prompt, "go? [y/n] ")
+		}
+		// Input after the answer is left alone.
+		if test.input != "" {
+			if rest, _ := ioutil.ReadAll(r); string(rest) != "rest" {
+				t.Errorf("Confirm left %q, want %q", rest, "rest")
+			}
+		}
+		r.Close()
+		out.Close()
+		os.Remove(out.Name())
+	}
+}
@@ -0,0 +1,42 @@
+package edit
+
+import (
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+)
+
+// Showing documentation of builtins.
+
+var _ = registerBuiltins("", map[string]func(*Editor){
+	"show-doc": showDoc,
+})
+
+// showDoc shows the documentation of the command under the cursor as a tip.
+func showDoc(ed *Editor) {
+	if ed.chunk == nil {
+		return
+	}
+	head := formHeadAt(ed.chunk, ed.dot)
+	if head == "" {
+		ed.addTip("no command under cursor")
+		return
+	}
+	doc, ok := eval.Doc(head)
+	if !ok {
+		ed.addTip("no documentation for %s", parse.Quote(head))
+		return
+	}
+	ed.showText("DOC "+head, doc)
+}
+
+// formHeadAt returns the source text of the head of the innermost form that
+// contains the position p, or "" if there is no such form.
+func formHeadAt(n parse.Node, p int) string {
+	path := parse.NodeAt(n, p)
+	for i := len(path) - 1; i >= 0; i-- {
+		if form, ok := path[i].(*parse.Form); ok && form.Head != nil {
+			return form.Head.SourceText()
+		}
+	}
+	return ""
+}
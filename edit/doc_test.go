@@ -0,0 +1,30 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/parse"
+)
+
+var formHeadAtTests = []struct {
+	src  string
+	pos  int
+	head string
+}{
+	{"echo foo", 6, "echo"},
+	{"echo (put foo)", 11, "put"},
+	{"echo (put foo)", 2, "echo"},
+	{"a=b", 1, ""},
+}
+
+func TestFormHeadAt(t *testing.T) {
+	for _, tt := range formHeadAtTests {
+		n, err := parse.Parse("[test]", tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.src, err)
+		}
+		if head := formHeadAt(n, tt.pos); head != tt.head {
+			t.Errorf("formHeadAt(%q, %d) => %q, want %q", tt.src, tt.pos, head, tt.head)
+		}
+	}
+}
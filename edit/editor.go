@@ -16,6 +16,7 @@ import (
 	"github.com/elves/elvish/edit/ui"
 	"github.com/elves/elvish/eval"
 	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/store/storedefs"
 	"github.com/elves/elvish/sys"
 	"github.com/elves/elvish/util"
 )
@@ -44,6 +45,20 @@ type Editor struct {
 
 	historyFuser *history.Fuser
 	historyMutex sync.RWMutex
+	// Metadata of the last command added to the history, to be completed
+	// by RecordCmdResult. Protected by historyMutex.
+	lastCmdMeta *storedefs.CmdMeta
+	// Arguments used with commands in the history, by command name. Cleared
+	// after each command.
+	historyArgsCache map[string][]string
+
+	// Requests to redraw the editor from other goroutines.
+	redrawCh chan struct{}
+
+	gitInfo gitInfoCache
+
+	// The last line read, used for notifications after it has run.
+	lastLine string
 
 	editorState
 }
@@ -60,6 +75,7 @@ type editorState struct {
 	line           string
 	lexedLine      *string
 	chunk          *parse.Chunk
+	parseError     error
 	styling        *highlight.Styling
 	promptContent  []*ui.Styled
 	rpromptContent []*ui.Styled
@@ -73,13 +89,19 @@ type editorState struct {
 	navigation navigation
 	hist       hist
 
+	// Where the listing was last rendered, and the click waiting for a cursor
+	// position report to be mapped to it.
+	listingLayout listingLayout
+	pendingClick  *tty.MouseEvent
+
 	// A cache of external commands, used in stylist.
 	isExternal      map[string]bool
 	parseErrorAtEnd bool
 
 	// Used for builtins.
-	lastKey    ui.Key
-	nextAction action
+	lastKey           ui.Key
+	nextAction        action
+	smartStartPresses smartStartPresses
 }
 
 // NewEditor creates an Editor.
@@ -94,6 +116,7 @@ func NewEditor(in *os.File, out *os.File, sigs chan os.Signal, ev *eval.Evaler,
 		evaler: ev,
 
 		variables: makeVariables(),
+		redrawCh:  make(chan struct{}, 1),
 	}
 	if daemon != nil {
 		f, err := history.NewFuser(daemon)
@@ -129,11 +152,23 @@ func (ed *Editor) addTip(format string, args ...interface{}) {
 	ed.tips = append(ed.tips, fmt.Sprintf(format, args...))
 }
 
-// Notify adds one notification entry. It is concurrency-safe.
+// Notify adds one notification entry, and requests a redraw so that it is
+// shown above the prompt without waiting for a keystroke. It is
+// concurrency-safe.
 func (ed *Editor) Notify(format string, args ...interface{}) {
 	ed.notificationMutex.Lock()
-	defer ed.notificationMutex.Unlock()
 	ed.notifications = append(ed.notifications, fmt.Sprintf(format, args...))
+	ed.notificationMutex.Unlock()
+	ed.redraw()
+}
+
+// redraw requests the editor to call the prompts and redraw. It is
+// concurrency-safe and does not block.
+func (ed *Editor) redraw() {
+	select {
+	case ed.redrawCh <- struct{}{}:
+	default:
+	}
 }
 
 func (ed *Editor) refresh(fullRefresh bool, addErrorsToTips bool) error {
@@ -141,14 +176,12 @@ func (ed *Editor) refresh(fullRefresh bool, addErrorsToTips bool) error {
 	// Re-lex the line if needed
 	if ed.lexedLine == nil || *ed.lexedLine != src {
 		ed.lexedLine = &src
-		n, err := parse.Parse("[interactive]", src)
-		ed.chunk = n
-
-		ed.parseErrorAtEnd = err != nil && atEnd(err, len(src))
-		// If all parse errors are at the end, it is likely caused by incomplete
-		// input. In that case, do not complain about parse errors.
-		// TODO(xiaq): Find a more reliable way to determine incomplete input.
-		// Ideally the parser should report it.
+		n, err := parse.Reparse("[interactive]", ed.chunk, ed.parseError, src)
+		ed.chunk, ed.parseError = n, err
+
+		ed.parseErrorAtEnd = parse.IsIncomplete(err)
+		// If all parse errors are caused by incomplete input, do not complain
+		// about them.
 		if err != nil && addErrorsToTips && !ed.parseErrorAtEnd {
 			ed.addTip("%s", err)
 		}
@@ -164,29 +197,38 @@ func (ed *Editor) refresh(fullRefresh bool, addErrorsToTips bool) error {
 			// Highlight errors in the input buffer.
 			// TODO(xiaq): There might be multiple tokens involved in the
 			// compiler error; they should all be highlighted as erroneous.
-			p := err.(*eval.CompilationError).Context.Begin
-			badn := findLeafNode(n, p)
-			ed.styling.Add(badn.Begin(), badn.End(), styleForCompilerError.String())
+			if ce := firstCompilationError(err); ce != nil {
+				badn := findLeafNode(n, ce.Context.Begin)
+				ed.styling.Add(badn.Begin(), badn.End(), styleForCompilerError.String())
+			}
 		}
 	}
 	return ed.writer.refresh(&ed.editorState, fullRefresh)
 }
 
 func atEnd(e error, n int) bool {
+	ce := firstCompilationError(e)
+	if ce == nil {
+		logger.Printf("atEnd called with error type %T", e)
+		return false
+	}
+	return ce.Context.Begin == n
+}
+
+// firstCompilationError returns the compilation error in e that comes first,
+// or nil if there is none. The compiler returns multiple errors as
+// *util.Errors.
+func firstCompilationError(e error) *eval.CompilationError {
 	switch e := e.(type) {
 	case *eval.CompilationError:
-		return e.Context.Begin == n
-	case *parse.Error:
-		for _, entry := range e.Entries {
-			if entry.Context.Begin != n {
-				return false
-			}
+		return e
+	case *util.Errors:
+		if len(e.Errors) > 0 {
+			ce, _ := e.Errors[0].(*eval.CompilationError)
+			return ce
 		}
-		return true
-	default:
-		logger.Printf("atEnd called with error type %T", e)
-		return false
 	}
+	return nil
 }
 
 // insertAtDot inserts text at the dot and moves the dot after it.
@@ -272,8 +314,10 @@ func (ed *Editor) startReadLine() error {
 		mismatch within one line.
 	*/
 	ed.out.WriteString("\033[?7l")
-	// Turn on SGR-style mouse tracking.
-	//ed.out.WriteString("\033[?1000;1006h")
+	// Turn on SGR-style mouse tracking if requested.
+	if ed.mouse() {
+		ed.out.WriteString(mouseOn)
+	}
 
 	// Enable bracketed paste.
 	ed.out.WriteString("\033[?2004h")
@@ -295,6 +339,7 @@ func (ed *Editor) finishReadLine(addError func(error)) {
 	if !ed.rpromptPersistent() {
 		ed.rpromptContent = nil
 	}
+	ed.promptContent = ed.acceptedPrompt()
 	addError(ed.refresh(false, false))
 	ed.out.WriteString("\n")
 	ed.writer.resetOldBuf()
@@ -303,8 +348,8 @@ func (ed *Editor) finishReadLine(addError func(error)) {
 
 	// Turn on autowrap.
 	ed.out.WriteString("\033[?7h")
-	// Turn off mouse tracking.
-	//ed.out.WriteString("\033[?1000;1006l")
+	// Turn off mouse tracking. This is harmless if it was not turned on.
+	ed.out.WriteString(mouseOff)
 
 	// Disable bracketed paste.
 	ed.out.WriteString("\033[?2004l")
@@ -317,6 +362,7 @@ func (ed *Editor) finishReadLine(addError func(error)) {
 
 	// Save the line before resetting all of editorState.
 	line := ed.line
+	ed.lastLine = line
 
 	ed.editorState = editorState{}
 
@@ -336,6 +382,7 @@ func (ed *Editor) ReadLine() (line string, err error) {
 	})
 
 	ed.mode = &ed.insert
+	ed.gitInfo.invalidate()
 
 	// Find external commands asynchronously, so that slow I/O won't block the
 	// editor.
@@ -364,6 +411,7 @@ MainLoop:
 		select {
 		case m := <-isExternalCh:
 			ed.isExternal = m
+		case <-ed.redrawCh:
 		case sig := <-ed.sigs:
 			// TODO(xiaq): Maybe support customizable handling of signals
 			switch sig {
@@ -388,9 +436,11 @@ MainLoop:
 		case unit := <-ed.reader.UnitChan():
 			switch unit := unit.(type) {
 			case tty.MouseEvent:
-				ed.addTip("mouse: %+v", unit)
+				ed.handleMouse(unit)
 			case tty.CursorPosition:
-				// Ignore CPR
+				ed.handleCursorPosition(tty.Pos(unit))
+			case tty.FocusEvent:
+				// Ignore focus reports
 			case tty.PasteSetting:
 				if !unit {
 					continue
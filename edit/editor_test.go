@@ -3,6 +3,10 @@ package edit
 import (
 	"testing"
 
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
 	"github.com/kr/pty"
 )
 
@@ -22,3 +26,23 @@ func TestSetupTerminal(t *testing.T) {
 	// set.
 	// termios, err := sys.NewTermiosFromFd(int(tty.Fd()))
 }
+
+func TestFirstCompilationError(t *testing.T) {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	src := "echo $a; echo $b"
+	n, err := parse.Parse("[test]", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ev.Compile(n, "[test]", src)
+	if _, ok := err.(*util.Errors); !ok {
+		t.Fatalf("Compile returns %T, want *util.Errors", err)
+	}
+	ce := firstCompilationError(err)
+	if ce == nil || ce.Context.Begin != 5 {
+		t.Errorf("firstCompilationError returns %v, want error at 5", ce)
+	}
+	if atEnd(err, len(src)) {
+		t.Errorf("atEnd returns true for errors not at end")
+	}
+}
@@ -0,0 +1,159 @@
+package edit
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+// gitInfo is the status of a git repository, as shown in prompts.
+type gitInfo struct {
+	branch string
+	dirty  bool
+	ahead  int
+	behind int
+}
+
+func (gi *gitInfo) toMap() eval.Map {
+	return eval.NewMap(map[eval.Value]eval.Value{
+		eval.String("branch"): eval.String(gi.branch),
+		eval.String("dirty"):  eval.Bool(gi.dirty),
+		eval.String("ahead"):  eval.String(strconv.Itoa(gi.ahead)),
+		eval.String("behind"): eval.String(strconv.Itoa(gi.behind)),
+	})
+}
+
+// gitInfoCache caches the status of git repositories, keyed by the root of
+// the work tree. Entries are computed asynchronously; until the computation
+// finishes, a stale entry (if any) is used. All entries become stale at the
+// beginning of each ReadLine, since the last command may have changed them.
+type gitInfoCache struct {
+	sync.Mutex
+	gen     int
+	entries map[string]*gitInfoEntry
+}
+
+type gitInfoEntry struct {
+	info    *gitInfo
+	gen     int
+	pending bool
+}
+
+func (c *gitInfoCache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+	c.gen++
+}
+
+// get returns the cached status of the repository at root, and starts an
+// update with the git executable at the given path in the background if the entry is missing or stale. The done
+// callback is called when an update finishes.
+func (c *gitInfoCache) get(git, root string, done func()) *gitInfo {
+	c.Lock()
+	defer c.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*gitInfoEntry)
+	}
+	e, ok := c.entries[root]
+	if !ok {
+		e = &gitInfoEntry{gen: -1}
+		c.entries[root] = e
+	}
+	if e.gen != c.gen && !e.pending {
+		e.pending = true
+		gen := c.gen
+		go func() {
+			info, err := getGitInfo(git, root)
+			if err != nil {
+				logger.Printf("git status in %s: %v", root, err)
+			}
+			c.Lock()
+			e.info, e.gen, e.pending = info, gen, false
+			c.Unlock()
+			done()
+		}()
+	}
+	return e.info
+}
+
+// findGitRoot returns the root of the git work tree containing dir, or an
+// empty string if dir is not in one.
+func findGitRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func getGitInfo(git, root string) (*gitInfo, error) {
+	cmd := exec.Command(git, "status", "--porcelain=v2", "--branch")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseGitStatus(out), nil
+}
+
+// parseGitStatus parses the output of "git status --porcelain=v2 --branch".
+func parseGitStatus(out []byte) *gitInfo {
+	gi := &gitInfo{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "# ") {
+			if line != "" {
+				gi.dirty = true
+			}
+			continue
+		}
+		fields := strings.Fields(line[2:])
+		switch {
+		case len(fields) == 2 && fields[0] == "branch.head":
+			gi.branch = fields[1]
+		case len(fields) == 3 && fields[0] == "branch.ab":
+			gi.ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "+"))
+			gi.behind, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "-"))
+		}
+	}
+	return gi
+}
+
+// gitInfoFn implements the edit:git-info builtin. It outputs a map with the
+// branch, dirty, ahead and behind fields of the git repository containing the
+// working directory, or nothing if the working directory is not in a git
+// repository or its status is not known yet. It never waits for git to finish;
+// when the status becomes known, the prompt is redrawn.
+func gitInfoFn(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.ScanArgs(args)
+	eval.TakeNoOpt(opts)
+
+	ed := editorOf(ec)
+	root := findGitRoot(util.Getwd())
+	if root == "" {
+		return
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return
+	}
+	ec.CheckExternal("git", git)
+	info := ed.gitInfo.get(git, root, ed.redraw)
+	if info != nil {
+		ec.OutputChan() <- info.toMap()
+	}
+}
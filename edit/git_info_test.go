@@ -0,0 +1,44 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+var parseGitStatusTests = []struct {
+	out  string
+	want gitInfo
+}{
+	{"", gitInfo{}},
+	{"# branch.oid 0123\n# branch.head master\n",
+		gitInfo{branch: "master"}},
+	{"# branch.head dev\n# branch.upstream origin/dev\n# branch.ab +2 -13\n",
+		gitInfo{branch: "dev", ahead: 2, behind: 13}},
+	{"# branch.head (detached)\n1 .M N... 100644 100644 100644 a b f.go\n",
+		gitInfo{branch: "(detached)", dirty: true}},
+	{"# branch.head master\n? untracked\n",
+		gitInfo{branch: "master", dirty: true}},
+}
+
+func TestParseGitStatus(t *testing.T) {
+	for _, test := range parseGitStatusTests {
+		got := parseGitStatus([]byte(test.out))
+		if !reflect.DeepEqual(*got, test.want) {
+			t.Errorf("parseGitStatus(%q) => %v, want %v", test.out, *got, test.want)
+		}
+	}
+}
+
+func TestGitInfoFnWithoutEditor(t *testing.T) {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ec := eval.NewTopEvalCtx(ev, "[test]", "", nil)
+	err := util.PCall(func() { gitInfoFn(ec, nil, nil) })
+	if err != errEditorInvalid {
+		t.Errorf("gitInfoFn without editor => %v, want %v", err, errEditorInvalid)
+	}
+}
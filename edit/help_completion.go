@@ -0,0 +1,169 @@
+package edit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/elves/elvish/eval"
+)
+
+// Completion of flags parsed from the --help output of external commands. The
+// edit:complete-help completer runs `cmd --help` and offers the flags it
+// finds, with their help texts as annotations. For commands listed in
+// $edit:complete-help-commands, the default argument completer falls back to
+// it for words that start with "-"; other commands are never run.
+//
+// Only commands found in $paths can be run, never those given by path, such
+// as ./script. They are run at most once per session, in the background so
+// that the editor is not blocked, with no input, in the directory for
+// temporary files, in their own process group, and are killed after
+// helpTimeout. Their flags are offered once they are known, from the next
+// completion on.
+
+var helpArgCompleter = &builtinArgCompleter{"complete-help", complHelp}
+
+var _ = registerVariable("complete-help-commands", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.NewList(), eval.ShouldBeList)
+})
+
+// helpCompletionEnabled returns whether the default argument completer can
+// run cmd to complete its flags.
+func (ed *Editor) helpCompletionEnabled(cmd string) bool {
+	enabled := false
+	ed.variables["complete-help-commands"].Get().(eval.List).Iterate(
+		func(v eval.Value) bool {
+			enabled = v == eval.String(cmd)
+			return !enabled
+		})
+	return enabled
+}
+
+const helpTimeout = time.Second
+
+var (
+	// A line that documents flags: flags come first, optionally followed by a
+	// help text, separated by at least two spaces.
+	helpLineRegexp = regexp.MustCompile(`^\s*(-.*?)(?:\s{2,}(\S.*))?$`)
+	// A flag, and the = that introduces its argument, if any.
+	helpFlagRegexp = regexp.MustCompile(`(?:^|[\s,])(--?[[:alnum:]][[:alnum:]_-]*)(=?)`)
+)
+
+// helpFlag is a flag found in --help output.
+type helpFlag struct {
+	name string
+	// Whether the argument of the flag is given after "=".
+	takesEq bool
+	doc     string
+}
+
+var errHelpNeedsName = errors.New("can only run commands found in $paths")
+
+var helpCache = struct {
+	sync.Mutex
+	flags map[string][]helpFlag
+	// Commands that are being run in the background.
+	loading map[string]bool
+}{flags: make(map[string][]helpFlag), loading: make(map[string]bool)}
+
+func complHelp(words []string, ev *eval.Evaler) ([]rawCandidate, error) {
+	if len(words) < 2 {
+		return nil, ErrTooFewArguments
+	}
+	if strings.ContainsRune(words[0], '/') {
+		return nil, errHelpNeedsName
+	}
+	path, err := exec.LookPath(words[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExternal(ev, words[0], path); err != nil {
+		return nil, err
+	}
+	return helpCandidates(cachedHelpFlags(path)), nil
+}
+
+// cachedHelpFlags returns the flags of a command if they are known. Otherwise
+// it starts loading them in the background and returns nil.
+func cachedHelpFlags(path string) []helpFlag {
+	helpCache.Lock()
+	defer helpCache.Unlock()
+	if flags, ok := helpCache.flags[path]; ok {
+		return flags
+	}
+	if !helpCache.loading[path] {
+		helpCache.loading[path] = true
+		go loadHelpFlags(path)
+	}
+	return nil
+}
+
+// loadHelpFlags runs a command with --help and caches the flags found.
+func loadHelpFlags(path string) {
+	flags := parseHelp(runHelp(path))
+	helpCache.Lock()
+	defer helpCache.Unlock()
+	helpCache.flags[path] = flags
+	delete(helpCache.loading, path)
+}
+
+// runHelp runs a command with --help and returns what it writes to stdout and
+// stderr. Errors are ignored, since many commands exit with a non-zero status
+// after showing help.
+func runHelp(path string) string {
+	var buf bytes.Buffer
+	cmd := exec.Command(path, "--help")
+	cmd.Dir = os.TempDir()
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cmd.Start() != nil {
+		return ""
+	}
+	timer := time.AfterFunc(helpTimeout, func() {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	})
+	cmd.Wait()
+	timer.Stop()
+	return buf.String()
+}
+
+// parseHelp extracts flags from --help output. The first occurrence of each
+// flag wins.
+func parseHelp(help string) []helpFlag {
+	var flags []helpFlag
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(help, "\n") {
+		m := helpLineRegexp.FindStringSubmatch(strings.TrimRight(line, " \t\r"))
+		if m == nil {
+			continue
+		}
+		for _, fm := range helpFlagRegexp.FindAllStringSubmatch(m[1], -1) {
+			if seen[fm[1]] {
+				continue
+			}
+			seen[fm[1]] = true
+			flags = append(flags, helpFlag{fm[1], fm[2] == "=", m[2]})
+		}
+	}
+	return flags
+}
+
+func helpCandidates(flags []helpFlag) []rawCandidate {
+	cands := make([]rawCandidate, len(flags))
+	for i, flag := range flags {
+		suffix := " "
+		if flag.takesEq {
+			suffix = "="
+		}
+		cands[i] = &complexCandidate{
+			stem: flag.name, codeSuffix: suffix, annotation: flag.doc}
+	}
+	return cands
+}
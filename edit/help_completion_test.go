@@ -0,0 +1,97 @@
+package edit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+)
+
+const testHelp = `Usage: foo [OPTION]... FILE...
+Frobnicate files.
+
+  -a, --all                 do not ignore entries starting with .
+      --color[=WHEN]        colorize the output
+      --block-size=SIZE     scale sizes by SIZE
+  -v                        be verbose
+      --help     display this help and exit
+  --all is mentioned again
+`
+
+func TestParseHelp(t *testing.T) {
+	want := []helpFlag{
+		{"-a", false, "do not ignore entries starting with ."},
+		{"--all", false, "do not ignore entries starting with ."},
+		{"--color", false, "colorize the output"},
+		{"--block-size", true, "scale sizes by SIZE"},
+		{"-v", false, "be verbose"},
+		{"--help", false, "display this help and exit"},
+	}
+	if got := parseHelp(testHelp); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseHelp => %v, want %v", got, want)
+	}
+}
+
+func TestHelpFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "foo")
+	err = ioutil.WriteFile(path, []byte("#!/bin/sh\necho '  --bar  the bar'\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []helpFlag{{"--bar", false, "the bar"}}
+	// The command is run in the background.
+	if got := cachedHelpFlags(path); got != nil {
+		t.Errorf("cachedHelpFlags before loading => %v, want nil", got)
+	}
+	deadline := time.Now().Add(2 * helpTimeout)
+	for cachedHelpFlags(path) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := cachedHelpFlags(path); !reflect.DeepEqual(got, want) {
+		t.Errorf("cachedHelpFlags => %v, want %v", got, want)
+	}
+	// The result is cached.
+	os.Remove(path)
+	if got := cachedHelpFlags(path); !reflect.DeepEqual(got, want) {
+		t.Errorf("cachedHelpFlags after removing the command => %v, want %v", got, want)
+	}
+}
+
+func TestComplHelpRejectsPaths(t *testing.T) {
+	if _, err := complHelp([]string{"./foo", "-"}, nil); err != errHelpNeedsName {
+		t.Errorf("complHelp with a path => %v, want %v", err, errHelpNeedsName)
+	}
+}
+
+func TestHelpCompletionEnabled(t *testing.T) {
+	ed := &Editor{variables: makeVariables()}
+	if ed.helpCompletionEnabled("ls") {
+		t.Errorf("help completion enabled by default")
+	}
+	ed.variables["complete-help-commands"].Set(eval.NewList(eval.String("ls")))
+	if !ed.helpCompletionEnabled("ls") || ed.helpCompletionEnabled("rm") {
+		t.Errorf("help completion not enabled for exactly the listed commands")
+	}
+}
+
+func TestComplHelpRestricted(t *testing.T) {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ev.Restriction = &eval.Restriction{RestrictExternals: true}
+	_, err := complHelp([]string{"sh", "-"}, ev)
+	if err == nil || !strings.Contains(err.Error(), eval.ErrRestricted.Error()) {
+		t.Errorf("complHelp in restricted mode => %v, want %v", err, eval.ErrRestricted)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/store/storedefs"
+	"github.com/elves/elvish/util"
 )
 
 // Command history listing mode.
@@ -15,6 +17,8 @@ var _ = registerBuiltins(modeHistoryListing, map[string]func(*Editor){
 	"start":                   histlistStart,
 	"toggle-dedup":            histlistToggleDedup,
 	"toggle-case-sensitivity": histlistToggleCaseSensitivity,
+	"toggle-dir-filter":       histlistToggleDirFilter,
+	"cycle-status-filter":     histlistCycleStatusFilter,
 })
 
 func init() {
@@ -22,6 +26,8 @@ func init() {
 		map[ui.Key]string{
 			{'G', ui.Ctrl}: "toggle-case-sensitivity",
 			{'D', ui.Ctrl}: "toggle-dedup",
+			{'d', ui.Alt}:  "toggle-dir-filter",
+			{'s', ui.Alt}:  "cycle-status-filter",
 		})
 }
 
@@ -34,21 +40,21 @@ type histlist struct {
 	all             []string
 	dedup           bool
 	caseInsensitive bool
-	last            map[string]int
-	shown           []string
-	index           []int
-	indexWidth      int
+	// Metadata of the entries in all, or nil if unavailable.
+	metas []*storedefs.CmdMeta
+	// If not empty, only show commands run in this directory.
+	dir        string
+	status     statusFilter
+	shown      []string
+	index      []int
+	indexWidth int
 }
 
 func newHistlist(cmds []string) *listing {
-	last := make(map[string]int)
-	for i, entry := range cmds {
-		last[entry] = i
-	}
 	hl := &histlist{
 		// This has to be here for the initializatio to work :(
 		listing: &listing{},
-		all:     cmds, last: last, indexWidth: len(strconv.Itoa(len(cmds) - 1))}
+		all:     cmds, indexWidth: len(strconv.Itoa(len(cmds) - 1))}
 	l := newListing(modeHistoryListing, hl)
 	hl.listing = &l
 	return &l
@@ -62,6 +68,15 @@ func (hl *histlist) ModeTitle(i int) string {
 	if hl.caseInsensitive {
 		s += "(case-insensitive) "
 	}
+	if hl.dir != "" {
+		s += "(in " + util.TildeAbbr(hl.dir) + ") "
+	}
+	switch hl.status {
+	case statusOK:
+		s += "(succeeded) "
+	case statusFailed:
+		s += "(failed) "
+	}
 	return s
 }
 
@@ -92,10 +107,30 @@ func (hl *histlist) toggleCaseSensitivity() {
 	hl.updateShown()
 }
 
+func (hl *histlist) toggleDirFilter(dir string) {
+	if hl.dir == "" {
+		hl.dir = dir
+	} else {
+		hl.dir = ""
+	}
+	hl.updateShown()
+}
+
+func (hl *histlist) cycleStatusFilter() {
+	hl.status = (hl.status + 1) % (statusFailed + 1)
+	hl.updateShown()
+}
+
+func (hl *histlist) meta(i int) *storedefs.CmdMeta {
+	if hl.metas == nil {
+		return nil
+	}
+	return hl.metas[i]
+}
+
 func (hl *histlist) updateShown() {
 	hl.shown = nil
 	hl.index = nil
-	dedup := hl.dedup
 	filter := hl.filter
 	if hl.caseInsensitive {
 		filter = strings.ToLower(filter)
@@ -105,11 +140,29 @@ func (hl *histlist) updateShown() {
 		if hl.caseInsensitive {
 			fentry = strings.ToLower(entry)
 		}
-		if (!dedup || hl.last[entry] == i) && strings.Contains(fentry, filter) {
+		if strings.Contains(fentry, filter) && hl.status.match(hl.meta(i), hl.dir) {
 			hl.index = append(hl.index, i)
-			hl.shown = append(hl.shown, entry)
 		}
 	}
+	if hl.dedup {
+		// Keep the last of the entries left by the filters, so that an entry
+		// is not hidden by a duplicate that the filters drop.
+		seen := make(map[string]bool)
+		var kept []int
+		for j := len(hl.index) - 1; j >= 0; j-- {
+			if entry := hl.all[hl.index[j]]; !seen[entry] {
+				seen[entry] = true
+				kept = append(kept, hl.index[j])
+			}
+		}
+		for j, k := 0, len(kept)-1; j < k; j, k = j+1, k-1 {
+			kept[j], kept[k] = kept[k], kept[j]
+		}
+		hl.index = kept
+	}
+	for _, i := range hl.index {
+		hl.shown = append(hl.shown, hl.all[i])
+	}
 	hl.selected = len(hl.shown) - 1
 }
 
@@ -124,20 +177,24 @@ func (hl *histlist) Accept(i int, ed *Editor) {
 }
 
 func histlistStart(ed *Editor) {
-	cmds, err := getCmds(ed)
+	cmds, metas, err := getCmdsWithMeta(ed)
 	if err != nil {
-		ed.Notify("%v", err)
-		return
+		if cmds == nil {
+			ed.Notify("%v", err)
+			return
+		}
+		// Without metadata, the directory and status filters match nothing
+		// but the plain command list is still usable.
+		ed.Notify("cannot get history metadata: %v", err)
 	}
 
-	ed.mode = newHistlist(cmds)
-}
-
-func getCmds(ed *Editor) ([]string, error) {
-	if ed.daemon == nil {
-		return nil, ErrStoreOffline
+	texts := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		texts[i] = cmd.Text
 	}
-	return ed.historyFuser.AllCmds()
+	l := newHistlist(texts)
+	l.provider.(*histlist).metas = metas
+	ed.mode = l
 }
 
 func histlistToggleDedup(ed *Editor) {
@@ -152,6 +209,18 @@ func histlistToggleCaseSensitivity(ed *Editor) {
 	}
 }
 
+func histlistToggleDirFilter(ed *Editor) {
+	if hl := getHistlist(ed); hl != nil {
+		hl.toggleDirFilter(util.Getwd())
+	}
+}
+
+func histlistCycleStatusFilter(ed *Editor) {
+	if hl := getHistlist(ed); hl != nil {
+		hl.cycleStatusFilter()
+	}
+}
+
 func getHistlist(ed *Editor) *histlist {
 	if l, ok := ed.mode.(*listing); ok {
 		if hl, ok := l.provider.(*histlist); ok {
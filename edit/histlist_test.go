@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/store/storedefs"
 )
 
 var (
@@ -25,3 +26,44 @@ var (
 func TestHistlist(t *testing.T) {
 	testListingFilter(t, "theHistList", theHistList, histlistFilterTests)
 }
+
+func TestHistlistMetaFilter(t *testing.T) {
+	l := newHistlist([]string{"make", "make test", "ls", "make"})
+	hl := l.provider.(*histlist)
+	hl.metas = []*storedefs.CmdMeta{
+		{Seq: 1, Dir: "/src", OK: true},
+		{Seq: 2, Dir: "/src", OK: false},
+		nil,
+		{Seq: 4, Dir: "/tmp", OK: true},
+	}
+
+	hl.toggleDirFilter("/src")
+	testListingFilter(t, "hl (in /src)", l, []listingFilterTestCases{
+		{"", []shown{
+			{"0", ui.Unstyled("make")},
+			{"1", ui.Unstyled("make test")}}},
+	})
+
+	hl.cycleStatusFilter()
+	hl.cycleStatusFilter()
+	testListingFilter(t, "hl (in /src, failed)", l, []listingFilterTestCases{
+		{"", []shown{{"1", ui.Unstyled("make test")}}},
+	})
+
+	hl.cycleStatusFilter()
+	hl.toggleDedup()
+	testListingFilter(t, "hl (in /src, dedup)", l, []listingFilterTestCases{
+		{"", []shown{
+			{"0", ui.Unstyled("make")},
+			{"1", ui.Unstyled("make test")}}},
+	})
+	hl.toggleDedup()
+
+	hl.toggleDirFilter("/src")
+	hl.cycleStatusFilter()
+	testListingFilter(t, "hl (succeeded)", l, []listingFilterTestCases{
+		{"", []shown{
+			{"0", ui.Unstyled("make")},
+			{"3", ui.Unstyled("make")}}},
+	})
+}
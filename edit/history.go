@@ -3,10 +3,13 @@ package edit
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/elves/elvish/edit/history"
 	"github.com/elves/elvish/edit/ui"
 	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/store/storedefs"
+	"github.com/elves/elvish/util"
 )
 
 // Command history subsystem.
@@ -22,6 +25,41 @@ var _ = registerBuiltins("history", map[string]func(*Editor){
 	"default":            historyDefault,
 })
 
+var _ = registerBuiltins("", map[string]func(*Editor){
+	"toggle-private-mode": togglePrivateMode,
+})
+
+// When $edit:private-mode is true, accepted lines are not added to the
+// persistent history.
+var _ = registerVariable("private-mode", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.Bool(false), eval.ShouldBeBool)
+})
+
+func (ed *Editor) privateMode() bool {
+	return bool(ed.variables["private-mode"].Get().(eval.Bool).Bool())
+}
+
+// When $edit:history-ignore-space is true, accepted lines starting with a
+// space are not added to the persistent history. This is useful for
+// confidential operations.
+var _ = registerVariable("history-ignore-space", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.Bool(true), eval.ShouldBeBool)
+})
+
+func (ed *Editor) historyIgnoreSpace() bool {
+	return bool(ed.variables["history-ignore-space"].Get().(eval.Bool).Bool())
+}
+
+func togglePrivateMode(ed *Editor) {
+	on := !ed.privateMode()
+	ed.variables["private-mode"].Set(eval.Bool(on))
+	if on {
+		ed.addTip("private mode on; commands will not be recorded in history")
+	} else {
+		ed.addTip("private mode off")
+	}
+}
+
 func init() {
 	registerBindings(modeHistory, "history", map[ui.Key]string{
 		{ui.Up, 0}:     "up",
@@ -91,19 +129,25 @@ func historyDefault(ed *Editor) {
 // Implementation.
 
 func (ed *Editor) appendHistory(line string) {
-	// TODO: should have a user variable to control the behavior
-	// Do not add command leading by space into history. This is
-	// useful for confidential operations.
-	if strings.HasPrefix(line, " ") {
+	if ed.privateMode() || (ed.historyIgnoreSpace() && strings.HasPrefix(line, " ")) {
+		ed.historyMutex.Lock()
+		ed.lastCmdMeta = nil
+		ed.historyMutex.Unlock()
 		return
 	}
 
 	if ed.daemon != nil && ed.historyFuser != nil {
+		meta := &storedefs.CmdMeta{Time: time.Now().Unix(), Dir: util.Getwd()}
 		ed.historyMutex.Lock()
+		ed.lastCmdMeta = nil
 		ed.daemon.Waits().Add(1)
 		go func() {
 			// TODO(xiaq): Report possible error
 			err := ed.historyFuser.AddCmd(line)
+			if err == nil {
+				meta.Seq = ed.historyFuser.LastSeq()
+				ed.lastCmdMeta = meta
+			}
 			ed.daemon.Waits().Done()
 			ed.historyMutex.Unlock()
 			if err != nil {
@@ -115,6 +159,33 @@ func (ed *Editor) appendHistory(line string) {
 	}
 }
 
+// RecordCmdResult records how long the last accepted command took to run and
+// whether it succeeded, as metadata of its history entry. It also sends a
+// desktop notification if the command took long.
+func (ed *Editor) RecordCmdResult(duration time.Duration, ok bool) {
+	ed.notifyCmdResult(duration, ok)
+	ed.historyArgsCache = nil
+
+	ed.historyMutex.Lock()
+	meta := ed.lastCmdMeta
+	ed.lastCmdMeta = nil
+	ed.historyMutex.Unlock()
+	if meta == nil {
+		return
+	}
+	meta.Duration = duration.Seconds()
+	meta.OK = ok
+
+	ed.daemon.Waits().Add(1)
+	go func() {
+		err := ed.daemon.SetCmdMeta(*meta)
+		ed.daemon.Waits().Done()
+		if err != nil {
+			logger.Println("failed to set cmd metadata:", err)
+		}
+	}()
+}
+
 func (ed *Editor) prevHistory() bool {
 	_, _, err := ed.hist.Prev()
 	return err == nil
@@ -1,5 +1,7 @@
 package history
 
+import "github.com/elves/elvish/store/storedefs"
+
 // Fuser provides a unified view into a shared storage-backed command history
 // and per-session history.
 type Fuser struct {
@@ -37,6 +39,28 @@ func (f *Fuser) AllCmds() ([]string, error) {
 	return append(cmds, f.cmds...), nil
 }
 
+// AllCmdsWithSeq is like AllCmds, but also returns the sequence numbers of the
+// commands.
+func (f *Fuser) AllCmdsWithSeq() ([]storedefs.Cmd, error) {
+	cmds, err := f.store.CmdsWithSeq(0, f.storeUpper)
+	if err != nil {
+		return nil, err
+	}
+	for i, cmd := range f.cmds {
+		cmds = append(cmds, storedefs.Cmd{cmd, f.seqs[i]})
+	}
+	return cmds, nil
+}
+
+// LastSeq returns the sequence number of the last command added in this
+// session, or -1 if there is none.
+func (f *Fuser) LastSeq() int {
+	if len(f.seqs) == 0 {
+		return -1
+	}
+	return f.seqs[len(f.seqs)-1]
+}
+
 func (f *Fuser) SessionCmds() []string {
 	return f.cmds
 }
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+
+	"github.com/elves/elvish/store/storedefs"
 )
 
 func TestNewFuser(t *testing.T) {
@@ -56,6 +58,20 @@ func TestFuser(t *testing.T) {
 		t.Errorf("AllCmds doesn't return all commands")
 	}
 
+	// AllCmdsWithSeq should return the same commands with sequence numbers
+	cmdsWithSeq, err := f.AllCmdsWithSeq()
+	wantCmdsWithSeq := []storedefs.Cmd{
+		{"store 1", 0}, {"session 1", 1}, {"session 2", 4}}
+	if err != nil || !reflect.DeepEqual(cmdsWithSeq, wantCmdsWithSeq) {
+		t.Errorf("AllCmdsWithSeq -> (%v, %v), want (%v, nil)",
+			cmdsWithSeq, err, wantCmdsWithSeq)
+	}
+
+	// LastSeq should return the sequence number of the last session command
+	if seq := f.LastSeq(); seq != 4 {
+		t.Errorf("LastSeq -> %d, want 4", seq)
+	}
+
 	// AllCmds should forward backend storage error
 	mockError = errors.New("another mock error")
 	fuserStore.oneOffError = mockError
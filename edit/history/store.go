@@ -2,6 +2,8 @@ package history
 
 import (
 	"strings"
+
+	"github.com/elves/elvish/store/storedefs"
 )
 
 // Store is the interface of the storage backend.
@@ -9,6 +11,7 @@ type Store interface {
 	NextCmdSeq() (int, error)
 	AddCmd(cmd string) (int, error)
 	Cmds(from, upto int) ([]string, error)
+	CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error)
 	PrevCmd(upto int, prefix string) (int, string, error)
 }
 
@@ -42,6 +45,14 @@ func (s *mockStore) Cmds(from, upto int) ([]string, error) {
 	return s.cmds[from:upto], s.error()
 }
 
+func (s *mockStore) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
+	var cmds []storedefs.Cmd
+	for i := from; i < upto; i++ {
+		cmds = append(cmds, storedefs.Cmd{s.cmds[i], i})
+	}
+	return cmds, s.error()
+}
+
 func (s *mockStore) PrevCmd(upto int, prefix string) (int, string, error) {
 	if s.oneOffError != nil {
 		return -1, "", s.error()
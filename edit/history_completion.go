@@ -0,0 +1,103 @@
+package edit
+
+import (
+	"strings"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/parse"
+)
+
+// Completion of arguments from the command history. When
+// $edit:complete-from-history is true, arguments previously used with the
+// same command are offered after the candidates of the argument completer,
+// most recent first. Commands that are known to have failed are skipped.
+
+var _ = registerVariable("complete-from-history", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.Bool(true), eval.ShouldBeBool)
+})
+
+func (ed *Editor) completeFromHistory() bool {
+	return bool(ed.variables["complete-from-history"].Get().(eval.Bool).Bool())
+}
+
+// maxHistoryArgs is the maximum number of arguments taken from the history.
+const maxHistoryArgs = 100
+
+// withHistoryArgs appends arguments used with the command words[0] in the
+// history to cands, skipping those already in cands.
+func withHistoryArgs(ed *Editor, words []string, cands []rawCandidate) []rawCandidate {
+	if !ed.completeFromHistory() {
+		return cands
+	}
+	seen := make(map[string]bool)
+	for _, cand := range cands {
+		seen[cand.text()] = true
+	}
+	for _, arg := range ed.historyArgs(words[0]) {
+		if !seen[arg] {
+			cands = append(cands, &complexCandidate{
+				stem: arg, codeSuffix: " ", annotation: "history"})
+		}
+	}
+	return cands
+}
+
+// historyArgs returns the arguments used with the command name in the
+// history, most recent first and without duplicates. The result is cached
+// until the next command is run, since finding it takes fetching and parsing
+// the whole history.
+func (ed *Editor) historyArgs(name string) []string {
+	if args, ok := ed.historyArgsCache[name]; ok {
+		return args
+	}
+	cmds, metas, err := getCmdsWithMeta(ed)
+	if err != nil {
+		return nil
+	}
+	var args []string
+	seen := make(map[string]bool)
+	for i := len(cmds) - 1; i >= 0 && len(args) < maxHistoryArgs; i-- {
+		if metas[i] != nil && !metas[i].OK {
+			continue
+		}
+		// Most commands don't mention name at all; don't parse them.
+		if !strings.Contains(cmds[i].Text, name) {
+			continue
+		}
+		for _, arg := range argsOfCmd(cmds[i].Text, name) {
+			if !seen[arg] {
+				seen[arg] = true
+				args = append(args, arg)
+			}
+		}
+	}
+	if ed.historyArgsCache == nil {
+		ed.historyArgsCache = make(map[string][]string)
+	}
+	ed.historyArgsCache[name] = args
+	return args
+}
+
+// argsOfCmd finds the forms in src whose head is name, and returns their
+// arguments that are simple compounds.
+func argsOfCmd(src, name string) []string {
+	n, _ := parse.Parse("[history]", src)
+	var args []string
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		if form, ok := n.(*parse.Form); ok && form.Head != nil {
+			if ok, head, _ := simpleCompound(form.Head, nil); ok && head == name {
+				for _, compound := range form.Args {
+					if ok, arg, _ := simpleCompound(compound, nil); ok && arg != "" {
+						args = append(args, arg)
+					}
+				}
+			}
+		}
+		for _, ch := range n.Children() {
+			walk(ch)
+		}
+	}
+	walk(n)
+	return args
+}
@@ -0,0 +1,46 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+)
+
+var argsOfCmdTests = []struct {
+	src  string
+	name string
+	want []string
+}{
+	{"git commit -m 'fix bug'", "git", []string{"commit", "-m", "fix bug"}},
+	{"ls a | git add b (put c); git log", "git", []string{"add", "b", "log"}},
+	{"echo [git x]", "git", nil},
+	{"ls a", "git", nil},
+}
+
+func TestArgsOfCmd(t *testing.T) {
+	for _, test := range argsOfCmdTests {
+		if got := argsOfCmd(test.src, test.name); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("argsOfCmd(%q, %q) => %q, want %q",
+				test.src, test.name, got, test.want)
+		}
+	}
+}
+
+func TestWithHistoryArgs(t *testing.T) {
+	// The history is not consulted when the arguments are cached.
+	ed := &Editor{variables: makeVariables(),
+		historyArgsCache: map[string][]string{"git": {"log", "commit"}}}
+	cands := withHistoryArgs(ed, []string{"git", ""},
+		[]rawCandidate{&complexCandidate{stem: "commit"}})
+	var texts []string
+	for _, cand := range cands {
+		texts = append(texts, cand.text())
+	}
+	if want := []string{"commit", "log"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("withHistoryArgs => %q, want %q", texts, want)
+	}
+
+	ed.RecordCmdResult(0, true)
+	if ed.historyArgsCache != nil {
+		t.Errorf("RecordCmdResult doesn't clear the cache of history arguments")
+	}
+}
@@ -0,0 +1,128 @@
+package edit
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/store/storedefs"
+)
+
+// Pruning, exporting and importing the command history.
+
+// historyPrune implements the edit:history-prune builtin. It deletes history
+// entries whose text matches &pattern (a regular expression), or that were
+// run more than &older-than seconds ago. Entries without metadata have no
+// time and are never deleted because of their age. It outputs the number of
+// deleted entries.
+func historyPrune(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var pattern eval.String
+	var olderThan float64
+	eval.ScanArgs(args)
+	eval.ScanOpts(opts,
+		eval.Opt{"pattern", &pattern, eval.String("")},
+		eval.Opt{"older-than", &olderThan, eval.String("-1")})
+
+	var re *regexp.Regexp
+	if pattern != "" {
+		var err error
+		re, err = regexp.Compile(string(pattern))
+		maybeThrow(err)
+	}
+	cutoff := int64(-1)
+	if olderThan >= 0 {
+		cutoff = time.Now().Unix() - int64(olderThan)
+	}
+
+	ed := editorOf(ec)
+	cmds, metas, err := getCmdsWithMeta(ed)
+	maybeThrow(err)
+
+	n := 0
+	for i, cmd := range cmds {
+		if (re != nil && re.MatchString(cmd.Text)) ||
+			(cutoff >= 0 && metas[i] != nil && metas[i].Time < cutoff) {
+			maybeThrow(ed.daemon.DelCmd(cmd.Seq))
+			n++
+		}
+	}
+	ec.OutputChan() <- eval.String(strconv.Itoa(n))
+}
+
+// historyRecord is the JSON representation of a history entry used by
+// edit:history-export and edit:history-import.
+type historyRecord struct {
+	Cmd      string   `json:"cmd"`
+	Time     *int64   `json:"time,omitempty"`
+	Dir      *string  `json:"dir,omitempty"`
+	Duration *float64 `json:"duration,omitempty"`
+	OK       *bool    `json:"ok,omitempty"`
+}
+
+// historyExport implements the edit:history-export builtin. It writes the
+// command history as JSON lines, one entry per line.
+func historyExport(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.ScanArgs(args)
+	eval.TakeNoOpt(opts)
+
+	cmds, metas, err := getCmdsWithMeta(editorOf(ec))
+	maybeThrow(err)
+
+	enc := json.NewEncoder(ec.OutputFile())
+	for i, cmd := range cmds {
+		rec := historyRecord{Cmd: cmd.Text}
+		if m := metas[i]; m != nil {
+			rec.Time, rec.Dir, rec.Duration, rec.OK = &m.Time, &m.Dir, &m.Duration, &m.OK
+		}
+		maybeThrow(enc.Encode(rec))
+	}
+}
+
+// historyImport implements the edit:history-import builtin. It reads JSON
+// lines in the format written by edit:history-export from the byte input and
+// appends them to the command history.
+func historyImport(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.ScanArgs(args)
+	eval.TakeNoOpt(opts)
+
+	ed := editorOf(ec)
+	if ed.daemon == nil {
+		throw(ErrStoreOffline)
+	}
+	records, err := readHistoryRecords(bufio.NewReader(ec.InputFile()))
+	maybeThrow(err)
+	for _, rec := range records {
+		seq, err := ed.daemon.AddCmd(rec.Cmd)
+		maybeThrow(err)
+		if rec.Time == nil {
+			continue
+		}
+		meta := storedefs.CmdMeta{Seq: seq, Time: *rec.Time}
+		if rec.Dir != nil {
+			meta.Dir = *rec.Dir
+		}
+		if rec.Duration != nil {
+			meta.Duration = *rec.Duration
+		}
+		if rec.OK != nil {
+			meta.OK = *rec.OK
+		}
+		maybeThrow(ed.daemon.SetCmdMeta(meta))
+	}
+}
+
+func readHistoryRecords(r *bufio.Reader) ([]historyRecord, error) {
+	var records []historyRecord
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var rec historyRecord
+		if err := dec.Decode(&rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
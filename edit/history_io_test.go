@@ -0,0 +1,35 @@
+package edit
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadHistoryRecords(t *testing.T) {
+	input := `{"cmd":"ls"}
+{"cmd":"make","time":1500000000,"dir":"/src","duration":1.5,"ok":false}
+`
+	records, err := readHistoryRecords(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("readHistoryRecords -> error %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("readHistoryRecords -> %d records, want 2", len(records))
+	}
+	if !reflect.DeepEqual(records[0], historyRecord{Cmd: "ls"}) {
+		t.Errorf("records[0] = %v, want only cmd", records[0])
+	}
+	r := records[1]
+	if r.Cmd != "make" || r.Time == nil || *r.Time != 1500000000 ||
+		r.Dir == nil || *r.Dir != "/src" || r.Duration == nil || *r.Duration != 1.5 ||
+		r.OK == nil || *r.OK {
+		t.Errorf("records[1] = %+v, want all fields set", r)
+	}
+
+	_, err = readHistoryRecords(bufio.NewReader(strings.NewReader("{bad")))
+	if err == nil {
+		t.Errorf("readHistoryRecords on bad input -> no error")
+	}
+}
@@ -0,0 +1,126 @@
+package edit
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/store/storedefs"
+)
+
+// Filtering the command history by metadata.
+
+// statusFilter filters history entries by whether the command succeeded.
+type statusFilter int
+
+const (
+	statusAny statusFilter = iota
+	statusOK
+	statusFailed
+)
+
+var errBadStatusFilter = errors.New("status filter must be '', ok or failed")
+
+func parseStatusFilter(s string) statusFilter {
+	switch s {
+	case "":
+		return statusAny
+	case "ok":
+		return statusOK
+	case "failed":
+		return statusFailed
+	}
+	throw(errBadStatusFilter)
+	panic("unreachable")
+}
+
+// match returns whether a history entry with the given metadata passes the
+// directory and status filters. Entries without metadata only pass when there
+// is no filter.
+func (sf statusFilter) match(meta *storedefs.CmdMeta, dir string) bool {
+	if meta == nil {
+		return sf == statusAny && dir == ""
+	}
+	if dir != "" && meta.Dir != dir {
+		return false
+	}
+	switch sf {
+	case statusOK:
+		return meta.OK
+	case statusFailed:
+		return !meta.OK
+	}
+	return true
+}
+
+// getCmdMetas returns the metadata of the commands, which must be sorted by
+// sequence number, with nil for commands without metadata.
+func getCmdMetas(ed *Editor, cmds []storedefs.Cmd) ([]*storedefs.CmdMeta, error) {
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	metas, err := ed.daemon.CmdMetas(cmds[0].Seq, cmds[len(cmds)-1].Seq+1)
+	if err != nil {
+		return nil, err
+	}
+	bySeq := make(map[int]*storedefs.CmdMeta, len(metas))
+	for i := range metas {
+		bySeq[metas[i].Seq] = &metas[i]
+	}
+	result := make([]*storedefs.CmdMeta, len(cmds))
+	for i, cmd := range cmds {
+		result[i] = bySeq[cmd.Seq]
+	}
+	return result, nil
+}
+
+// getCmdsWithMeta returns all commands in the history along with their
+// metadata. If only the metadata cannot be fetched, the commands are still
+// returned along with the error.
+func getCmdsWithMeta(ed *Editor) ([]storedefs.Cmd, []*storedefs.CmdMeta, error) {
+	if ed.daemon == nil || ed.historyFuser == nil {
+		return nil, nil, ErrStoreOffline
+	}
+	ed.historyMutex.RLock()
+	defer ed.historyMutex.RUnlock()
+	cmds, err := ed.historyFuser.AllCmdsWithSeq()
+	if err != nil {
+		return nil, nil, err
+	}
+	metas, err := getCmdMetas(ed, cmds)
+	return cmds, metas, err
+}
+
+// historyEntries implements the edit:history-entries builtin. It outputs maps
+// describing the entries of the command history, optionally filtered by
+// directory and status.
+func historyEntries(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var dir, status eval.String
+	eval.ScanArgs(args)
+	eval.ScanOpts(opts,
+		eval.Opt{"dir", &dir, eval.String("")},
+		eval.Opt{"status", &status, eval.String("")})
+	sf := parseStatusFilter(string(status))
+
+	cmds, metas, err := getCmdsWithMeta(editorOf(ec))
+	maybeThrow(err)
+
+	out := ec.OutputChan()
+	for i, cmd := range cmds {
+		meta := metas[i]
+		if !sf.match(meta, string(dir)) {
+			continue
+		}
+		entry := map[eval.Value]eval.Value{
+			eval.String("seq"): eval.String(strconv.Itoa(cmd.Seq)),
+			eval.String("cmd"): eval.String(cmd.Text),
+		}
+		if meta != nil {
+			entry[eval.String("time")] = eval.String(strconv.FormatInt(meta.Time, 10))
+			entry[eval.String("dir")] = eval.String(meta.Dir)
+			entry[eval.String("duration")] = eval.String(strconv.FormatFloat(meta.Duration, 'g', -1, 64))
+			entry[eval.String("ok")] = eval.Bool(meta.OK)
+		}
+		out <- eval.NewMap(entry)
+	}
+}
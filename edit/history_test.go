@@ -0,0 +1,30 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/store/storedefs"
+)
+
+func TestPrivateMode(t *testing.T) {
+	ed := &Editor{variables: makeVariables()}
+	if ed.privateMode() {
+		t.Errorf("private mode on by default")
+	}
+	togglePrivateMode(ed)
+	if !ed.privateMode() {
+		t.Errorf("togglePrivateMode doesn't turn private mode on")
+	}
+
+	// Commands accepted in private mode do not get metadata recorded.
+	ed.lastCmdMeta = &storedefs.CmdMeta{Seq: 1}
+	ed.appendHistory("echo secret")
+	if ed.lastCmdMeta != nil {
+		t.Errorf("appendHistory in private mode leaves lastCmdMeta")
+	}
+
+	togglePrivateMode(ed)
+	if ed.privateMode() {
+		t.Errorf("togglePrivateMode doesn't turn private mode off")
+	}
+}
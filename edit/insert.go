@@ -91,6 +91,7 @@ func init() {
 		{'1', ui.Alt}:  "lastcmd:start",
 		{'L', ui.Ctrl}: "loc:start",
 		{'V', ui.Ctrl}: "insert-raw",
+		{'h', ui.Alt}:  "show-doc",
 
 		ui.Default: "insert:default",
 	})
@@ -324,6 +325,10 @@ func insertKey(ed *Editor) {
 }
 
 func returnLine(ed *Editor) {
+	if forms := ed.formsToConfirm(ed.line); len(forms) > 0 {
+		ed.confirmReturnLine(forms)
+		return
+	}
 	ed.nextAction = action{typ: exitReadLine, returnLine: ed.line}
 }
 
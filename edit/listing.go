@@ -53,6 +53,9 @@ type listing struct {
 	filter      string
 	pagesize    int
 	headerWidth int
+	// The entry shown on each line when the listing was last rendered. Used
+	// for mapping mouse clicks to entries.
+	shown []int
 }
 
 type listingProvider interface {
@@ -68,7 +71,7 @@ type Placeholderer interface {
 }
 
 func newListing(t string, p listingProvider) listing {
-	l := listing{t, p, 0, "", 0, 0}
+	l := listing{t, p, 0, "", 0, 0, nil}
 	l.changeFilter("")
 	for i := 0; i < p.Len(); i++ {
 		header, _ := p.Show(i)
@@ -114,6 +117,7 @@ func (l *listing) CursorOnModeLine() bool {
 func (l *listing) List(maxHeight int) renderer {
 	n := l.provider.Len()
 	if n == 0 {
+		l.shown = nil
 		var ph string
 		if pher, ok := l.provider.(Placeholderer); ok {
 			ph = pher.Placeholder()
@@ -133,6 +137,9 @@ func (l *listing) List(maxHeight int) renderer {
 	high := low
 	height := 0
 	var listOfLines list.List
+	// The entries of the lines prepended, in reverse order, and those of the
+	// lines appended.
+	var shownLow, shownHigh []int
 	getEntry := func(i int) []ui.Styled {
 		header, content := l.provider.Show(i)
 		lines := strings.Split(content.Text, "\n")
@@ -166,6 +173,7 @@ func (l *listing) List(maxHeight int) renderer {
 			// Prepend at most the last (height - maxHeight) lines.
 			for i = len(entry) - 1; i >= 0 && height < maxHeight; i-- {
 				listOfLines.PushFront(entry[i])
+				shownLow = append(shownLow, low)
 				height++
 			}
 			if i >= 0 {
@@ -176,6 +184,7 @@ func (l *listing) List(maxHeight int) renderer {
 			// Append at most the first (height - maxHeight) lines.
 			for i = 0; i < len(entry) && height < maxHeight; i++ {
 				listOfLines.PushBack(entry[i])
+				shownHigh = append(shownHigh, high)
 				height++
 			}
 			if i < len(entry) {
@@ -188,6 +197,11 @@ func (l *listing) List(maxHeight int) renderer {
 	}
 
 	l.pagesize = high - low
+	l.shown = l.shown[:0]
+	for i := len(shownLow) - 1; i >= 0; i-- {
+		l.shown = append(l.shown, shownLow[i])
+	}
+	l.shown = append(l.shown, shownHigh...)
 
 	// Convert the List to a slice.
 	lines := make([]ui.Styled, 0, listOfLines.Len())
@@ -312,6 +326,13 @@ func (l *listing) pageDown() {
 	}
 }
 
+// click selects the entry shown on the given line of the listing.
+func (l *listing) click(line, col, width, height int) {
+	if line < len(l.shown) {
+		l.selected = l.shown[line]
+	}
+}
+
 func (l *listing) accept(ed *Editor) {
 	if l.selected >= 0 {
 		l.provider.Accept(l.selected, ed)
@@ -1,7 +1,9 @@
 package edit
 
 import (
+	"errors"
 	"strings"
+	"unicode"
 
 	"github.com/elves/elvish/eval"
 	"github.com/elves/elvish/util"
@@ -15,10 +17,56 @@ func (ed *Editor) useSubseqMatcher() bool {
 	return bool(ed.variables["-use-subseq-matcher"].Get().(eval.Bool).Bool())
 }
 
+// $edit:completion-case controls how completion candidates are matched
+// against what has been typed: "sensitive" matches case-sensitively,
+// "insensitive" ignores case, and "smart" ignores case unless what has been
+// typed contains an uppercase letter.
+
+var errBadCompletionCase = errors.New("must be sensitive, insensitive or smart")
+
+var _ = registerVariable("completion-case", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.String("sensitive"), func(v eval.Value) error {
+		switch v {
+		case eval.String("sensitive"), eval.String("insensitive"), eval.String("smart"):
+			return nil
+		}
+		return errBadCompletionCase
+	})
+})
+
+func (ed *Editor) completionCase() string {
+	return string(ed.variables["completion-case"].Get().(eval.String))
+}
+
 func (ed *Editor) matcher() func(string, string) bool {
+	match := strings.HasPrefix
 	if ed.useSubseqMatcher() {
-		return util.HasSubseq
-	} else {
-		return strings.HasPrefix
+		match = util.HasSubseq
+	}
+	switch ed.completionCase() {
+	case "insensitive":
+		return foldMatcher(match)
+	case "smart":
+		return smartCaseMatcher(match)
+	}
+	return match
+}
+
+// foldMatcher makes a case-insensitive matcher from a matcher.
+func foldMatcher(match func(string, string) bool) func(string, string) bool {
+	return func(s, p string) bool {
+		return match(strings.ToLower(s), strings.ToLower(p))
+	}
+}
+
+// smartCaseMatcher makes a matcher that is case-insensitive when the pattern
+// has no uppercase letters.
+func smartCaseMatcher(match func(string, string) bool) func(string, string) bool {
+	fold := foldMatcher(match)
+	return func(s, p string) bool {
+		if strings.IndexFunc(p, unicode.IsUpper) == -1 {
+			return fold(s, p)
+		}
+		return match(s, p)
 	}
 }
@@ -0,0 +1,47 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+var caseMatcherTests = []struct {
+	s, p                string
+	wantFold, wantSmart bool
+}{
+	{"Documents", "doc", true, true},
+	{"Documents", "Doc", true, true},
+	{"documents", "Doc", true, false},
+	{"documents", "doc", true, true},
+	{"Music", "doc", false, false},
+}
+
+func TestCaseMatchers(t *testing.T) {
+	fold := foldMatcher(strings.HasPrefix)
+	smart := smartCaseMatcher(strings.HasPrefix)
+	for _, test := range caseMatcherTests {
+		if got := fold(test.s, test.p); got != test.wantFold {
+			t.Errorf("fold(%q, %q) => %v, want %v", test.s, test.p, got, test.wantFold)
+		}
+		if got := smart(test.s, test.p); got != test.wantSmart {
+			t.Errorf("smart(%q, %q) => %v, want %v", test.s, test.p, got, test.wantSmart)
+		}
+	}
+	if !smartCaseMatcher(util.HasSubseq)("Documents", "dcs") {
+		t.Errorf("smart subsequence matcher does not match Documents with dcs")
+	}
+}
+
+func TestCompletionCase(t *testing.T) {
+	ed := &Editor{variables: makeVariables()}
+	if ed.matcher()("Documents", "doc") {
+		t.Errorf("default matcher is not case-sensitive")
+	}
+	ed.variables["completion-case"].Set(eval.String("smart"))
+	if !ed.matcher()("Documents", "doc") {
+		t.Errorf("smart-case matcher does not match Documents with doc")
+	}
+}
@@ -0,0 +1,206 @@
+package edit
+
+import (
+	"errors"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/elves/elvish/edit/tty"
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/eval"
+)
+
+// The minibuffer asks the user one-line questions. While the editor is reading
+// a line, modes and builtins start it with startMinibuffer; it takes the place
+// of the mode line, leaving the main buffer as it is, and calls back when the
+// user accepts or cancels. When the editor is not reading a line, for
+// instance while a command is running, ReadMinibuffer asks a question on the
+// current line of the terminal. Both use the bindings of the minibuffer mode.
+
+var (
+	errNotMinibuffer       = errors.New("not in the minibuffer")
+	errEditorActive        = errors.New("editor active")
+	errMinibufferCancelled = errors.New("cancelled")
+)
+
+var _ = registerBuiltins(modeMinibuffer, map[string]func(*Editor){
+	"backspace": func(ed *Editor) { getMinibuffer(ed).backspace() },
+	"kill-left": func(ed *Editor) { getMinibuffer(ed).text = "" },
+	"accept":    func(ed *Editor) { getMinibuffer(ed).finish(ed, true) },
+	"cancel":    func(ed *Editor) { getMinibuffer(ed).finish(ed, false) },
+	"default":   minibufferDefault,
+})
+
+func init() {
+	registerBindings(modeMinibuffer, modeMinibuffer, map[ui.Key]string{
+		{ui.Backspace, 0}: "backspace",
+		{'U', ui.Ctrl}:    "kill-left",
+		{ui.Enter, 0}:     "accept",
+		{'[', ui.Ctrl}:    "cancel",
+		{'G', ui.Ctrl}:    "cancel",
+		ui.Default:        "default",
+	})
+}
+
+type minibuffer struct {
+	prompt string
+	text   string
+	// Whether the text is kept from being shown.
+	secret bool
+	// The mode to return to.
+	prev Mode
+	// Called with the text and whether the user has accepted it.
+	done func(ed *Editor, text string, ok bool)
+}
+
+func (*minibuffer) Binding(k ui.Key) eval.CallableValue {
+	return getBinding(modeMinibuffer, k)
+}
+
+func (mb *minibuffer) ModeLine() renderer {
+	return modeLineRenderer{" " + mb.prompt + " ", mb.shownText()}
+}
+
+func (mb *minibuffer) shownText() string {
+	if mb.secret {
+		return ""
+	}
+	return mb.text
+}
+
+func (*minibuffer) CursorOnModeLine() bool {
+	return true
+}
+
+// startMinibuffer asks a question in the minibuffer. When the user accepts or
+// cancels, the previous mode is restored and done is called.
+func (ed *Editor) startMinibuffer(prompt string, done func(*Editor, string, bool)) {
+	ed.mode = &minibuffer{prompt: prompt, prev: ed.mode, done: done}
+}
+
+func (mb *minibuffer) backspace() {
+	_, size := utf8.DecodeLastRuneInString(mb.text)
+	mb.text = mb.text[:len(mb.text)-size]
+}
+
+func (mb *minibuffer) finish(ed *Editor, ok bool) {
+	ed.mode = mb.prev
+	text := mb.text
+	if !ok {
+		text = ""
+	}
+	mb.done(ed, text, ok)
+}
+
+func minibufferDefault(ed *Editor) {
+	mb := getMinibuffer(ed)
+	if likeChar(ed.lastKey) {
+		mb.text += string(ed.lastKey.Rune)
+	} else {
+		ed.flash()
+	}
+}
+
+func getMinibuffer(ed *Editor) *minibuffer {
+	if mb, ok := ed.mode.(*minibuffer); ok {
+		return mb
+	}
+	throw(errNotMinibuffer)
+	panic("unreachable")
+}
+
+// minibufferStart implements edit:minibuffer:start, which asks a question in
+// the minibuffer and calls f with the answer if the user accepts it.
+func minibufferStart(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var (
+		prompt eval.String
+		f      eval.CallableValue
+	)
+	eval.ScanArgs(args, &prompt, &f)
+	eval.TakeNoOpt(opts)
+
+	ed, ok := ec.Editor.(*Editor)
+	if !ok {
+		throw(errEditorInvalid)
+	}
+	if !ed.active {
+		throw(errEditorInactive)
+	}
+	ed.startMinibuffer(string(prompt), func(ed *Editor, text string, ok bool) {
+		if ok {
+			ed.CallFn(f, eval.String(text))
+		}
+	})
+}
+
+// ReadMinibuffer asks a question while the editor is not reading a line, and
+// returns the answer. It fails if the user cancels with one of the cancel
+// keys or Ctrl-C.
+func (ed *Editor) ReadMinibuffer(prompt string) (string, error) {
+	return ed.readMinibuffer(prompt, false)
+}
+
+// Ask implements eval.Asker with the minibuffer. Secret answers are not shown
+// as they are typed.
+func (ed *Editor) Ask(prompt string, secret bool) (string, error) {
+	return ed.readMinibuffer(prompt, secret)
+}
+
+func (ed *Editor) readMinibuffer(prompt string, secret bool) (string, error) {
+	ed.activeMutex.Lock()
+	if ed.active {
+		ed.activeMutex.Unlock()
+		return "", errEditorActive
+	}
+	savedTermios, err := setupTerminal(ed.in)
+	if err != nil {
+		ed.activeMutex.Unlock()
+		return "", err
+	}
+	ed.active = true
+	ed.activeMutex.Unlock()
+
+	defer func() {
+		ed.activeMutex.Lock()
+		defer ed.activeMutex.Unlock()
+		ed.active = false
+		ed.mode = nil
+		ed.lastKey = ui.Key{}
+		savedTermios.ApplyToFd(int(ed.in.Fd()))
+	}()
+
+	go ed.reader.Run()
+	defer ed.reader.Quit()
+
+	var (
+		answer   string
+		accepted bool
+		finished bool
+	)
+	mb := &minibuffer{prompt: prompt, secret: secret,
+		done: func(_ *Editor, text string, ok bool) {
+			answer, accepted, finished = text, ok, true
+		}}
+	ed.mode = mb
+	for !finished {
+		ed.out.WriteString("\r\033[K" + mb.prompt + " " + mb.shownText())
+		select {
+		case sig := <-ed.sigs:
+			if sig == syscall.SIGINT {
+				mb.finish(ed, false)
+			}
+		case unit := <-ed.reader.UnitChan():
+			if k, ok := unit.(tty.Key); ok {
+				ed.lastKey = ui.Key(k)
+				if fn := ed.mode.Binding(ed.lastKey); fn != nil {
+					ed.CallFn(fn)
+				}
+			}
+		}
+	}
+	ed.out.WriteString("\n")
+	if !accepted {
+		return "", errMinibufferCancelled
+	}
+	return answer, nil
+}
@@ -0,0 +1,70 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/edit/ui"
+)
+
+func TestMinibuffer(t *testing.T) {
+	ed := &Editor{variables: makeVariables()}
+	ed.mode = &ed.insert
+
+	var (
+		gotText string
+		gotOK   bool
+		calls   int
+	)
+	done := func(_ *Editor, text string, ok bool) {
+		gotText, gotOK = text, ok
+		calls++
+	}
+	press := func(k ui.Key) {
+		ed.lastKey = k
+		ed.CallFn(ed.mode.Binding(k))
+	}
+	typeText := func(s string) {
+		for _, r := range s {
+			press(ui.Key{r, 0})
+		}
+	}
+
+	ed.line = "echo main"
+	ed.startMinibuffer("name?", done)
+	typeText("abcé")
+	press(ui.Key{ui.Backspace, 0})
+	if mb := getMinibuffer(ed); mb.text != "abc" {
+		t.Errorf("minibuffer text is %q, want %q", mb.text, "abc")
+	}
+	press(ui.Key{ui.Enter, 0})
+	if calls != 1 || gotText != "abc" || !gotOK {
+		t.Errorf("accepting called done %d times with (%q, %v), want once with (%q, true)",
+			calls, gotText, gotOK, "abc")
+	}
+	if ed.mode != &ed.insert {
+		t.Errorf("mode after accepting is %v, want insert mode", ed.mode)
+	}
+	if ed.line != "echo main" {
+		t.Errorf("main buffer changed to %q", ed.line)
+	}
+
+	ed.startMinibuffer("name?", done)
+	typeText("xyz")
+	press(ui.Key{'U', ui.Ctrl})
+	typeText("w")
+	press(ui.Key{'G', ui.Ctrl})
+	if calls != 2 || gotText != "" || gotOK {
+		t.Errorf("cancelling called done %d times with (%q, %v), want twice with (\"\", false)",
+			calls, gotText, gotOK)
+	}
+	if ed.mode != &ed.insert {
+		t.Errorf("mode after cancelling is %v, want insert mode", ed.mode)
+	}
+}
+
+func TestMinibuffer_Secret(t *testing.T) {
+	mb := &minibuffer{prompt: "password:", text: "hunter2", secret: true}
+	if ml := mb.ModeLine().(modeLineRenderer); ml.filter != "" {
+		t.Errorf("secret minibuffer shows %q, want nothing", ml.filter)
+	}
+}
@@ -17,7 +17,9 @@ const (
 	modeHistoryListing = "histlist"
 	modeLastCmd        = "lastcmd"
 	modeLocation       = "loc"
-	modeListing        = "listing" // A "super mode" for histlist, lastcmd, loc
+	modePager          = "pager"
+	modeMinibuffer     = "minibuffer"
+	modeListing        = "listing" // A "super mode" for histlist, lastcmd, loc, pager
 )
 
 // Mode is an editor mode.
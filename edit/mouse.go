@@ -0,0 +1,114 @@
+package edit
+
+import (
+	"github.com/elves/elvish/edit/tty"
+	"github.com/elves/elvish/eval"
+)
+
+// Mouse support. When $edit:mouse is true, the editor turns on mouse
+// tracking of the terminal. In completion, navigation and listing modes, the
+// wheel moves the selection, and clicking an entry selects it. Terminals that
+// do not support mouse tracking simply ignore the request.
+//
+// Mouse events report positions on the screen, while the editor only knows
+// where it has drawn relative to the cursor. To map a click to an entry, the
+// editor asks the terminal for the cursor position, and handles the click
+// when the report arrives.
+
+var _ = registerVariable("mouse", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.Bool(false), eval.ShouldBeBool)
+})
+
+func (ed *Editor) mouse() bool {
+	return bool(ed.variables["mouse"].Get().(eval.Bool).Bool())
+}
+
+// Sequences for turning SGR-style mouse tracking on and off.
+const (
+	mouseOn  = "\033[?1000;1006h"
+	mouseOff = "\033[?1000;1006l"
+	// Request for a cursor position report.
+	cursorPositionRequest = "\033[6n"
+)
+
+// listingLayout records where the listing was rendered in the buffer of the
+// editor.
+type listingLayout struct {
+	// The line of the buffer the listing starts at.
+	top int
+	// The line of the buffer the cursor is on.
+	dotLine int
+	// The width of the listing and the height it was rendered under. A height
+	// of 0 means there is no listing.
+	width, height int
+}
+
+// clicker is a mode that can select an entry of its listing with the mouse.
+// The line and column are 0-based and relative to the listing.
+type clicker interface {
+	click(line, col, width, height int)
+}
+
+type upDowner interface {
+	up(cycle bool)
+	down(cycle bool)
+}
+
+// handleMouse handles a mouse event. Only wheel events and presses of the left
+// button are handled; other events are ignored.
+func (ed *Editor) handleMouse(e tty.MouseEvent) {
+	if !e.Down {
+		return
+	}
+	if e.Button == 0 {
+		if _, ok := ed.mode.(clicker); ok && ed.listingLayout.height > 0 {
+			ed.pendingClick = &e
+			ed.out.WriteString(cursorPositionRequest)
+		}
+		return
+	}
+	if e.Button != tty.MouseWheelUp && e.Button != tty.MouseWheelDown {
+		return
+	}
+	up := e.Button == tty.MouseWheelUp
+	switch mode := ed.mode.(type) {
+	case *completion:
+		if up {
+			mode.prev(false)
+		} else {
+			mode.next(false)
+		}
+	case *navigation:
+		if up {
+			mode.prev()
+		} else {
+			mode.next()
+		}
+	case upDowner:
+		if up {
+			mode.up(false)
+		} else {
+			mode.down(false)
+		}
+	}
+}
+
+// handleCursorPosition handles a cursor position report by handling the click
+// that requested it, if any.
+func (ed *Editor) handleCursorPosition(p tty.Pos) {
+	e := ed.pendingClick
+	if e == nil {
+		return
+	}
+	ed.pendingClick = nil
+	mode, ok := ed.mode.(clicker)
+	layout := ed.listingLayout
+	if !ok || layout.height == 0 {
+		return
+	}
+	line := e.Line() - p.Line() + layout.dotLine - layout.top
+	col := e.Col() - 1
+	if 0 <= line && line < layout.height && 0 <= col && col < layout.width {
+		mode.click(line, col, layout.width, layout.height)
+	}
+}
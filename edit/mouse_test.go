@@ -0,0 +1,71 @@
+package edit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elves/elvish/edit/ui"
+)
+
+func TestListingClick(t *testing.T) {
+	l := newListing(mode, p)
+	l.selected = 4
+	l.List(2)
+	l.click(0, 0, 10, 2)
+	if l.selected != 3 {
+		t.Errorf("clicking line 0 selected %d, want 3", l.selected)
+	}
+	l.click(5, 0, 10, 2)
+	if l.selected != 3 {
+		t.Errorf("clicking below the entries changed selection to %d", l.selected)
+	}
+}
+
+func TestCompletionClick(t *testing.T) {
+	c := &completion{}
+	for _, s := range []string{"a", "b", "c", "d"} {
+		c.filtered = append(c.filtered, &candidate{s, ui.Unstyled(s), ""})
+	}
+	// Each candidate takes 3 columns with the margins, and they all fit in
+	// one line.
+	c.ListRender(20, 2)
+	c.click(0, 7, 20, 2)
+	if c.selected != 2 {
+		t.Errorf("clicking column 7 selected %d, want 2", c.selected)
+	}
+	c.click(0, 15, 20, 2)
+	if c.selected != 2 {
+		t.Errorf("clicking past the candidates changed selection to %d", c.selected)
+	}
+}
+
+func TestNavigationClick(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+	for _, name := range []string{"a", "b", "c"} {
+		ioutil.WriteFile(filepath.Join(tmpdir, name), nil, 0644)
+	}
+	os.Chdir(tmpdir)
+
+	n := &navigation{}
+	n.refresh()
+	render(n.List(10), 40)
+	// Find the left edge of the current column by clicking along the line.
+	for col := 0; col < 40 && n.current.selectedName() != "c"; col++ {
+		n.click(2, col, 40, 10)
+	}
+	if name := n.current.selectedName(); name != "c" {
+		t.Errorf("clicking line 2 of the current column selected %q, want c", name)
+	}
+	n.click(0, 0, 40, 10)
+	if name := n.current.selectedName(); name != "c" {
+		t.Errorf("clicking the parent column changed selection to %q", name)
+	}
+}
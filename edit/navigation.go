@@ -344,6 +344,24 @@ func (n *navigation) List(maxHeight int) renderer {
 	)
 }
 
+// click selects the file shown at the given position of the listing, if it is
+// in the current column. The layout is worked out the same way as in
+// navRenderer.
+func (n *navigation) click(line, col, width, height int) {
+	margin := navigationListingColMargin
+	ws := distributeWidths(width-margin*2,
+		[]float64{parentColumnWeight, currentColumnWeight, previewColumnWeight},
+		[]int{n.parent.FullWidth(height), n.current.FullWidth(height),
+			n.preview.FullWidth(height)},
+	)
+	left := ws[0] + margin
+	if col < left || col >= left+ws[1] || line >= len(n.current.shown) {
+		return
+	}
+	n.current.selected = n.current.shown[line]
+	n.refresh()
+}
+
 // navColumn is a column in the navigation layout.
 type navColumn struct {
 	listing
@@ -6,6 +6,7 @@ import (
 	"github.com/elves/elvish/edit/nodeutil"
 	"github.com/elves/elvish/eval"
 	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
 )
 
 // Utilities for insepcting the AST. Used for completers and stylists.
@@ -50,20 +51,32 @@ func purelyEvalPrimary(pn *parse.Primary, ev *eval.Evaler) eval.Value {
 	return nil
 }
 
-// leafNodeAtDot finds the leaf node at a specific position. It returns nil if
-// position is out of bound.
-func findLeafNode(n parse.Node, p int) parse.Node {
-descend:
-	for len(n.Children()) > 0 {
-		for _, ch := range n.Children() {
-			if ch.Begin() <= p && p <= ch.End() {
-				n = ch
-				continue descend
+// purelyIndex indexes a value, returning nil if v is nil, cannot be indexed or
+// does not have the index.
+func purelyIndex(v, index eval.Value) (result eval.Value) {
+	indexer, ok := v.(eval.IndexOneer)
+	if !ok {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(util.Thrown); !ok {
+				panic(r)
 			}
+			result = nil
 		}
+	}()
+	return indexer.IndexOne(index)
+}
+
+// findLeafNode finds the leaf node at a specific position. It returns nil if
+// position is out of bound.
+func findLeafNode(n parse.Node, p int) parse.Node {
+	path := parse.NodeAt(n, p)
+	if path == nil {
 		return nil
 	}
-	return n
+	return path[len(path)-1]
 }
 
 func wordify(src string) []string {
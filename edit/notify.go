@@ -0,0 +1,104 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/elves/elvish/eval"
+)
+
+// Desktop notifications for long-running commands. When a command runs for at
+// least $edit:notify-threshold seconds, $edit:notify-fn is called with the
+// command, its duration in seconds and whether it succeeded. The default
+// notifier writes an OSC 9 sequence, or an OSC 777 one if $edit:notify-osc is
+// 777, which terminals that support them turn into desktop notifications.
+//
+// Whether the terminal has focus is not taken into account: the command owns
+// the terminal while it runs, so focus changes can't be observed.
+
+var errBadNotifyOSC = errors.New("must be 9 or 777")
+
+var _ = registerVariable("notify-threshold", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.String("10"), shouldBeSeconds)
+})
+
+func shouldBeSeconds(v eval.Value) error {
+	s, ok := v.(eval.String)
+	if !ok {
+		return errMustBeString
+	}
+	_, err := strconv.ParseFloat(string(s), 64)
+	return err
+}
+
+func (ed *Editor) notifyThreshold() time.Duration {
+	s := ed.variables["notify-threshold"].Get().(eval.String)
+	f, _ := strconv.ParseFloat(string(s), 64)
+	return time.Duration(f * float64(time.Second))
+}
+
+var _ = registerVariable("notify-osc", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(eval.String("9"), func(v eval.Value) error {
+		if v != eval.String("9") && v != eval.String("777") {
+			return errBadNotifyOSC
+		}
+		return nil
+	})
+})
+
+var _ = registerVariable("notify-fn", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(
+		&eval.BuiltinFn{"default notifier", defaultNotifier}, eval.ShouldBeFn)
+})
+
+// notifyMessage returns the body of the notification for a command.
+func notifyMessage(cmd string, duration time.Duration, ok bool) string {
+	status := "finished"
+	if !ok {
+		status = "failed"
+	}
+	return fmt.Sprintf("%s %s after %s", cmd, status,
+		duration-duration%time.Second)
+}
+
+// notifySeq returns the escape sequence that asks the terminal to show a
+// desktop notification, using OSC 9 or OSC 777.
+func notifySeq(osc string, msg string) string {
+	if osc == "777" {
+		return "\033]777;notify;elvish;" + msg + "\a"
+	}
+	return "\033]9;" + msg + "\a"
+}
+
+func defaultNotifier(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var (
+		cmd     eval.String
+		seconds float64
+		ok      eval.Bool
+	)
+	eval.ScanArgs(args, &cmd, &seconds, &ok)
+	eval.TakeNoOpt(opts)
+
+	ed, isEd := ec.Editor.(*Editor)
+	if !isEd {
+		throw(errEditorInvalid)
+	}
+	osc := string(ed.variables["notify-osc"].Get().(eval.String))
+	msg := notifyMessage(string(cmd),
+		time.Duration(seconds*float64(time.Second)), bool(ok))
+	ed.out.WriteString(notifySeq(osc, msg))
+}
+
+// notifyCmdResult calls $edit:notify-fn if the last accepted command ran long
+// enough.
+func (ed *Editor) notifyCmdResult(duration time.Duration, ok bool) {
+	if duration < ed.notifyThreshold() {
+		return
+	}
+	fn := ed.variables["notify-fn"].Get()
+	callHooks(ed.evaler, eval.NewList(fn), eval.String(ed.lastLine),
+		eval.String(strconv.FormatFloat(duration.Seconds(), 'f', -1, 64)),
+		eval.Bool(ok))
+}
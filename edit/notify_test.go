@@ -0,0 +1,29 @@
+package edit
+
+import (
+	"testing"
+	"time"
+)
+
+var notifySeqTests = []struct {
+	osc      string
+	cmd      string
+	duration time.Duration
+	ok       bool
+	want     string
+}{
+	{"9", "make", 12500 * time.Millisecond, true,
+		"\033]9;make finished after 12s\a"},
+	{"777", "make test", time.Minute, false,
+		"\033]777;notify;elvish;make test failed after 1m0s\a"},
+}
+
+func TestNotifySeq(t *testing.T) {
+	for _, test := range notifySeqTests {
+		got := notifySeq(test.osc, notifyMessage(test.cmd, test.duration, test.ok))
+		if got != test.want {
+			t.Errorf("notifySeq(%q, notifyMessage(%q, %v, %v)) => %q, want %q",
+				test.osc, test.cmd, test.duration, test.ok, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package edit
+
+import (
+	"strings"
+
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/eval"
+)
+
+// Pager mode, for showing text that may not fit in the screen. It is a listing
+// mode with one entry per line and less-like key bindings. The editor uses it
+// for long documentation, for many outputs of functions called from key
+// bindings, and for edit:page.
+
+var _ = registerBuiltins(modePager, map[string]func(*Editor){
+	"quit":    pagerQuit,
+	"default": pagerDefault,
+})
+
+func init() {
+	registerBindings(modePager, modePager, map[ui.Key]string{
+		{'j', 0}:   "listing:down",
+		{'k', 0}:   "listing:up",
+		{' ', 0}:   "listing:page-down",
+		{'b', 0}:   "listing:page-up",
+		{'q', 0}:   "quit",
+		ui.Default: "default",
+	})
+}
+
+// pagerTallThreshold is the number of lines above which text shown by the
+// editor is put in the pager instead of tips.
+const pagerTallThreshold = 10
+
+type pager struct {
+	title string
+	lines []string
+	// The mode to go back to when leaving the pager.
+	prev Mode
+}
+
+func newPager(title, text string) *listing {
+	p := &pager{title, strings.Split(strings.TrimRight(text, "\n"), "\n"), nil}
+	l := newListing(modePager, p)
+	return &l
+}
+
+func (p *pager) ModeTitle(int) string {
+	return " " + p.title + " "
+}
+
+func (p *pager) Len() int {
+	return len(p.lines)
+}
+
+func (p *pager) Show(i int) (string, ui.Styled) {
+	return "", ui.Unstyled(p.lines[i])
+}
+
+// Filter does nothing; the pager is never filtered.
+func (p *pager) Filter(string) int {
+	return 0
+}
+
+func (p *pager) Accept(int, *Editor) {
+}
+
+// pagerQuit closes the pager and goes back to the mode it was started from.
+func pagerQuit(ed *Editor) {
+	var prev Mode
+	if l, ok := ed.mode.(*listing); ok {
+		if p, ok := l.provider.(*pager); ok {
+			prev = p.prev
+		}
+	}
+	if prev == nil {
+		insertStart(ed)
+	} else {
+		ed.mode = prev
+	}
+}
+
+// pagerDefault closes the pager and handles the key in the mode it was started
+// from.
+func pagerDefault(ed *Editor) {
+	pagerQuit(ed)
+	ed.nextAction = action{typ: reprocessKey}
+}
+
+// showText shows text to the user, in tips if it is short and in the pager
+// otherwise.
+func (ed *Editor) showText(title, text string) {
+	if strings.Count(text, "\n") < pagerTallThreshold {
+		ed.addTip("%s", text)
+		return
+	}
+	ed.startPager(title, text)
+}
+
+// showOutputs shows the outputs of a function called from the editor, as
+// notifications if there are few of them and in the pager otherwise.
+func (ed *Editor) showOutputs(outputs []string) {
+	if len(outputs) < pagerTallThreshold {
+		for _, output := range outputs {
+			ed.Notify("%s", output)
+		}
+		return
+	}
+	ed.startPager("OUTPUT", strings.Join(outputs, "\n"))
+}
+
+// startPager shows text in the pager. When the user leaves the pager, the
+// current mode is restored.
+func (ed *Editor) startPager(title, text string) {
+	l := newPager(title, text)
+	l.provider.(*pager).prev = ed.mode
+	ed.mode = l
+}
+
+// page implements the edit:page builtin, which shows its argument or its
+// value inputs as lines in the pager.
+func page(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoOpt(opts)
+	iterate := eval.ScanArgsAndOptionalIterate(ec, args)
+	var lines []string
+	iterate(func(v eval.Value) {
+		lines = append(lines, eval.ToString(v))
+	})
+	ed, ok := ec.Editor.(*Editor)
+	if !ok {
+		throw(errEditorInvalid)
+	}
+	if !ed.active {
+		throw(errEditorInactive)
+	}
+	ed.startPager("PAGER", strings.Join(lines, "\n"))
+}
@@ -0,0 +1,62 @@
+package edit
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/edit/ui"
+)
+
+var (
+	thePager = newPager("PAGER", "line 1\nline 2\n\nline 4\n")
+
+	pagerFilterTests = []listingFilterTestCases{
+		{"", []shown{
+			{"", ui.Unstyled("line 1")},
+			{"", ui.Unstyled("line 2")},
+			{"", ui.Unstyled("")},
+			{"", ui.Unstyled("line 4")}}},
+	}
+)
+
+func TestPager(t *testing.T) {
+	testListingFilter(t, "thePager", thePager, pagerFilterTests)
+}
+
+func TestPagerQuit(t *testing.T) {
+	ed := &Editor{variables: makeVariables()}
+	ed.mode = &ed.insert
+	ed.startMinibuffer("name?", func(*Editor, string, bool) {})
+	mb := ed.mode
+
+	ed.startPager("PAGER", "line 1\nline 2")
+	if _, ok := ed.mode.(*listing); !ok {
+		t.Fatalf("startPager sets mode to %T, want pager", ed.mode)
+	}
+	ed.lastKey = ui.Key{'q', 0}
+	ed.CallFn(ed.mode.Binding(ed.lastKey))
+	if ed.mode != mb {
+		t.Errorf("quitting the pager sets mode to %T, want the minibuffer", ed.mode)
+	}
+}
+
+func TestShowOutputs(t *testing.T) {
+	ed := &Editor{variables: makeVariables()}
+	ed.mode = &ed.insert
+
+	ed.showOutputs([]string{"a", "b"})
+	if ed.mode != &ed.insert || len(ed.notifications) != 2 {
+		t.Errorf("few outputs: mode %T, %d notifications, want insert mode and 2",
+			ed.mode, len(ed.notifications))
+	}
+
+	outputs := make([]string, pagerTallThreshold)
+	for i := range outputs {
+		outputs[i] = "[value output] x"
+	}
+	ed.showOutputs(outputs)
+	l, ok := ed.mode.(*listing)
+	if !ok || l.provider.Len() != pagerTallThreshold {
+		t.Errorf("many outputs: mode %T, want the pager with %d lines",
+			ed.mode, pagerTallThreshold)
+	}
+}
@@ -61,3 +61,28 @@ var _ = registerVariable("rprompt-persistent", func() eval.Variable {
 func (ed *Editor) rpromptPersistent() bool {
 	return bool(ed.variables["rprompt-persistent"].Get().(eval.Bool).Bool())
 }
+
+// $edit:transient-prompt replaces the prompt of an accepted line, keeping the
+// scrollback compact when the prompt is long or spans multiple lines. By
+// default, the prompt is kept as is.
+var _ = registerVariable("transient-prompt", func() eval.Variable {
+	return eval.NewPtrVariableWithValidator(keepPrompt, eval.ShouldBeFn)
+})
+
+// keepPrompt is the default value of $edit:transient-prompt, which keeps the
+// prompt unchanged.
+var keepPrompt = &eval.BuiltinFn{"edit:keep-prompt",
+	func(*eval.EvalCtx, []eval.Value, map[string]eval.Value) {}}
+
+func (ed *Editor) transientPrompt() eval.Callable {
+	return ed.variables["transient-prompt"].Get().(eval.Callable)
+}
+
+// acceptedPrompt returns the prompt to show for an accepted line.
+func (ed *Editor) acceptedPrompt() []*ui.Styled {
+	fn := ed.transientPrompt()
+	if fn == eval.Callable(keepPrompt) {
+		return ed.promptContent
+	}
+	return callPrompt(ed, fn)
+}
@@ -0,0 +1,30 @@
+package edit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/eval"
+)
+
+func TestAcceptedPrompt(t *testing.T) {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ed := &Editor{evaler: ev, variables: makeVariables()}
+	ed.promptContent = []*ui.Styled{{"~/a/long/path> ", ui.Styles{}}}
+
+	if got := ed.acceptedPrompt(); !reflect.DeepEqual(got, ed.promptContent) {
+		t.Errorf("acceptedPrompt() => %v by default, want the prompt kept", got)
+	}
+
+	ed.variables["transient-prompt"].Set(&eval.BuiltinFn{"transient",
+		func(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+			ec.OutputChan() <- eval.String("> ")
+		}})
+	want := []*ui.Styled{{"> ", ui.Styles{}}}
+	if got := ed.acceptedPrompt(); !reflect.DeepEqual(got, want) {
+		t.Errorf("acceptedPrompt() => %v, want %v", got, want)
+	}
+}
@@ -230,10 +230,12 @@ func (er *editorRenderer) render(buf *buffer) {
 
 	var bufNoti, bufLine, bufMode, bufTips, bufListing *buffer
 	// butNoti
+	es.notificationMutex.Lock()
 	if len(es.notifications) > 0 {
 		bufNoti = render(linesRenderer{es.notifications, ""}, width)
 		es.notifications = nil
 	}
+	es.notificationMutex.Unlock()
 
 	// bufLine
 	clr := newCmdlineRenderer(es.promptContent, es.line, es.styling, es.dot, es.rpromptContent)
@@ -320,6 +322,11 @@ func (er *editorRenderer) render(buf *buffer) {
 	}
 	buf.extend(bufMode, cursorOnModeLine)
 	buf.extend(bufTips, false)
+	es.listingLayout = listingLayout{}
+	if bufListing != nil {
+		es.listingLayout = listingLayout{
+			len(buf.lines), buf.dot.line, width, hListing}
+	}
 	buf.extend(bufListing, false)
 
 	er.bufNoti = bufNoti
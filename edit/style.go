@@ -25,4 +25,6 @@ var (
 	styleForCompletion = ui.Styles{}
 	// Use inverse style for selected completion entry
 	styleForSelectedCompletion = ui.Styles{"inverse"}
+	// Use dim style for annotations of completion entries
+	styleForCompletionAnnotation = ui.Styles{"dim"}
 )
@@ -4,3 +4,13 @@ package tty
 type Pos struct {
 	line, col int
 }
+
+// Line returns the line of the position. Lines are numbered from 1.
+func (p Pos) Line() int {
+	return p.line
+}
+
+// Col returns the column of the position. Columns are numbered from 1.
+func (p Pos) Col() int {
+	return p.col
+}
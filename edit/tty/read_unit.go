@@ -4,7 +4,7 @@ import "github.com/elves/elvish/edit/ui"
 
 // ReadUnit represents one "thing" that the Reader has read. It is one of the
 // following: RawRune (when the reader is in the raw mode), Key, CursorPosition,
-// MouseEvent, or PasteSetting.
+// MouseEvent, PasteSetting, or FocusEvent.
 type ReadUnit interface {
 	isReadUnit()
 }
@@ -14,8 +14,13 @@ type Key ui.Key
 type CursorPosition Pos
 type PasteSetting bool
 
+// FocusEvent is reported when the terminal gains (true) or loses (false)
+// focus, if focus reporting is turned on.
+type FocusEvent bool
+
 func (RawRune) isReadUnit()        {}
 func (Key) isReadUnit()            {}
 func (CursorPosition) isReadUnit() {}
 func (MouseEvent) isReadUnit()     {}
 func (PasteSetting) isReadUnit()   {}
+func (FocusEvent) isReadUnit()     {}
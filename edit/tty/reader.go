@@ -44,11 +44,27 @@ type Reader struct {
 type MouseEvent struct {
 	Pos
 	Down bool
-	// Number of the Button, 0-based. -1 for unknown.
+	// Number of the Button, 0-based. -1 for unknown. The wheel is reported as
+	// MouseWheelUp and MouseWheelDown.
 	Button int
 	Mod    ui.Mod
 }
 
+// Buttons of mouse wheel events.
+const (
+	MouseWheelUp   = 3
+	MouseWheelDown = 4
+)
+
+// mouseButton determines the button of a mouse event from the button byte or
+// number in the event.
+func mouseButton(n int) int {
+	if n&64 != 0 {
+		return MouseWheelUp + n&1
+	}
+	return n & 3
+}
+
 // NewReader creates a new Reader on the given terminal file.
 func NewReader(f *os.File) *Reader {
 	rd := &Reader{
@@ -202,8 +218,8 @@ func (rd *Reader) readOne(r rune) {
 					return
 				}
 				down := true
-				button := int(cb & 3)
-				if button == 3 {
+				button := mouseButton(int(cb))
+				if button == 3 && cb&64 == 0 {
 					down = false
 					button = -1
 				}
@@ -236,12 +252,11 @@ func (rd *Reader) readOne(r rune) {
 
 				r = readRune()
 			}
-			if starter == 0 && r == 'R' {
-				// Cursor position report.
-				if len(nums) != 2 {
-					badSeq("bad CPR")
-					return
-				}
+			if starter == 0 && r == 'R' && len(nums) == 2 {
+				// Cursor position report. It always has two arguments, so
+				// \e[R is left to parseCSI as F3. A modified F3 like
+				// \e[1;5R cannot be told apart from a report and is taken
+				// as one.
 				unit = CursorPosition{nums[0], nums[1]}
 			} else if starter == '<' && (r == 'm' || r == 'M') {
 				// SGR-style mouse event.
@@ -250,12 +265,15 @@ func (rd *Reader) readOne(r rune) {
 					return
 				}
 				down := r == 'M'
-				button := nums[0] & 3
+				button := mouseButton(nums[0])
 				mod := mouseModify(nums[0])
 				unit = MouseEvent{Pos{nums[2], nums[1]}, down, button, mod}
 			} else if r == '~' && len(nums) == 1 && (nums[0] == 200 || nums[0] == 201) {
 				b := nums[0] == 200
 				unit = PasteSetting(b)
+			} else if starter == 0 && len(nums) == 0 && (r == 'I' || r == 'O') {
+				// Focus event.
+				unit = FocusEvent(r == 'I')
 			} else {
 				k := parseCSI(nums, r, currentSeq)
 				if k == (ui.Key{}) {
@@ -268,7 +286,7 @@ func (rd *Reader) readOne(r rune) {
 			// An 'O' follows. G3 style function key sequence: read one rune.
 			r = readRune()
 			if r == runeTimeout || r == runeReadError {
-				// Nothing follows after 'O'. Taken as ui.Alt-o.
+				// Nothing follows after 'O'. Taken as Alt-o.
 				unit = Key{'o', ui.Alt}
 				return
 			}
@@ -280,7 +298,7 @@ func (rd *Reader) readOne(r rune) {
 			}
 		default:
 			// Something other than '[' or 'O' follows. Taken as an
-			// ui.Alt-modified key, possibly also modified by ui.Ctrl.
+			// Alt-modified key, possibly also modified by Ctrl.
 			k := ctrlModify(r2)
 			k.Mod |= ui.Alt
 			unit = Key(k)
@@ -291,8 +309,8 @@ func (rd *Reader) readOne(r rune) {
 	}
 }
 
-// ctrlModify determines whether a rune corresponds to a ui.Ctrl-modified key and
-// returns the ui.Key the rune represents.
+// ctrlModify determines whether a rune corresponds to a Ctrl-modified key and
+// returns the Key the rune represents.
 func ctrlModify(r rune) ui.Key {
 	switch r {
 	case 0x0:
@@ -304,7 +322,7 @@ func ctrlModify(r rune) ui.Key {
 	case ui.Tab, ui.Enter, ui.Backspace: // ^I ^J ^?
 		return ui.Key{r, 0}
 	default:
-		// Regular ui.Ctrl sequences.
+		// Regular Ctrl sequences.
 		if 0x1 <= r && r <= 0x1d {
 			return ui.Key{r + 0x40, ui.Ctrl}
 		}
@@ -313,15 +331,15 @@ func ctrlModify(r rune) ui.Key {
 }
 
 // G3-style key sequences: \eO followed by exactly one character. For instance,
-// \eOP is ui.F1.
+// \eOP is F1.
 var g3Seq = map[rune]rune{
 	'A': ui.Up, 'B': ui.Down, 'C': ui.Right, 'D': ui.Left,
 
-	// ui.F1-ui.F4: xterm, libvte and tmux
+	// F1-F4: xterm, libvte and tmux
 	'P': ui.F1, 'Q': ui.F2,
 	'R': ui.F3, 'S': ui.F4,
 
-	// ui.Home and ui.End: libvte
+	// Home and End: libvte
 	'H': ui.Home, 'F': ui.End,
 }
 
@@ -330,16 +348,27 @@ var g3Seq = map[rune]rune{
 // non-numeric, non-semicolon rune.
 
 // CSI-style key sequences that can be identified based on the ending rune. For
-// instance, \e[A is ui.Up.
+// instance, \e[A is Up.
 var keyByLast = map[rune]ui.Key{
 	'A': {ui.Up, 0}, 'B': {ui.Down, 0},
 	'C': {ui.Right, 0}, 'D': {ui.Left, 0},
 	'H': {ui.Home, 0}, 'F': {ui.End, 0},
 	'Z': {ui.Tab, ui.Shift},
+	// F1-F4, e.g. \e[1;5P is Ctrl-F1. Modified F3 sequences are
+	// taken as cursor position reports instead; see readOne.
+	'P': {ui.F1, 0}, 'Q': {ui.F2, 0},
+	'R': {ui.F3, 0}, 'S': {ui.F4, 0},
+}
+
+// Keys in CSI-u (also known as fixterms) sequences that are identified by a
+// codepoint that is not their rune.
+var keyByCodepoint = map[int]ui.Key{
+	9: {ui.Tab, 0}, 13: {ui.Enter, 0}, 127: {ui.Backspace, 0},
+	27: {'[', ui.Ctrl},
 }
 
 // CSI-style key sequences ending with '~' and can be identified based on the
-// only number argument. For instance, \e[~ is ui.Home. When they are
+// only number argument. For instance, \e[~ is Home. When they are
 // modified, they take two arguments, first being 1 and second identifying the
 // modifier (see xtermModify). For instance, \e[1;4~ is Shift-Alt-Home.
 var keyByNum0 = map[int]rune{
@@ -351,7 +380,7 @@ var keyByNum0 = map[int]rune{
 }
 
 // CSI-style key sequences ending with '~', with 27 as the first numeric
-// argument. For instance, \e[27;9~ is ui.Tab.
+// argument. For instance, \e[27;9~ is Tab.
 //
 // The list is taken blindly from tmux source xterm-keys.c. I don't have a
 // keyboard-terminal combination that generate such sequences, but assumably
@@ -369,25 +398,43 @@ var keyByNum2 = map[int]rune{
 func parseCSI(nums []int, last rune, seq string) ui.Key {
 	if k, ok := keyByLast[last]; ok {
 		if len(nums) == 0 {
-			// Unmodified: \e[A (ui.Up)
+			// Unmodified: \e[A (Up)
 			return k
 		} else if len(nums) == 2 && nums[0] == 1 {
-			// Modified: \e[1;5A (ui.Ctrl-ui.Up)
+			// Modified: \e[1;5A (Ctrl-Up)
 			return xtermModify(k, nums[1], seq)
 		} else {
 			return ui.Key{}
 		}
 	}
 
+	if last == 'u' && (len(nums) == 1 || len(nums) == 2) {
+		// CSI-u style key: \e[97;5u (Ctrl-a). The first argument is the
+		// codepoint and the optional second one identifies the modifier.
+		k, ok := keyByCodepoint[nums[0]]
+		if !ok {
+			k = ui.Key{rune(nums[0]), 0}
+		}
+		if len(nums) == 1 {
+			return k
+		}
+		k = xtermModify(k, nums[1], seq)
+		if k.Mod&ui.Ctrl != 0 && 'a' <= k.Rune && k.Rune <= 'z' {
+			// Ctrl-modified letters are always represented in upper case.
+			k.Rune += 'A' - 'a'
+		}
+		return k
+	}
+
 	if last == '~' {
 		if len(nums) == 1 || len(nums) == 2 {
 			if r, ok := keyByNum0[nums[0]]; ok {
 				k := ui.Key{r, 0}
 				if len(nums) == 1 {
-					// Unmodified: \e[5~ (ui.PageUp)
+					// Unmodified: \e[5~ (PageUp)
 					return k
 				}
-				// Modified: \e[5;5~ (ui.Ctrl-ui.PageUp)
+				// Modified: \e[5;5~ (Ctrl-PageUp)
 				return xtermModify(k, nums[1], seq)
 			}
 		} else if len(nums) == 3 && nums[0] == 27 {
@@ -68,6 +68,11 @@ var keyTests = []struct {
 	{"\033[H", Key{ui.Home, 0}},
 	// Test for all possible modifier
 	{"\033[1;2A", Key{ui.Up, ui.Shift}},
+	{"\033[1;5P", Key{ui.F1, ui.Ctrl}},
+	{"\033[R", Key{ui.F3, 0}},
+
+	// Cursor position report, which always has two arguments.
+	{"\033[12;34R", CursorPosition{12, 34}},
 
 	// CSI-sequence key with one argument, always ending in '~'.
 	{"\033[1~", Key{ui.Home, 0}},
@@ -77,6 +82,23 @@ var keyTests = []struct {
 	// argument is always 27, the second identifies the modifier and the last
 	// identifies the key.
 	{"\033[27;4;63~", Key{';', ui.Shift | ui.Alt}},
+
+	// CSI-u sequence key. The first argument is the codepoint and the
+	// optional second one identifies the modifier.
+	{"\033[97u", Key{'a', 0}},
+	{"\033[97;5u", Key{'A', ui.Ctrl}},
+	{"\033[13;2u", Key{ui.Enter, ui.Shift}},
+	{"\033[27u", Key{'[', ui.Ctrl}},
+
+	// SGR-style mouse events, including the wheel.
+	{"\033[<0;10;5M", MouseEvent{Pos{5, 10}, true, 0, 0}},
+	{"\033[<0;10;5m", MouseEvent{Pos{5, 10}, false, 0, 0}},
+	{"\033[<64;10;5M", MouseEvent{Pos{5, 10}, true, MouseWheelUp, 0}},
+	{"\033[<65;10;5M", MouseEvent{Pos{5, 10}, true, MouseWheelDown, 0}},
+
+	// Focus events.
+	{"\033[I", FocusEvent(true)},
+	{"\033[O", FocusEvent(false)},
 }
 
 func TestKey(t *testing.T) {
@@ -0,0 +1,206 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+)
+
+// builtinDocs contains short usage and documentation strings for builtin
+// functions and special forms. The first line of each entry is the usage.
+var builtinDocs = map[string]string{
+	// Special forms
+	"del":   "del $var...\nDeletes local, environment or shared variables.",
+	"fn":    "fn name [args]{ body }\nDefines a function; shorthand for '&name = [args]{ body }'.",
+	"use":   "use module [filename]\nLoads a module and makes it available as module:.",
+	"and":   "and value...\nOutputs the first falsy value, or the last value if all are truthy.",
+	"or":    "or value...\nOutputs the first truthy value, or the last value if all are falsy.",
+	"if":    "if cond { body } [elif cond { body }]... [else { body }]\nConditionally runs a body.",
+	"while": "while cond { body }\nRuns the body as long as the condition is truthy.",
+	"for":   "for var iterable { body } [else { body }]\nRuns the body for each element of the iterable.",
+	"try":   "try { body } [except var { body }] [else { body }] [finally { body }]\nRuns the body, handling exceptions.",
+
+	"nop":      "nop [arg...]\nDoes nothing and ignores all arguments.",
+	"kind-of":  "kind-of value...\nOutputs the kinds of the values.",
+	"doc":      "doc name\nPrints the documentation of a builtin function or special form.",
+	"vars":     "vars\nOutputs a map from the names of the variables in the current scope to their values.",
+	"fns":      "fns\nOutputs a map for each function in the current scope, with its name, and the params and rest param of closures.",
+	"builtins": "builtins\nOutputs the names of all builtin functions.",
+
+	"fn-source":   "fn-source f\nPrints the definition of the function, which may be given by name.",
+	"fn-location": "fn-location f\nPrints where the function is defined, as name:line:column.",
+
+	"set-option": "set-option name value\nSets the option until the enclosing closure returns, or globally at the top level.",
+	"get-option": "get-option name\nOutputs the value of the option.",
+	"options":    "options\nOutputs a map for each option, with its name, value, default and doc.",
+
+	"is": "is value...\nDetermines whether all the values are the same object.",
+	"eq": "eq value...\nDetermines whether all the values are structurally equal.",
+
+	"put": "put value...\nOutputs the values.",
+
+	"print":  "print &sep=' ' &end='' value...\nWrites the values as bytes, separated by &sep and followed by &end.",
+	"echo":   "echo &sep=' ' &end=\"\\n\" &n=$false value...\nLike print, but ends with a newline unless &n is true.",
+	"pprint": "pprint &color=auto value...\nPretty-prints the representations of the values, colored when &color is always, or auto and the output is a terminal.",
+	"repr":   "repr value...\nWrites the representations of the values.",
+
+	"slurp":      "slurp\nReads all byte input into a single string.",
+	"from-lines": "from-lines\nOutputs each line of the byte input as a string.",
+	"read":       "read &prompt='' &secret=$false &confirm=$false\nReads one line of the byte input and outputs it as a string. The prompt is written to stderr, or shown in the editor's minibuffer when reading from the terminal. With &secret, the input is not echoed and the line is output as a secret, which is shown as <secret> in traces, the debugger and the REPL. With &confirm, the line is read twice and the two must match.",
+	"from-json":  "from-json\nParses JSON values from the byte input.",
+	"load":       "load\nOutputs the values dumped with dump from the byte input.",
+
+	"to-lines": "to-lines [iterable]\nWrites each value input as a line.",
+	"to-table": "to-table &columns=[] &header=$true &color=auto [iterable]\nWrites the map or list inputs as a table with aligned columns.",
+	"to-json":  "to-json [iterable]\nWrites each value input as JSON.",
+	"dump":     "dump [iterable]\nWrites each value input, which may only contain strings, bools, lists and maps, in a versioned format that load reads back.",
+
+	"tee": "tee &append=$false target...\nPasses the byte and value inputs on, copying them to files or functions.",
+
+	"fail":        "fail &cause=$ok message\nThrows an exception with the message. A non-$ok &cause is recorded as the exception that caused it, available as $e[cause].",
+	"multi-error": "multi-error exception...\nThrows an exception combining several exceptions.",
+	"return":      "return\nReturns from the enclosing function.",
+	"break":       "break\nTerminates the enclosing loop.",
+	"continue":    "continue\nContinues with the next iteration of the enclosing loop.",
+
+	"constantly": "constantly value...\nOutputs a function that always outputs the values.",
+
+	"source": "source filename\nEvaluates the content of a file.",
+	"posix":  "posix script [arg...]\nRuns the script with /bin/sh, passing the args as $1, $2, ...",
+
+	"each":   "each f [iterable]\nCalls f with each value input.",
+	"peach":  "peach f [iterable]\nCalls f with each value input, in parallel.",
+	"repeat": "repeat n value\nOutputs the value n times.",
+
+	"explode":     "explode iterable\nOutputs all elements of the iterable.",
+	"take":        "take n [iterable]\nOutputs the first n value inputs, and stops reading the input.",
+	"drop":        "drop n [iterable]\nOutputs all but the first n value inputs.",
+	"first":       "first [iterable]\nOutputs the first value input, and stops reading the input.",
+	"last":        "last [iterable]\nOutputs the last value input.",
+	"range":       "range &step=1 [low] high\nOutputs numbers from low (inclusive) to high (exclusive); counts down if step is negative.",
+	"count":       "count [iterable]\nOutputs the number of value inputs.",
+	"uniq":        "uniq &global=$false [iterable]\nOutputs the value inputs without consecutive duplicates, or without any duplicates if &global is true.",
+	"frequencies": "frequencies [iterable]\nOutputs a map from each distinct value input to the number of times it appears.",
+	"group-by":    "group-by f [iterable]\nOutputs a map from outputs of f to lists of the value inputs that f maps to them.",
+	"zip":         "zip &longest=$false &fill='' iterable...\nOutputs lists of the corresponding elements of the iterables, stopping at the shortest one, or padding with &fill up to the longest one.",
+	"unzip":       "unzip [iterable]\nTakes lists of the same length n as inputs and outputs n lists, the i-th of which contains the i-th elements of the inputs.",
+	"order":       "order &reverse=$false &numeric=$false &key=fn &stable=$false &collate=bytes [iterable]\nOutputs the value inputs in sorted order. Strings are compared byte-wise, ignoring case, or comparing runs of digits numerically, when &collate is bytes, icase or version respectively. Values of different kinds are ordered by their kinds, and lists are ordered lexicographically.",
+
+	"joins":  "joins sep [iterable]\nJoins the value inputs with the separator.",
+	"splits": "splits &sep=sep string\nSplits the string by the separator.",
+
+	"ord":               "ord string\nOutputs the codepoints of the string in hexadecimal.",
+	"base":              "base b integer...\nOutputs the integers in base b.",
+	"format-num":        "format-num &digits=-1 &sci=$false number\nFormats the number with &digits digits after the decimal point, or as many as needed, in scientific notation if &sci is true.",
+	"wcswidth":          "wcswidth string\nOutputs the display width of the string.",
+	"str-index":         "str-index &unit=rune string index\nIndexes or slices the string like $string[index], counting in runes, bytes or display columns as specified by &unit.",
+	"quote":             "quote string\nOutputs the string quoted as elvish source.",
+	"-override-wcwidth": "-override-wcwidth char width\nOverrides the display width of a character.",
+
+	"has-prefix": "has-prefix string prefix\nDetermines whether the string starts with the prefix.",
+	"has-suffix": "has-suffix string suffix\nDetermines whether the string ends with the suffix.",
+
+	"<s":  "<s string...\nDetermines whether the strings are strictly increasing.",
+	"<=s": "<=s string...\nDetermines whether the strings are non-decreasing.",
+	"==s": "==s string...\nDetermines whether the strings are all equal.",
+	"!=s": "!=s string string\nDetermines whether the strings are different.",
+	">s":  ">s string...\nDetermines whether the strings are strictly decreasing.",
+	">=s": ">=s string...\nDetermines whether the strings are non-increasing.",
+
+	"eawk": "eawk f [iterable]\nCalls f with each line of input and its fields, like awk.",
+
+	"cd":    "cd [dir]\nChanges the working directory; defaults to the home directory. The directory - is the previous working directory. If dir doesn't exist but a directory next to it has a similar name, cd offers to change there instead, or does so directly if $cd-autocorrect is true.",
+	"allow": "allow [dir]\nAllows the .elvish-env file in dir or the closest one to be evaluated.",
+	"dirs":  "dirs\nOutputs the directory history with scores.",
+
+	"path-abs":      "path-abs path\nOutputs the absolute version of the path. Deprecated; use path:abs instead.",
+	"path-base":     "path-base path\nOutputs the last element of the path. Deprecated; use path:base instead.",
+	"path-clean":    "path-clean path\nOutputs the shortest equivalent path. Deprecated; use path:clean instead.",
+	"path-dir":      "path-dir path\nOutputs all but the last element of the path. Deprecated; use path:dir instead.",
+	"path-ext":      "path-ext path\nOutputs the extension of the path. Deprecated; use path:ext instead.",
+	"eval-symlinks": "eval-symlinks path\nOutputs the path with symbolic links resolved.",
+	"tilde-abbr":    "tilde-abbr path\nAbbreviates the home directory in the path to ~.",
+
+	"bool": "bool value\nOutputs the boolean value of the value.",
+	"not":  "not value\nOutputs the negated boolean value of the value.",
+
+	"+": "+ number...\nOutputs the sum of the numbers.",
+	"-": "- number [number...]\nSubtracts the remaining numbers from the first, or negates it.",
+	"*": "* number...\nOutputs the product of the numbers.",
+	"/": "/ number number...\nDivides the first number by the remaining numbers.",
+	"^": "^ base exponent\nOutputs base raised to the exponent.",
+	"%": "% a b\nOutputs the remainder of integer division.",
+
+	"rand":       "rand &crypto=$false\nOutputs a random number in [0, 1), using crypto/rand if &crypto is true.",
+	"randint":    "randint &crypto=$false low high\nOutputs a random integer in [low, high), using crypto/rand if &crypto is true.",
+	"uuid":       "uuid\nOutputs a random (version 4) UUID.",
+	"rand-token": "rand-token &bytes=16\nOutputs the hexadecimal encoding of random bytes from crypto/rand.",
+
+	"<":  "< number...\nDetermines whether the numbers are strictly increasing.",
+	"<=": "<= number...\nDetermines whether the numbers are non-decreasing.",
+	"==": "== number...\nDetermines whether the numbers are all equal.",
+	"!=": "!= number number\nDetermines whether the numbers are different.",
+	">":  "> number...\nDetermines whether the numbers are strictly decreasing.",
+	">=": ">= number...\nDetermines whether the numbers are non-increasing.",
+
+	"resolve":         "resolve command\nOutputs what the command resolves to.",
+	"which":           "which name...\nShows how the names resolve as commands, and what they shadow.",
+	"has-external":    "has-external command\nDetermines whether the external command exists.",
+	"search-external": "search-external command\nOutputs the path of the external command.",
+
+	"fopen":          "fopen &write=$false &append=$false filename\nOpens a file and outputs it. The file is opened for reading, unless &write or &append is true, in which case it is created if needed and truncated unless &append is true.",
+	"fclose":         "fclose file\nCloses a file opened with fopen.",
+	"pipe":           "pipe\nCreates a pipe and outputs it.",
+	"prclose":        "prclose pipe\nCloses the read end of the pipe.",
+	"pwclose":        "pwclose pipe\nCloses the write end of the pipe.",
+	"mkfifo":         "mkfifo &perm=0600 [path]\nCreates a named pipe and outputs its path. Without a path, the pipe is created in a new temporary directory. Open it with fopen &write, so that several background jobs can write to one reader.",
+	"rmfifo":         "rmfifo path\nRemoves a named pipe, and the temporary directory mkfifo created for it, if any.",
+	"with-temp-file": "with-temp-file &dir='' &prefix=elvish- f\nCalls f with the path of a new empty temporary file, which is removed when f returns.",
+	"flock":          "flock &timeout=0 &nonblock=$false &shared=$false path f\nCalls f while holding an advisory lock on the file at path, creating it if needed. With &nonblock, fails at once if the lock is held elsewhere; otherwise waits at most &timeout seconds, or forever if it is 0.",
+	"dial":           "dial &timeout=0 network address\nConnects to a tcp or unix socket and outputs a file for reading and a file for writing.",
+
+	"fg":   "fg pid...\nBrings stopped processes to the foreground.",
+	"exec": "exec [command] [arg...]\nReplaces the shell process with the command.",
+	"run":  "run &env=[&] &clear-env=$false &dir='' &stdin=input &capture=$false command [arg...]\nRuns the command and outputs a map of its pid, exit-status and signal, and its stdout and stderr if &capture is true.",
+	"exit": "exit [status]\nExits the shell.",
+
+	"esleep":          "esleep duration\nSleeps for the duration, given in seconds or like 1m30s.",
+	"every":           "every interval f\nCalls f every interval until it breaks or the user interrupts.",
+	"retry":           "retry &times=5 &backoff=2s f\nCalls f until it succeeds, at most &times times, waiting &backoff before the first retry and twice as long before each following one. Throws the exception from the last call if all of them fail. Like in other loops, break in f stops retrying and continue goes on to the next attempt.",
+	"watch":           "watch &interval=0.5 &debounce=0.1 path... f\nCalls f, and again whenever a file under the paths changes, until f breaks or returns or the user interrupts; continue waits for the next change. Changes are polled every &interval, and f is only called once the files have not changed for &debounce. Exceptions from f are printed.",
+	"now":             "now &layout=''\nOutputs the current time as a Unix timestamp, or formatted with the layout.",
+	"time-format":     "time-format &layout=rfc3339 [timestamp]\nFormats the Unix timestamp, or the current time. The layout is rfc3339, rfc1123, kitchen, date, datetime or a Go layout.",
+	"time-parse":      "time-parse &layout=rfc3339 string\nParses the time and outputs it as a Unix timestamp.",
+	"duration-parse":  "duration-parse duration\nOutputs the number of seconds in a duration like 1m30s.",
+	"duration-format": "duration-format seconds\nFormats a number of seconds as a duration like 1m30s.",
+	"-time":           "-time f\nCalls f and prints the time it took.",
+	"breakpoint":      "breakpoint\nStarts the debugger when $debug is true.",
+	"cover":           "cover f\nCalls f and prints how many times each line of the code it runs was run.",
+	"profile":         "profile f\nCalls f and prints the time spent in each command it runs, slowest first.",
+
+	"-gc":    "-gc\nForces a garbage collection.",
+	"-stack": "-stack\nPrints the stacks of all goroutines.",
+	"-log":   "-log filename\nWrites debug logs to the file.",
+
+	"-ifaddrs": "-ifaddrs\nOutputs the addresses of network interfaces.",
+}
+
+// ErrNoDoc is thrown by the doc builtin when no documentation is available.
+var ErrNoDoc = errors.New("no documentation")
+
+// Doc returns the documentation of a builtin function or special form.
+func Doc(name string) (string, bool) {
+	doc, ok := builtinDocs[name]
+	return doc, ok
+}
+
+func doc(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var name String
+	ScanArgs(args, &name)
+	TakeNoOpt(opts)
+
+	text, ok := Doc(strings.TrimPrefix(string(name), "builtin:"))
+	if !ok {
+		throw(ErrNoDoc)
+	}
+	ec.ports[1].File.WriteString(text + "\n")
+}
@@ -3,13 +3,13 @@ package eval
 // Builtin functions.
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math"
 	"math/rand"
 	"net"
 	"os"
@@ -17,6 +17,7 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,7 +27,6 @@ import (
 
 	"github.com/elves/elvish/parse"
 	"github.com/elves/elvish/store/storedefs"
-	"github.com/elves/elvish/sys"
 	"github.com/elves/elvish/util"
 )
 
@@ -61,8 +61,19 @@ func init() {
 		// Trivial builtin
 		{"nop", nop},
 
+		// Options
+		{"set-option", setOption},
+		{"get-option", getOption},
+		{"options", optionsFn},
+
 		// Introspection
 		{"kind-of", kindOf},
+		{"doc", doc},
+		{"vars", vars},
+		{"fns", fns},
+		{"builtins", builtins},
+		{"fn-source", fnSource},
+		{"fn-location", fnLocation},
 
 		// Generic identity and equality
 		{"is", is},
@@ -80,11 +91,17 @@ func init() {
 		// Bytes to value
 		{"slurp", slurp},
 		{"from-lines", fromLines},
+		{"read", read},
 		{"from-json", fromJSON},
+		{"load", load},
 
 		// Value to bytes
 		{"to-lines", toLines},
 		{"to-json", toJSON},
+		{"to-table", toTable},
+		{"dump", dump},
+
+		{"tee", tee},
 
 		// Exception and control
 		{"fail", fail},
@@ -98,6 +115,7 @@ func init() {
 
 		// Misc shell basic
 		{"source", source},
+		{"posix", posix},
 
 		// Iterations.
 		{"each", each},
@@ -107,8 +125,17 @@ func init() {
 		// Sequence primitives
 		{"explode", explode},
 		{"take", take},
+		{"drop", drop},
+		{"first", first},
+		{"last", last},
 		{"range", rangeFn},
 		{"count", count},
+		{"order", order},
+		{"uniq", uniq},
+		{"frequencies", frequencies},
+		{"group-by", groupBy},
+		{"zip", zip},
+		{"unzip", unzip},
 
 		// String
 		{"joins", joins},
@@ -117,7 +144,10 @@ func init() {
 		// String operations
 		{"ord", ord},
 		{"base", base},
+		{"format-num", formatNum},
 		{"wcswidth", wcswidth},
+		{"str-index", strIndex},
+		{"quote", WrapStringToString(parse.Quote)},
 		{"-override-wcwidth", overrideWcwidth},
 
 		// String predicates
@@ -143,9 +173,11 @@ func init() {
 
 		// Directory
 		{"cd", cd},
+		{"allow", allow},
 		{"dirs", dirs},
 
-		// Path
+		// Path. The path-* builtins are deprecated in favor of the same
+		// functions in the path: module.
 		{"path-abs", WrapStringToStringError(filepath.Abs)},
 		{"path-base", WrapStringToString(filepath.Base)},
 		{"path-clean", WrapStringToString(filepath.Clean)},
@@ -169,6 +201,8 @@ func init() {
 		// Random
 		{"rand", randFn},
 		{"randint", randint},
+		{"uuid", uuid},
+		{"rand-token", randToken},
 
 		// Numerical comparison
 		{"<",
@@ -186,6 +220,7 @@ func init() {
 
 		// Command resolution
 		{"resolve", resolveFn},
+		{"which", which},
 		{"has-external", hasExternal},
 		{"search-external", searchExternal},
 
@@ -195,15 +230,32 @@ func init() {
 		{"pipe", pipe},
 		{"prclose", prclose},
 		{"pwclose", pwclose},
+		{"mkfifo", mkfifo},
+		{"rmfifo", rmfifo},
+		{"dial", dial},
+		{"with-temp-file", withTempFile},
+		{"flock", flock},
 
 		// Process control
 		{"fg", fg},
 		{"exec", exec},
+		{"run", run},
 		{"exit", exit},
 
 		// Time
 		{"esleep", sleep},
+		{"every", every},
+		{"retry", retry},
+		{"watch", watch},
+		{"now", now},
+		{"time-format", timeFormat},
+		{"time-parse", timeParse},
+		{"duration-parse", durationParse},
+		{"duration-format", durationFormat},
 		{"-time", _time},
+		{"profile", profile},
+		{"breakpoint", breakpoint},
+		{"cover", cover},
 
 		// Debugging
 		{"-gc", _gc},
@@ -224,6 +276,10 @@ var (
 	ErrNoMatchingDir     = errors.New("no matching directory")
 	ErrNotInSameGroup    = errors.New("not in the same process group")
 	ErrInterrupted       = errors.New("interrupted")
+	ErrNoInput           = errors.New("no input")
+	ErrZeroStep          = errors.New("step must not be zero")
+	ErrUnevenLengths     = errors.New("iterables of different lengths")
+	ErrNoOldpwd          = errors.New("no previous directory")
 )
 
 func WrapStringToString(f func(string) string) func(*EvalCtx, []Value, map[string]Value) {
@@ -247,34 +303,20 @@ func WrapStringToStringError(f func(string) (string, error)) func(*EvalCtx, []Va
 func wrapStrCompare(cmp func(a, b string) bool) func(*EvalCtx, []Value, map[string]Value) {
 	return func(ec *EvalCtx, args []Value, opts map[string]Value) {
 		TakeNoOpt(opts)
-		for _, a := range args {
-			if _, ok := a.(String); !ok {
+		strs := make([]string, len(args))
+		for i, a := range args {
+			switch a := a.(type) {
+			case String:
+				strs[i] = string(a)
+			case Secret:
+				strs[i] = string(a)
+			default:
 				throw(ErrArgs)
 			}
 		}
 		result := true
 		for i := 0; i < len(args)-1; i++ {
-			if !cmp(string(args[i].(String)), string(args[i+1].(String))) {
-				result = false
-				break
-			}
-		}
-		ec.OutputChan() <- Bool(result)
-	}
-}
-
-func wrapNumCompare(cmp func(a, b float64) bool) func(*EvalCtx, []Value, map[string]Value) {
-	return func(ec *EvalCtx, args []Value, opts map[string]Value) {
-		TakeNoOpt(opts)
-		floats := make([]float64, len(args))
-		for i, a := range args {
-			f, err := toFloat(a)
-			maybeThrow(err)
-			floats[i] = f
-		}
-		result := true
-		for i := 0; i < len(floats)-1; i++ {
-			if !cmp(floats[i], floats[i+1]) {
+			if !cmp(strs[i], strs[i+1]) {
 				result = false
 				break
 			}
@@ -332,10 +374,22 @@ func ScanArgsVariadic(s []Value, args ...interface{}) {
 // optional iterable value at the end. The return value is a function that
 // iterates the iterable value if it exists, or the input otherwise.
 func ScanArgsAndOptionalIterate(ec *EvalCtx, s []Value, args ...interface{}) func(func(Value)) {
+	iterate := scanArgsAndOptionalIterateWhile(ec, s, args...)
+	return func(f func(Value)) {
+		iterate(func(v Value) bool {
+			f(v)
+			return true
+		})
+	}
+}
+
+// scanArgsAndOptionalIterateWhile is like ScanArgsAndOptionalIterate, but the
+// iteration stops as soon as the callback returns false.
+func scanArgsAndOptionalIterateWhile(ec *EvalCtx, s []Value, args ...interface{}) func(func(Value) bool) {
 	switch len(s) {
 	case len(args):
 		ScanArgs(s, args...)
-		return ec.IterateInputs
+		return ec.iterateInputsWhile
 	case len(args) + 1:
 		ScanArgs(s[:len(args)], args...)
 		value := s[len(args)]
@@ -343,12 +397,7 @@ func ScanArgsAndOptionalIterate(ec *EvalCtx, s []Value, args ...interface{}) fun
 		if !ok {
 			throwf("need iterable argument, got %s", value.Kind())
 		}
-		return func(f func(Value)) {
-			iterable.Iterate(func(v Value) bool {
-				f(v)
-				return true
-			})
-		}
+		return iterable.Iterate
 	default:
 		throwf("arity mistmatch: want %d or %d arguments, got %d", len(args), len(args)+1, len(s))
 		return nil
@@ -442,38 +491,44 @@ func eq(ec *EvalCtx, args []Value, opts map[string]Value) {
 
 func put(ec *EvalCtx, args []Value, opts map[string]Value) {
 	TakeNoOpt(opts)
-	out := ec.ports[1].Chan
+	out := ec.ports[1]
 	for _, a := range args {
-		out <- a
+		out.Put(a)
 	}
 }
 
+// print writes the values as bytes, separated by &sep and followed by &end.
+// Strings are written as they are, and other values as their
+// representations.
 func print(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var sepv String
-	ScanOpts(opts, Opt{"sep", &sepv, String(" ")})
-
-	out := ec.ports[1].File
-	sep := string(sepv)
-	for i, arg := range args {
-		if i > 0 {
-			out.WriteString(sep)
-		}
-		out.WriteString(ToString(arg))
-	}
+	var sep, end String
+	ScanOpts(opts, Opt{"sep", &sep, String(" ")}, Opt{"end", &end, String("")})
+	writeValues(ec.ports[1].File, args, string(sep), string(end))
 }
 
+// echo is like print, but &end defaults to a newline. &n, named after the
+// flag of the echo command, suppresses it.
 func echo(ec *EvalCtx, args []Value, opts map[string]Value) {
-	print(ec, args, opts)
-	ec.ports[1].File.WriteString("\n")
+	var sep, end String
+	var n Bool
+	ScanOpts(opts, Opt{"sep", &sep, String(" ")},
+		Opt{"end", &end, String("\n")}, Opt{"n", &n, Bool(false)})
+	if n {
+		end = ""
+	}
+	writeValues(ec.ports[1].File, args, string(sep), string(end))
 }
 
-func pprint(ec *EvalCtx, args []Value, opts map[string]Value) {
-	TakeNoOpt(opts)
-	out := ec.ports[1].File
-	for _, arg := range args {
-		out.WriteString(arg.Repr(0))
-		out.WriteString("\n")
+func writeValues(out *os.File, args []Value, sep, end string) {
+	var b bytes.Buffer
+	for i, arg := range args {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(ToString(arg))
 	}
+	b.WriteString(end)
+	out.Write(b.Bytes())
 }
 
 func repr(ec *EvalCtx, args []Value, opts map[string]Value) {
@@ -496,11 +551,6 @@ func slurp(ec *EvalCtx, args []Value, opts map[string]Value) {
 	out := ec.ports[1].Chan
 
 	all, err := ioutil.ReadAll(in)
-	if err != nil {
-		b, err := sys.GetNonblock(0)
-		fmt.Println("stdin is nonblock:", b, err)
-		fmt.Println("stdin is stdin:", in == os.Stdin)
-	}
 	maybeThrow(err)
 	out <- String(string(all))
 }
@@ -509,10 +559,21 @@ func fromLines(ec *EvalCtx, args []Value, opts map[string]Value) {
 	TakeNoArg(args)
 	TakeNoOpt(opts)
 
-	in := ec.ports[0].File
-	out := ec.ports[1].Chan
+	in := bufio.NewReader(ec.ports[0].File)
+	out := ec.ports[1]
 
-	linesToChan(in, out)
+	for {
+		line, err := in.ReadString('\n')
+		if line != "" {
+			out.Put(String(strings.TrimSuffix(line, "\n")))
+		}
+		if err != nil {
+			if err != io.EOF {
+				throw(err)
+			}
+			return
+		}
+	}
 }
 
 // fromJSON parses a stream of JSON data into Value's.
@@ -521,7 +582,7 @@ func fromJSON(ec *EvalCtx, args []Value, opts map[string]Value) {
 	TakeNoOpt(opts)
 
 	in := ec.ports[0].File
-	out := ec.ports[1].Chan
+	out := ec.ports[1]
 
 	dec := json.NewDecoder(in)
 	var v interface{}
@@ -533,7 +594,7 @@ func fromJSON(ec *EvalCtx, args []Value, opts map[string]Value) {
 			}
 			throw(err)
 		}
-		out <- FromJSONInterface(v)
+		out.Put(FromJSONInterface(v))
 	}
 }
 
@@ -563,10 +624,16 @@ func toJSON(ec *EvalCtx, args []Value, opts map[string]Value) {
 }
 
 func fail(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var msg String
+	var (
+		msg   String
+		cause *Exception
+	)
 	ScanArgs(args, &msg)
-	TakeNoOpt(opts)
+	ScanOpts(opts, Opt{"cause", &cause, OK})
 
+	if cause.Cause != nil {
+		throw(&WrappedError{string(msg), cause})
+	}
 	throw(errors.New(string(msg)))
 }
 
@@ -627,17 +694,33 @@ func source(ec *EvalCtx, args []Value, opts map[string]Value) {
 	ec.Source(string(fname))
 }
 
-// each takes a single closure and applies it to all input values.
+// posixShell is the shell used by the posix builtin.
+const posixShell = "/bin/sh"
+
+// posix runs a script with the POSIX shell, passing the rest of the arguments
+// as positional parameters.
+func posix(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	if len(args) < 1 {
+		throw(ErrArgs)
+	}
+	script, ok := args[0].(String)
+	if !ok {
+		throw(ErrArgs)
+	}
+
+	shArgs := append([]Value{String("-c"), script, String("posix")}, args[1:]...)
+	ExternalCmd{posixShell}.Call(ec, shArgs, NoOpts)
+}
+
+// each takes a single closure and applies it to all input values. It stops
+// reading the input when the closure breaks.
 func each(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var f CallableValue
-	iterate := ScanArgsAndOptionalIterate(ec, args, &f)
+	iterate := scanArgsAndOptionalIterateWhile(ec, args, &f)
 	TakeNoOpt(opts)
 
-	broken := false
-	iterate(func(v Value) {
-		if broken {
-			return
-		}
+	iterate(func(v Value) bool {
 		// NOTE We don't have the position range of the closure in the source.
 		// Ideally, it should be kept in the Closure itself.
 		newec := ec.fork("closure of each")
@@ -650,11 +733,12 @@ func each(ec *EvalCtx, args []Value, opts map[string]Value) {
 			case nil, Continue:
 				// nop
 			case Break:
-				broken = true
+				return false
 			default:
 				throw(ex)
 			}
 		}
+		return true
 	})
 }
 
@@ -665,10 +749,15 @@ func peach(ec *EvalCtx, args []Value, opts map[string]Value) {
 	TakeNoOpt(opts)
 
 	var w sync.WaitGroup
+	// Protects broken and err, which are set by the goroutines.
+	var mutex sync.Mutex
 	broken := false
 	var err error
 	iterate(func(v Value) {
-		if broken || err != nil {
+		mutex.Lock()
+		stop := broken || err != nil
+		mutex.Unlock()
+		if stop {
 			return
 		}
 		w.Add(1)
@@ -681,6 +770,7 @@ func peach(ec *EvalCtx, args []Value, opts map[string]Value) {
 			ClosePorts(newec.ports)
 
 			if ex != nil {
+				mutex.Lock()
 				switch ex.(*Exception).Cause {
 				case nil, Continue:
 					// nop
@@ -689,6 +779,7 @@ func peach(ec *EvalCtx, args []Value, opts map[string]Value) {
 				default:
 					err = ex
 				}
+				mutex.Unlock()
 			}
 			w.Done()
 		}()
@@ -705,9 +796,10 @@ func repeat(ec *EvalCtx, args []Value, opts map[string]Value) {
 	ScanArgs(args, &n, &v)
 	TakeNoOpt(opts)
 
-	out := ec.OutputChan()
+	out := ec.ports[1]
 	for i := 0; i < n; i++ {
-		out <- v
+		ec.checkInterrupted()
+		out.Put(v)
 	}
 }
 
@@ -717,26 +809,78 @@ func explode(ec *EvalCtx, args []Value, opts map[string]Value) {
 	ScanArgs(args, &v)
 	TakeNoOpt(opts)
 
-	out := ec.ports[1].Chan
+	out := ec.ports[1]
 	v.Iterate(func(e Value) bool {
-		out <- e
+		out.Put(e)
 		return true
 	})
 }
 
+// take outputs the first n value inputs. It stops reading the input after
+// that, which also stops cooperating writers upstream.
 func take(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var n int
-	iterate := ScanArgsAndOptionalIterate(ec, args, &n)
+	iterate := scanArgsAndOptionalIterateWhile(ec, args, &n)
 	TakeNoOpt(opts)
 
-	out := ec.ports[1].Chan
+	if n <= 0 {
+		return
+	}
+	out := ec.ports[1]
 	i := 0
-	iterate(func(v Value) {
-		if i < n {
-			out <- v
-		}
+	iterate(func(v Value) bool {
 		i++
+		out.Put(v)
+		return i < n
+	})
+}
+
+// drop outputs all but the first n value inputs.
+func drop(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var n int
+	iterate := scanArgsAndOptionalIterateWhile(ec, args, &n)
+	TakeNoOpt(opts)
+
+	out := ec.ports[1]
+	i := 0
+	iterate(func(v Value) bool {
+		i++
+		if i > n {
+			out.Put(v)
+		}
+		return true
+	})
+}
+
+// first outputs the first value input. It throws if there is none.
+func first(ec *EvalCtx, args []Value, opts map[string]Value) {
+	iterate := scanArgsAndOptionalIterateWhile(ec, args)
+	TakeNoOpt(opts)
+
+	var found Value
+	iterate(func(v Value) bool {
+		found = v
+		return false
+	})
+	if found == nil {
+		throw(ErrNoInput)
+	}
+	ec.ports[1].Chan <- found
+}
+
+// last outputs the last value input. It throws if there is none.
+func last(ec *EvalCtx, args []Value, opts map[string]Value) {
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	TakeNoOpt(opts)
+
+	var found Value
+	iterate(func(v Value) {
+		found = v
 	})
+	if found == nil {
+		throw(ErrNoInput)
+	}
+	ec.ports[1].Chan <- found
 }
 
 func rangeFn(ec *EvalCtx, args []Value, opts map[string]Value) {
@@ -759,9 +903,20 @@ func rangeFn(ec *EvalCtx, args []Value, opts map[string]Value) {
 		throw(ErrArgs)
 	}
 
-	out := ec.ports[1].Chan
-	for i := lower; i < upper; i += step {
-		out <- String(fmt.Sprintf("%g", i))
+	out := ec.ports[1]
+	switch {
+	case step > 0:
+		for i := lower; i < upper; i += step {
+			ec.checkInterrupted()
+			out.Put(String(fmt.Sprintf("%g", i)))
+		}
+	case step < 0:
+		for i := lower; i > upper; i += step {
+			ec.checkInterrupted()
+			out.Put(String(fmt.Sprintf("%g", i)))
+		}
+	default:
+		throw(ErrZeroStep)
 	}
 }
 
@@ -794,6 +949,260 @@ func count(ec *EvalCtx, args []Value, opts map[string]Value) {
 	ec.ports[1].Chan <- String(strconv.Itoa(n))
 }
 
+// order outputs the value inputs in sorted order. Values are compared as
+// strings unless &numeric is true, in which case they are compared as
+// numbers. When &key is a function, it is called with each value and its sole
+// output is compared instead of the value.
+func order(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		reverse, numeric, stable Bool
+		key                      Value
+		collate                  String
+	)
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	ScanOpts(opts,
+		Opt{"reverse", &reverse, Bool(false)},
+		Opt{"numeric", &numeric, Bool(false)},
+		Opt{"stable", &stable, Bool(false)},
+		Opt{"key", &key, String("")},
+		Opt{"collate", &collate, String("bytes")})
+	collateLess := getCollation(string(collate))
+
+	var keyFn CallableValue
+	if key != String("") {
+		var ok bool
+		keyFn, ok = key.(CallableValue)
+		if !ok {
+			throwf("&key should be function, got %s", key.Kind())
+		}
+	}
+
+	var values, keys []Value
+	iterate(func(v Value) {
+		values = append(values, v)
+	})
+	keys = values
+	if keyFn != nil {
+		keys = make([]Value, len(values))
+		for i, v := range values {
+			outs, err := ec.PCaptureOutput(keyFn, []Value{v}, NoOpts)
+			maybeThrow(err)
+			if len(outs) != 1 {
+				throwf("&key should output one value, got %d", len(outs))
+			}
+			keys[i] = outs[0]
+		}
+	}
+
+	var less func(i, j int) bool
+	if numeric {
+		nums := make([]float64, len(keys))
+		for i, k := range keys {
+			f, err := toFloat(k)
+			maybeThrow(err)
+			nums[i] = f
+		}
+		less = func(i, j int) bool { return nums[i] < nums[j] }
+	} else {
+		less = func(i, j int) bool {
+			a, aok := keys[i].(String)
+			b, bok := keys[j].(String)
+			if aok && bok {
+				return collateLess(string(a), string(b))
+			}
+			return Less(keys[i], keys[j])
+		}
+	}
+
+	// Sort a permutation, so that the keys and values stay together.
+	perm := make([]int, len(values))
+	for i := range perm {
+		perm[i] = i
+	}
+	lessPerm := func(i, j int) bool {
+		if reverse {
+			return less(perm[j], perm[i])
+		}
+		return less(perm[i], perm[j])
+	}
+	if stable {
+		sort.SliceStable(perm, lessPerm)
+	} else {
+		sort.Slice(perm, lessPerm)
+	}
+
+	out := ec.ports[1].Chan
+	for _, i := range perm {
+		out <- values[i]
+	}
+}
+
+// uniq outputs the value inputs with consecutive duplicates removed, or with
+// all duplicates removed when &global is true.
+func uniq(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var global Bool
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	ScanOpts(opts, Opt{"global", &global, Bool(false)})
+
+	out := ec.ports[1].Chan
+	if global {
+		ck := canonicalKeys{}
+		seen := make(map[Value]bool)
+		iterate(func(v Value) {
+			k := ck.key(v)
+			if !seen[k] {
+				seen[k] = true
+				out <- v
+			}
+		})
+		return
+	}
+	var last Value
+	iterate(func(v Value) {
+		if last == nil || !DeepEq(last, v) {
+			out <- v
+		}
+		last = v
+	})
+}
+
+// canonicalKeys maps values to canonical keys for Go maps, so that equal lists
+// and maps share a key; the first value seen is used as the key.
+type canonicalKeys map[uint32][]Value
+
+func (ck canonicalKeys) key(v Value) Value {
+	h := Hash(v)
+	for _, k := range ck[h] {
+		if Eq(k, v) {
+			return k
+		}
+	}
+	ck[h] = append(ck[h], v)
+	return v
+}
+
+// frequencies outputs a map from each distinct value input to the number of
+// times it appears.
+func frequencies(ec *EvalCtx, args []Value, opts map[string]Value) {
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	TakeNoOpt(opts)
+
+	ck := canonicalKeys{}
+	counts := make(map[Value]int)
+	iterate(func(v Value) {
+		counts[ck.key(v)]++
+	})
+	m := make(map[Value]Value, len(counts))
+	for k, n := range counts {
+		m[k] = String(strconv.Itoa(n))
+	}
+	ec.ports[1].Chan <- NewMap(m)
+}
+
+// groupBy outputs a map from the output of f on each value input to a list of
+// the value inputs with that output, in their original order.
+func groupBy(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var f CallableValue
+	iterate := ScanArgsAndOptionalIterate(ec, args, &f)
+	TakeNoOpt(opts)
+
+	ck := canonicalKeys{}
+	groups := make(map[Value][]Value)
+	iterate(func(v Value) {
+		outs, err := ec.PCaptureOutput(f, []Value{v}, NoOpts)
+		maybeThrow(err)
+		if len(outs) != 1 {
+			throwf("function should output one value, got %d", len(outs))
+		}
+		k := ck.key(outs[0])
+		groups[k] = append(groups[k], v)
+	})
+	m := make(map[Value]Value, len(groups))
+	for k, vs := range groups {
+		m[k] = NewList(vs...)
+	}
+	ec.ports[1].Chan <- NewMap(m)
+}
+
+// zip outputs lists of the corresponding elements of the iterables. It stops
+// at the end of the shortest iterable, unless &longest is true, in which case
+// the missing elements of shorter iterables are replaced by &fill.
+func zip(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var iterables []IterableValue
+	ScanArgsVariadic(args, &iterables)
+	var (
+		longest Bool
+		fill    Value
+	)
+	ScanOpts(opts, Opt{"longest", &longest, Bool(false)},
+		Opt{"fill", &fill, String("")})
+
+	if len(iterables) == 0 {
+		return
+	}
+	columns := make([][]Value, len(iterables))
+	n := -1
+	for i, iterable := range iterables {
+		iterable.Iterate(func(v Value) bool {
+			columns[i] = append(columns[i], v)
+			return true
+		})
+		if n == -1 || (len(columns[i]) > n) == bool(longest) {
+			n = len(columns[i])
+		}
+	}
+
+	out := ec.ports[1]
+	for i := 0; i < n; i++ {
+		row := make([]Value, len(columns))
+		for j, column := range columns {
+			if i < len(column) {
+				row[j] = column[i]
+			} else {
+				row[j] = fill
+			}
+		}
+		out.Put(NewList(row...))
+	}
+}
+
+// unzip is the inverse of zip. It takes iterables of the same length n as
+// value inputs, and outputs n lists, the i-th of which contains the i-th
+// elements of all the inputs.
+func unzip(ec *EvalCtx, args []Value, opts map[string]Value) {
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	TakeNoOpt(opts)
+
+	var columns [][]Value
+	rows := 0
+	iterate(func(v Value) {
+		iterable, ok := v.(Iterable)
+		if !ok {
+			throwf("need iterable input, got %s", v.Kind())
+		}
+		j := 0
+		iterable.Iterate(func(e Value) bool {
+			if rows == 0 {
+				columns = append(columns, nil)
+			} else if j >= len(columns) {
+				throw(ErrUnevenLengths)
+			}
+			columns[j] = append(columns[j], e)
+			j++
+			return true
+		})
+		if j != len(columns) {
+			throw(ErrUnevenLengths)
+		}
+		rows++
+	})
+
+	out := ec.ports[1]
+	for _, column := range columns {
+		out.Put(NewList(column...))
+	}
+}
+
 // joins joins all input strings with a delimiter.
 func joins(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var sepv String
@@ -840,29 +1249,6 @@ func ord(ec *EvalCtx, args []Value, opts map[string]Value) {
 	}
 }
 
-// ErrBadBase is thrown by the "base" builtin if the base is smaller than 2 or
-// greater than 36.
-var ErrBadBase = errors.New("bad base")
-
-func base(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var (
-		b    int
-		nums []int
-	)
-	ScanArgsVariadic(args, &b, &nums)
-	TakeNoOpt(opts)
-
-	if b < 2 || b > 36 {
-		throw(ErrBadBase)
-	}
-
-	out := ec.ports[1].Chan
-
-	for _, num := range nums {
-		out <- String(strconv.FormatInt(int64(num), b))
-	}
-}
-
 func wcswidth(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var s String
 	ScanArgs(args, &s)
@@ -953,14 +1339,30 @@ func cd(ec *EvalCtx, args []Value, opts map[string]Value) {
 		dir = mustGetHome("")
 	} else if len(args) == 1 {
 		dir = ToString(args[0])
+		if dir == "-" {
+			var ok bool
+			dir, ok = os.LookupEnv("OLDPWD")
+			if !ok {
+				throw(ErrNoOldpwd)
+			}
+		}
 	} else {
 		throw(ErrArgs)
 	}
 
-	cdInner(dir, ec)
+	ec.CheckChdir(dir)
+	err := Chdir(dir, ec.Daemon)
+	if os.IsNotExist(err) {
+		if fixed, ok := ec.correctDir(dir); ok {
+			ec.CheckChdir(fixed)
+			err = Chdir(fixed, ec.Daemon)
+		}
+	}
+	maybeThrow(err)
 }
 
 func cdInner(dir string, ec *EvalCtx) {
+	ec.CheckChdir(dir)
 	maybeThrow(Chdir(dir, ec.Daemon))
 }
 
@@ -1012,114 +1414,26 @@ func not(ec *EvalCtx, args []Value, opts map[string]Value) {
 	ec.OutputChan() <- Bool(!ToBool(v))
 }
 
-func plus(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var nums []float64
-	ScanArgsVariadic(args, &nums)
-	TakeNoOpt(opts)
-
-	out := ec.ports[1].Chan
-	sum := 0.0
-	for _, f := range nums {
-		sum += f
-	}
-	out <- String(fmt.Sprintf("%g", sum))
-}
-
-func minus(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var (
-		sum  float64
-		nums []float64
-	)
-	ScanArgsVariadic(args, &sum, &nums)
-	TakeNoOpt(opts)
-
-	out := ec.ports[1].Chan
-	if len(nums) == 0 {
-		// Unary -
-		sum = -sum
-	} else {
-		for _, f := range nums {
-			sum -= f
-		}
-	}
-	out <- String(fmt.Sprintf("%g", sum))
-}
-
-func times(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var nums []float64
-	ScanArgsVariadic(args, &nums)
-	TakeNoOpt(opts)
-
-	out := ec.ports[1].Chan
-	prod := 1.0
-	for _, f := range nums {
-		prod *= f
-	}
-	out <- String(fmt.Sprintf("%g", prod))
-}
-
-func slash(ec *EvalCtx, args []Value, opts map[string]Value) {
-	TakeNoOpt(opts)
-	if len(args) == 0 {
-		// cd /
-		cdInner("/", ec)
-		return
-	}
-	// Division
-	divide(ec, args, opts)
-}
-
-func divide(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var (
-		prod float64
-		nums []float64
-	)
-	ScanArgsVariadic(args, &prod, &nums)
-	TakeNoOpt(opts)
-
-	out := ec.ports[1].Chan
-	for _, f := range nums {
-		prod /= f
-	}
-	out <- String(fmt.Sprintf("%g", prod))
-}
-
-func pow(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var b, p float64
-	ScanArgs(args, &b, &p)
-	TakeNoOpt(opts)
-
-	out := ec.ports[1].Chan
-	out <- String(fmt.Sprintf("%g", math.Pow(b, p)))
-}
-
-func mod(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var a, b int
-	ScanArgs(args, &a, &b)
-	TakeNoOpt(opts)
-
-	out := ec.ports[1].Chan
-	out <- String(strconv.Itoa(a % b))
-}
-
 func randFn(ec *EvalCtx, args []Value, opts map[string]Value) {
 	TakeNoArg(args)
-	TakeNoOpt(opts)
+	var crypto Bool
+	ScanOpts(opts, Opt{"crypto", &crypto, Bool(false)})
 
 	out := ec.ports[1].Chan
-	out <- String(fmt.Sprint(rand.Float64()))
+	out <- String(fmt.Sprint(rng(crypto).Float64()))
 }
 
 func randint(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var low, high int
 	ScanArgs(args, &low, &high)
-	TakeNoOpt(opts)
+	var crypto Bool
+	ScanOpts(opts, Opt{"crypto", &crypto, Bool(false)})
 
 	if low >= high {
 		throw(ErrArgs)
 	}
 	out := ec.ports[1].Chan
-	i := low + rand.Intn(high-low)
+	i := low + rng(crypto).Intn(high-low)
 	out <- String(strconv.Itoa(i))
 }
 
@@ -1153,15 +1467,28 @@ func searchExternal(ec *EvalCtx, args []Value, opts map[string]Value) {
 	out <- String(path)
 }
 
+// fopen opens a file for reading, or for writing if &write or &append is true.
+// Files opened for writing are created if they don't exist, and truncated
+// unless &append is true.
 func fopen(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var namev String
 	ScanArgs(args, &namev)
 	name := string(namev)
-	TakeNoOpt(opts)
-
-	// TODO support opening files for writing etc as well.
+	var writeOpt, appendOpt Bool
+	ScanOpts(opts, Opt{"write", &writeOpt, Bool(false)},
+		Opt{"append", &appendOpt, Bool(false)})
+
+	flag := os.O_RDONLY
+	if appendOpt {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	} else if writeOpt {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	if flag != os.O_RDONLY {
+		ec.CheckWrite(name)
+	}
 	out := ec.ports[1].Chan
-	f, err := os.Open(name)
+	f, err := os.OpenFile(name, flag, defaultFileRedirPerm)
 	maybeThrow(err)
 	out <- File{f}
 }
@@ -1200,52 +1527,28 @@ func pwclose(ec *EvalCtx, args []Value, opts map[string]Value) {
 	maybeThrow(p.w.Close())
 }
 
-func fg(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var pids []int
-	ScanArgsVariadic(args, &pids)
-	TakeNoOpt(opts)
+// withTempFile creates an empty temporary file, calls f with its path, and
+// removes the file when f returns, even if it throws. The file is created in
+// &dir, or the default directory for temporary files if &dir is empty.
+func withTempFile(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var f CallableValue
+	ScanArgs(args, &f)
+	var dir, prefix String
+	ScanOpts(opts, Opt{"dir", &dir, String("")},
+		Opt{"prefix", &prefix, String("elvish-")})
 
-	if len(pids) == 0 {
-		throw(ErrArgs)
-	}
-	var thepgid int
-	for i, pid := range pids {
-		pgid, err := syscall.Getpgid(pid)
-		maybeThrow(err)
-		if i == 0 {
-			thepgid = pgid
-		} else if pgid != thepgid {
-			throw(ErrNotInSameGroup)
-		}
+	if dir == "" {
+		ec.CheckWrite(os.TempDir())
+	} else {
+		ec.CheckWrite(string(dir))
 	}
-
-	err := sys.Tcsetpgrp(0, thepgid)
+	file, err := ioutil.TempFile(string(dir), string(prefix))
 	maybeThrow(err)
+	name := file.Name()
+	defer os.Remove(name)
+	maybeThrow(file.Close())
 
-	errors := make([]*Exception, len(pids))
-
-	for i, pid := range pids {
-		err := syscall.Kill(pid, syscall.SIGCONT)
-		if err != nil {
-			errors[i] = &Exception{err, nil}
-		}
-	}
-
-	for i, pid := range pids {
-		if errors[i] != nil {
-			continue
-		}
-		var ws syscall.WaitStatus
-		_, err = syscall.Wait4(pid, &ws, syscall.WUNTRACED, nil)
-		if err != nil {
-			errors[i] = &Exception{err, nil}
-		} else {
-			// TODO find command name
-			errors[i] = &Exception{NewExternalCmdExit(fmt.Sprintf("(pid %d)", pid), ws, pid), nil}
-		}
-	}
-
-	maybeThrow(ComposeExceptionsFromPipeline(errors))
+	f.Call(ec, []Value{String(name)}, NoOpts)
 }
 
 func exec(ec *EvalCtx, args []Value, opts map[string]Value) {
@@ -1261,13 +1564,15 @@ func exec(ec *EvalCtx, args []Value, opts map[string]Value) {
 		}
 	}
 
+	name := argstrings[0]
 	var err error
-	argstrings[0], err = ec.Search(argstrings[0])
+	argstrings[0], err = ec.Search(name)
 	maybeThrow(err)
+	ec.CheckExternal(name, argstrings[0])
 
 	preExit(ec)
 
-	err = syscall.Exec(argstrings[0], argstrings, os.Environ())
+	err = syscall.Exec(argstrings[0], argstrings, ec.environ())
 	maybeThrow(err)
 }
 
@@ -1290,19 +1595,6 @@ func exit(ec *EvalCtx, args []Value, opts map[string]Value) {
 	}
 }
 
-func sleep(ec *EvalCtx, args []Value, opts map[string]Value) {
-	var t float64
-	ScanArgs(args, &t)
-	TakeNoOpt(opts)
-
-	d := time.Duration(float64(time.Second) * t)
-	select {
-	case <-ec.Interrupts():
-		throw(ErrInterrupted)
-	case <-time.After(d):
-	}
-}
-
 func _time(ec *EvalCtx, args []Value, opts map[string]Value) {
 	var f CallableValue
 	ScanArgs(args, &f)
@@ -1403,6 +1695,9 @@ func toRune(arg Value) (rune, error) {
 }
 
 func preExit(ec *EvalCtx) {
+	for _, f := range ec.atExit {
+		f()
+	}
 	err := ec.Daemon.Close()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
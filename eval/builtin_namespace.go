@@ -15,7 +15,10 @@ func makeBuiltinNamespace(daemon *api.Client) Namespace {
 		"true":  NewRoVariable(Bool(true)),
 		"false": NewRoVariable(Bool(false)),
 		"paths": &EnvPathList{envName: "PATH"},
-		"pwd":   PwdVariable{daemon},
+		"pwd":   PwdVariable{daemon, nil},
+	}
+	for _, opt := range options {
+		ns[opt.Name] = NewPtrVariableWithValidator(opt.Default, opt.Validator)
 	}
 	AddBuiltinFns(ns, builtinFns...)
 	return ns
@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
 )
 
 type compileBuiltin func(*compiler, *parse.Form) OpFunc
@@ -59,7 +60,7 @@ func init() {
 func compileDel(cp *compiler, fn *parse.Form) OpFunc {
 	// Do conventional compiling of all compound expressions, including
 	// ensuring that variables can be resolved
-	var names, envNames []string
+	var names, envNames, sharedNames []string
 	for _, cn := range fn.Args {
 		cp.compiling(cn)
 		qname := mustString(cp, cn, "should be a literal variable name")
@@ -76,8 +77,10 @@ func compileDel(cp *compiler, fn *parse.Form) OpFunc {
 			names = append(names, name)
 		case "E":
 			envNames = append(envNames, name)
+		case "shared":
+			sharedNames = append(sharedNames, name)
 		default:
-			cp.errorf("can only delete a variable in local: or E:")
+			cp.errorf("can only delete a variable in local:, E: or shared:")
 		}
 
 	}
@@ -86,10 +89,21 @@ func compileDel(cp *compiler, fn *parse.Form) OpFunc {
 			delete(ec.local, name)
 		}
 		for _, name := range envNames {
+			// A variable set by a temporary assignment is only deleted for
+			// the rest of the form.
+			if ec.env.del(name) {
+				continue
+			}
 			// BUG(xiaq): We rely on the fact that os.Unsetenv always returns
 			// nil.
 			os.Unsetenv(name)
 		}
+		if len(sharedNames) > 0 && ec.Daemon == nil {
+			throw(ErrStoreUnconnected)
+		}
+		for _, name := range sharedNames {
+			maybeThrow(ec.Daemon.DelSharedVar(name))
+		}
 	}
 }
 
@@ -114,7 +128,7 @@ func compileFn(cp *compiler, fn *parse.Form) OpFunc {
 	bodyNode := args.nextMustLambda()
 	args.mustEnd()
 
-	cp.registerVariableSet(":" + varName)
+	cp.registerVariableSet("local:" + varName)
 	op := cp.lambda(bodyNode)
 
 	return func(ec *EvalCtx) {
@@ -124,6 +138,7 @@ func compileFn(cp *compiler, fn *parse.Form) OpFunc {
 		ec.local[varName] = NewPtrVariable(&BuiltinFn{"<shouldn't be called>", nop})
 		closure := op(ec)[0].(*Closure)
 		closure.Op = makeFnOp(closure.Op)
+		closure.Name = varName[len(FnPrefix):]
 		ec.local[varName].Set(closure)
 	}
 }
@@ -195,6 +210,7 @@ func use(ec *EvalCtx, modname string, pfilename *string) {
 			// File exists. Load it.
 			source, err = readFileUTF8(filename)
 			maybeThrow(err)
+			util.RegisterSource(filename, source)
 		}
 	}
 
@@ -210,7 +226,8 @@ func use(ec *EvalCtx, modname string, pfilename *string) {
 		filename, source,
 		local, Namespace{},
 		ec.ports, nil,
-		0, len(source), ec.addTraceback(), false,
+		0, len(source), ec.addTraceback(), "", false, nil, ec.callDepth, nil, nil,
+		ec.limiter, ec.intCh, ec.profiler, ec.stepper,
 	}
 
 	op, err := newEc.Compile(n, filename, source)
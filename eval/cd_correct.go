@@ -0,0 +1,105 @@
+package eval
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/elves/elvish/parse"
+)
+
+// maxCdCorrectDistance is the largest edit distance between a mistyped
+// directory name and its correction.
+const maxCdCorrectDistance = 2
+
+// closestDir returns the directory next to path whose name is closest to that
+// of path, if there is exactly one within maxCdCorrectDistance edits, and the
+// name is long enough for that many edits to make sense.
+func closestDir(path string) (string, bool) {
+	parent, base := filepath.Split(path)
+	if base == "" || len(base) <= maxCdCorrectDistance {
+		return "", false
+	}
+	listParent := parent
+	if listParent == "" {
+		listParent = "."
+	}
+	infos, err := ioutil.ReadDir(listParent)
+	if err != nil {
+		return "", false
+	}
+	best, bestDistance, unique := "", maxCdCorrectDistance+1, false
+	for _, info := range infos {
+		if !isDir(listParent, info.Name()) {
+			continue
+		}
+		d := editDistance(base, info.Name())
+		if d < bestDistance {
+			best, bestDistance, unique = info.Name(), d, true
+		} else if d == bestDistance {
+			unique = false
+		}
+	}
+	if !unique {
+		return "", false
+	}
+	return parent + best, true
+}
+
+// isDir returns whether name in parent is a directory, following symlinks.
+func isDir(parent, name string) bool {
+	info, err := os.Stat(filepath.Join(parent, name))
+	return err == nil && info.IsDir()
+}
+
+// editDistance returns the Levenshtein distance between a and b, counting
+// runes.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur := prev + cost
+			if row[j]+1 < cur {
+				cur = row[j] + 1
+			}
+			if row[j-1]+1 < cur {
+				cur = row[j-1] + 1
+			}
+			prev, row[j] = row[j], cur
+		}
+	}
+	return row[len(rb)]
+}
+
+// correctDir finds a correction for a directory that cd has failed to change
+// to, and returns it if the cd-autocorrect option is on, or the user accepts
+// it when asked through the editor.
+func (ec *EvalCtx) correctDir(dir string) (string, bool) {
+	fixed, ok := closestDir(dir)
+	if !ok {
+		return "", false
+	}
+	if ec.option("cd-autocorrect") {
+		fmt.Fprintf(ec.ports[2].File, "cd: correcting %s to %s\n",
+			parse.Quote(dir), parse.Quote(fixed))
+		return fixed, true
+	}
+	if confirmer, ok := ec.Editor.(Confirmer); ok {
+		if confirmer.Confirm(fmt.Sprintf("cd: correct %s to %s?",
+			parse.Quote(dir), parse.Quote(fixed))) {
+			return fixed, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,85 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+var editDistanceTests = []struct {
+	a, b string
+	want int
+}{
+	{"", "", 0},
+	{"abc", "abc", 0},
+	{"abc", "", 3},
+	{"kitten", "sitting", 3},
+	{"docs", "dcos", 2},
+	{"lé", "le", 1},
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, test := range editDistanceTests {
+		if got := editDistance(test.a, test.b); got != test.want {
+			t.Errorf("editDistance(%q, %q) => %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestCdCorrect(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tmpdir, _ = filepath.EvalSymlinks(tmpdir)
+	for _, name := range []string{"documents", "downloads", "music", "musik"} {
+		os.Mkdir(filepath.Join(tmpdir, name), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(tmpdir, "documentz"), nil, 0644)
+
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+	os.Chdir(tmpdir)
+
+	for _, test := range []struct {
+		path, want string
+		wantOK     bool
+	}{
+		{"documnets", "documents", true},
+		{"downlods", "downloads", true},
+		{tmpdir + "/documnets", tmpdir + "/documents", true},
+		// Ambiguous.
+		{"musi", "", false},
+		// Too far.
+		{"pictures", "", false},
+		// Too short.
+		{"do", "", false},
+		// No such parent.
+		{"nonexistent/documents", "", false},
+	} {
+		got, ok := closestDir(test.path)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("closestDir(%q) => (%q, %v), want (%q, %v)",
+				test.path, got, ok, test.want, test.wantOK)
+		}
+	}
+
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ports := []*Port{
+		{File: os.Stdin, Chan: ClosedChan},
+		{File: os.Stdout, Chan: BlackholeChan},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	if err := ev.SourceTextWithPorts(ports, "[test]", "cd documnets"); err == nil {
+		t.Errorf("cd to a mistyped directory succeeded without cd-autocorrect")
+	}
+	err = ev.SourceTextWithPorts(ports, "[test]", "cd-autocorrect = $true; cd documnets")
+	if pwd, _ := os.Getwd(); err != nil || pwd != filepath.Join(tmpdir, "documents") {
+		t.Errorf("cd with cd-autocorrect => %v, pwd %s", err, pwd)
+	}
+}
@@ -2,18 +2,30 @@ package eval
 
 import (
 	"os"
+	"sync"
 
 	"github.com/elves/elvish/daemon/api"
 )
 
-// Chdir changes the current directory. On success it also updates the PWD
-// environment variable and records the new directory in the directory history.
-// It returns nil as long as the directory changing part succeeds.
+// chdirMutex serializes directory changes, which affect the whole process, so
+// that PWD and OLDPWD agree with the working directory even when multiple
+// Evalers change it concurrently.
+var chdirMutex sync.Mutex
+
+// Chdir changes the current directory. On success it also updates the PWD and
+// OLDPWD environment variables and records the new directory in the directory
+// history. It returns nil as long as the directory changing part succeeds.
 func Chdir(path string, daemon *api.Client) error {
+	chdirMutex.Lock()
+	defer chdirMutex.Unlock()
+	oldpwd, oldErr := os.Getwd()
 	err := os.Chdir(path)
 	if err != nil {
 		return err
 	}
+	if oldErr == nil {
+		os.Setenv("OLDPWD", oldpwd)
+	}
 	pwd, err := os.Getwd()
 	if err != nil {
 		logger.Println("getwd after cd:", err)
@@ -0,0 +1,229 @@
+package eval
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Time builtins. Points in time are represented as Unix timestamps in
+// seconds, and durations as numbers of seconds, so that they work with the
+// arithmetic builtins. Where a duration is expected, strings in the syntax
+// of Go's time.ParseDuration, like 1m30s, are also accepted.
+
+// ErrBadDuration is thrown when a value is neither a number nor a duration
+// string.
+var ErrBadDuration = errors.New("bad duration")
+
+// timeLayouts maps names of layouts to Go layouts.
+var timeLayouts = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"rfc1123":  time.RFC1123,
+	"kitchen":  time.Kitchen,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+}
+
+// timeLayout resolves a layout name. Strings that are not layout names are
+// used as Go layouts directly.
+func timeLayout(name String) string {
+	if layout, ok := timeLayouts[string(name)]; ok {
+		return layout
+	}
+	return string(name)
+}
+
+func toDuration(v Value) time.Duration {
+	if s, ok := v.(String); ok {
+		if f, err := strconv.ParseFloat(string(s), 64); err == nil {
+			return time.Duration(f * float64(time.Second))
+		}
+		if d, err := time.ParseDuration(string(s)); err == nil {
+			return d
+		}
+	}
+	throw(ErrBadDuration)
+	panic("unreachable")
+}
+
+func formatSeconds(f float64) String {
+	return String(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+func timestamp(t time.Time) String {
+	return formatSeconds(float64(t.UnixNano()) / float64(time.Second))
+}
+
+func fromTimestamp(f float64) time.Time {
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*float64(time.Second)))
+}
+
+// now outputs the current time, as a timestamp or formatted with &layout.
+func now(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	var layout String
+	ScanOpts(opts, Opt{"layout", &layout, String("")})
+
+	t := time.Now()
+	if layout == "" {
+		ec.OutputChan() <- timestamp(t)
+	} else {
+		ec.OutputChan() <- String(t.Format(timeLayout(layout)))
+	}
+}
+
+// timeFormat formats a timestamp, which defaults to the current time.
+func timeFormat(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var layout String
+	ScanOpts(opts, Opt{"layout", &layout, String("rfc3339")})
+
+	t := time.Now()
+	switch len(args) {
+	case 0:
+	case 1:
+		var f float64
+		ScanArgs(args, &f)
+		t = fromTimestamp(f)
+	default:
+		throw(ErrArgs)
+	}
+	ec.OutputChan() <- String(t.Format(timeLayout(layout)))
+}
+
+// timeParse parses a time and outputs its timestamp.
+func timeParse(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var s String
+	ScanArgs(args, &s)
+	var layout String
+	ScanOpts(opts, Opt{"layout", &layout, String("rfc3339")})
+
+	t, err := time.ParseInLocation(timeLayout(layout), string(s), time.Local)
+	maybeThrow(err)
+	ec.OutputChan() <- timestamp(t)
+}
+
+// durationParse converts a duration string to a number of seconds.
+func durationParse(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var v Value
+	ScanArgs(args, &v)
+	TakeNoOpt(opts)
+
+	ec.OutputChan() <- formatSeconds(toDuration(v).Seconds())
+}
+
+// durationFormat converts a number of seconds to a duration string.
+func durationFormat(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var v Value
+	ScanArgs(args, &v)
+	TakeNoOpt(opts)
+
+	ec.OutputChan() <- String(toDuration(v).String())
+}
+
+// every calls f every interval, until f breaks or the user interrupts. An
+// interrupt while waiting stops it without an error; the interval is measured
+// from the start of each call, and ticks missed by a slow f are dropped.
+func every(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		v Value
+		f CallableValue
+	)
+	ScanArgs(args, &v, &f)
+	TakeNoOpt(opts)
+	interval := toDuration(v)
+	if interval <= 0 {
+		throw(ErrBadDuration)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := ec.PCall(f, NoArgs, NoOpts)
+		if err != nil {
+			switch err.(*Exception).Cause {
+			case nil, Continue:
+			case Break, ErrInterrupted:
+				return
+			default:
+				// Errors caused by the interrupt, like external commands
+				// killed by SIGINT, also stop it cleanly.
+				select {
+				case <-ec.Interrupts():
+					return
+				default:
+					throw(err)
+				}
+			}
+		}
+		select {
+		case <-ec.Interrupts():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sleep sleeps for a duration, throwing ErrInterrupted if the user
+// interrupts.
+func sleep(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var v Value
+	ScanArgs(args, &v)
+	TakeNoOpt(opts)
+
+	select {
+	case <-ec.Interrupts():
+		ec.throwInterrupted()
+	case <-time.After(toDuration(v)):
+	}
+}
+
+// retry calls f until it succeeds, at most &times times, waiting &backoff
+// before the first retry and doubling the wait before each following one. If
+// all the calls fail, the exception from the last one is thrown. As in other
+// looping builtins such as every and watch, break stops retrying, continue
+// goes on to the next attempt and return is thrown right away. Outputs of
+// failed calls are not discarded.
+func retry(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var f CallableValue
+	ScanArgs(args, &f)
+	var (
+		times   int
+		backoff Value
+	)
+	ScanOpts(opts, Opt{"times", &times, String("5")},
+		Opt{"backoff", &backoff, String("2s")})
+	if times < 1 {
+		throw(ErrArgs)
+	}
+	wait := toDuration(backoff)
+
+	for i := 1; ; i++ {
+		err := ec.PCall(f, NoArgs, NoOpts)
+		if err == nil {
+			return
+		}
+		// Like other looping builtins, retry stops on break and goes on to
+		// the next attempt on continue.
+		switch err.(*Exception).Cause {
+		case Break:
+			return
+		case Continue:
+			if i == times {
+				return
+			}
+		case Return, ErrInterrupted:
+			throw(err)
+		default:
+			if i == times {
+				throw(err)
+			}
+		}
+		select {
+		case <-ec.Interrupts():
+			ec.throwInterrupted()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
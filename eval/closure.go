@@ -3,12 +3,21 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 )
 
 // ErrArityMismatch is thrown by a closure when the number of arguments the user
 // supplies does not match with what is required.
 var ErrArityMismatch = errors.New("arity mismatch")
 
+// ErrMaxCallDepth is thrown by a closure when calling it would exceed
+// $max-call-depth active closure calls.
+var ErrMaxCallDepth = errors.New("maximum recursion depth exceeded")
+
+// defaultMaxCallDepth is the initial value of $max-call-depth.
+const defaultMaxCallDepth = 1000
+
 var unnamedRestArg = "@"
 
 // Closure is a closure defined in elvish script.
@@ -22,6 +31,11 @@ type Closure struct {
 	Captured   map[string]Variable
 	SourceName string
 	Source     string
+	// Position of the closure literal in Source.
+	Begin, End int
+	// Name of the function, set when the closure is defined with fn. Empty
+	// for anonymous closures.
+	Name string
 }
 
 var _ CallableValue = &Closure{}
@@ -36,6 +50,25 @@ func (c *Closure) Repr(int) string {
 	return fmt.Sprintf("<closure %p>", c)
 }
 
+// Location returns where the closure is defined, in the form of
+// name:line:column.
+func (c *Closure) Location() string {
+	before := c.Source[:c.Begin]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return fmt.Sprintf("%s:%d:%d", c.SourceName, line, column)
+}
+
+// Definition returns the source of the closure literal, preceded by "fn name "
+// if the closure is defined with fn.
+func (c *Closure) Definition() string {
+	text := c.Source[c.Begin:c.End]
+	if c.Name != "" {
+		return "fn " + c.Name + " " + text
+	}
+	return text
+}
+
 // Call calls a closure.
 func (c *Closure) Call(ec *EvalCtx, args []Value, opts map[string]Value) {
 	// TODO Support keyword arguments
@@ -49,6 +82,13 @@ func (c *Closure) Call(ec *EvalCtx, args []Value, opts map[string]Value) {
 		}
 	}
 
+	ec.callDepth++
+	if ec.callDepth > ec.maxCallDepth() {
+		throw(ErrMaxCallDepth)
+	}
+	// Options set with set-option in the closure are restored when it returns.
+	ec.options = &optionScope{parent: ec.options}
+
 	// This evalCtx is dedicated to the current form, so we modify it in place.
 	// BUG(xiaq): When evaluating closures, async access to global variables
 	// and ports can be problematic.
@@ -74,10 +114,23 @@ func (c *Closure) Call(ec *EvalCtx, args []Value, opts map[string]Value) {
 	for k, v := range opts {
 		convertedOpts[String(k)] = v
 	}
-	ec.local["opts"] = NewPtrVariable(Map{&convertedOpts})
+	ec.local["opts"] = NewPtrVariable(NewMap(convertedOpts))
 
 	ec.traceback = ec.addTraceback()
 
 	ec.srcName, ec.src = c.SourceName, c.Source
+	ec.fnName = c.Name
+	if ec.fnName == "" {
+		ec.fnName = "<closure>"
+	}
 	c.Op.Exec(ec)
 }
+
+// maxCallDepth returns the value of $max-call-depth.
+func (ec *EvalCtx) maxCallDepth() int {
+	n, err := toInt(ec.getOption("max-call-depth"))
+	if err != nil {
+		return defaultMaxCallDepth
+	}
+	return n
+}
@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrBadCollation is thrown when an unknown collation is requested.
+var ErrBadCollation = errors.New("collation must be bytes, icase or version")
+
+// collations maps names of collations to functions that determine whether a
+// string comes before another one.
+//
+// The bytes collation compares strings byte-wise. The icase collation ignores
+// case, and the version collation compares runs of digits numerically, so that
+// a2 comes before a10. Strings that are equal under the icase or version
+// collations are ordered byte-wise.
+var collations = map[string]func(a, b string) bool{
+	"bytes":   func(a, b string) bool { return a < b },
+	"icase":   lessIcase,
+	"version": lessVersion,
+}
+
+func getCollation(name string) func(a, b string) bool {
+	less, ok := collations[name]
+	if !ok {
+		throw(ErrBadCollation)
+	}
+	return less
+}
+
+func lessIcase(a, b string) bool {
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la != lb {
+		return la < lb
+	}
+	return a < b
+}
+
+func lessVersion(a, b string) bool {
+	if c := compareVersion(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
+// compareVersion compares two strings, comparing runs of digits by their
+// numeric values and other characters byte-wise.
+func compareVersion(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			da, db := digitsPrefix(a), digitsPrefix(b)
+			a, b = a[len(da):], b[len(db):]
+			da, db = strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(da) != len(db) {
+				return len(da) - len(db)
+			}
+			if c := strings.Compare(da, db); c != 0 {
+				return c
+			}
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+func digitsPrefix(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i]
+}
@@ -1,10 +1,6 @@
 package eval
 
-import (
-	"errors"
-
-	"github.com/elves/elvish/parse"
-)
+import "github.com/elves/elvish/parse"
 
 // LValuesOp is an operation on an EvalCtx that produce Variable's.
 type LValuesOp struct {
@@ -98,6 +94,7 @@ func (cp *compiler) lvaluesMulti(nodes []*parse.Compound) (LValuesOp, LValuesOp)
 
 func (cp *compiler) lvaluesOne(n *parse.Indexing, msg string) (bool, LValuesOpFunc) {
 	varname := cp.literal(n.Head, msg)
+	cp.compiling(n)
 	cp.registerVariableSet(varname)
 	explode, ns, barename := ParseAndFixVariable(varname)
 
@@ -134,30 +131,27 @@ func (cp *compiler) lvaluesOne(n *parse.Indexing, msg string) (bool, LValuesOpFu
 		// Indexing. Do Index up to the last but one index.
 		value := variable.Get()
 		n := len(indexOps)
+		indicies := make([]Value, n)
 		// TODO set location information according.
-		for _, op := range indexOps[:n-1] {
-			indexer := mustIndexer(value, ec)
-
-			indicies := op.Exec(ec)
-			values := indexer.Index(indicies)
+		for i, op := range indexOps {
+			values := op.Exec(ec)
 			if len(values) != 1 {
-				throw(errors.New("multi indexing not implemented"))
+				ec.errorpf(op.Begin, op.End, "index must eval to a single Value (got %v)", values)
+			}
+			indicies[i] = values[0]
+			if i < n-1 {
+				value = mustIndexer(value, ec).Index(values)[0]
 			}
-			value = values[0]
 		}
-		// Now this must be an IndexSetter.
-		indexSetter, ok := value.(IndexSetter)
-		if !ok {
+		// Now this must be an IndexSetter or an Assocer.
+		switch value.(type) {
+		case IndexSetter, Assocer:
+		default:
 			// XXX the indicated end location will fall on or after the opening
 			// bracket of the last index, instead of exactly on the penultimate
 			// index.
 			ec.errorpf(p, indexOps[n-1].Begin, "cannot be indexed for setting (value is %s, type %s)", value.Repr(NoPretty), value.Kind())
 		}
-		// XXX Duplicate code.
-		indicies := indexOps[n-1].Exec(ec)
-		if len(indicies) != 1 {
-			ec.errorpf(indexOps[n-1].Begin, indexOps[n-1].End, "index must eval to a single Value (got %v)", indicies)
-		}
-		return []Variable{elemVariable{indexSetter, indicies[0]}}
+		return []Variable{elemVariable{variable, indicies}}
 	}
 }
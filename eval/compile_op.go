@@ -3,7 +3,11 @@ package eval
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/elves/elvish/parse"
 )
@@ -20,21 +24,55 @@ type OpFunc func(*EvalCtx)
 // Exec executes an Op.
 func (op Op) Exec(ec *EvalCtx) {
 	ec.begin, ec.end = op.Begin, op.End
+	if ec.limiter != nil {
+		ec.limiter.check()
+	}
 	op.Func(ec)
 }
 
 func (cp *compiler) chunk(n *parse.Chunk) OpFunc {
-	ops := cp.pipelineOps(n.Pipelines)
+	ops := make([]Op, 0, len(n.Pipelines))
+	for _, pn := range n.Pipelines {
+		if op, ok := cp.tryPipelineOp(pn); ok {
+			ops = append(ops, op)
+		}
+	}
 
 	return func(ec *EvalCtx) {
+		if ec.option("errexit") {
+			for _, op := range ops {
+				op.Exec(ec)
+			}
+			return
+		}
+		// When errexit is off, a failing pipeline has its exception printed
+		// and does not stop the chunk. Control flows still propagate.
 		for _, op := range ops {
-			op.Exec(ec)
+			err := ec.PEval(op)
+			if err == nil {
+				continue
+			}
+			if _, ok := err.(*Exception).Cause.(Flow); ok {
+				throw(err)
+			}
+			if p := ec.port(2); p != nil && p.File != nil {
+				p.File.WriteString(err.(*Exception).Pprint("") + "\n")
+			}
 		}
 	}
 }
 
 const pipelineChanBufferSize = 32
 
+// jobNotice returns the line that announces the end of a background job.
+func jobNotice(id int32, src string, d time.Duration, err error) string {
+	d -= d % time.Millisecond
+	if err != nil {
+		return fmt.Sprintf("job %d (%s) failed after %s: %s", id, src, d, err)
+	}
+	return fmt.Sprintf("job %d (%s) done after %s", id, src, d)
+}
+
 func (cp *compiler) pipeline(n *parse.Pipeline) OpFunc {
 	ops := cp.formOps(n.Forms)
 
@@ -44,12 +82,25 @@ func (cp *compiler) pipeline(n *parse.Pipeline) OpFunc {
 			ec = ec.fork("background job " + n.SourceText())
 			ec.intCh = nil
 			ec.background = true
+			// Background jobs outlive the profile builtin that started
+			// them, so they are not profiled, and they don't stop when the
+			// debugger steps through the code that started them.
+			ec.profiler = nil
+			ec.stepper = &stepper{}
 
 			if ec.Editor != nil {
 				// TODO: Redirect output in interactive mode so that the line
 				// editor does not get messed up.
 			}
 		}
+		var (
+			jobID int32
+			start time.Time
+		)
+		if bg {
+			jobID = atomic.AddInt32(&ec.lastJobID, 1)
+			start = time.Now()
+		}
 
 		nforms := len(ops)
 
@@ -74,18 +125,27 @@ func (cp *compiler) pipeline(n *parse.Pipeline) OpFunc {
 					throwf("failed to create pipe: %s", e)
 				}
 				ch := make(chan Value, pipelineChanBufferSize)
+				gone := make(chan struct{})
 				newEc.ports[1] = &Port{
-					File: writer, Chan: ch, CloseFile: true, CloseChan: true}
+					File: writer, Chan: ch, CloseFile: true, CloseChan: true,
+					ReaderGone: gone}
 				nextIn = &Port{
-					File: reader, Chan: ch, CloseFile: true, CloseChan: false}
+					File: reader, Chan: ch, CloseFile: true, CloseChan: false,
+					ReaderGone: gone, CloseReaderGone: true}
 			}
 			thisOp := op
 			thisError := &errors[i]
+			isLast := i == nforms-1
 			go func() {
+				if c := newEc.coverage; c != nil {
+					c.record(newEc.srcName, newEc.src, thisOp.Begin)
+				}
 				err := newEc.PEval(thisOp)
 				// Logger.Printf("closing ports of %s", newEc.context)
 				ClosePorts(newEc.ports)
-				if err != nil {
+				// A form that stopped because the next one stopped reading
+				// has not failed.
+				if err != nil && (isLast || !readerGone(err)) {
 					*thisError = err.(*Exception)
 				}
 				wg.Done()
@@ -96,11 +156,8 @@ func (cp *compiler) pipeline(n *parse.Pipeline) OpFunc {
 			// Background job, wait for form termination asynchronously.
 			go func() {
 				wg.Wait()
-				msg := "job " + n.SourceText() + " finished"
-				err := ComposeExceptionsFromPipeline(errors)
-				if err != nil {
-					msg += ", errors = " + err.Error()
-				}
+				msg := jobNotice(jobID, n.SourceText(), time.Since(start),
+					ComposeExceptionsFromPipeline(errors))
 				if ec.Editor != nil {
 					m := ec.Editor.ActiveMutex()
 					m.Lock()
@@ -117,28 +174,70 @@ func (cp *compiler) pipeline(n *parse.Pipeline) OpFunc {
 			}()
 		} else {
 			wg.Wait()
+			if !ec.option("pipefail") {
+				// Only the last form determines the result of the pipeline.
+				errors = errors[nforms-1:]
+			}
 			maybeThrow(ComposeExceptionsFromPipeline(errors))
 		}
 	}
 }
 
+// envAssignmentName returns the name of the environment variable that an
+// assignment assigns to, if its left hand side is a single E: variable.
+func envAssignmentName(a *parse.Assignment) (string, bool) {
+	if len(a.Left.Indicies) > 0 || a.Left.Head.Type != parse.Bareword {
+		return "", false
+	}
+	explode, ns, name := ParseVariable(a.Left.Head.Value)
+	if explode || ns != "E" {
+		return "", false
+	}
+	return name, true
+}
+
+// readerGone determines whether an error from a form in a pipeline is caused by
+// the next form no longer reading its output: either ErrReaderGone, or an
+// external command killed by SIGPIPE.
+func readerGone(err error) bool {
+	switch cause := err.(*Exception).Cause.(type) {
+	case ExternalCmdExit:
+		return cause.Signaled() && cause.Signal() == syscall.SIGPIPE
+	default:
+		return cause == ErrReaderGone
+	}
+}
+
 func (cp *compiler) form(n *parse.Form) OpFunc {
 	var saveVarsOps []LValuesOp
 	var assignmentOps []Op
+	var envNames []string
+	var envValueOps []ValuesOp
 	if len(n.Assignments) > 0 {
-		assignmentOps = cp.assignmentOps(n.Assignments)
 		if n.Head == nil && n.Vars == nil {
 			// Permanent assignment.
+			assignmentOps = cp.assignmentOps(n.Assignments)
 			return func(ec *EvalCtx) {
 				for _, op := range assignmentOps {
 					op.Exec(ec)
 				}
 			}
 		}
+		var assignments []*parse.Assignment
 		for _, a := range n.Assignments {
+			if name, ok := envAssignmentName(a); ok {
+				// Temporary assignment to an environment variable, which
+				// only applies to this form. It does not touch the
+				// environment of the process, which is shared by all forms.
+				envNames = append(envNames, name)
+				envValueOps = append(envValueOps, cp.compoundOp(a.Right))
+				continue
+			}
+			assignments = append(assignments, a)
 			v, r := cp.lvaluesOp(a.Left)
 			saveVarsOps = append(saveVarsOps, v, r)
 		}
+		assignmentOps = cp.assignmentOps(assignments)
 		logger.Println("temporary assignment of", len(n.Assignments), "pairs")
 	}
 
@@ -187,13 +286,27 @@ func (cp *compiler) form(n *parse.Form) OpFunc {
 	}
 
 	optsOp := cp.mapPairs(n.Opts)
+	cp.checkRedirConflicts(n.Redirs)
 	redirOps := cp.redirOps(n.Redirs)
 	// TODO: n.ErrorRedir
 
 	begin, end := n.Begin(), n.End()
+	standalone := isStandalone(n)
 	// ec here is always a subevaler created in compiler.pipeline, so it can
 	// be safely modified.
 	return func(ec *EvalCtx) {
+		// Temporary assignment to environment variables.
+		if len(envNames) > 0 {
+			env := ec.env.copy()
+			for i, op := range envValueOps {
+				values := op.Exec(ec)
+				ec.must(values, "value of $E:"+envNames[i], op.Begin, op.End).mustLen(1)
+				env.values[envNames[i]] = ToString(values[0])
+				delete(env.deleted, envNames[i])
+			}
+			ec.env = env
+		}
+
 		// Temporary assignment.
 		if len(saveVarsOps) > 0 {
 			// There is a temporary assignment.
@@ -230,12 +343,14 @@ func (cp *compiler) form(n *parse.Form) OpFunc {
 		}
 
 		var headFn Callable
+		var headValue Value
 		var args []Value
 		if headOp.Func != nil {
 			// head
 			headValues := headOp.Exec(ec)
 			ec.must(headValues, "head of command", headOp.Begin, headOp.End).mustLen(1)
-			headFn = mustFn(headValues[0])
+			headValue = headValues[0]
+			headFn = mustFn(headValue)
 
 			// args
 			for _, argOp := range argOps {
@@ -247,23 +362,48 @@ func (cp *compiler) form(n *parse.Form) OpFunc {
 		// XXX This conversion should be avoided.
 		opts := optsOp(ec)[0].(Map)
 		convertedOpts := make(map[string]Value)
-		for k, v := range *opts.inner {
+		opts.IterateKey(func(k Value) bool {
 			if ks, ok := k.(String); ok {
-				convertedOpts[string(ks)] = v
+				convertedOpts[string(ks)] = opts.IndexOne(k)
 			} else {
 				throwf("Option key must be string, got %s", k.Kind())
 			}
-		}
+			return true
+		})
 
 		// redirs
+		trace := ec.option("trace")
+		var traceFile *os.File
+		if trace {
+			// Write the trace to stderr as it is before the redirections.
+			if p := ec.port(2); p != nil {
+				traceFile = p.File
+			}
+		}
 		for _, redirOp := range redirOps {
 			redirOp.Exec(ec)
 		}
 
 		ec.begin, ec.end = begin, end
 
+		if headFn != nil && ec.stepper.take() {
+			ec.debug("step")
+		}
+
+		if traceFile != nil && headFn != nil {
+			traceFile.WriteString(traceLine(headFn, args, convertedOpts, ec.redirTrace))
+		}
+
 		if headFn != nil {
-			headFn.Call(ec, args, convertedOpts)
+			if p := ec.profiler; p != nil {
+				start := time.Now()
+				defer func() {
+					p.record(ec.srcName, ec.src, begin, end, time.Since(start))
+				}()
+			}
+			if !ec.implicitCall(headValue, args, convertedOpts, standalone) {
+				headFn.Call(ec, args, convertedOpts)
+			}
 		} else {
 			spaceyAssignOp.Exec(ec)
 		}
@@ -358,48 +498,44 @@ func (cp *compiler) redir(n *parse.Redir) OpFunc {
 	return func(ec *EvalCtx) {
 		var dst int
 		if dstOp.Func == nil {
-			// use default dst fd
-			switch mode {
-			case parse.Read:
-				dst = 0
-			case parse.Write, parse.ReadWrite, parse.Append:
-				dst = 1
-			default:
-				// XXX should report parser bug
-				panic("bad RedirMode; parser bug")
-			}
+			dst = defaultRedirDst(mode)
 		} else {
 			// dst must be a valid fd
 			dst = ec.must(dstOp.Exec(ec), "FD", dstOp.Begin, dstOp.End).mustOneNonNegativeInt()
 		}
 
-		ec.growPorts(dst + 1)
-		// Logger.Printf("closing old port %d of %s", dst, ec.context)
-		ec.ports[dst].Close()
-
+		// The source is fully evaluated before the old port is touched, so
+		// that a failing source leaves the port table intact.
 		srcMust := ec.must(srcOp.Exec(ec), "redirection source", srcOp.Begin, srcOp.End)
+		var port *Port
 		if sourceIsFd {
 			src := string(srcMust.mustOneStr())
 			if src == "-" {
 				// close
-				ec.ports[dst] = &Port{}
+				port = &Port{}
 			} else {
 				fd := srcMust.zerothMustNonNegativeInt()
-				ec.ports[dst] = ec.ports[fd].Fork()
+				if ec.port(fd) == nil {
+					ec.errorpf(srcOp.Begin, srcOp.End, "fd %d is not open", fd)
+				}
+				port = ec.ports[fd].Fork()
 			}
 		} else {
 			switch src := srcMust.mustOne().(type) {
 			case String:
+				if mode != parse.Read {
+					ec.CheckWrite(string(src))
+				}
 				f, err := os.OpenFile(string(src), flag, defaultFileRedirPerm)
 				if err != nil {
 					throwf("failed to open file %s: %s", src.Repr(NoPretty), err)
 				}
-				ec.ports[dst] = &Port{
+				port = &Port{
 					File: f, Chan: BlackholeChan,
 					CloseFile: true,
 				}
 			case File:
-				ec.ports[dst] = &Port{
+				port = &Port{
 					File: src.inner, Chan: BlackholeChan,
 					CloseFile: false,
 				}
@@ -411,9 +547,9 @@ func (cp *compiler) redir(n *parse.Redir) OpFunc {
 				case parse.Write:
 					f = src.w
 				default:
-					cp.errorf("can only use < or > with pipes")
+					ec.errorpf(srcOp.Begin, srcOp.End, "can only use < or > with pipes")
 				}
-				ec.ports[dst] = &Port{
+				port = &Port{
 					File: f, Chan: BlackholeChan,
 					CloseFile: false,
 				}
@@ -421,5 +557,60 @@ func (cp *compiler) redir(n *parse.Redir) OpFunc {
 				srcMust.error("string or file", "%s", src.Kind())
 			}
 		}
+
+		ec.setPort(dst, port)
+		if ec.option("trace") {
+			ec.redirTrace = append(ec.redirTrace, traceRedir(dst, mode, sourceIsFd, srcMust.mustOne()))
+		}
+	}
+}
+
+// defaultRedirDst returns the destination fd of a redirection without an
+// explicit left-hand side.
+func defaultRedirDst(mode parse.RedirMode) int {
+	switch mode {
+	case parse.Read:
+		return 0
+	case parse.Write, parse.ReadWrite, parse.Append:
+		return 1
+	default:
+		// XXX should report parser bug
+		panic("bad RedirMode; parser bug")
+	}
+}
+
+// checkRedirConflicts reports redirections in the same form that redirect the
+// same fd. Only destinations that are known at compile time are checked.
+func (cp *compiler) checkRedirConflicts(ns []*parse.Redir) {
+	seen := make(map[int]bool)
+	for _, n := range ns {
+		dst, ok := staticRedirDst(n)
+		if !ok {
+			continue
+		}
+		if seen[dst] {
+			cp.errorpf(n.Begin(), n.End(), "conflicting redirections of fd %d", dst)
+		}
+		seen[dst] = true
+	}
+}
+
+// staticRedirDst returns the destination fd of a redirection if it is known
+// at compile time.
+func staticRedirDst(n *parse.Redir) (int, bool) {
+	if n.Left == nil {
+		return defaultRedirDst(n.Mode), true
+	}
+	if len(n.Left.Indexings) != 1 {
+		return 0, false
+	}
+	in := n.Left.Indexings[0]
+	if len(in.Indicies) > 0 || in.Head.Type != parse.Bareword {
+		return 0, false
+	}
+	dst, err := strconv.Atoi(in.Head.Value)
+	if err != nil || dst < 0 {
+		return 0, false
 	}
+	return dst, true
 }
@@ -28,7 +28,13 @@ type ValuesOpFunc func(*EvalCtx) []Value
 // Exec executes a ValuesOp and produces Value's.
 func (op ValuesOp) Exec(ec *EvalCtx) []Value {
 	ec.begin, ec.end = op.Begin, op.End
-	return op.Func(ec)
+	if ec.limiter == nil {
+		return op.Func(ec)
+	}
+	ec.limiter.check()
+	vs := op.Func(ec)
+	ec.limiter.count(vs)
+	return vs
 }
 
 func (cp *compiler) compound(n *parse.Compound) ValuesOpFunc {
@@ -82,6 +88,9 @@ func (cp *compiler) compound(n *parse.Compound) ValuesOpFunc {
 			for _, v := range vs {
 				if gp, ok := v.(GlobPattern); ok {
 					// Logger.Printf("globbing %v", gp)
+					if ec.option("nomatch-ok") {
+						gp.Flags |= NoMatchOK
+					}
 					newvs = append(newvs, doGlob(gp, ec.Interrupts())...)
 				} else {
 					newvs = append(newvs, v)
@@ -94,6 +103,13 @@ func (cp *compiler) compound(n *parse.Compound) ValuesOpFunc {
 }
 
 func cat(lhs, rhs Value) Value {
+	// Secrets are concatenated as ordinary strings.
+	if s, ok := lhs.(Secret); ok {
+		lhs = String(s)
+	}
+	if s, ok := rhs.(Secret); ok {
+		rhs = String(s)
+	}
 	switch lhs := lhs.(type) {
 	case String:
 		switch rhs := rhs.(type) {
@@ -103,7 +119,7 @@ func cat(lhs, rhs Value) Value {
 			segs := stringToSegments(string(lhs))
 			// We know rhs contains exactly one segment.
 			segs = append(segs, rhs.Segments[0])
-			return GlobPattern{glob.Pattern{segs, ""}, rhs.Flags, rhs.Buts}
+			return GlobPattern{glob.Pattern{segs, ""}, rhs.Flags, rhs.Buts, rhs.Collation}
 		}
 	case GlobPattern:
 		// NOTE Modifies lhs in place.
@@ -267,7 +283,7 @@ func (cp *compiler) primary(n *parse.Primary) ValuesOpFunc {
 			cp.errorf("%s", err)
 		}
 		vs := []Value{
-			GlobPattern{glob.Pattern{[]glob.Segment{seg}, ""}, 0, nil}}
+			GlobPattern{glob.Pattern{[]glob.Segment{seg}, ""}, 0, nil, ""}}
 		return func(ec *EvalCtx) []Value {
 			return vs
 		}
@@ -439,13 +455,14 @@ func (cp *compiler) lambda(n *parse.Primary) ValuesOpFunc {
 	}
 
 	name, text := cp.name, cp.text
+	begin, end := n.Begin(), n.End()
 
 	return func(ec *EvalCtx) []Value {
 		evCapture := make(map[string]Variable, len(capture))
 		for name := range capture {
 			evCapture[name] = ec.ResolveVar("", name)
 		}
-		return []Value{&Closure{argNames, restArg, op, evCapture, name, text}}
+		return []Value{&Closure{argNames, restArg, op, evCapture, name, text, begin, end, ""}}
 	}
 }
 
@@ -469,7 +486,7 @@ func (cp *compiler) mapPairs(pairs []*parse.MapPair) ValuesOpFunc {
 		begins[i], ends[i] = pair.Begin(), pair.End()
 	}
 	return func(ec *EvalCtx) []Value {
-		m := make(map[Value]Value)
+		m := NewMap(make(map[Value]Value))
 		for i := 0; i < npairs; i++ {
 			keys := keysOps[i].Exec(ec)
 			values := valuesOps[i].Exec(ec)
@@ -478,10 +495,10 @@ func (cp *compiler) mapPairs(pairs []*parse.MapPair) ValuesOpFunc {
 					"%d keys but %d values", len(keys), len(values))
 			}
 			for j, key := range keys {
-				m[key] = values[j]
+				m = m.Assoc(key, values[j]).(Map)
 			}
 		}
-		return []Value{Map{&m}}
+		return []Value{m}
 	}
 }
 
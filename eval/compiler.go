@@ -17,6 +17,8 @@ type scope map[string]bool
 type compiler struct {
 	// Builtin scope.
 	builtin scope
+	// Names in the builtin scope that are read-only.
+	builtinRo scope
 	// Lexical scopes.
 	scopes []scope
 	// Variables captured from outer scopes.
@@ -25,12 +27,38 @@ type compiler struct {
 	begin, end int
 	// Information about the source.
 	name, text string
+	// Errors collected so far.
+	errors error
 }
 
-func compile(b, g scope, n *parse.Chunk, name, text string) (op Op, err error) {
-	cp := &compiler{b, []scope{g}, scope{}, 0, 0, name, text}
+func compile(b, bRo, g scope, n *parse.Chunk, name, text string) (op Op, err error) {
+	cp := &compiler{b, bRo, []scope{g}, scope{}, 0, 0, name, text, nil}
 	defer util.Catch(&err)
-	return cp.chunkOp(n), nil
+	op = cp.chunkOp(n)
+	if cp.errors != nil {
+		return Op{}, cp.errors
+	}
+	return op, nil
+}
+
+// tryPipelineOp compiles a pipeline. If the compilation fails, the error is
+// recorded instead of aborting the compilation, so that errors in subsequent
+// pipelines can also be reported.
+func (cp *compiler) tryPipelineOp(n *parse.Pipeline) (op Op, ok bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if thrown, isThrown := r.(util.Thrown); isThrown {
+			if ce, isCE := thrown.Error.(*CompilationError); isCE {
+				cp.errors = util.CatError(cp.errors, ce)
+				return
+			}
+		}
+		panic(r)
+	}()
+	return cp.pipelineOp(n), true
 }
 
 func (cp *compiler) compiling(n parse.Node) {
@@ -39,7 +67,8 @@ func (cp *compiler) compiling(n parse.Node) {
 
 func (cp *compiler) errorpf(begin, end int, format string, args ...interface{}) {
 	throw(&CompilationError{fmt.Sprintf(format, args...),
-		util.SourceContext{cp.name, cp.text, begin, end, nil}})
+		util.SourceContext{cp.name, util.ContextSource(cp.name, cp.text),
+			begin, end, nil, ""}})
 }
 
 func (cp *compiler) errorf(format string, args ...interface{}) {
@@ -126,6 +155,11 @@ func (cp *compiler) registerVariableSet(qname string) bool {
 				return true
 			}
 		}
+		// The name resolves to a read-only builtin; setting it would always
+		// fail, so report it now.
+		if cp.builtinRo[name] {
+			cp.errorf("cannot set read-only variable $%s", name)
+		}
 		// New name. Register on this scope!
 		cp.thisScope()[name] = true
 		return true
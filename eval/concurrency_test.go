@@ -0,0 +1,163 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+const concurrentEvals = 8
+
+func TestConcurrentEvalers(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentEvals; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := strconv.Itoa(i)
+			outs, _, err := evalAndCollect(t, []string{
+				"x = " + s, "range 10 | peach [_]{ nop }", "put $x"}, 1)
+			if err != nil {
+				t.Errorf("evaler %d: got error %v", i, err)
+			}
+			if want := strs(s); !reflect.DeepEqual(outs, want) {
+				t.Errorf("evaler %d: got %v, want %v", i, outs, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Evaluations of one Evaler share its global namespace, so this test only
+// uses code that doesn't change it.
+func TestConcurrentEvalsOfOneEvaler(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentEvals; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outCh := make(chan Value, 1)
+			ports := []*Port{
+				{File: os.Stdin, Chan: ClosedChan},
+				{File: os.Stdout, Chan: outCh},
+				{File: os.Stderr, Chan: BlackholeChan},
+			}
+			err := ev.SourceTextWithPorts(ports, "[test]",
+				"range 20 | peach [x]{ if (== $x 3) { break } }; "+
+					"range 20 | peach [_]{ fail bad }")
+			if err == nil || err.(*Exception).Cause.Error() != "bad" {
+				t.Errorf("eval %d: got error %v, want bad", i, err)
+			}
+			if len(outCh) != 0 {
+				t.Errorf("eval %d: got unexpected output %v", i, <-outCh)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// The working directory is shared by all Evalers; concurrent changes keep PWD
+// in agreement with it.
+func TestConcurrentCd(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tmpdir, _ = filepath.EvalSymlinks(tmpdir)
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		dir := filepath.Join(tmpdir, strconv.Itoa(i))
+		os.Mkdir(dir, 0755)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := evalAndCollect(t, []string{
+				"range 100 | each [_]{ cd " + dir + " }"}, 1)
+			if err != nil {
+				t.Errorf("cd %s: got error %v", dir, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	pwd, _ := os.Getwd()
+	if env := os.Getenv("PWD"); env != pwd {
+		t.Errorf("got $E:PWD %q after concurrent cd, want %q", env, pwd)
+	}
+	outs, _, _ := evalAndCollect(t, []string{"put $pwd"}, 1)
+	if want := strs(pwd); !reflect.DeepEqual(outs, want) {
+		t.Errorf("got $pwd %v in another Evaler, want %v", outs, want)
+	}
+}
+
+// Profiling, the debugger and string indexing keep their state per
+// evaluation, so they can be used by concurrent evaluations of one Evaler.
+func TestConcurrentProfileDebugAndIndex(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentEvals; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			outCh := make(chan Value, 20)
+			ports := []*Port{
+				{File: os.Stdin, Chan: ClosedChan},
+				{File: os.Stdout, Chan: outCh},
+				{File: os.Stderr, Chan: BlackholeChan},
+			}
+			err := ev.SourceTextWithPorts(ports, "[test]",
+				"profile { range 20 | peach [_]{ "+
+					"breakpoint; str-index &unit=width a你好 -2 } } 2>/dev/null")
+			if err != nil {
+				t.Errorf("eval %d: got error %v", i, err)
+			}
+			if len(outCh) != 20 {
+				t.Errorf("eval %d: got %d outputs, want 20", i, len(outCh))
+			}
+			for len(outCh) > 0 {
+				if v := <-outCh; v != String("好") {
+					t.Errorf("eval %d: got output %v, want 好", i, v)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentStep(t *testing.T) {
+	s := &stepper{}
+	s.set()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	taken := 0
+	for i := 0; i < concurrentEvals; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.take() {
+				mu.Lock()
+				taken++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != 1 {
+		t.Errorf("step stopped %d commands, want 1", taken)
+	}
+}
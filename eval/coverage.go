@@ -0,0 +1,141 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
+)
+
+// Coverage of elvish code. While coverage is enabled, every source compiled by
+// the Evaler has the lines containing commands registered, and each executed
+// command is counted against its line. The report lists, for each source, the
+// number of times each of these lines was run.
+
+type coverage struct {
+	mutex sync.Mutex
+	files map[string]*coverageFile
+}
+
+type coverageFile struct {
+	src string
+	// Hit counts, indexed by 0-based line numbers. Lines without commands
+	// are absent.
+	hits map[int]int
+}
+
+func newCoverage() *coverage {
+	return &coverage{files: make(map[string]*coverageFile)}
+}
+
+func (c *coverage) file(srcName, src string) *coverageFile {
+	f, ok := c.files[srcName]
+	if !ok || f.src != src {
+		f = &coverageFile{src, make(map[int]int)}
+		c.files[srcName] = f
+	}
+	return f
+}
+
+// addSource registers the lines of all commands in the chunk.
+func (c *coverage) addSource(srcName, src string, n *parse.Chunk) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	f := c.file(srcName, src)
+	var walk func(parse.Node)
+	walk = func(n parse.Node) {
+		if _, ok := n.(*parse.Form); ok {
+			lineno, _, _ := util.FindContext(src, n.Begin())
+			if _, ok := f.hits[lineno]; !ok {
+				f.hits[lineno] = 0
+			}
+		}
+		for _, ch := range n.Children() {
+			walk(ch)
+		}
+	}
+	walk(n)
+}
+
+// record counts one execution of the command starting at begin.
+func (c *coverage) record(srcName, src string, begin int) {
+	if begin < 0 || begin > len(src) {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	f := c.file(srcName, src)
+	lineno, _, _ := util.FindContext(src, begin)
+	f.hits[lineno]++
+}
+
+// report writes, for each source, a summary line followed by the covered
+// lines prefixed with their hit counts. Lines that were never run are marked
+// with #####.
+func (c *coverage) report(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	names := make([]string, 0, len(c.files))
+	for name := range c.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := c.files[name]
+		linenos := make([]int, 0, len(f.hits))
+		covered := 0
+		for lineno, hits := range f.hits {
+			linenos = append(linenos, lineno)
+			if hits > 0 {
+				covered++
+			}
+		}
+		sort.Ints(linenos)
+		fmt.Fprintf(w, "%s: %d/%d lines covered\n", name, covered, len(linenos))
+		lines := strings.Split(f.src, "\n")
+		for _, lineno := range linenos {
+			count := "#####"
+			if hits := f.hits[lineno]; hits > 0 {
+				count = fmt.Sprint(hits)
+			}
+			fmt.Fprintf(w, "%8s %5d: %s\n", count, lineno+1, lines[lineno])
+		}
+	}
+}
+
+// StartCoverage enables coverage recording. Sources compiled from now on are
+// included in the report.
+func (ev *Evaler) StartCoverage() {
+	ev.coverage = newCoverage()
+}
+
+// StopCoverage disables coverage recording and writes the coverage report to
+// w.
+func (ev *Evaler) StopCoverage(w io.Writer) {
+	c := ev.coverage
+	ev.coverage = nil
+	if c != nil {
+		c.report(w)
+	}
+}
+
+// cover implements the cover builtin. It calls the function, and then writes
+// the coverage report of the code compiled and run by it.
+func cover(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var f CallableValue
+	ScanArgs(args, &f)
+	TakeNoOpt(opts)
+
+	if ec.coverage != nil {
+		// Already recording; the outer recorder reports.
+		f.Call(ec, nil, NoOpts)
+		return
+	}
+	ec.StartCoverage()
+	defer ec.StopCoverage(ec.ports[1].File)
+	f.Call(ec, nil, NoOpts)
+}
@@ -0,0 +1,47 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/parse"
+)
+
+func TestCoverage(t *testing.T) {
+	src := "if $true {\n  nop\n} else {\n  nop\n}\nnop; nop"
+	n, err := parse.Parse("a.elv", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCoverage()
+	c.addSource("a.elv", src, n)
+	c.record("a.elv", src, 0)
+	c.record("a.elv", src, 13)
+	c.record("a.elv", src, 34)
+	c.record("a.elv", src, 39)
+
+	var b bytes.Buffer
+	c.report(&b)
+	want := "a.elv: 3/4 lines covered\n" +
+		"       1     1: if $true {\n" +
+		"       1     2:   nop\n" +
+		"   #####     4:   nop\n" +
+		"       2     6: nop; nop\n"
+	if b.String() != want {
+		t.Errorf("report = %q, want %q", b.String(), want)
+	}
+}
+
+func TestAtExit(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.StartCoverage()
+	ev.SourceText("a.elv", "nop")
+	var b bytes.Buffer
+	ev.AtExit(func() { ev.StopCoverage(&b) })
+	preExit(NewTopEvalCtx(ev, "[test]", "", nil))
+	if want := "a.elv: 1/1 lines covered\n"; !strings.HasPrefix(b.String(), want) {
+		t.Errorf("report before exit = %q, want prefix %q", b.String(), want)
+	}
+}
@@ -0,0 +1,163 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
+)
+
+// The debugger. When $debug is true, the breakpoint builtin starts a nested
+// REPL on the terminal, in which code is evaluated in the scope of the
+// breakpoint. The REPL also understands the following commands:
+//
+//     c, continue   resume execution
+//     s, step       resume execution and stop again before the next command
+//     bt, backtrace show where execution is stopped
+//     h, help       show the commands
+
+const debugHelp = `Commands:
+    c, continue    resume execution
+    s, step        resume execution and stop before the next command
+    bt, backtrace  show where execution is stopped
+    h, help        show this help
+Other input is evaluated in the scope of the breakpoint.
+`
+
+// stepper records whether the debugger should stop before the next command. It
+// is accessed atomically, as the commands of a pipeline run concurrently.
+type stepper struct {
+	step int32
+}
+
+func (s *stepper) set() {
+	atomic.StoreInt32(&s.step, 1)
+}
+
+// take reports whether the debugger should stop, and clears the flag so that
+// only one command stops.
+func (s *stepper) take() bool {
+	return atomic.CompareAndSwapInt32(&s.step, 1, 0)
+}
+
+// breakpoint implements the breakpoint builtin.
+func breakpoint(ec *EvalCtx, args []Value, opts map[string]Value) {
+	ScanArgs(args)
+	TakeNoOpt(opts)
+	if !ec.option("debug") {
+		return
+	}
+	ec.debug("breakpoint")
+}
+
+// debug runs the debugger REPL on the terminal, or on stdin and stderr when
+// there is no terminal.
+func (ec *EvalCtx) debug(reason string) {
+	var in io.Reader
+	var out io.Writer
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		defer tty.Close()
+		in, out = tty, tty
+	} else {
+		in, out = ec.ports[0].File, ec.ports[2].File
+	}
+	ec.debugREPL(reason, in, out)
+}
+
+func (ec *EvalCtx) debugREPL(reason string, in io.Reader, out io.Writer) {
+	fmt.Fprintf(out, "%s at ", reason)
+	ec.addTraceback().Pprint(out, "")
+	fmt.Fprintln(out)
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprint(out, "debug> ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(out)
+			return
+		}
+		switch strings.TrimSpace(line) {
+		case "":
+		case "c", "continue":
+			return
+		case "s", "step":
+			ec.stepper.set()
+			return
+		case "bt", "backtrace":
+			for tb := ec.addTraceback(); tb != nil; tb = tb.Next {
+				fmt.Fprint(out, "  ")
+				tb.Pprint(out, "    ")
+				fmt.Fprintln(out)
+			}
+		case "h", "help":
+			fmt.Fprint(out, debugHelp)
+		default:
+			err := ec.evalInScope(line, out)
+			if err != nil {
+				if p, ok := err.(util.Pprinter); ok {
+					fmt.Fprintln(out, p.Pprint(""))
+				} else {
+					fmt.Fprintln(out, err)
+				}
+			}
+		}
+	}
+}
+
+// evalInScope evaluates code in the scope of ec, writing value outputs and
+// byte outputs to out.
+func (ec *EvalCtx) evalInScope(src string, out io.Writer) error {
+	const name = "[debug]"
+	n, err := parse.Parse(name, src)
+	if err != nil {
+		return err
+	}
+	visible := scope{}
+	for name := range ec.up {
+		visible[name] = true
+	}
+	for name := range ec.local {
+		visible[name] = true
+	}
+	op, err := compile(makeScope(ec.Builtin), makeRoScope(ec.Builtin),
+		visible, n, name, src)
+	if err != nil {
+		return err
+	}
+
+	f, isFile := out.(*os.File)
+	if !isFile {
+		f = DevNull
+	}
+	outCh := make(chan Value)
+	outDone := make(chan struct{})
+	go func() {
+		for v := range outCh {
+			fmt.Fprintln(out, outChanLeader+v.Repr(initIndent))
+		}
+		close(outDone)
+	}()
+	ports := []*Port{
+		DevNullClosedChan,
+		{File: f, Chan: outCh},
+		{File: f, Chan: BlackholeChan},
+	}
+	newEc := &EvalCtx{
+		ec.Evaler, "debug",
+		name, src,
+		ec.local, ec.up,
+		ports, ec.positionals,
+		0, len(src), ec.addTraceback(), ec.fnName, false, nil, ec.callDepth,
+		ec.env, ec.options, ec.limiter, ec.intCh, ec.profiler, ec.stepper,
+	}
+	err = newEc.PEval(op)
+	close(outCh)
+	<-outDone
+	return err
+}
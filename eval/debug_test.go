@@ -0,0 +1,51 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestDebugREPL(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ec := NewTopEvalCtx(ev, "[test]", "breakpoint", nil)
+	ec.end = len("breakpoint")
+	ec.local["x"] = NewPtrVariable(String("foo"))
+
+	var out bytes.Buffer
+	in := strings.NewReader("put $x\nput $nonexistent\nbt\nc\nput unreachable\n")
+	ec.debugREPL("breakpoint", in, &out)
+
+	s := out.String()
+	for _, want := range []string{"▶ foo", "variable $nonexistent not found", "  [test]:1:1:"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("output %q does not contain %q", s, want)
+		}
+	}
+	if strings.Contains(s, "unreachable") {
+		t.Errorf("REPL did not stop at continue")
+	}
+}
+
+func TestDebugStep(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ec := NewTopEvalCtx(ev, "[test]", "", nil)
+	ec.debugREPL("breakpoint", strings.NewReader("s\n"), &bytes.Buffer{})
+	if !ec.stepper.take() {
+		t.Errorf("step did not make the debugger stop")
+	}
+}
+
+func TestDebugStepIsPerEvaluation(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ec := NewTopEvalCtx(ev, "[test]", "", nil)
+	ec.debugREPL("breakpoint", strings.NewReader("s\n"), &bytes.Buffer{})
+	if NewTopEvalCtx(ev, "[test]", "", nil).stepper.take() {
+		t.Errorf("step made another evaluation stop")
+	}
+	if !ec.fork("test").stepper.take() {
+		t.Errorf("step did not make a fork stop")
+	}
+}
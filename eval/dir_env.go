@@ -0,0 +1,288 @@
+package eval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
+)
+
+// Per-directory environments.
+//
+// When the working directory is in a directory containing a file named
+// .elvish-env, and the file has been allowed with the allow builtin, the file
+// is evaluated in a namespace of its own. Changes it makes to environment
+// variables are undone when the working directory leaves the directory. When
+// directories with such files are nested, the files are all evaluated, outer
+// ones first. A file has to be allowed again after its content changes.
+
+// DirEnvName is the name of files containing per-directory environments.
+const DirEnvName = ".elvish-env"
+
+// ErrNoDirEnv is thrown by the allow builtin when there is no per-directory
+// environment file.
+var ErrNoDirEnv = errors.New("no " + DirEnvName + " found")
+
+// dirEnvAllowedPrefix is the prefix of the names of shared variables that
+// record the hashes of allowed per-directory environment files.
+const dirEnvAllowedPrefix = "dir-env-allowed:"
+
+// dirEnv is a per-directory environment that has been loaded.
+type dirEnv struct {
+	dir string
+	// Values of environment variables before the file was evaluated. A nil
+	// value means that the variable was unset.
+	saved map[string]*string
+}
+
+// UpdateDirEnv unloads per-directory environments of directories that the
+// working directory has left, and loads those of the working directory and its
+// ancestors that are not loaded yet, from the outermost to the innermost. An
+// environment is only loaded after those of all its ancestors, so loading
+// stops at the first one that is not allowed. It should be called after each
+// command in interactive sessions. Messages and errors are written to stderr.
+func (ev *Evaler) UpdateDirEnv() {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+	ev.dirEnvMutex.Lock()
+	defer ev.dirEnvMutex.Unlock()
+	for len(ev.dirEnvs) > 0 {
+		top := ev.dirEnvs[len(ev.dirEnvs)-1]
+		if isInDir(pwd, top.dir) {
+			break
+		}
+		top.restore()
+		ev.dirEnvs = ev.dirEnvs[:len(ev.dirEnvs)-1]
+	}
+
+	for _, dir := range findDirEnvs(pwd) {
+		if len(ev.dirEnvs) > 0 && isInDir(ev.dirEnvs[len(ev.dirEnvs)-1].dir, dir) {
+			// Loaded already.
+			continue
+		}
+		if !ev.loadDirEnv(dir) {
+			return
+		}
+	}
+}
+
+// loadDirEnv evaluates the per-directory environment file in dir and pushes it
+// onto ev.dirEnvs. It returns false if the file cannot be evaluated because it
+// is unreadable or not allowed. It must be called with dirEnvMutex held.
+func (ev *Evaler) loadDirEnv(dir string) bool {
+	filename := filepath.Join(dir, DirEnvName)
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	if !ev.dirEnvAllowed(filename, content) {
+		if ev.dirEnvNotified != filename {
+			fmt.Fprintf(os.Stderr, "%s is not allowed; run allow to evaluate it\n", filename)
+			ev.dirEnvNotified = filename
+		}
+		return false
+	}
+
+	// The lock is released while evaluating, since the file may use builtins
+	// that need it, such as allow.
+	ev.dirEnvMutex.Unlock()
+	before := environ()
+	err = ev.evalIsolated(filename, string(content))
+	after := environ()
+	ev.dirEnvMutex.Lock()
+	de := &dirEnv{dir, make(map[string]*string)}
+	for name, value := range after {
+		if old, ok := before[name]; !ok || *old != *value {
+			de.saved[name] = old
+		}
+	}
+	for name, old := range before {
+		if _, ok := after[name]; !ok {
+			de.saved[name] = old
+		}
+	}
+	ev.dirEnvs = append(ev.dirEnvs, de)
+	if err != nil {
+		if p, ok := err.(util.Pprinter); ok {
+			fmt.Fprintln(os.Stderr, p.Pprint(""))
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	return true
+}
+
+func (de *dirEnv) restore() {
+	for name, old := range de.saved {
+		if old == nil {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, *old)
+		}
+	}
+}
+
+// evalIsolated evaluates source code in a namespace of its own.
+func (ev *Evaler) evalIsolated(name, src string) error {
+	n, err := parse.Parse(name, src)
+	if err != nil {
+		return err
+	}
+	op, err := compile(makeScope(ev.Builtin), makeRoScope(ev.Builtin),
+		scope{}, n, name, src)
+	if err != nil {
+		return err
+	}
+	ports := []*Port{
+		DevNullClosedChan,
+		{File: os.Stdout, Chan: BlackholeChan},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	ec := &EvalCtx{
+		ev, "dir env",
+		name, src,
+		Namespace{}, Namespace{},
+		ports, nil,
+		0, len(src), nil, "", false, nil, 0, nil, nil, nil, nil, nil,
+		&stepper{},
+	}
+	return ec.PEval(op)
+}
+
+// dirEnvAllowed must be called with dirEnvMutex held.
+func (ev *Evaler) dirEnvAllowed(filename string, content []byte) bool {
+	want := hashContent(content)
+	if ev.Daemon != nil {
+		got, err := ev.Daemon.SharedVar(dirEnvAllowedPrefix + filename)
+		return err == nil && got == want
+	}
+	return ev.dirEnvAllowedLocal[filename] == want
+}
+
+// allowDirEnv must be called with dirEnvMutex held.
+func (ev *Evaler) allowDirEnv(filename string, content []byte) error {
+	hash := hashContent(content)
+	if ev.Daemon != nil {
+		return ev.Daemon.SetSharedVar(dirEnvAllowedPrefix+filename, hash)
+	}
+	if ev.dirEnvAllowedLocal == nil {
+		ev.dirEnvAllowedLocal = make(map[string]string)
+	}
+	ev.dirEnvAllowedLocal[filename] = hash
+	return nil
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// findDirEnv returns the closest directory, starting from dir and going up,
+// that contains a per-directory environment file, or "" if there is none.
+func findDirEnv(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, DirEnvName)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findDirEnvs returns all the directories, from the root down to dir, that
+// contain a per-directory environment file.
+func findDirEnvs(dir string) []string {
+	var dirs []string
+	for {
+		if _, err := os.Stat(filepath.Join(dir, DirEnvName)); err == nil {
+			dirs = append(dirs, dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return dirs
+}
+
+// isInDir returns whether path is dir or inside dir.
+func isInDir(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func environ() map[string]*string {
+	m := make(map[string]*string)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			value := kv[i+1:]
+			m[kv[:i]] = &value
+		}
+	}
+	return m
+}
+
+// allow allows the per-directory environment file in the given directory, or
+// the closest one from the working directory, to be evaluated.
+func allow(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	var dir string
+	switch len(args) {
+	case 0:
+		pwd, err := os.Getwd()
+		maybeThrow(err)
+		dir = findDirEnv(pwd)
+		if dir == "" {
+			throw(ErrNoDirEnv)
+		}
+	case 1:
+		var err error
+		dir, err = filepath.Abs(ToString(args[0]))
+		maybeThrow(err)
+	default:
+		throw(ErrArgs)
+	}
+	filename := filepath.Join(dir, DirEnvName)
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		throw(ErrNoDirEnv)
+	}
+	maybeThrow(err)
+	ev := ec.Evaler
+	ev.dirEnvMutex.Lock()
+	defer ev.dirEnvMutex.Unlock()
+	maybeThrow(ev.allowDirEnv(filename, content))
+	// Allow the file to be loaded again if it has been skipped.
+	ev.dirEnvNotified = ""
+	ev.dirEnvs = removeDirEnv(ev.dirEnvs, dir)
+}
+
+// removeDirEnv unloads the environment of dir if it is loaded, so that it is
+// loaded again with its current content.
+func removeDirEnv(envs []*dirEnv, dir string) []*dirEnv {
+	for i := len(envs) - 1; i >= 0; i-- {
+		if envs[i].dir == dir {
+			for j := len(envs) - 1; j >= i; j-- {
+				envs[j].restore()
+			}
+			return envs[:i]
+		}
+	}
+	return envs
+}
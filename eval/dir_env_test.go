@@ -0,0 +1,123 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestDirEnv(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tmpdir, _ = filepath.EvalSymlinks(tmpdir)
+	subdir := filepath.Join(tmpdir, "sub")
+	os.Mkdir(subdir, 0755)
+	content := []byte("E:ELVISH_DIR_ENV_TEST = foo\n")
+	ioutil.WriteFile(filepath.Join(tmpdir, DirEnvName), content, 0644)
+
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+	os.Unsetenv("ELVISH_DIR_ENV_TEST")
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	// Record allowed files in memory.
+	ev.Daemon = nil
+
+	// Not allowed yet.
+	os.Chdir(subdir)
+	ev.UpdateDirEnv()
+	if _, ok := os.LookupEnv("ELVISH_DIR_ENV_TEST"); ok {
+		t.Errorf("dir env evaluated before being allowed")
+	}
+
+	// Allowed; evaluated when in the directory or a subdirectory.
+	ev.allowDirEnv(filepath.Join(tmpdir, DirEnvName), content)
+	ev.UpdateDirEnv()
+	if v := os.Getenv("ELVISH_DIR_ENV_TEST"); v != "foo" {
+		t.Errorf("dir env not evaluated after being allowed, $E:ELVISH_DIR_ENV_TEST = %q", v)
+	}
+
+	// Undone after leaving the directory.
+	os.Chdir(oldpwd)
+	ev.UpdateDirEnv()
+	if _, ok := os.LookupEnv("ELVISH_DIR_ENV_TEST"); ok {
+		t.Errorf("dir env not undone after leaving the directory")
+	}
+
+	// Changed files need to be allowed again.
+	ioutil.WriteFile(filepath.Join(tmpdir, DirEnvName), []byte("E:ELVISH_DIR_ENV_TEST = bar\n"), 0644)
+	os.Chdir(tmpdir)
+	ev.UpdateDirEnv()
+	if _, ok := os.LookupEnv("ELVISH_DIR_ENV_TEST"); ok {
+		t.Errorf("changed dir env evaluated without being allowed again")
+	}
+}
+
+func TestDirEnvChain(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tmpdir, _ = filepath.EvalSymlinks(tmpdir)
+	subdir := filepath.Join(tmpdir, "sub")
+	os.Mkdir(subdir, 0755)
+	outer := []byte("E:ELVISH_DIR_ENV_A = outer; E:ELVISH_DIR_ENV_B = outer\n")
+	inner := []byte("E:ELVISH_DIR_ENV_B = inner\n")
+	ioutil.WriteFile(filepath.Join(tmpdir, DirEnvName), outer, 0644)
+	ioutil.WriteFile(filepath.Join(subdir, DirEnvName), inner, 0644)
+
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+	os.Unsetenv("ELVISH_DIR_ENV_A")
+	os.Unsetenv("ELVISH_DIR_ENV_B")
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ev.allowDirEnv(filepath.Join(tmpdir, DirEnvName), outer)
+	ev.allowDirEnv(filepath.Join(subdir, DirEnvName), inner)
+
+	check := func(what, wantA, wantB string) {
+		a, b := os.Getenv("ELVISH_DIR_ENV_A"), os.Getenv("ELVISH_DIR_ENV_B")
+		if a != wantA || b != wantB {
+			t.Errorf("%s: A = %q, B = %q, want %q and %q", what, a, b, wantA, wantB)
+		}
+	}
+
+	// Entering the inner directory directly loads both, outer first.
+	os.Chdir(subdir)
+	ev.UpdateDirEnv()
+	check("in inner", "outer", "inner")
+
+	// Going up only unloads the inner one.
+	os.Chdir(tmpdir)
+	ev.UpdateDirEnv()
+	check("in outer", "outer", "outer")
+
+	os.Chdir(oldpwd)
+	ev.UpdateDirEnv()
+	check("outside", "", "")
+}
+
+var isInDirTests = []struct {
+	path, dir string
+	want      bool
+}{
+	{"/a/b", "/a/b", true},
+	{"/a/b/c", "/a/b", true},
+	{"/a/bc", "/a/b", false},
+	{"/a", "/a/b", false},
+	{"/a", "/", true},
+}
+
+func TestIsInDir(t *testing.T) {
+	for _, test := range isInDirTests {
+		if got := isInDir(test.path, test.dir); got != test.want {
+			t.Errorf("isInDir(%q, %q) -> %v, want %v", test.path, test.dir, got, test.want)
+		}
+	}
+}
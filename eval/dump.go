@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Dump format. A dump is a JSON object like {"elvish-dump":1,"value":...},
+// where the version number allows the format to evolve. Values are encoded as
+// follows:
+//
+// * Strings as JSON strings, and bools as JSON booleans.
+//
+// * Lists as JSON arrays.
+//
+// * Maps as {"map":[[key,value]...]}, so that keys need not be strings.
+//
+// * Rats as {"rat":"numerator/denominator"}.
+//
+// Other values, like functions and files, cannot be dumped.
+
+const dumpVersion = 1
+
+// Errors from dumping and loading.
+var (
+	ErrBadDump        = errors.New("bad dump")
+	ErrBadDumpVersion = errors.New("unsupported dump version")
+)
+
+type dumpDoc struct {
+	Version int             `json:"elvish-dump"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// Dump serializes a value that consists only of strings, bools, lists, maps
+// and rats.
+func Dump(v Value) ([]byte, error) {
+	enc, err := encodeDump(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{
+		"elvish-dump": dumpVersion,
+		"value":       enc,
+	})
+}
+
+func encodeDump(v Value) (interface{}, error) {
+	switch v := v.(type) {
+	case String:
+		return string(v), nil
+	case Bool:
+		return bool(v), nil
+	case Rat:
+		return map[string]string{"rat": v.b.String()}, nil
+	case List:
+		elems := make([]interface{}, 0, v.Len())
+		var err error
+		v.Iterate(func(elem Value) bool {
+			var enc interface{}
+			enc, err = encodeDump(elem)
+			elems = append(elems, enc)
+			return err == nil
+		})
+		return elems, err
+	case Map:
+		pairs := make([][2]interface{}, 0, v.Len())
+		for _, k := range sortedKeys(v) {
+			kenc, err := encodeDump(k)
+			if err != nil {
+				return nil, err
+			}
+			venc, err := encodeDump(v.IndexOne(k))
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, [2]interface{}{kenc, venc})
+		}
+		return map[string]interface{}{"map": pairs}, nil
+	default:
+		return nil, fmt.Errorf("value of kind %s cannot be dumped", v.Kind())
+	}
+}
+
+// Load deserializes a value serialized by Dump.
+func Load(data []byte) (Value, error) {
+	var doc dumpDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, ErrBadDump
+	}
+	return doc.load()
+}
+
+func (doc *dumpDoc) load() (Value, error) {
+	if doc.Version != dumpVersion {
+		return nil, ErrBadDumpVersion
+	}
+	var v interface{}
+	if err := json.Unmarshal(doc.Value, &v); err != nil {
+		return nil, ErrBadDump
+	}
+	return decodeDump(v)
+}
+
+func decodeDump(v interface{}) (Value, error) {
+	switch v := v.(type) {
+	case string:
+		return String(v), nil
+	case bool:
+		return Bool(v), nil
+	case []interface{}:
+		elems := make([]Value, len(v))
+		for i, elem := range v {
+			dec, err := decodeDump(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = dec
+		}
+		return NewList(elems...), nil
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return nil, ErrBadDump
+		}
+		if s, ok := v["rat"].(string); ok {
+			r, ok := new(big.Rat).SetString(s)
+			if !ok {
+				return nil, ErrBadDump
+			}
+			return Rat{r}, nil
+		}
+		pairs, ok := v["map"].([]interface{})
+		if !ok {
+			return nil, ErrBadDump
+		}
+		m := NewMap(map[Value]Value{})
+		for _, pair := range pairs {
+			kv, ok := pair.([]interface{})
+			if !ok || len(kv) != 2 {
+				return nil, ErrBadDump
+			}
+			k, err := decodeDump(kv[0])
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeDump(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			m = m.Assoc(k, val).(Map)
+		}
+		return m, nil
+	default:
+		return nil, ErrBadDump
+	}
+}
+
+// dump writes each value input as a dump on its own line.
+func dump(ec *EvalCtx, args []Value, opts map[string]Value) {
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	TakeNoOpt(opts)
+
+	out := ec.ports[1].File
+	iterate(func(v Value) {
+		data, err := Dump(v)
+		maybeThrow(err)
+		_, err = out.Write(append(data, '\n'))
+		maybeThrow(err)
+	})
+}
+
+// load outputs the values in the dumps read from the byte input.
+func load(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	TakeNoOpt(opts)
+
+	dec := json.NewDecoder(ec.ports[0].File)
+	out := ec.ports[1]
+	for {
+		var doc dumpDoc
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			throw(ErrBadDump)
+		}
+		v, err := doc.load()
+		maybeThrow(err)
+		out.Put(v)
+	}
+}
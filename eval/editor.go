@@ -9,3 +9,18 @@ type Editor interface {
 	ActiveMutex() *sync.Mutex
 	Notify(string, ...interface{})
 }
+
+// Confirmer is implemented by editors that can ask the user a yes/no question
+// while a command is running.
+type Confirmer interface {
+	// Confirm asks the question and returns whether the answer is yes.
+	Confirm(question string) bool
+}
+
+// Asker is implemented by editors that can ask for a line of input while a
+// command is running.
+type Asker interface {
+	// Ask shows the prompt and returns the line entered. If secret is true,
+	// the line is not shown as it is typed.
+	Ask(prompt string, secret bool) (string, error)
+}
@@ -0,0 +1,57 @@
+package eval
+
+import "os"
+
+// Entry points for Go programs that embed elvish. They take care of setting up
+// the ports: the byte input is empty, byte outputs go to the standard output
+// and error of the process, and values output are collected and returned.
+//
+// An embedding program typically creates an Evaler with NewEvaler, evaluates
+// code with EvalString and calls the functions it defines with Call:
+//
+//	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+//	vs, err := ev.EvalString("[init]", "fn greet [x]{ put 'hello '$x }; put $&greet")
+//	...
+//	vs, err = ev.Call(vs[0].(eval.Callable), []eval.Value{eval.String("world")}, nil)
+
+// EvalString evaluates src and returns the values it outputs. The name is used
+// for src in error messages.
+func (ev *Evaler) EvalString(name, src string) ([]Value, error) {
+	return collectOutputs(func(ports []*Port) error {
+		return ev.SourceTextWithPorts(ports, name, src)
+	})
+}
+
+// Call calls f with the given arguments and options and returns the values it
+// outputs.
+func (ev *Evaler) Call(f Callable, args []Value, opts map[string]Value) ([]Value, error) {
+	return collectOutputs(func(ports []*Port) error {
+		ec := NewTopEvalCtx(ev, "[call]", "", ports)
+		ec.begin, ec.end = -1, -1
+		return ec.PCall(f, args, opts)
+	})
+}
+
+// collectOutputs calls f with ports that collect the values output and returns
+// them along with the error f returns. Values are collected while f runs, so
+// there is no limit on their number.
+func collectOutputs(f func([]*Port) error) ([]Value, error) {
+	outCh := make(chan Value, outChanSize)
+	collected := make(chan []Value)
+	go func() {
+		var outs []Value
+		for v := range outCh {
+			outs = append(outs, v)
+		}
+		collected <- outs
+	}()
+
+	ports := []*Port{
+		DevNullClosedChan,
+		{File: os.Stdout, Chan: outCh},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	err := f(ports)
+	close(outCh)
+	return <-collected, err
+}
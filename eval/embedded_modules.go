@@ -1,6 +1,136 @@
 package eval
 
 var embeddedModules = map[string]string{
+	"completers": `# Argument completers for some common commands. They also serve as examples of
+# the programmable completion API: a completer is called with the words of the
+# command, the last of which is the word being completed, and outputs the
+# candidates as strings or with edit:complex-candidate.
+#
+# Use this module to install them:
+#
+#     use completers
+
+git-subcommands = [
+    add bisect blame branch checkout cherry-pick clean clone commit config
+    diff fetch grep init log merge mv pull push rebase reflog remote reset
+    revert rm show stash status submodule tag
+]
+
+fn complete-git [@words]{
+    if (== (count $words) 2) {
+        explode $git-subcommands
+        return
+    }
+    # Branches, tags and remotes, followed by filenames.
+    try {
+        put (e:git for-each-ref '--format=%(refname:short)' 2>/dev/null)
+    } except {
+        # Not in a git repository.
+    }
+    edit:complete-filename $words[-1]
+}
+
+# Outputs the host names found in ~/.ssh/config and ~/.ssh/known_hosts.
+fn ssh-hosts {
+    try {
+        cat ~/.ssh/config 2>/dev/null | eawk [line @fields]{
+            if (and (> (count $fields) 1) (==s $fields[0] Host)) {
+                for host $fields[1:] {
+                    if (!=s $host '*') {
+                        put $host
+                    }
+                }
+            }
+        }
+    } except {
+    }
+    try {
+        cat ~/.ssh/known_hosts 2>/dev/null | eawk [line @fields]{
+            # Hashed host names start with |.
+            if (not (has-prefix $fields[0] '|')) {
+                splits &sep=, $fields[0]
+            }
+        }
+    } except {
+    }
+}
+
+fn complete-ssh [@words]{
+    ssh-hosts
+}
+
+fn complete-kill [@words]{
+    ps -e -o pid= -o comm= | eawk [line pid @name]{
+        edit:complex-candidate $pid &display-suffix=' '(joins ' ' $name)
+    }
+}
+
+edit:completer[git] = $&complete-git
+edit:completer[ssh] = $&complete-ssh
+edit:completer[kill] = $&complete-kill
+`,
+	"prompt-minimal": `# A minimal prompt theme: the working directory, abbreviated, and no right
+# prompt.
+#
+# Use this module to install it:
+#
+#     use prompt-minimal
+
+fn prompt {
+    edit:styled (tilde-abbr $pwd) blue
+    edit:styled ' > ' bold
+}
+
+fn rprompt { }
+
+edit:prompt = $&prompt
+edit:rprompt = $&rprompt
+`,
+	"prompt-powerline": `# A powerline-like prompt theme, showing the working directory and the status
+# of the current git repository as colored segments. It also serves as an
+# example of building a prompt with edit:styled.
+#
+# Use this module to install it:
+#
+#     use prompt-powerline
+
+use platform
+
+# The user and host don't change during a session, so they are looked up once
+# instead of on every redraw.
+user-host = (platform:user)[name]@(platform:hostname)
+
+fn segment [text style]{
+    edit:styled ' '$text' ' $style
+}
+
+fn prompt {
+    segment (tilde-abbr $pwd) 'white;bg-blue'
+    # edit:git-info runs git in the background and outputs nothing until the
+    # status is known, so the prompt never waits for git.
+    for info [(edit:git-info)] {
+        text = $info[branch]
+        if $info[dirty] {
+            text = $text' *'
+        }
+        if (!=s $info[ahead] 0) {
+            text = $text' +'$info[ahead]
+        }
+        if (!=s $info[behind] 0) {
+            text = $text' -'$info[behind]
+        }
+        segment $text 'black;bg-yellow'
+    }
+    put ' '
+}
+
+fn rprompt {
+    segment $user-host 'black;bg-white'
+}
+
+edit:prompt = $&prompt
+edit:rprompt = $&rprompt
+`,
 	"readline-binding": `fn bind-mode [m k f]{
     edit:binding[$m][$k] = $f
 }
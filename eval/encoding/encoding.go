@@ -0,0 +1,103 @@
+// Package encoding implements the encoding: module, which encodes and decodes
+// strings and computes hashes.
+package encoding
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+func Namespace() eval.Namespace {
+	ns := eval.Namespace{}
+	eval.AddBuiltinFns(ns, fns...)
+	return ns
+}
+
+var fns = []*eval.BuiltinFn{
+	{"base64-encode", base64Encode},
+	{"base64-decode", base64Decode},
+	{"hex-encode", eval.WrapStringToString(hexEncode)},
+	{"hex-decode", eval.WrapStringToStringError(hexDecode)},
+	{"url-encode", eval.WrapStringToString(url.QueryEscape)},
+	{"url-decode", eval.WrapStringToStringError(url.QueryUnescape)},
+	{"md5", hashFn(md5.New)},
+	{"sha1", hashFn(sha1.New)},
+	{"sha256", hashFn(sha256.New)},
+}
+
+// base64Encoding returns the standard base64 encoding, or the URL-safe one
+// if url is true.
+func base64Encoding(url eval.Bool) *base64.Encoding {
+	if url {
+		return base64.URLEncoding
+	}
+	return base64.StdEncoding
+}
+
+func base64Encode(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var s eval.String
+	eval.ScanArgs(args, &s)
+	var url eval.Bool
+	eval.ScanOpts(opts, eval.Opt{"url", &url, eval.Bool(false)})
+
+	ec.OutputChan() <- eval.String(base64Encoding(url).EncodeToString([]byte(s)))
+}
+
+func base64Decode(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var s eval.String
+	eval.ScanArgs(args, &s)
+	var url eval.Bool
+	eval.ScanOpts(opts, eval.Opt{"url", &url, eval.Bool(false)})
+
+	bs, err := base64Encoding(url).DecodeString(string(s))
+	maybeThrow(err)
+	ec.OutputChan() <- eval.String(bs)
+}
+
+func hexEncode(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+func hexDecode(s string) (string, error) {
+	bs, err := hex.DecodeString(s)
+	return string(bs), err
+}
+
+// hashFn returns a builtin that outputs the hexadecimal digest of its
+// argument, or of the content of the file it names if &file is true.
+func hashFn(newHash func() hash.Hash) func(*eval.EvalCtx, []eval.Value, map[string]eval.Value) {
+	return func(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+		var s eval.String
+		eval.ScanArgs(args, &s)
+		var file eval.Bool
+		eval.ScanOpts(opts, eval.Opt{"file", &file, eval.Bool(false)})
+
+		h := newHash()
+		if file {
+			f, err := os.Open(string(s))
+			maybeThrow(err)
+			defer f.Close()
+			_, err = io.Copy(h, f)
+			maybeThrow(err)
+		} else {
+			io.WriteString(h, string(s))
+		}
+		ec.OutputChan() <- eval.String(hex.EncodeToString(h.Sum(nil)))
+	}
+}
+
+func maybeThrow(err error) {
+	if err != nil {
+		util.Throw(err)
+	}
+}
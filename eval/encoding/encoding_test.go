@@ -0,0 +1,49 @@
+package encoding
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/eval/evaltest"
+)
+
+func evalOutputs(t *testing.T, src string) []eval.Value {
+	return evaltest.EvalOutputs(t, "encoding", Namespace(), src)
+}
+
+func TestEncoding(t *testing.T) {
+	f, err := ioutil.TempFile("", "elvish-encoding-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("abc")
+	f.Close()
+
+	for _, test := range []struct {
+		src  string
+		want string
+	}{
+		{"encoding:base64-encode 'hello?>'", "aGVsbG8/Pg=="},
+		{"encoding:base64-encode &url 'hello?>'", "aGVsbG8_Pg=="},
+		{"encoding:base64-decode aGVsbG8/Pg==", "hello?>"},
+		{"encoding:base64-decode &url aGVsbG8_Pg==", "hello?>"},
+		{"encoding:hex-encode hi", "6869"},
+		{"encoding:hex-decode 6869", "hi"},
+		{"encoding:url-encode 'a b&c'", "a+b%26c"},
+		{"encoding:url-decode a+b%26c", "a b&c"},
+		{"encoding:md5 abc", "900150983cd24fb0d6963f7d28e17f72"},
+		{"encoding:sha1 abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"encoding:sha256 abc",
+			"ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"encoding:md5 &file " + f.Name(), "900150983cd24fb0d6963f7d28e17f72"},
+	} {
+		want := []eval.Value{eval.String(test.want)}
+		if outs := evalOutputs(t, test.src); !reflect.DeepEqual(outs, want) {
+			t.Errorf("%s outputs %v, want %v", test.src, outs, want)
+		}
+	}
+}
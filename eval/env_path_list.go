@@ -134,7 +134,7 @@ func (epl *EnvPathList) get() []string {
 		return epl.cachedPaths
 	}
 	epl.cachedValue = value
-	epl.cachedPaths = strings.Split(value, ":")
+	epl.cachedPaths = strings.Split(value, string(os.PathListSeparator))
 	return epl.cachedPaths
 }
 
@@ -147,7 +147,7 @@ func (epl *EnvPathList) set(paths []string) {
 }
 
 func (epl *EnvPathList) syncFromPaths() {
-	epl.cachedValue = strings.Join(epl.cachedPaths, ":")
+	epl.cachedValue = strings.Join(epl.cachedPaths, string(os.PathListSeparator))
 	err := os.Setenv(epl.envName, epl.cachedValue)
 	maybeThrow(err)
 }
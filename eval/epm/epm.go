@@ -0,0 +1,295 @@
+// Package epm implements the epm module, a package manager for elvish
+// modules.
+//
+// Packages are git repositories. Each package is cloned into
+// $datadir/lib/<name>, where <name> is the last element of its URL with any
+// ".git" suffix removed, so that a module file foo.elv in the package can be
+// loaded with "use <name>:foo". Installed packages and the commits they are
+// at are recorded in the manifest $datadir/lib/epm.json; installing without
+// arguments restores the packages recorded in the manifest.
+package epm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+// ManifestName is the name of the manifest file in $datadir/lib.
+const ManifestName = "epm.json"
+
+// Errors thrown by the epm builtins.
+var (
+	ErrNoDataDir    = errors.New("no data directory")
+	ErrBadURL       = errors.New("cannot derive package name from URL")
+	ErrNotInstalled = errors.New("package not installed")
+	ErrBadCommit    = errors.New("bad commit")
+)
+
+func Namespace() eval.Namespace {
+	ns := eval.Namespace{}
+	eval.AddBuiltinFns(ns, fns...)
+	return ns
+}
+
+var fns = []*eval.BuiltinFn{
+	{"install", install},
+	{"upgrade", upgrade},
+	{"uninstall", uninstall},
+	{"installed", installed},
+}
+
+// Package is an entry in the manifest.
+type Package struct {
+	URL    string `json:"url"`
+	Commit string `json:"commit"`
+}
+
+// Manifest maps package names to packages.
+type Manifest map[string]Package
+
+// PackageName derives the name of a package from its URL. URLs starting with
+// "-" are rejected, since git would take them as options.
+func PackageName(url string) (string, error) {
+	if strings.HasPrefix(url, "-") {
+		return "", ErrBadURL
+	}
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(url, "/")), ".git")
+	if i := strings.LastIndexByte(name, ':'); i != -1 {
+		// scp-like URLs such as git@host:repo.git
+		name = name[i+1:]
+	}
+	if !validName(name) {
+		return "", ErrBadURL
+	}
+	return name, nil
+}
+
+// validName returns whether name can be the directory of a package in the lib
+// directory, that is, a single path element other than "." and "..". Absolute
+// paths contain a separator and are thus rejected too.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, "/:\\")
+}
+
+// validCommit returns whether commit is empty or a hexadecimal object name, as
+// recorded by install and upgrade.
+func validCommit(commit string) bool {
+	for _, r := range commit {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func libDir(ec *eval.EvalCtx) string {
+	if ec.DataDir == "" {
+		util.Throw(ErrNoDataDir)
+	}
+	return filepath.Join(ec.DataDir, "lib")
+}
+
+// writableLibDir is like libDir, but also checks that the lib directory can
+// be written.
+func writableLibDir(ec *eval.EvalCtx) string {
+	lib := libDir(ec)
+	ec.CheckWrite(lib)
+	return lib
+}
+
+// ReadManifest reads the manifest in the lib directory. A missing manifest is
+// treated as an empty one. Since the manifest may come from elsewhere, a
+// package whose name, URL or commit could not have been recorded by epm is an
+// error.
+func ReadManifest(lib string) (Manifest, error) {
+	m := Manifest{}
+	content, err := ioutil.ReadFile(filepath.Join(lib, ManifestName))
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(content, &m)
+	if err != nil {
+		return nil, err
+	}
+	for name, pkg := range m {
+		if !validName(name) {
+			return nil, fmt.Errorf("bad package name %q in manifest", name)
+		}
+		if _, err := PackageName(pkg.URL); err != nil {
+			return nil, fmt.Errorf("%s: %v in manifest: %q", name, err, pkg.URL)
+		}
+		if !validCommit(pkg.Commit) {
+			return nil, fmt.Errorf("%s: %v in manifest: %q", name, ErrBadCommit, pkg.Commit)
+		}
+	}
+	return m, nil
+}
+
+// WriteManifest writes the manifest to the lib directory.
+func WriteManifest(lib string, m Manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(lib, 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(lib, ManifestName), append(content, '\n'), 0644)
+}
+
+func install(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoOpt(opts)
+	lib := writableLibDir(ec)
+	m := mustReadManifest(lib)
+
+	if len(args) == 0 {
+		// Restore the packages in the manifest at the recorded commits.
+		for _, name := range sortedNames(m) {
+			pkg := m[name]
+			dir := filepath.Join(lib, name)
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				git(ec, "", "clone", "-q", "--", pkg.URL, dir)
+			}
+			if pkg.Commit != "" {
+				git(ec, dir, "checkout", "-q", pkg.Commit, "--")
+			}
+		}
+		return
+	}
+
+	for _, arg := range args {
+		url := eval.ToString(arg)
+		name, err := PackageName(url)
+		maybeThrow(err)
+		if _, ok := m[name]; ok {
+			fmt.Fprintf(ec.OutputFile(), "%s already installed\n", name)
+			continue
+		}
+		dir := filepath.Join(lib, name)
+		git(ec, "", "clone", "-q", "--", url, dir)
+		m[name] = Package{url, headCommit(ec, dir)}
+		maybeThrow(WriteManifest(lib, m))
+	}
+}
+
+func upgrade(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoOpt(opts)
+	lib := writableLibDir(ec)
+	m := mustReadManifest(lib)
+
+	names := argNames(args, m)
+	for _, name := range names {
+		dir := filepath.Join(lib, name)
+		git(ec, dir, "pull", "-q", "--ff-only")
+		pkg := m[name]
+		pkg.Commit = headCommit(ec, dir)
+		m[name] = pkg
+	}
+	maybeThrow(WriteManifest(lib, m))
+}
+
+func uninstall(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoOpt(opts)
+	lib := writableLibDir(ec)
+	m := mustReadManifest(lib)
+
+	for _, name := range argNames(args, m) {
+		maybeThrow(os.RemoveAll(filepath.Join(lib, name)))
+		delete(m, name)
+	}
+	maybeThrow(WriteManifest(lib, m))
+}
+
+func installed(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.ScanArgs(args)
+	eval.TakeNoOpt(opts)
+	m := mustReadManifest(libDir(ec))
+
+	out := ec.OutputChan()
+	for _, name := range sortedNames(m) {
+		out <- eval.String(name)
+	}
+}
+
+// argNames converts the arguments to package names, checking that they are
+// installed. Without arguments, it returns all installed packages.
+func argNames(args []eval.Value, m Manifest) []string {
+	if len(args) == 0 {
+		return sortedNames(m)
+	}
+	names := make([]string, len(args))
+	for i, arg := range args {
+		names[i] = eval.ToString(arg)
+		if _, ok := m[names[i]]; !ok {
+			throwf("%s: %v", names[i], ErrNotInstalled)
+		}
+	}
+	return names
+}
+
+func sortedNames(m Manifest) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mustReadManifest(lib string) Manifest {
+	m, err := ReadManifest(lib)
+	maybeThrow(err)
+	return m
+}
+
+// gitCommand returns a git command to run in dir, after checking that git can
+// be run.
+func gitCommand(ec *eval.EvalCtx, dir string, args ...string) *exec.Cmd {
+	path, err := exec.LookPath("git")
+	maybeThrow(err)
+	ec.CheckExternal("git", path)
+	cmd := exec.Command(path, args...)
+	cmd.Dir = dir
+	return cmd
+}
+
+func git(ec *eval.EvalCtx, dir string, args ...string) {
+	cmd := gitCommand(ec, dir, args...)
+	cmd.Stdout = ec.OutputFile()
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		throwf("git %s: %v", args[0], err)
+	}
+}
+
+func headCommit(ec *eval.EvalCtx, dir string) string {
+	out, err := gitCommand(ec, dir, "rev-parse", "HEAD").Output()
+	maybeThrow(err)
+	return strings.TrimSpace(string(out))
+}
+
+func throwf(format string, args ...interface{}) {
+	util.Throw(fmt.Errorf(format, args...))
+}
+
+func maybeThrow(err error) {
+	if err != nil {
+		util.Throw(err)
+	}
+}
@@ -0,0 +1,111 @@
+package epm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+)
+
+var packageNameTests = []struct {
+	url     string
+	want    string
+	wantErr bool
+}{
+	{"https://github.com/elves/sample-pkg", "sample-pkg", false},
+	{"https://github.com/elves/sample-pkg.git", "sample-pkg", false},
+	{"https://github.com/elves/sample-pkg/", "sample-pkg", false},
+	{"git@github.com:sample-pkg.git", "sample-pkg", false},
+	{"/home/user/src/pkg", "pkg", false},
+	{"", "", true},
+	{"/", "", true},
+	{"https://example.com/..", "", true},
+	{"https://example.com/...git", "", true},
+	{"https://example.com/.", "", true},
+	{"--upload-pack=touch /tmp/x", "", true},
+}
+
+func TestPackageName(t *testing.T) {
+	for _, test := range packageNameTests {
+		name, err := PackageName(test.url)
+		if name != test.want || (err != nil) != test.wantErr {
+			t.Errorf("PackageName(%q) => (%q, %v), want (%q, error %v)",
+				test.url, name, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	lib, err := ioutil.TempDir("", "epm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(lib)
+
+	m, err := ReadManifest(lib)
+	if err != nil || len(m) != 0 {
+		t.Errorf("ReadManifest on empty dir => (%v, %v), want empty manifest", m, err)
+	}
+
+	m = Manifest{"pkg": Package{"https://example.com/pkg", "0123abcd"}}
+	if err := WriteManifest(lib, m); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := ReadManifest(lib)
+	if err != nil || !reflect.DeepEqual(m, m2) {
+		t.Errorf("ReadManifest => (%v, %v), want %v", m2, err, m)
+	}
+
+	for _, bad := range []Manifest{
+		{"../escape": Package{"https://example.com/escape", ""}},
+		{"pkg": Package{"--upload-pack=x", ""}},
+		{"pkg": Package{"https://example.com/pkg", "--orphan=x"}},
+	} {
+		if err := WriteManifest(lib, bad); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadManifest(lib); err == nil {
+			t.Errorf("ReadManifest with %v => no error, want error", bad)
+		}
+	}
+}
+
+func TestRestriction(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "epm-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+	lib := filepath.Join(dataDir, "lib")
+
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, dataDir,
+		map[string]eval.Namespace{"epm": Namespace()})
+	ev.Daemon = nil
+	ports := []*eval.Port{
+		eval.DevNullClosedChan,
+		{File: os.Stdout, Chan: eval.BlackholeChan},
+		{File: os.Stderr, Chan: eval.BlackholeChan},
+	}
+	for _, test := range []struct {
+		restriction eval.Restriction
+		src         string
+	}{
+		{eval.Restriction{RestrictWrites: true}, "epm:uninstall"},
+		{eval.Restriction{RestrictWrites: true}, "epm:install " + dataDir + "/pkg"},
+		{eval.Restriction{RestrictExternals: true}, "epm:install " + dataDir + "/pkg"},
+	} {
+		ev.Restriction = &test.restriction
+		err := ev.SourceTextWithPorts(ports, "[test]", "use epm; "+test.src)
+		if err == nil || !strings.Contains(err.Error(), eval.ErrRestricted.Error()) {
+			t.Errorf("%s with %v => %v, want restricted", test.src, test.restriction, err)
+		}
+	}
+	if _, err := os.Stat(lib); err == nil {
+		t.Errorf("restricted epm created %s", lib)
+	}
+}
@@ -22,7 +22,6 @@ import (
 	"github.com/elves/elvish/daemon"
 	"github.com/elves/elvish/daemon/api"
 	"github.com/elves/elvish/parse"
-	"github.com/elves/elvish/sys"
 	"github.com/elves/elvish/util"
 )
 
@@ -37,6 +36,13 @@ type Namespace map[string]Variable
 
 // Evaler is used to evaluate elvish sources. It maintains runtime context
 // shared among all evalCtx instances.
+//
+// Multiple Evalers can be used concurrently, and each has its own namespaces
+// and settings. However, the working directory and the environment variables
+// belong to the process and are shared by all Evalers: a cd in one Evaler
+// changes the directory of the others too, and the changes that per-directory
+// environments record may include those made by other Evalers meanwhile. The
+// source registry of the util package is also shared.
 type Evaler struct {
 	Builtin Namespace
 	Global  Namespace
@@ -45,7 +51,32 @@ type Evaler struct {
 	ToSpawn *daemon.Daemon
 	Editor  Editor
 	DataDir string
-	intCh   chan struct{}
+
+	// Limits on what evaluated code can do, or nil if there are none.
+	Restriction *Restriction
+	// Limits on the resources that each evaluation can use, or nil if there
+	// are none.
+	Limits *Limits
+
+	// Guards the fields about per-directory environments below.
+	dirEnvMutex sync.Mutex
+	// Loaded per-directory environments, outermost first.
+	dirEnvs []*dirEnv
+	// The per-directory environment file that was last reported as not
+	// allowed, to avoid repeating the message.
+	dirEnvNotified string
+	// Hashes of allowed per-directory environment files, used when there is
+	// no daemon.
+	dirEnvAllowedLocal map[string]string
+	// The profiler started by StartProfile, if any. It is set before any code
+	// is evaluated and not changed afterwards.
+	profile *profiler
+	// The active coverage recorder, if any.
+	coverage *coverage
+	// Functions to call before the exit and exec builtins end the process.
+	atExit []func()
+	// The ID of the last background job started, accessed atomically.
+	lastJobID int32
 }
 
 // EvalCtx maintains an Evaler along with its runtime context. After creation
@@ -60,8 +91,42 @@ type EvalCtx struct {
 
 	begin, end int
 	traceback  *util.SourceContext
+	// Name of the function being executed. Empty at the top level.
+	fnName string
 
 	background bool
+
+	// Descriptions of the redirections of the form being executed, collected
+	// for tracing when $trace is true.
+	redirTrace []string
+
+	// Number of closure calls that are active.
+	callDepth int
+
+	// Environment variables set by temporary assignments to E: variables,
+	// which apply only to the commands run in this context.
+	env *envOverlay
+
+	// Options set with set-option in the active closure calls. It is nil at
+	// the top level.
+	options *optionScope
+
+	// Tracks the resources used by the evaluation when the Evaler has Limits.
+	// It is shared between forks.
+	limiter *limiter
+
+	// Closed when the user interrupts the evaluation. It is nil for
+	// evaluations that can't be interrupted, such as background jobs.
+	intCh chan struct{}
+
+	// The profiler recording the commands run, if any. It is inherited by
+	// forks, except those for background jobs.
+	profiler *profiler
+
+	// Whether the debugger should stop before the next command. It is shared
+	// between forks, except those for background jobs, so that stepping only
+	// stops in the evaluation where the debugger was started.
+	stepper *stepper
 }
 
 // NewEvaler creates a new Evaler.
@@ -76,7 +141,7 @@ func NewEvaler(daemon *api.Client, toSpawn *daemon.Daemon,
 		modules[name] = mod
 	}
 
-	return &Evaler{
+	ev := &Evaler{
 		Builtin: makeBuiltinNamespace(daemon),
 		Global:  Namespace{},
 		Modules: modules,
@@ -84,8 +149,15 @@ func NewEvaler(daemon *api.Client, toSpawn *daemon.Daemon,
 		ToSpawn: toSpawn,
 		Editor:  nil,
 		DataDir: dataDir,
-		intCh:   nil,
 	}
+	ev.Builtin["pwd"] = PwdVariable{daemon, ev}
+	return ev
+}
+
+// AtExit registers f to be called when the exit or exec builtin is about to
+// end the process, which skips deferred calls.
+func (ev *Evaler) AtExit(f func()) {
+	ev.atExit = append(ev.atExit, f)
 }
 
 func (ev *Evaler) searchPaths() []string {
@@ -106,7 +178,8 @@ func NewTopEvalCtx(ev *Evaler, name, text string, ports []*Port) *EvalCtx {
 		name, text,
 		ev.Global, Namespace{},
 		ports, nil,
-		0, len(text), nil, false,
+		0, len(text), nil, "", false, nil, 0, nil, nil, nil, nil, ev.profile,
+		&stepper{},
 	}
 }
 
@@ -122,7 +195,9 @@ func (ec *EvalCtx) fork(name string) *EvalCtx {
 		ec.srcName, ec.src,
 		ec.local, ec.up,
 		newPorts, ec.positionals,
-		ec.begin, ec.end, ec.traceback, ec.background,
+		ec.begin, ec.end, ec.traceback, ec.fnName, ec.background, nil,
+		ec.callDepth, ec.env, ec.options, ec.limiter, ec.intCh, ec.profiler,
+		ec.stepper,
 	}
 }
 
@@ -145,6 +220,14 @@ func (ec *EvalCtx) growPorts(n int) {
 	copy(ec.ports, ports)
 }
 
+// setPort closes ec.ports[i] and replaces it with p, growing ec.ports if
+// necessary.
+func (ec *EvalCtx) setPort(i int, p *Port) {
+	ec.growPorts(i + 1)
+	ec.ports[i].Close()
+	ec.ports[i] = p
+}
+
 func makeScope(s Namespace) scope {
 	sc := scope{}
 	for name := range s {
@@ -153,17 +236,62 @@ func makeScope(s Namespace) scope {
 	return sc
 }
 
-// eval evaluates a chunk node n. The supplied name and text are used in
-// diagnostic messages.
-func (ev *Evaler) eval(op Op, ports []*Port, name, text string) error {
+// makeRoScope makes a scope of the names of all read-only variables in a
+// Namespace.
+func makeRoScope(s Namespace) scope {
+	sc := scope{}
+	for name, variable := range s {
+		if _, ok := variable.(roVariable); ok {
+			sc[name] = true
+		}
+	}
+	return sc
+}
+
+// eval evaluates a chunk node n. The evaluation is interrupted when intCh is
+// closed, unless it is nil. The supplied name and text are used in diagnostic
+// messages.
+func (ev *Evaler) eval(op Op, ports []*Port, intCh chan struct{}, name, text string) error {
 	ec := NewTopEvalCtx(ev, name, text, ports)
-	return ec.PEval(op)
+	ec.intCh = intCh
+	if ev.Limits == nil {
+		return ec.PEval(op)
+	}
+	finish, err := limitEvalCtx(ec, *ev.Limits)
+	if err != nil {
+		return err
+	}
+	err = ec.PEval(op)
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	return err
 }
 
 func (ec *EvalCtx) Interrupts() <-chan struct{} {
 	return ec.intCh
 }
 
+// checkInterrupted throws if the evaluation has been interrupted. Builtins
+// that may run for long without calling back into elvish code call it
+// regularly.
+func (ec *EvalCtx) checkInterrupted() {
+	select {
+	case <-ec.intCh:
+		ec.throwInterrupted()
+	default:
+	}
+}
+
+// throwInterrupted throws ErrInterrupted, or the error for the exceeded limit
+// if the interrupt is caused by one.
+func (ec *EvalCtx) throwInterrupted() {
+	if ec.limiter != nil {
+		ec.limiter.check()
+	}
+	throw(ErrInterrupted)
+}
+
 // Eval sets up the Evaler with standard ports and evaluates an Op. The supplied
 // name and text are used in diagnostic messages.
 func (ev *Evaler) Eval(op Op, name, text string) error {
@@ -207,11 +335,11 @@ func (ev *Evaler) EvalWithPorts(ports []*Port, op Op, name, text string) error {
 	// (most likely for elvish), the call will outright fail. Therefore, for
 	// elvish to be able to move itself back to the foreground, we need to
 	// ignore TTOU.
-	signal.Ignore(syscall.SIGTTOU)
+	ignoreTTOU()
 	stopSigGoroutine := make(chan struct{})
 	sigGoRoutineDone := make(chan struct{})
 	// Set up intCh.
-	ev.intCh = make(chan struct{})
+	intCh := make(chan struct{})
 	sigCh := make(chan os.Signal)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGQUIT)
 	go func() {
@@ -221,34 +349,25 @@ func (ev *Evaler) EvalWithPorts(ports []*Port, op Op, name, text string) error {
 			select {
 			case <-sigCh:
 				if !closedIntCh {
-					close(ev.intCh)
+					close(intCh)
 					closedIntCh = true
 				}
 			case <-stopSigGoroutine:
 				break loop
 			}
 		}
-		ev.intCh = nil
 		signal.Stop(sigCh)
 		close(sigGoRoutineDone)
 	}()
 
-	err := ev.eval(op, ports, name, text)
+	err := ev.eval(op, ports, intCh, name, text)
 
 	close(stopSigGoroutine)
 	<-sigGoRoutineDone
 
-	// Put myself in foreground, in case some command has put me in background.
-	// XXX Should probably use fd of /dev/tty instead of 0.
-	if sys.IsATTY(0) {
-		err := sys.Tcsetpgrp(0, syscall.Getpgrp())
-		if err != nil {
-			fmt.Println("failed to put myself in foreground:", err)
-		}
-	}
+	putSelfInForeground()
 
-	// Un-ignore TTOU.
-	signal.Reset(syscall.SIGTTOU)
+	unignoreTTOU()
 
 	return err
 }
@@ -269,9 +388,14 @@ func summarize(text string) string {
 }
 
 // Compile compiles elvish code in the global scope. If the error is not nil, it
-// always has type CompilationError.
+// has type *CompilationError, or *util.Errors holding several of them when
+// there are errors in more than one pipeline.
 func (ev *Evaler) Compile(n *parse.Chunk, name, text string) (Op, error) {
-	return compile(makeScope(ev.Builtin), makeScope(ev.Global), n, name, text)
+	if c := ev.coverage; c != nil {
+		c.addSource(name, text, n)
+	}
+	return compile(makeScope(ev.Builtin), makeRoScope(ev.Builtin),
+		makeScope(ev.Global), n, name, text)
 }
 
 // PEval evaluates an op in a protected environment so that calls to errorf are
@@ -320,8 +444,9 @@ func (ec *EvalCtx) makeException(e error) *Exception {
 
 func (ec *EvalCtx) addTraceback() *util.SourceContext {
 	return &util.SourceContext{
-		Name: ec.srcName, Source: ec.src,
+		Name: ec.srcName, Source: util.ContextSource(ec.srcName, ec.src),
 		Begin: ec.begin, End: ec.end, Next: ec.traceback,
+		Function: ec.fnName,
 	}
 }
 
@@ -332,8 +457,11 @@ func (ec *EvalCtx) errorpf(begin, end int, format string, args ...interface{}) {
 	throwf(format, args...)
 }
 
-// SourceText evaluates a chunk of elvish source.
+// SourceText evaluates a chunk of elvish source. The source is also recorded
+// in the source registry of the util package under the given name, so that
+// errors from it need not carry the text.
 func (ev *Evaler) SourceText(name, src string) error {
+	util.RegisterSource(name, src)
 	n, err := parse.Parse(name, src)
 	if err != nil {
 		return err
@@ -356,6 +484,22 @@ func readFileUTF8(fname string) (string, error) {
 	return string(bytes), nil
 }
 
+// SourceTextWithPorts evaluates a chunk of elvish source with the given ports.
+// Unlike SourceText, it does not touch signal handlers or the terminal, so it
+// is suitable for Go programs that embed elvish.
+func (ev *Evaler) SourceTextWithPorts(ports []*Port, name, src string) error {
+	util.RegisterSource(name, src)
+	n, err := parse.Parse(name, src)
+	if err != nil {
+		return err
+	}
+	op, err := ev.Compile(n, name, src)
+	if err != nil {
+		return err
+	}
+	return ev.eval(op, ports, nil, name, src)
+}
+
 // Source evaluates the content of a file.
 func (ev *Evaler) Source(fname string) error {
 	src, err := readFileUTF8(fname)
@@ -392,7 +536,7 @@ func (ec *EvalCtx) ResolveVar(ns, name string) Variable {
 			return NewRoVariable(ExternalCmd{name[len(FnPrefix):]})
 		}
 	case "E":
-		return envVariable{name}
+		return envVariable{name, ec.env}
 	case "shared":
 		if ec.Daemon == nil {
 			throw(ErrStoreUnconnected)
@@ -429,12 +573,29 @@ var ErrMoreThanOneRest = errors.New("more than one @ lvalue")
 
 // IterateInputs calls the passed function for each input element.
 func (ec *EvalCtx) IterateInputs(f func(Value)) {
+	ec.iterateInputsWhile(func(v Value) bool {
+		f(v)
+		return true
+	})
+}
+
+// iterateInputsWhile is like IterateInputs, but stops as soon as f returns
+// false. The rest of the value inputs are discarded. A terminal is not read
+// beyond the lines that f has been called with, so that the rest of its input
+// is left for the editor.
+func (ec *EvalCtx) iterateInputsWhile(f func(Value) bool) {
 	var w sync.WaitGroup
 	inputs := make(chan Value)
+	more := make(chan struct{}, 1)
+	stop := make(chan struct{})
 
 	w.Add(2)
 	go func() {
-		linesToChan(ec.ports[0].File, inputs)
+		if in := ec.ports[0].File; isTerminal(in) {
+			ttyLinesToChan(in, inputs, more, stop)
+		} else {
+			linesToChan(in, inputs)
+		}
 		w.Done()
 	}()
 	go func() {
@@ -447,9 +608,23 @@ func (ec *EvalCtx) IterateInputs(f func(Value)) {
 		w.Wait()
 		close(inputs)
 	}()
+	defer func() {
+		close(stop)
+		go func() {
+			for range inputs {
+			}
+		}()
+	}()
 
 	for v := range inputs {
-		f(v)
+		ec.checkInterrupted()
+		if !f(v) {
+			return
+		}
+		select {
+		case more <- struct{}{}:
+		default:
+		}
 	}
 }
 
@@ -469,6 +644,35 @@ func linesToChan(r io.Reader, ch chan<- Value) {
 	}
 }
 
+// environ returns the environment for external commands: that of the process,
+// with the temporary assignments of ec applied.
+func (ec *EvalCtx) environ() []string {
+	env := os.Environ()
+	overlay := ec.env.copy()
+	if len(overlay.values) == 0 && len(overlay.deleted) == 0 {
+		return env
+	}
+	kept := env[:0]
+	for _, kv := range env {
+		if j := strings.IndexByte(kv, '='); j != -1 {
+			if overlay.deleted[kv[:j]] {
+				continue
+			}
+			if val, ok := overlay.values[kv[:j]]; ok {
+				kv = kv[:j+1] + val
+			}
+		}
+		kept = append(kept, kv)
+	}
+	env = kept
+	for name, val := range overlay.values {
+		if _, ok := os.LookupEnv(name); !ok {
+			env = append(env, name+"="+val)
+		}
+	}
+	return env
+}
+
 // OutputChan returns a channel onto which output can be written.
 func (ec *EvalCtx) OutputChan() chan<- Value {
 	return ec.ports[1].Chan
@@ -478,3 +682,8 @@ func (ec *EvalCtx) OutputChan() chan<- Value {
 func (ec *EvalCtx) OutputFile() *os.File {
 	return ec.ports[1].File
 }
+
+// InputFile returns a file from which byte input can be read.
+func (ec *EvalCtx) InputFile() *os.File {
+	return ec.ports[0].File
+}
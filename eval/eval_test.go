@@ -5,8 +5,10 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/elves/elvish/daemon/api"
 	"github.com/elves/elvish/parse"
@@ -44,6 +46,9 @@ var evalTests = []struct {
 	// A failed pipeline cause the whole chunk to fail
 	{"put a; e:false; put b", strs("a"), more{wantError: errAny}},
 
+	// Multi-line raw strings.
+	{"put 'a\n  $b\\n\n'", strs("a\n  $b\\n\n"), nomore},
+
 	// Pipelines.
 	// Pure byte pipeline
 	{`echo "Albert\nAllan\nAlbraham\nBerlin" | sed s/l/1/g | grep e`,
@@ -53,12 +58,22 @@ var evalTests = []struct {
 	// TODO: Add a useful hybrid pipeline sample
 
 	// List element assignment
-	// {"li=[foo bar]; li[0]=233; put $@li", strs("233", "bar"), nomore},
+	{"li=[foo bar]; li[0]=233; put $@li", strs("233", "bar"), nomore},
+	{"li=[foo bar]; li[0:1]=233", noout, more{wantError: errAny}},
 	// Map element assignment
 	{"di=[&k=v]; di[k]=lorem; di[k2]=ipsum; put $di[k] $di[k2]",
 		strs("lorem", "ipsum"), nomore},
 	{"d=[&a=[&b=v]]; put $d[a][b]; d[a][b]=u; put $d[a][b]",
 		strs("v", "u"), nomore},
+	{"d=[&a=[x y]]; d[a][1]=z; explode $d[a]", strs("x", "z"), nomore},
+	// Lists and maps are immutable; element assignment only changes the
+	// assigned variable.
+	{"li=[foo bar]; li2=$li; li2[0]=233; put $li[0] $li2[0]",
+		strs("foo", "233"), nomore},
+	{"di=[&k=v]; di2=$di; di2[k]=u; put $di[k] $di2[k]",
+		strs("v", "u"), nomore},
+	{"di=[&k=v]; f={ put $di[k] }; di2=$di; di2[k]=u; $f",
+		strs("v"), nomore},
 	// Multi-assignments.
 	{"{a,b}=`put a b`; put $a $b", strs("a", "b"), nomore},
 	{"@a=`put a b`; put $@a", strs("a", "b"), nomore},
@@ -83,6 +98,16 @@ var evalTests = []struct {
 	// try
 	{"try { nop } except { put bad } else { put good }", strs("good"), nomore},
 	{"try { e:false } except - { put bad } else { put good }", strs("bad"), nomore},
+	{"try { fail haha } except e { put $e[kind] $e[message] }",
+		strs("error", "haha"), nomore},
+	{"try { e:false } except e { put $e[kind] $e[reason][cmd-name] $e[reason][exit-status] }",
+		strs("external-cmd/exited", "false", "1"), nomore},
+	{"try { fail a | fail b } except e { put $e[kind]; for x $e[reason][errors] { put $x[message] } }",
+		strs("pipeline", "a", "b"), nomore},
+	{"put $ok[kind]", strs("ok"), nomore},
+	{"try { try { fail a } except e { fail b &cause=$e } } except e { put $e[message] $e[cause][message] $e[cause][cause][kind] }",
+		strs("b", "a", "ok"), nomore},
+	{"try { fail haha } except e { put $e[bad] }", noout, more{wantError: errAny}},
 	// while
 	{"x=0; while (< $x 4) { put $x; x=(+ $x 1) }",
 		strs("0", "1", "2", "3"), nomore},
@@ -96,6 +121,93 @@ var evalTests = []struct {
 	// continue
 	{"for x [a b] { put $x; continue; put $x; }", strs("a", "b"), nomore},
 
+	// POSIX shell scripts.
+	{"posix 'echo $1 $2' a b", noout, more{wantBytesOut: []byte("a b\n")}},
+	{"posix 'echo $0 >&2; echo foo' 2>/dev/null", noout, more{wantBytesOut: []byte("foo\n")}},
+	{"posix 'exit 3'", noout, more{wantError: errAny}},
+
+	// Documentation.
+	{"doc put", noout, more{wantBytesOut: []byte("put value...\nOutputs the values.\n")}},
+	{"doc builtin:if", noout, more{wantBytesOut: []byte(builtinDocs["if"] + "\n")}},
+	{"doc nonexistent", noout, more{wantError: ErrNoDoc}},
+	{"x = 1; fn f [a @b]{ }; put (vars)[x]", strs("1"), nomore},
+	{"fn f [a @b]{ }; fns | each [m]{ put $m[name] $m[params] $m[rest] }",
+		[]Value{String("f"), NewList(String("a")), String("b")}, nomore},
+	// Closures only capture the variables they use.
+	{"x = 1; { y = 2; nop $x; put (vars)[x] (vars)[y] }", strs("1", "2"), nomore},
+	{"builtins | each [b]{ if (eq $b put) { put found } }", strs("found"), nomore},
+	{"fn f [a]{ put $a }; fn-source f; fn-source { nop }", noout,
+		more{wantBytesOut: []byte("fn f [a]{ put $a }\n{ nop }\n")}},
+	{"nop\n  fn f { }; fn-location $&f", noout,
+		more{wantBytesOut: []byte("<eval test>:2:8\n")}},
+	{"fn-source put", noout, more{wantError: ErrNotClosure}},
+
+	// trace
+	{"{ local:trace = $true; echo a 'b c' &sep=, >/dev/null; nop } 2>&1; nop",
+		noout, more{wantBytesOut: []byte(
+			"+ echo a 'b c' &sep=, 1>/dev/null\n+ nop\n")}},
+
+	// Recursion limit.
+	{"fn f { f }; f", noout, more{wantError: ErrMaxCallDepth}},
+	{"fn f [n]{ if (< $n 20) { f (+ $n 1) } else { put $n } }; max-call-depth = 10; try { f 0 } except e { put caught }",
+		strs("caught"), nomore},
+	// Bodies of if count as closure calls too: 21 calls of f, 21 bodies.
+	{"fn f [n]{ if (< $n 20) { f (+ $n 1) } else { put $n } }; max-call-depth = 42; f 0",
+		strs("20"), nomore},
+	{"fn f [n]{ if (< $n 20) { f (+ $n 1) } else { put $n } }; max-call-depth = 41; f 0",
+		noout, more{wantError: ErrMaxCallDepth}},
+	{"max-call-depth = 0", noout, more{wantError: errAny}},
+
+	// cover
+	{"cover { nop; nop }", noout, more{wantBytesOut: []byte(
+		"<eval test>: 1/1 lines covered\n" +
+			"       2     1: cover { nop; nop }\n")}},
+
+	// which
+	{"which if", noout, more{wantBytesOut: []byte("if: special form\n")}},
+	{"which put", noout, more{wantBytesOut: []byte("put: builtin function in builtin scope\n")}},
+	{"fn put { }; which put", noout, more{wantBytesOut: []byte(
+		"put: function in local scope, defined in <eval test>:1:8\n" +
+			"     builtin function in builtin scope (shadowed)\n")}},
+	{"which e:elvish-no-such-command", noout, more{wantBytesOut: []byte(
+		"e:elvish-no-such-command: external command (not found)\n")}},
+
+	// Shell options.
+	{"fail x | put a", strs("a"), more{wantError: errAny}},
+	{"pipefail = $false; fail x | put a", strs("a"), nomore},
+	{"f = { local:pipefail = $false; fail x | put a }; $f; fail y | put b",
+		strs("a", "b"), more{wantError: errAny}},
+	{"f = { fail x; put a }; $f", noout, more{wantError: errAny}},
+	{"errexit = $false; f = { fail x; put a }; $f 2>/dev/null", strs("a"), nomore},
+	{"errexit = $false; fn f { put a; return; put b }; f", strs("a"), nomore},
+	{"pipefail = foo", noout, more{wantError: errAny}},
+	// set-option applies until the enclosing closure returns.
+	{"{ set-option pipefail $false; fail x | put a; get-option pipefail }; get-option pipefail",
+		[]Value{String("a"), Bool(false), Bool(true)}, nomore},
+	{"f = { get-option pipefail }; { set-option pipefail $false; $f }; $f",
+		bools(false, true), nomore},
+	{"{ set-option nomatch-ok $true; put /a/b/nonexistent* }", noout, nomore},
+	{"set-option pipefail foo", noout, more{wantError: errAny}},
+	{"get-option no-such-option", noout, more{wantError: errAny}},
+	{"options | each [o]{ put $o[name] } | count", strs("9"), nomore},
+
+	// Temporary assignments to environment variables only apply to the form.
+	{"E:ELVISH_TEST_TMP=foo sh -c 'echo $ELVISH_TEST_TMP'; put $E:ELVISH_TEST_TMP",
+		strs(""), more{wantBytesOut: []byte("foo\n")}},
+	{"E:ELVISH_TEST_TMP=foo { put $E:ELVISH_TEST_TMP; sh -c 'echo $ELVISH_TEST_TMP' }",
+		strs("foo"), more{wantBytesOut: []byte("foo\n")}},
+	{"E:ELVISH_TEST_TMP=foo sh -c 'echo $ELVISH_TEST_TMP' | put (slurp)",
+		strs("foo\n"), nomore},
+	{"E:ELVISH_TEST_TMP=foo { E:ELVISH_TEST_TMP = bar; put $E:ELVISH_TEST_TMP; sh -c 'echo $ELVISH_TEST_TMP' }; put $E:ELVISH_TEST_TMP",
+		strs("bar", ""), more{wantBytesOut: []byte("bar\n")}},
+	{"E:HOME=/x E:ELVISH_TEST_TMP=$E:HOME sh -c 'echo $HOME $ELVISH_TEST_TMP'",
+		noout, more{wantBytesOut: []byte("/x " + os.Getenv("HOME") + "\n")}},
+	{"E:HOME=/x { del E:HOME; put $E:HOME; sh -c 'echo ${HOME-unset}' }; put $E:HOME",
+		strs("", os.Getenv("HOME")), more{wantBytesOut: []byte("unset\n")}},
+	{"d = (mktemp -d); echo \"#!/bin/sh\necho found\" > $d/elvish-test-cmd; " +
+		"chmod +x $d/elvish-test-cmd; E:PATH=$d':'$E:PATH elvish-test-cmd; rm -r $d",
+		noout, more{wantBytesOut: []byte("found\n")}},
+
 	// Redirections.
 	{"f=`mktemp elvXXXXXX`; echo 233 > $f; cat < $f; rm $f", noout,
 		more{wantBytesOut: []byte("233\n")}},
@@ -106,6 +218,77 @@ var evalTests = []struct {
 	// Redirections from Pipe object.
 	{`p=(pipe); echo haha > $p; pwclose $p; cat < $p; prclose $p`, noout,
 		more{wantBytesOut: []byte("haha\n")}},
+	// File locks.
+	{`with-temp-file [t]{ flock $t { put a } }`, strs("a"), nomore},
+	{`with-temp-file [t]{ flock $t { flock &nonblock $t { } } }`, noout,
+		more{wantError: ErrLocked}},
+	{`with-temp-file [t]{ flock $t { flock &timeout=0.05 $t { } } }`, noout,
+		more{wantError: ErrLocked}},
+	{`with-temp-file [t]{ flock &shared $t { flock &shared &nonblock $t { put a } } }`,
+		strs("a"), nomore},
+	{`with-temp-file [t]{ try { flock $t { fail x } } except _ { }; flock &nonblock $t { put a } }`,
+		strs("a"), nomore},
+	// Named pipes.
+	{`f=(mkfifo); { echo haha > $f } | cat < $f; rmfifo $f`, noout,
+		more{wantBytesOut: []byte("haha\n")}},
+	{`f=(mkfifo); { w=(fopen &write $f); put a b c | peach [x]{ echo $x > $w }; fclose $w } | cat < $f | sort; rmfifo $f`,
+		noout, more{wantBytesOut: []byte("a\nb\nc\n")}},
+	{`f=(mkfifo); rmfifo $f; bool ?(test -e $f)`, bools(false), nomore},
+	{`with-temp-file [t]{ rmfifo $t }`, noout, more{wantError: errAny}},
+	{`with-temp-file [t]{ f=(fopen &write $t); echo haha > $f; fclose $f
+			f=(fopen &append $t); echo hoho > $f; fclose $f; cat < $t }`, noout,
+		more{wantBytesOut: []byte("haha\nhoho\n")}},
+	// Redirections to temporary files.
+	{`with-temp-file [t]{ put a b > $t; echo haha > $t; cat < $t }`, noout,
+		more{wantBytesOut: []byte("haha\n")}},
+	{`with-temp-file [t]{ put $t } | each [t]{ bool ?(test -e $t) }`,
+		bools(false), nomore},
+	{`with-temp-file [t]{ fail x }`, noout, more{wantError: errAny}},
+	{`f = ''; try { with-temp-file [t]{ f = $t; fail x } } except _ { }
+		bool ?(test -e $f)`, bools(false), nomore},
+	// Representations evaluate back to the values.
+	{`repr [&'a=b'=[c '=']]`, noout,
+		more{wantBytesOut: []byte("[&'a=b'=[c '=']]\n")}},
+	{`put [&'a=b'=c]['a=b'] [&"=x"=y]['=x']`, strs("c", "y"), nomore},
+
+	// Byte output.
+	{`print a b &sep=, &end=!`, noout, more{wantBytesOut: []byte("a,b!")}},
+	{`echo &n a`, noout, more{wantBytesOut: []byte("a")}},
+	{`echo &end=. a b`, noout, more{wantBytesOut: []byte("a b.")}},
+	{`echo a [b]`, noout, more{wantBytesOut: []byte("a [b]\n")}},
+	{`pprint &color=always $true`, noout,
+		more{wantBytesOut: []byte("\033[35m$true\033[m\n")}},
+	{`pprint &color=bad x`, noout, more{wantError: errAny}},
+
+	// Tables.
+	{`put [&name=a &size=100] [&name=bcd &size=2] | to-table`, noout,
+		more{wantBytesOut: []byte("name  size\na     100\nbcd   2\n")}},
+	{`to-table &columns=[size] [[&name=a &size=1] [&name=b]]`, noout,
+		more{wantBytesOut: []byte("size\n1\n\n")}},
+	{`put [a bb] [ccc d e] | to-table`, noout,
+		more{wantBytesOut: []byte("a    bb\nccc  d   e\n")}},
+	{`to-table &color=always [[&a=1]]`, noout,
+		more{wantBytesOut: []byte("\033[1ma\033[m\n1\n")}},
+	{`to-table [a]`, noout, more{wantError: errAny}},
+
+	// tee copies inputs to files and functions.
+	{`with-temp-file [t]{ echo hello | tee $t; cat < $t }`, noout,
+		more{wantBytesOut: []byte("hello\nhello\n")}},
+	{`with-temp-file [t]{ put a [b] | tee $t; cat < $t }`,
+		[]Value{String("a"), NewList(String("b"))},
+		more{wantBytesOut: []byte("a\n[b]\n")}},
+	{`with-temp-file [t]{ echo a > $t; echo b | tee &append $t > /dev/null; cat < $t }`,
+		noout, more{wantBytesOut: []byte("a\nb\n")}},
+	{`n = 0; put a b c | tee { n = (count) } | count; put $n`,
+		strs("3", "3"), nomore},
+	{`echo x | tee { fail y }`, noout,
+		more{wantBytesOut: []byte("x\n"), wantError: errAny}},
+	// Redirections are applied in order.
+	{`echo haha 2>&1 >&-`, noout, nomore},
+	// Redirecting from an fd that is not open.
+	{`echo haha >&10`, noout, more{wantError: errAny}},
+	// A failing redirection source leaves the port intact.
+	{`put a | { put b >(fail x) }`, noout, more{wantError: errAny}},
 
 	// Compounding.
 	{"put {fi,elvi}sh{1.0,1.1}",
@@ -117,6 +300,22 @@ var evalTests = []struct {
 	{"put [a b c][2]", strs("c"), nomore},
 	{"put [;a;b c][2][0]", strs("b"), nomore},
 	{"put [&key=value][key]", strs("value"), nomore},
+	// Strings are indexed by runes.
+	{"s = aé你好; put $s[1] $s[2:4] $s[-1]", strs("é", "你好", "好"), nomore},
+	{"count aé你好", strs("4"), nomore},
+	{"put abc[5]", noout, more{wantError: ErrIndexOutOfRange}},
+	{"str-index &unit=byte aé你 1:3", strs("é"), nomore},
+	{"str-index &unit=width a你好 1:3", strs("你"), nomore},
+	{"str-index &unit=width a你好 3", strs("好"), nomore},
+	{"str-index &unit=width a你好 2", noout, more{wantError: ErrBadIndex}},
+	{"str-index &unit=width a你好 1", strs("你"), nomore},
+	{"str-index &unit=width a你好 -2", strs("好"), nomore},
+	{"str-index &unit=width a你好 1:2", noout, more{wantError: ErrBadIndex}},
+	{"str-index &unit=width \"e\u0301x\" 0", strs("e\u0301"), nomore},
+	{"str-index a你好 -1", strs("好"), nomore},
+	{"str-index &unit=line abc 0", noout, more{wantError: ErrBadUnit}},
+	{"s = a你好; put $s[1] (str-index &unit=width $s 3) $s[2] abc[2]",
+		strs("你", "好", "好", "c"), nomore},
 
 	// String literal
 	{`put 'such \"''literal'`, strs(`such \"'literal`), nomore},
@@ -140,6 +339,11 @@ var evalTests = []struct {
 	// XXX assumes there is no /a/b/nonexistent*
 	{"put /a/b/nonexistent*", noout, more{wantError: ErrWildcardNoMatch}},
 	{"put /a/b/nonexistent*[nomatch-ok]", noout, nomore},
+	{"d = (mktemp -d); touch $d/a10 $d/a2 $d/B1; " +
+		"for f [$d/*[collate:version]] { path-base $f }; " +
+		"for f [$d/*[collate:icase]] { path-base $f }; rm -r $d",
+		strs("B1", "a2", "a10", "a10", "a2", "B1"), nomore},
+	{"put *[collate:locale]", noout, more{wantError: ErrBadCollation}},
 
 	// Tilde.
 	{"h=$E:HOME; E:HOME=/foo; put ~ ~/src; E:HOME=$h",
@@ -211,6 +415,20 @@ var evalTests = []struct {
 	{`print "a\nb" | slurp`, strs("a\nb"), nomore},
 	{`print "a\nb" | from-lines`, strs("a", "b"), nomore},
 	{`print "a\nb\n" | from-lines`, strs("a", "b"), nomore},
+	{`print "a\nb" | { read; read }`, strs("a", "b"), nomore},
+	{`print "a\nb\n" | { read >/dev/null; from-lines }`, strs("b"), nomore},
+	{`read </dev/null`, noout, more{wantError: ErrNoInput}},
+	{`print "a\na\n" | read &confirm 2>/dev/null`, strs("a"), nomore},
+	{`print "a\nb\n" | read &confirm 2>/dev/null`, noout, more{wantError: ErrMismatch}},
+	{`print "pw\n" | read &secret | each [s]{ kind-of $s }`, strs("secret"), nomore},
+	{`print "pw\n" | read &secret | each [s]{ ==s $s pw; !=s $s pw; <s $s px }`,
+		bools(true, false, true), nomore},
+	{`print "pw\n" | read &secret | each [s]{ eq $s pw; eq $s'' pw; put (put $s'')[0] }`,
+		[]Value{Bool(false), Bool(true), String("p")}, nomore},
+	{`print "pw\n" | read &secret | each [s]{ echo $s; repr $s }`,
+		noout, more{wantBytesOut: []byte("pw\n<secret>\n")}},
+	{`print "pw\n" | read &secret | each [s]{ { local:trace = $true; nop $s } 2>&1 }`,
+		noout, more{wantBytesOut: []byte("+ nop <secret>\n")}},
 	{`echo '{"k": "v", "a": [1, 2]}' '"foo"' | from-json`, []Value{
 		NewMap(map[Value]Value{
 			String("k"): String("v"),
@@ -226,6 +444,16 @@ var evalTests = []struct {
 "foo"
 `)}},
 
+	{`put [&k=[v $true]] | dump`, noout, more{wantBytesOut: []byte(
+		`{"elvish-dump":1,"value":{"map":[["k",["v",true]]]}}` + "\n")}},
+	{`v = [a [b c] [&[k]=[x] &l=[&]] $true]; eq $v (put $v | dump | load)`,
+		bools(true), nomore},
+	{`dump [{ }]`, noout, more{wantError: errAny}},
+	{`echo '{"elvish-dump":2,"value":"x"}' | load`, noout,
+		more{wantError: ErrBadDumpVersion}},
+	{`echo '{"elvish-dump":1,"value":1}' | load`, noout,
+		more{wantError: ErrBadDump}},
+
 	{`joins : [/usr /bin /tmp]`, strs("/usr:/bin:/tmp"), nomore},
 	{`splits &sep=: /usr:/bin:/tmp`, strs("/usr", "/bin", "/tmp"), nomore},
 	{`has-prefix golang go`, bools(true), nomore},
@@ -254,9 +482,128 @@ var evalTests = []struct {
 	{`range 1 3`, strs("1", "2"), nomore},
 	{`range 0 10 &step=3`, strs("0", "3", "6", "9"), nomore},
 	{`range 100 | take 2`, strs("0", "1"), nomore},
+	{`range 5 0 &step=-2`, strs("5", "3", "1"), nomore},
+	{`range 1 &step=0`, noout, more{wantError: ErrZeroStep}},
+	// Upstream stops early.
+	{`range 1e9 | take 3`, strs("0", "1", "2"), nomore},
+	{`repeat 1000000000 x | first`, strs("x"), nomore},
+	{`range 1e9 | take 2 | first`, strs("0"), nomore},
+	{`{ put a; range 1e9; put never } | take 2`, strs("a", "0"), nomore},
+	{`range 1e9 | each [x]{ put $x } | take 2`, strs("0", "1"), nomore},
+	{`{ range 1e9 | each [x]{ put $x } } | take 1`, strs("0"), nomore},
+	{`range 1e9 | each [x]{ break }`, noout, nomore},
+	{`yes | take 1`, strs("y"), nomore},
+	{`take 0 [a b]`, noout, nomore},
+	{`put a b c | drop 1`, strs("b", "c"), nomore},
+	{`drop 5 [a b]`, noout, nomore},
+	{`first [a b]`, strs("a"), nomore},
+	{`last [a b]`, strs("b"), nomore},
+	{`first []`, noout, more{wantError: ErrNoInput}},
+	{`put | last`, noout, more{wantError: ErrNoInput}},
 	{`range 100 | count`, strs("100"), nomore},
 	{`count [(range 100)]`, strs("100"), nomore},
 
+	{`zip [a b c] [1 2 3]`, lists([]string{"a", "1"}, []string{"b", "2"},
+		[]string{"c", "3"}), nomore},
+	{`zip [a b c] [1 2]`, lists([]string{"a", "1"}, []string{"b", "2"}),
+		nomore},
+	{`zip &longest &fill=x [a b c] [1 2]`, lists([]string{"a", "1"},
+		[]string{"b", "2"}, []string{"c", "x"}), nomore},
+	{`zip`, noout, nomore},
+	{`put [a 1] [b 2] [c 3] | unzip`,
+		lists([]string{"a", "b", "c"}, []string{"1", "2", "3"}), nomore},
+	{`unzip [[a 1] [b 2]]`, lists([]string{"a", "b"}, []string{"1", "2"}),
+		nomore},
+	{`zip [a b] [1 2] | unzip`, lists([]string{"a", "b"}, []string{"1", "2"}),
+		nomore},
+	{`put [a 1] [b] | unzip`, noout, more{wantError: ErrUnevenLengths}},
+	{`put [a] [b 2] | unzip`, noout, more{wantError: ErrUnevenLengths}},
+
+	{`duration-parse 1m30s`, strs("90"), nomore},
+	{`duration-parse 1.5`, strs("1.5"), nomore},
+	{`duration-format 90`, strs("1m30s"), nomore},
+	{`duration-parse abc`, noout, more{wantError: ErrBadDuration}},
+	{`time-parse 1970-01-02T00:00:00Z`, strs("86400"), nomore},
+	{`time-parse &layout=datetime (time-format &layout=datetime 86400)`,
+		strs("86400"), nomore},
+	{`< 0 (now)`, bools(true), nomore},
+	{`esleep 1ms`, noout, nomore},
+	{`i = 0; every 1ms { i = (+ $i 1); if (== $i 3) { break } }; put $i`,
+		strs("3"), nomore},
+	{`every 0 { }`, noout, more{wantError: ErrBadDuration}},
+	{`i = 0; retry &backoff=1ms { i = (+ $i 1); if (< $i 3) { fail x } }; put $i`,
+		strs("3"), nomore},
+	{`i = 0; try { retry &times=2 &backoff=0 { i = (+ $i 1); fail x } } except _ { }; put $i`,
+		strs("2"), nomore},
+	{`retry &times=1 { fail x }`, noout, more{wantError: errAny}},
+	{`for x [a b] { retry { put $x; break; put c } }`, strs("a", "b"), nomore},
+	{`i = 0; retry &times=3 &backoff=0 { i = (+ $i 1); continue }; put $i`,
+		strs("3"), nomore},
+	{`i = 0; fn f { retry { i = (+ $i 1); return } }; f; put $i`,
+		strs("1"), nomore},
+	{`retry &times=0 { }`, noout, more{wantError: ErrArgs}},
+	{`retry &backoff=x { }`, noout, more{wantError: ErrBadDuration}},
+	{`with-temp-file [t]{ i = 0; watch &interval=1ms &debounce=1ms $t {
+			i = (+ $i 1); if (== $i 3) { break }; echo $i >> $t }; put $i }`,
+		strs("3"), nomore},
+	{`with-temp-file [t]{ i = 0; watch &interval=1ms &debounce=1ms $t {
+			i = (+ $i 1); if (== $i 2) { break }; echo >> $t; fail x } 2>/dev/null; put $i }`,
+		strs("2"), nomore},
+	{`with-temp-file [t]{ i = 0; fn f { watch &interval=1ms &debounce=1ms $t {
+			i = (+ $i 1); if (== $i 2) { return }; echo >> $t } }; f; put $i }`,
+		strs("2"), nomore},
+	{`with-temp-file [t]{ i = 0; watch &interval=1ms &debounce=1ms $t {
+			i = (+ $i 1); echo >> $t; if (< $i 3) { continue }; break }; put $i }`,
+		strs("3"), nomore},
+	{`for x [a b] { with-temp-file [t]{ watch &interval=1ms $t { put $x; break } } }`,
+		strs("a", "b"), nomore},
+	{`watch { }`, noout, more{wantError: ErrArgs}},
+
+	{`randint &crypto 3 4`, strs("3"), nomore},
+	{`< (rand &crypto) 1`, bools(true), nomore},
+	{`u = (uuid); put (wcswidth $u) $u[14] $u[8]`, strs("36", "4", "-"), nomore},
+	{`==s (uuid) (uuid)`, bools(false), nomore},
+	{`wcswidth (rand-token &bytes=4)`, strs("8"), nomore},
+	{`rand-token &bytes=0`, noout, more{wantError: ErrArgs}},
+
+	{`r = (run &capture &stdin=hello cat); put $r[stdout] $r[exit-status]`,
+		strs("hello", "0"), nomore},
+	{`r = (run &capture &clear-env &env=[&X=y] env); put $r[stdout]`,
+		strs("X=y\n"), nomore},
+	{`r = (run &capture &dir=/ pwd); put $r[stdout]`, strs("/\n"), nomore},
+	{`r = (run false); put $r[exit-status] $r[signal]`, strs("1", ""), nomore},
+	{`r = (run sh -c 'kill -TERM $$'); put $r[exit-status] $r[signal]`,
+		strs("-1", "terminated"), nomore},
+	// Without &capture, the output is written to the byte output.
+	{`count [(run echo hi)]`, strs("2"), nomore},
+
+	{`put b c a | order`, strs("a", "b", "c"), nomore},
+	{`order [10 9 1.5]`, strs("1.5", "10", "9"), nomore},
+	{`order &numeric [10 9 1.5]`, strs("1.5", "9", "10"), nomore},
+	{`order &numeric &reverse [10 9 1.5]`, strs("10", "9", "1.5"), nomore},
+	{`order &key=[x]{ put $x[1] } &stable [a2 b1 c2 d1]`,
+		strs("b1", "d1", "a2", "c2"), nomore},
+	// Values of different kinds are ordered by their kinds.
+	{`order [[b] c [a] $true [a b] $false]`, []Value{
+		Bool(false), Bool(true), String("c"), NewList(String("a")),
+		NewList(String("a"), String("b")), NewList(String("b"))}, nomore},
+	{`order &collate=version [a10 a9 b1 a9x]`,
+		strs("a9", "a9x", "a10", "b1"), nomore},
+	{`order &collate=icase [b A a B]`, strs("A", "a", "B", "b"), nomore},
+	{`order &collate=version [v1.10 v1.9 v1.09]`,
+		strs("v1.09", "v1.9", "v1.10"), nomore},
+	{`order &numeric [a]`, noout, more{wantError: errAny}},
+	{`order &key=[x]{ } [a b]`, noout, more{wantError: errAny}},
+
+	{`put a a b a c c | uniq`, strs("a", "b", "a", "c"), nomore},
+	{`uniq &global [a a b a [x] [x] c]`, []Value{
+		String("a"), String("b"), NewList(String("x")), String("c")}, nomore},
+	{`frequencies [a b a c a] | each [m]{ put $m[a] $m[b] $m[c] }`,
+		strs("3", "1", "1"), nomore},
+	{`m = (group-by [x]{ put $x[0] } [ab cd ae]); explode $m[a]; explode $m[c]`,
+		strs("ab", "ae", "cd"), nomore},
+	{`group-by [x]{ put a b } [a]`, noout, more{wantError: errAny}},
+
 	{`echo "  ax  by cz  \n11\t22 33" | eawk { put $args[-1] }`,
 		strs("cz", "33"), nomore},
 
@@ -271,9 +618,28 @@ var evalTests = []struct {
 	{"/ 1 0", strs("+Inf"), nomore},
 	{"^ 16 2", strs("256"), nomore},
 	{"% 23 7", strs("2"), nomore},
+	// Integers are computed exactly.
+	{"+ 9007199254740993 1", strs("9007199254740994"), nomore},
+	{"* 99999999999 99999999999", strs("9999999999800000000001"), nomore},
+	{"* 1000 1000", strs("1000000"), nomore},
+	{"- 0x10 0b1", strs("15"), nomore},
+	{"/ 100000000000000000000 8", strs("12500000000000000000"), nomore},
+	{"/ 10 4", strs("2.5"), nomore},
+	{"+ 1.5 1", strs("2.5"), nomore},
+	{"^ 2 100", strs("1267650600228229401496703205376"), nomore},
+	{"^ 2 -1", strs("0.5"), nomore},
+	{"% 100000000000000000007 10", strs("7"), nomore},
+	{"% 1 0", noout, more{wantError: ErrDivideByZero}},
+	{"% 1.5 1", noout, more{wantError: ErrNotInteger}},
+	{`format-num &digits=2 3.14159`, strs("3.14"), nomore},
+	{`format-num &sci 123456`, strs("1.23456e+05"), nomore},
+	{`format-num &sci &digits=2 100000000000000000000`, strs("1.00e+20"), nomore},
+	{`format-num 0.000001`, strs("0.000001"), nomore},
 
 	{`== 1 1.0`, bools(true), nomore},
 	{`== 10 0xa`, bools(true), nomore},
+	{`== 9007199254740993 9007199254740992`, bools(false), nomore},
+	{`< 9007199254740992 9007199254740993`, bools(true), nomore},
 	{`== a a`, noout, more{wantError: errAny}},
 	{`> 0x10 1`, bools(true), nomore},
 
@@ -282,10 +648,32 @@ var evalTests = []struct {
 	{`is [1] [1]`, bools(false), nomore},
 	{`eq 1 1`, bools(true), nomore},
 	{`eq [] []`, bools(true), nomore},
+	{`eq [a [b]] [a [b]] [a [c]]`, bools(false), nomore},
+	{`eq [&a=[b] &c=d] [&c=d &a=[b]]`, bools(true), nomore},
+	{`eq [&a=b] [&a=b &c=d]`, bools(false), nomore},
+	// Lists and maps can be used as map keys.
+	{`m = [&[a b]=x]; put $m[[a b]]`, strs("x"), nomore},
+	{`m = [&]; m[[&k=v]] = x; m[[&k=v]] = y; repr $m`, noout,
+		more{wantBytesOut: []byte("[&[&k=v]=y]\n")}},
+	{`repr [&b=x &[a]=y &a=z &$true=w]`, noout,
+		more{wantBytesOut: []byte("[&$true=w &a=z &b=x &[a]=y]\n")}},
 
 	{`ord a`, strs("0x61"), nomore},
 	{`base 16 42 233`, strs("2a", "e9"), nomore},
+	{`base 16 18446744073709551616`, strs("10000000000000000"), nomore},
 	{`wcswidth 你好`, strs("4"), nomore},
+	{`quote a/b`, strs("a/b"), nomore},
+	{`quote 'a b' "it's"`, noout, more{wantError: errAny}},
+	{`quote "it's"`, strs("'it''s'"), nomore},
+	{`quote "\n"`, strs(`"\n"`), nomore},
+}
+
+func lists(sss ...[]string) []Value {
+	vs := make([]Value, len(sss))
+	for i, ss := range sss {
+		vs[i] = NewList(strs(ss...)...)
+	}
+	return vs
 }
 
 func strs(ss ...string) []Value {
@@ -360,7 +748,7 @@ func evalAndCollect(t *testing.T, texts []string, chsize int) ([]Value, []byte,
 			{File: os.Stderr, Chan: BlackholeChan},
 		}
 
-		ex = ev.eval(op, ports, name, text)
+		ex = ev.eval(op, ports, nil, name, text)
 		close(outCh)
 		<-outDone
 	}
@@ -422,3 +810,142 @@ func TestMultipleEval(t *testing.T) {
 		t.Errorf("eval %s outputs %v, want %v", texts, outs, wanted)
 	}
 }
+
+func TestSourceTextWithPorts(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	outCh := make(chan Value, 10)
+	ports := []*Port{
+		{File: os.Stdin, Chan: ClosedChan},
+		{File: os.Stdout, Chan: outCh},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	err := ev.SourceTextWithPorts(ports, "[embed]", "put a (+ 1 2)")
+	close(outCh)
+	if err != nil {
+		t.Errorf("SourceTextWithPorts => %v, want nil", err)
+	}
+	var outs []Value
+	for v := range outCh {
+		outs = append(outs, v)
+	}
+	if wanted := strs("a", "3"); !reflect.DeepEqual(outs, wanted) {
+		t.Errorf("SourceTextWithPorts outputs %v, want %v", outs, wanted)
+	}
+}
+
+func TestEvalStringAndCall(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	outs, err := ev.EvalString("[embed]", "fn f [x]{ put $x $x }; put $&f; range 100")
+	if err != nil {
+		t.Fatalf("EvalString => error %v", err)
+	}
+	if len(outs) != 101 {
+		t.Fatalf("EvalString outputs %d values, want 101", len(outs))
+	}
+	f, ok := outs[0].(Callable)
+	if !ok {
+		t.Fatalf("EvalString outputs %v, want a function first", outs[0])
+	}
+	outs, err = ev.Call(f, []Value{String("a")}, nil)
+	if wanted := strs("a", "a"); err != nil || !reflect.DeepEqual(outs, wanted) {
+		t.Errorf("Call => (%v, %v), want (%v, nil)", outs, err, wanted)
+	}
+	if _, err := ev.Call(f, nil, nil); err == nil {
+		t.Errorf("Call with wrong arity => nil error")
+	}
+}
+
+func TestJobNotice(t *testing.T) {
+	d := 1500*time.Millisecond + 300*time.Microsecond
+	if got, want := jobNotice(1, "sleep 1", d, nil),
+		"job 1 (sleep 1) done after 1.5s"; got != want {
+		t.Errorf("jobNotice => %q, want %q", got, want)
+	}
+	if got, want := jobNotice(2, "fail x", d, errors.New("x")),
+		"job 2 (fail x) failed after 1.5s: x"; got != want {
+		t.Errorf("jobNotice => %q, want %q", got, want)
+	}
+}
+
+var compileErrorTests = []string{
+	"true = foo",
+	"{a,false} = x y",
+	"builtin:pid = 1",
+	"f = { true = x }",
+	"echo >a >b",
+	"echo 2>a 2>&1",
+}
+
+func TestCompileError(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	for _, text := range compileErrorTests {
+		n, err := parse.Parse("[test]", text)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %s", text, err)
+		}
+		_, err = ev.Compile(n, "[test]", text)
+		if _, ok := err.(*CompilationError); !ok {
+			t.Errorf("Compile(%q) => %v, want *CompilationError", text, err)
+		}
+	}
+	// Errors in different pipelines are all reported.
+	text := "true = x; put $nonexistent; false = y"
+	n, _ := parse.Parse("[test]", text)
+	_, err := ev.Compile(n, "[test]", text)
+	if es, ok := err.(*util.Errors); !ok || len(es.Errors) != 3 {
+		t.Errorf("Compile(%q) => %v, want 3 errors", text, err)
+	}
+	// Shadowing a read-only builtin explicitly is fine.
+	for _, text := range []string{"fn put { }", "local:true = x", "x = 1; x = 2"} {
+		mustParseAndCompile(t, ev, "[test]", text)
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ports := []*Port{
+		{File: os.Stdin, Chan: ClosedChan},
+		{File: os.Stdout, Chan: BlackholeChan},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	err := ev.SourceTextWithPorts(ports, "[traceback test]",
+		"fn f { fail x }\nfn g { f }\ng")
+	exc, ok := err.(*Exception)
+	if !ok {
+		t.Fatalf("got error %v, want *Exception", err)
+	}
+	var fns []string
+	for tb := exc.Traceback; tb != nil; tb = tb.Next {
+		fns = append(fns, tb.Function)
+		// The source is registered, so the traceback doesn't carry it.
+		if tb.Source != "" {
+			t.Errorf("traceback entry carries source %q", tb.Source)
+		}
+	}
+	if wanted := []string{"f", "g", ""}; !reflect.DeepEqual(fns, wanted) {
+		t.Errorf("traceback functions = %v, want %v", fns, wanted)
+	}
+	if p := exc.Pprint(""); !strings.Contains(p, "fail x") {
+		t.Errorf("Pprint => %q, want excerpt from the registered source", p)
+	}
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	for _, b := range builtinFns {
+		if _, ok := Doc(b.Name); !ok {
+			t.Errorf("builtin function %s has no documentation", b.Name)
+		}
+	}
+	for name := range builtinSpecials {
+		if _, ok := Doc(name); !ok {
+			t.Errorf("special form %s has no documentation", name)
+		}
+	}
+}
+
+func TestEmbeddedModulesCompile(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	for name, src := range embeddedModules {
+		mustParseAndCompile(t, ev, "[embedded module "+name+"]", src)
+	}
+}
@@ -0,0 +1,33 @@
+// Package evaltest provides helpers for testing native modules of Elvish.
+package evaltest
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+)
+
+// NewEvaler returns an Evaler with the given module available and without a
+// daemon.
+func NewEvaler(name string, ns eval.Namespace) *eval.Evaler {
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "",
+		map[string]eval.Namespace{name: ns})
+	ev.Daemon = nil
+	return ev
+}
+
+// Collect evaluates src with ev and returns the values it outputs.
+func Collect(ev *eval.Evaler, src string) ([]eval.Value, error) {
+	return ev.EvalString("[test]", src)
+}
+
+// EvalOutputs uses the module with the given name, evaluates src and returns
+// the values it outputs. Errors are reported with t.Errorf.
+func EvalOutputs(t *testing.T, name string, ns eval.Namespace, src string) []eval.Value {
+	outs, err := Collect(NewEvaler(name, ns), "use "+name+"; "+src)
+	if err != nil {
+		t.Errorf("%s: error %v", src, err)
+	}
+	return outs
+}
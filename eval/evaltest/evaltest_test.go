@@ -0,0 +1,16 @@
+package evaltest
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/eval"
+)
+
+func TestEvalOutputsManyValues(t *testing.T) {
+	ns := eval.Namespace{"x": eval.NewPtrVariable(eval.String("x"))}
+	outs := EvalOutputs(t, "m", ns, "range 100; put $m:x")
+	if len(outs) != 101 || outs[100] != eval.String("x") {
+		t.Errorf("got %d outputs ending with %v, want 101 ending with x",
+			len(outs), outs[len(outs)-1])
+	}
+}
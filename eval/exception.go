@@ -2,6 +2,7 @@ package eval
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -27,6 +28,53 @@ func (exc *Exception) Error() string {
 	return exc.Cause.Error()
 }
 
+// Unwrap returns the error of the exception.
+func (exc *Exception) Unwrap() error {
+	return exc.Cause
+}
+
+// WrappedError is an error that was raised because of another error, its
+// cause.
+type WrappedError struct {
+	Message string
+	Inner   error
+}
+
+func (e *WrappedError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *WrappedError) Unwrap() error {
+	return e.Inner
+}
+
+// causeOf returns the exception that caused exc, or nil if exc was not caused
+// by another error.
+func causeOf(exc *Exception) *Exception {
+	wrapper, ok := exc.Cause.(interface {
+		Unwrap() error
+	})
+	if !ok {
+		return nil
+	}
+	switch inner := wrapper.Unwrap().(type) {
+	case nil:
+		return nil
+	case *Exception:
+		return inner
+	default:
+		return &Exception{inner, nil}
+	}
+}
+
+// Long tracebacks, typically from deep recursions, are truncated to the
+// innermost tracebackHead and the outermost tracebackTail entries.
+const (
+	tracebackHead = 10
+	tracebackTail = 5
+)
+
 func (exc *Exception) Pprint(indent string) string {
 	buf := new(bytes.Buffer)
 	// Error message
@@ -36,10 +84,24 @@ func (exc *Exception) Pprint(indent string) string {
 	} else {
 		msg = "\033[31;1m" + exc.Cause.Error() + "\033[m"
 	}
-	fmt.Fprintf(buf, "Exception: %s\n", msg)
-	buf.WriteString(indent + "Traceback:")
+	fmt.Fprintf(buf, "Exception: %s", msg)
+	if exc.Traceback != nil {
+		buf.WriteString("\n" + indent + "Traceback:")
+	}
 
+	var tbs []*util.SourceContext
 	for tb := exc.Traceback; tb != nil; tb = tb.Next {
+		tbs = append(tbs, tb)
+	}
+	for i, tb := range tbs {
+		if len(tbs) > tracebackHead+tracebackTail && i >= tracebackHead &&
+			i < len(tbs)-tracebackTail {
+			if i == tracebackHead {
+				fmt.Fprintf(buf, "\n%s  ... %d more entries ...", indent,
+					len(tbs)-tracebackHead-tracebackTail)
+			}
+			continue
+		}
 		buf.WriteString("\n" + indent + "  ")
 		tb.Pprint(buf, indent+"    ")
 	}
@@ -54,6 +116,11 @@ func (exc *Exception) Pprint(indent string) string {
 		}
 	}
 
+	if cause := causeOf(exc); cause != nil {
+		buf.WriteString("\n" + indent + "Caused by:")
+		buf.WriteString("\n" + indent + "  " + cause.Pprint(indent+"  "))
+	}
+
 	return buf.String()
 }
 
@@ -75,6 +142,97 @@ func (exc *Exception) Bool() bool {
 	return exc.Cause == nil
 }
 
+// IndexOne makes the content of an Exception inspectable from elvishscript.
+// $e[kind] is a string identifying the kind of the error, $e[message] is the
+// error message, $e[reason] is a map with details specific to the kind, and
+// $e[cause] is the exception that caused it, or $ok.
+func (exc *Exception) IndexOne(idx Value) Value {
+	key, ok := idx.(String)
+	if !ok {
+		throw(ErrIndexMustBeString)
+	}
+	switch key {
+	case "kind":
+		return String(exceptionKind(exc.Cause))
+	case "message":
+		if exc.Cause == nil {
+			return String("")
+		}
+		return String(exc.Cause.Error())
+	case "reason":
+		return exceptionReason(exc.Cause)
+	case "cause":
+		if cause := causeOf(exc); cause != nil {
+			return cause
+		}
+		return OK
+	default:
+		throw(errors.New("no such key: " + key.Repr(NoPretty)))
+		panic("unreachable")
+	}
+}
+
+func exceptionKind(cause error) string {
+	switch cause := cause.(type) {
+	case nil:
+		return "ok"
+	case Flow:
+		return "flow"
+	case PipelineError:
+		return "pipeline"
+	case ExternalCmdExit:
+		switch ws := cause.WaitStatus; {
+		case ws.Signaled():
+			return "external-cmd/signaled"
+		case ws.Stopped():
+			return "external-cmd/stopped"
+		default:
+			return "external-cmd/exited"
+		}
+	default:
+		return "error"
+	}
+}
+
+func exceptionReason(cause error) Value {
+	switch cause := cause.(type) {
+	case Flow:
+		return makeReason("name", String(cause.Error()))
+	case PipelineError:
+		errs := make([]Value, len(cause.Errors))
+		for i, e := range cause.Errors {
+			errs[i] = e
+		}
+		return makeReason("errors", NewList(errs...))
+	case ExternalCmdExit:
+		ws := cause.WaitStatus
+		var signal String
+		if ws.Signaled() {
+			signal = String(ws.Signal().String())
+		} else if ws.Stopped() {
+			signal = String(ws.StopSignal().String())
+		}
+		return makeReason(
+			"cmd-name", String(cause.CmdName),
+			"exit-status", String(strconv.Itoa(ws.ExitStatus())),
+			"signal", signal,
+			"pid", String(strconv.Itoa(cause.Pid)))
+	default:
+		return makeReason()
+	}
+}
+
+// makeReason builds a read-only Struct from alternating field names and
+// values.
+func makeReason(pairs ...interface{}) *Struct {
+	s := &Struct{}
+	for i := 0; i < len(pairs); i += 2 {
+		s.FieldNames = append(s.FieldNames, pairs[i].(string))
+		s.Fields = append(s.Fields, NewRoVariable(pairs[i+1].(Value)))
+	}
+	return s
+}
+
 // PipelineError represents the errors of pipelines, in which multiple commands
 // may error.
 type PipelineError struct {
@@ -193,7 +351,7 @@ func NewExternalCmdExit(name string, ws syscall.WaitStatus, pid int) error {
 }
 
 func FakeExternalCmdExit(name string, exit int, sig syscall.Signal) ExternalCmdExit {
-	return ExternalCmdExit{syscall.WaitStatus(exit<<8 + int(sig)), name, 0}
+	return ExternalCmdExit{fakeWaitStatus(exit, sig), name, 0}
 }
 
 func (exit ExternalCmdExit) Error() string {
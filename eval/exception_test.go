@@ -0,0 +1,36 @@
+package eval
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/util"
+)
+
+func TestExceptionPprintShowsCause(t *testing.T) {
+	inner := &Exception{errors.New("inner"), nil}
+	exc := &Exception{&WrappedError{"outer", inner}, nil}
+	s := exc.Pprint("")
+	if !strings.Contains(s, "outer") || !strings.Contains(s, "Caused by:") ||
+		!strings.Contains(s, "inner") {
+		t.Errorf("Pprint => %q, want outer message and its cause", s)
+	}
+	if !errors.Is(exc, inner) {
+		t.Errorf("errors.Is(exc, inner) => false, want true")
+	}
+}
+
+func TestExceptionPprintTruncatesTraceback(t *testing.T) {
+	var tb *util.SourceContext
+	for i := 0; i < 20; i++ {
+		tb = &util.SourceContext{Name: "a.elv", Source: "f", Begin: 0, End: 1, Next: tb}
+	}
+	s := (&Exception{errors.New("x"), tb}).Pprint("")
+	if !strings.Contains(s, "... 5 more entries ...") {
+		t.Errorf("traceback not truncated:\n%s", s)
+	}
+	if n := strings.Count(s, "a.elv:"); n != tracebackHead+tracebackTail {
+		t.Errorf("traceback shows %d entries, want %d", n, tracebackHead+tracebackTail)
+	}
+}
@@ -0,0 +1,58 @@
+// +build !windows
+
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// FdNil is a special impossible fd value used for "close fd" in
+// syscall.ProcAttr.Files.
+const fdNil uintptr = ^uintptr(0)
+
+// run runs the external command at path with the given arguments, and waits
+// for it to exit or stop.
+func (e ExternalCmd) run(ec *EvalCtx, path string, args []string) {
+	files := make([]uintptr, len(ec.ports))
+	for i, port := range ec.ports {
+		if port == nil || port.File == nil {
+			files[i] = fdNil
+		} else {
+			files[i] = port.File.Fd()
+		}
+	}
+
+	l := ec.limiter
+	limited := l != nil && l.killsChildren()
+	sys := syscall.SysProcAttr{Setpgid: ec.background || limited}
+	attr := syscall.ProcAttr{Env: ec.environ(), Files: files[:], Sys: &sys}
+
+	pid, err := syscall.ForkExec(path, args, &attr)
+	if err != nil {
+		throw(errors.New("forkExec: " + err.Error()))
+	}
+
+	if limited {
+		l.started(pid)
+	}
+
+	var ws syscall.WaitStatus
+	var usage syscall.Rusage
+	_, err = syscall.Wait4(pid, &ws, syscall.WUNTRACED, &usage)
+
+	if err != nil {
+		throw(fmt.Errorf("wait: %s", err.Error()))
+	}
+	if limited && !ws.Stopped() {
+		l.exited(pid, &usage)
+		// A command killed for going over a limit fails with the limit.
+		l.check()
+	}
+	maybeThrow(NewExternalCmdExit(e.Name, ws, pid))
+}
+
+func fakeWaitStatus(exit int, sig syscall.Signal) syscall.WaitStatus {
+	return syscall.WaitStatus(exit<<8 + int(sig))
+}
@@ -0,0 +1,50 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// run runs the external command at path with the given arguments, and waits
+// for it to exit. There is no fork on Windows; os.StartProcess creates the
+// process with CreateProcess, quoting the arguments into a command line.
+func (e ExternalCmd) run(ec *EvalCtx, path string, args []string) {
+	files := make([]*os.File, len(ec.ports))
+	for i, port := range ec.ports {
+		if port != nil {
+			files[i] = port.File
+		}
+	}
+
+	l := ec.limiter
+	limited := l != nil && l.killsChildren()
+	attr := &os.ProcAttr{Env: ec.environ(), Files: files}
+	if ec.background || limited {
+		attr.Sys = newProcessGroupAttr()
+	}
+
+	p, err := os.StartProcess(path, args, attr)
+	if err != nil {
+		throw(fmt.Errorf("start process: %s", err.Error()))
+	}
+
+	if limited {
+		l.started(p.Pid)
+	}
+
+	state, err := p.Wait()
+	if err != nil {
+		throw(fmt.Errorf("wait: %s", err.Error()))
+	}
+	if limited {
+		l.exited(p.Pid, state.SysUsage().(*syscall.Rusage))
+		// A command killed for going over a limit fails with the limit.
+		l.check()
+	}
+	maybeThrow(NewExternalCmdExit(e.Name, state.Sys().(syscall.WaitStatus), p.Pid))
+}
+
+func fakeWaitStatus(exit int, sig syscall.Signal) syscall.WaitStatus {
+	return syscall.WaitStatus{ExitCode: uint32(exit)}
+}
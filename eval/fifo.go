@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Named pipes. They let background jobs and external commands talk to each
+// other through the filesystem. A typical fan-in, where several jobs write to
+// one reader, looks like this:
+//
+//	f = (mkfifo)
+//	{ w = (fopen &write $f); peach [x]{ work $x > $w } $inputs; fclose $w } |
+//	    cat < $f
+//	rmfifo $f
+//
+// Holding the write end open with fopen keeps the reader from seeing EOF
+// before all writers are done.
+
+// ErrNotFifo is thrown by rmfifo when the path is not a named pipe.
+var ErrNotFifo = errors.New("not a named pipe")
+
+// fifoDirPrefix is the prefix of the temporary directories made by mkfifo.
+const fifoDirPrefix = "elvish-fifo-"
+
+// mkfifo creates a named pipe and outputs its path. Without a path, the pipe is
+// created in a new temporary directory.
+func mkfifo(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var paths []String
+	ScanArgsVariadic(args, &paths)
+	var permOpt String
+	ScanOpts(opts, Opt{"perm", &permOpt, String("0600")})
+	if len(paths) > 1 {
+		throw(ErrArgs)
+	}
+	perm, err := strconv.ParseUint(string(permOpt), 8, 32)
+	if err != nil {
+		throwf("bad permission: %s", permOpt)
+	}
+
+	var path, dir string
+	if len(paths) == 1 {
+		path = string(paths[0])
+		ec.CheckWrite(path)
+	} else {
+		ec.CheckWrite(os.TempDir())
+		dir, err = ioutil.TempDir("", fifoDirPrefix)
+		maybeThrow(err)
+		path = filepath.Join(dir, "fifo")
+	}
+	err = makeFifo(path, uint32(perm))
+	if err != nil && dir != "" {
+		os.Remove(dir)
+	}
+	maybeThrow(err)
+	ec.ports[1].Chan <- String(path)
+}
+
+// rmfifo removes a named pipe, as well as the temporary directory that mkfifo
+// created for it, if any.
+func rmfifo(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var path String
+	ScanArgs(args, &path)
+	TakeNoOpt(opts)
+
+	info, err := os.Lstat(string(path))
+	maybeThrow(err)
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		throwf("%s: %s", ErrNotFifo, path)
+	}
+	ec.CheckWrite(string(path))
+	maybeThrow(os.Remove(string(path)))
+	dir := filepath.Dir(string(path))
+	if strings.HasPrefix(filepath.Base(dir), fifoDirPrefix) {
+		// Fails harmlessly if something else has been put in the directory.
+		os.Remove(dir)
+	}
+}
@@ -0,0 +1,9 @@
+// +build !windows
+
+package eval
+
+import "syscall"
+
+func makeFifo(path string, perm uint32) error {
+	return syscall.Mkfifo(path, perm)
+}
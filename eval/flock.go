@@ -0,0 +1,77 @@
+package eval
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrLocked is thrown by flock when the lock is held by someone else and
+// cannot be acquired without blocking, or within the timeout.
+var ErrLocked = errors.New("file is locked")
+
+// flockPollInterval is how often flock retries when waiting for a lock.
+const flockPollInterval = 10 * time.Millisecond
+
+// flock calls f while holding an advisory lock on the file at path, which is
+// created if it doesn't exist. The lock is exclusive, or shared if &shared is
+// true. If &nonblock is true, flock fails right away when the lock is held by
+// someone else; otherwise it waits, for at most &timeout seconds unless
+// &timeout is 0, or until interrupted. The lock is released when f returns,
+// even if it throws.
+func flock(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		path String
+		f    CallableValue
+	)
+	ScanArgs(args, &path, &f)
+	var (
+		timeout          float64
+		nonblock, shared Bool
+	)
+	ScanOpts(opts, Opt{"timeout", &timeout, String("0")},
+		Opt{"nonblock", &nonblock, Bool(false)},
+		Opt{"shared", &shared, Bool(false)})
+
+	if _, err := os.Stat(string(path)); err != nil {
+		// The file is going to be created.
+		ec.CheckWrite(string(path))
+	}
+	file, err := os.OpenFile(string(path), os.O_RDONLY|os.O_CREATE, defaultFileRedirPerm)
+	maybeThrow(err)
+	defer file.Close()
+
+	if nonblock {
+		err = lockNonblock(file, bool(shared))
+	} else {
+		err = lockWait(ec, file, bool(shared), time.Duration(timeout*float64(time.Second)))
+	}
+	maybeThrow(err)
+	defer unlock(file)
+
+	f.Call(ec, NoArgs, NoOpts)
+}
+
+// lockWait waits for the lock, for at most timeout unless it is 0. Waiting
+// polls instead of blocking, so that it can be interrupted.
+func lockWait(ec *EvalCtx, file *os.File, shared bool, timeout time.Duration) error {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	ticker := time.NewTicker(flockPollInterval)
+	defer ticker.Stop()
+	for {
+		err := lockNonblock(file, shared)
+		if err != ErrLocked {
+			return err
+		}
+		select {
+		case <-ec.Interrupts():
+			ec.throwInterrupted()
+		case <-deadline:
+			return ErrLocked
+		case <-ticker.C:
+		}
+	}
+}
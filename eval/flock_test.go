@@ -0,0 +1,37 @@
+package eval
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestFlockInterrupt(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ports := []*Port{
+		{File: os.Stdin, Chan: ClosedChan},
+		{File: os.Stdout, Chan: BlackholeChan},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	for _, code := range []string{
+		"with-temp-file [t]{ flock $t { flock $t { } } }",
+		"with-temp-file [t]{ flock $t { flock &timeout=10 $t { } } }",
+	} {
+		op := mustParseAndCompile(t, ev, "[test]", code)
+		intCh := make(chan struct{})
+		time.AfterFunc(50*time.Millisecond, func() { close(intCh) })
+		errCh := make(chan error, 1)
+		go func() { errCh <- ev.eval(op, ports, intCh, "[test]", code) }()
+		select {
+		case err := <-errCh:
+			if err == nil || err.(*Exception).Cause != ErrInterrupted {
+				t.Errorf("%s => %v, want %v", code, err, ErrInterrupted)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not interrupted", code)
+		}
+	}
+}
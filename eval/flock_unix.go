@@ -0,0 +1,26 @@
+// +build !windows
+
+package eval
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockNonblock tries to lock file with flock(2), and returns ErrLocked if the
+// lock is held by someone else.
+func lockNonblock(file *os.File, shared bool) error {
+	how := syscall.LOCK_EX
+	if shared {
+		how = syscall.LOCK_SH
+	}
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrLocked
+	}
+	return err
+}
+
+func unlock(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
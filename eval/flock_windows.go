@@ -0,0 +1,47 @@
+package eval
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// Flags of LockFileEx, and the error it returns when the lock is held by
+// someone else.
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// lockNonblock tries to lock the whole of file with LockFileEx, and returns
+// ErrLocked if the lock is held by someone else.
+func lockNonblock(file *os.File, shared bool) error {
+	flags := uintptr(lockfileFailImmediately)
+	if !shared {
+		flags |= lockfileExclusiveLock
+	}
+	var ol syscall.Overlapped
+	r, _, err := procLockFileEx.Call(file.Fd(), flags, 0,
+		^uintptr(0), ^uintptr(0), uintptr(unsafe.Pointer(&ol)))
+	if r != 0 {
+		return nil
+	}
+	if err == errorLockViolation {
+		return ErrLocked
+	}
+	return err
+}
+
+func unlock(file *os.File) {
+	var ol syscall.Overlapped
+	procUnlockFileEx.Call(file.Fd(), 0, ^uintptr(0), ^uintptr(0),
+		uintptr(unsafe.Pointer(&ol)))
+}
@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -16,6 +17,9 @@ type GlobPattern struct {
 	glob.Pattern
 	Flags GlobFlag
 	Buts  []string
+	// Collation is the name of the collation to sort the results with. The
+	// results are not sorted if it is empty.
+	Collation string
 }
 
 type GlobFlag uint
@@ -75,6 +79,9 @@ func (gp GlobPattern) Index(modifiers []Value) []Value {
 			gp.Flags |= NoMatchOK
 		case strings.HasPrefix(modifier, "but:"):
 			gp.Buts = append(gp.Buts, modifier[len("but:"):])
+		case strings.HasPrefix(modifier, "collate:"):
+			gp.Collation = modifier[len("collate:"):]
+			getCollation(gp.Collation)
 		case modifier == "match-hidden":
 			lastSeg := gp.mustGetLastWildSeg()
 			gp.Segments[len(gp.Segments)-1] = glob.Wild{
@@ -196,5 +203,11 @@ func doGlob(gp GlobPattern, abort <-chan struct{}) []Value {
 	if len(vs) == 0 && !gp.Flags.Has(NoMatchOK) {
 		throw(ErrWildcardNoMatch)
 	}
+	if gp.Collation != "" {
+		less := getCollation(gp.Collation)
+		sort.Slice(vs, func(i, j int) bool {
+			return less(string(vs[i].(String)), string(vs[j].(String)))
+		})
+	}
 	return vs
 }
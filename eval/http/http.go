@@ -0,0 +1,148 @@
+// Package http implements the http: module, a minimal HTTP client.
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+func Namespace() eval.Namespace {
+	ns := eval.Namespace{}
+	eval.AddBuiltinFns(ns, fns...)
+	return ns
+}
+
+var fns = []*eval.BuiltinFn{
+	{"get", get},
+	{"post", post},
+	{"request", request},
+}
+
+// Options common to all requests:
+//
+// &headers: a map of request headers;
+// &timeout: the timeout of the whole request in seconds, 0 for none;
+// &json: whether to parse the response body as JSON.
+func scanRequestOpts(opts map[string]eval.Value, extra ...eval.Opt) *requestOpts {
+	ro := &requestOpts{}
+	eval.ScanOpts(opts, append([]eval.Opt{
+		{"headers", &ro.headers, eval.NewMap(map[eval.Value]eval.Value{})},
+		{"timeout", &ro.timeout, eval.String("30")},
+		{"json", &ro.json, eval.Bool(false)},
+	}, extra...)...)
+	return ro
+}
+
+type requestOpts struct {
+	headers eval.Map
+	timeout float64
+	json    eval.Bool
+}
+
+// get sends a GET request to a URL and outputs the response.
+func get(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var url eval.String
+	eval.ScanArgs(args, &url)
+	ro := scanRequestOpts(opts)
+
+	ec.OutputChan() <- do(ec, ro, "GET", string(url), nil)
+}
+
+// post sends a POST request with &body to a URL and outputs the response.
+// The body is read from the byte input if &body is not given.
+func post(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var url eval.String
+	eval.ScanArgs(args, &url)
+	_, hasBody := opts["body"]
+	var body eval.String
+	ro := scanRequestOpts(opts, eval.Opt{"body", &body, eval.String("")})
+
+	var r io.Reader = ec.InputFile()
+	if hasBody {
+		r = strings.NewReader(string(body))
+	}
+	ec.OutputChan() <- do(ec, ro, "POST", string(url), r)
+}
+
+// request sends a request with an arbitrary method.
+func request(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var method, url eval.String
+	eval.ScanArgs(args, &method, &url)
+	var body eval.String
+	ro := scanRequestOpts(opts, eval.Opt{"body", &body, eval.String("")})
+
+	ec.OutputChan() <- do(ec, ro, string(method), string(url),
+		strings.NewReader(string(body)))
+}
+
+// do sends a request and converts the response to a map with the following
+// keys:
+//
+// status: the status code;
+// headers: a map from header names to values, multiple values joined by ", ";
+// body: the body, as a string, or the value it encodes if &json is true.
+//
+// Responses with non-2xx status codes are not errors. The request is canceled
+// when the user interrupts.
+func do(ec *eval.EvalCtx, ro *requestOpts, method, url string, body io.Reader) eval.Value {
+	req, err := http.NewRequest(method, url, body)
+	maybeThrow(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ec.Interrupts():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	req = req.WithContext(ctx)
+	ro.headers.IterateKey(func(k eval.Value) bool {
+		req.Header.Set(eval.ToString(k), eval.ToString(ro.headers.IndexOne(k)))
+		return true
+	})
+
+	client := &http.Client{Timeout: time.Duration(ro.timeout * float64(time.Second))}
+	resp, err := client.Do(req)
+	if err != nil && ctx.Err() != nil {
+		util.Throw(eval.ErrInterrupted)
+	}
+	maybeThrow(err)
+	defer resp.Body.Close()
+
+	var bodyValue eval.Value
+	if ro.json {
+		var v interface{}
+		maybeThrow(json.NewDecoder(resp.Body).Decode(&v))
+		bodyValue = eval.FromJSONInterface(v)
+	} else {
+		bs, err := ioutil.ReadAll(resp.Body)
+		maybeThrow(err)
+		bodyValue = eval.String(bs)
+	}
+
+	headers := make(map[eval.Value]eval.Value, len(resp.Header))
+	for k, vs := range resp.Header {
+		headers[eval.String(k)] = eval.String(strings.Join(vs, ", "))
+	}
+	return eval.NewMap(map[eval.Value]eval.Value{
+		eval.String("status"):  eval.String(strconv.Itoa(resp.StatusCode)),
+		eval.String("headers"): eval.NewMap(headers),
+		eval.String("body"):    bodyValue,
+	})
+}
+
+func maybeThrow(err error) {
+	if err != nil {
+		util.Throw(err)
+	}
+}
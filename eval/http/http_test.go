@@ -0,0 +1,63 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/eval/evaltest"
+)
+
+func evalOutputs(t *testing.T, src string) []eval.Value {
+	return evaltest.EvalOutputs(t, "http", Namespace(), src)
+}
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Token", r.Header.Get("Token"))
+			switch r.URL.Path {
+			case "/json":
+				w.Write([]byte(`{"a": [1, "b"]}`))
+			case "/missing":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.Write(body)
+			}
+		}))
+	defer server.Close()
+	url := server.URL
+
+	for _, test := range []struct {
+		src  string
+		want []string
+	}{
+		{"r = (http:get " + url + "/json); put $r[status] $r[body]",
+			[]string{"200", `{"a": [1, "b"]}`}},
+		{"r = (http:get &json " + url + "/json); put $r[body][a][1]",
+			[]string{"b"}},
+		{"r = (http:get " + url + "/missing); put $r[status]",
+			[]string{"404"}},
+		{"r = (http:get &headers=[&Token=secret] " + url + "); " +
+			"put $r[headers][X-Token]", []string{"secret"}},
+		{"r = (http:post &body=data " + url + "); " +
+			"put $r[headers][X-Method] $r[body]", []string{"POST", "data"}},
+		{"r = (print piped | http:post " + url + "); put $r[body]",
+			[]string{"piped"}},
+		{"r = (http:request PUT &body=x " + url + "); " +
+			"put $r[headers][X-Method] $r[body]", []string{"PUT", "x"}},
+	} {
+		want := make([]eval.Value, len(test.want))
+		for i, s := range test.want {
+			want[i] = eval.String(s)
+		}
+		if outs := evalOutputs(t, test.src); !reflect.DeepEqual(outs, want) {
+			t.Errorf("%s outputs %v, want %v", test.src, outs, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
+)
+
+// implicitCall handles commands whose head names neither a function nor an
+// external command, but a path: a directory is changed to when the autocd
+// option is on and the command is standalone, and a file is opened with the
+// opener for its extension in the suffix-aliases option. It reports whether
+// the command has been handled.
+func (ec *EvalCtx) implicitCall(head Value, args []Value, opts map[string]Value, standalone bool) bool {
+	name, ok := head.(String)
+	if !ok {
+		return false
+	}
+	if dir, ok := ec.autocdTarget(string(name), args, opts, standalone); ok {
+		cdInner(dir, ec)
+		return true
+	}
+	if opener, ok := ec.suffixOpener(string(name)); ok {
+		opener.Call(ec, append([]Value{name}, args...), opts)
+		return true
+	}
+	return false
+}
+
+// isStandalone returns whether a form is a pipeline of its own at the top level
+// of the source, as opposed to a stage of a longer pipeline, a background job
+// or a form in a lambda or output capture.
+func isStandalone(n *parse.Form) bool {
+	pn, ok := n.Parent().(*parse.Pipeline)
+	if !ok || len(pn.Forms) != 1 || pn.Background {
+		return false
+	}
+	chunk, ok := pn.Parent().(*parse.Chunk)
+	return ok && chunk.Parent() == nil
+}
+
+// isCommand returns whether name resolves to a function, or to an external
+// command that can be found.
+func (ec *EvalCtx) isCommand(name string) bool {
+	if _, ok := resolve(name, ec).(ExternalCmd); !ok {
+		return true
+	}
+	if util.DontSearch(name) {
+		return false
+	}
+	_, err := ec.Search(name)
+	return err == nil
+}
+
+// autocdTarget returns the directory to change to when the autocd option is
+// on and a standalone command consists of just the path of a directory, or "-"
+// for the previous directory.
+func (ec *EvalCtx) autocdTarget(name string, args []Value, opts map[string]Value, standalone bool) (string, bool) {
+	if !standalone || len(args) > 0 || len(opts) > 0 || !ec.option("autocd") {
+		return "", false
+	}
+	// "-" alone is otherwise an error, since the builtin - needs arguments.
+	if name == "-" {
+		return os.LookupEnv("OLDPWD")
+	}
+	if ec.isCommand(name) {
+		return "", false
+	}
+	info, err := os.Stat(name)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return name, true
+}
+
+// suffixOpener returns the opener for a command that is the path of a file
+// whose extension, without the dot, is a key of the suffix-aliases option.
+func (ec *EvalCtx) suffixOpener(name string) (Callable, bool) {
+	// Most commands have no extension, so check that before looking up the
+	// option.
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return nil, false
+	}
+	aliases, ok := ec.getOption("suffix-aliases").(Map)
+	if !ok || !aliases.HasKey(String(ext[1:])) || ec.isCommand(name) {
+		return nil, false
+	}
+	info, err := os.Stat(name)
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+	return mustFn(aliases.IndexOne(String(ext[1:]))), true
+}
@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestImplicitCall(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tmpdir, _ = filepath.EvalSymlinks(tmpdir)
+	subdir := filepath.Join(tmpdir, "sub")
+	os.Mkdir(subdir, 0755)
+
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+	os.Chdir(tmpdir)
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ports := []*Port{
+		{File: os.Stdin, Chan: ClosedChan},
+		{File: os.Stdout, Chan: BlackholeChan},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+	run := func(src string) error {
+		return ev.SourceTextWithPorts(ports, "[test]", src)
+	}
+	pwd := func() string {
+		dir, _ := os.Getwd()
+		return dir
+	}
+
+	// Off by default.
+	if err := run("sub"); err == nil {
+		t.Errorf("running a directory without autocd succeeded")
+	}
+	if pwd() != tmpdir {
+		t.Errorf("running a directory without autocd changed to %s", pwd())
+	}
+
+	if err := run("autocd = $true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("sub"); err != nil || pwd() != subdir {
+		t.Errorf("autocd sub => %v, pwd %s; want nil, pwd %s", err, pwd(), subdir)
+	}
+	if err := run(".."); err != nil || pwd() != tmpdir {
+		t.Errorf("autocd .. => %v, pwd %s; want nil, pwd %s", err, pwd(), tmpdir)
+	}
+	if err := run("-"); err != nil || pwd() != subdir {
+		t.Errorf("autocd - => %v, pwd %s; want nil, pwd %s", err, pwd(), subdir)
+	}
+	// Only when the directory is the whole command.
+	if err := run(".. x"); err == nil || pwd() != subdir {
+		t.Errorf("autocd with argument => %v, pwd %s; want error, pwd %s", err, pwd(), subdir)
+	}
+	// Only for standalone commands at the top level.
+	os.Chdir(tmpdir)
+	for _, src := range []string{"put x | sub", "sub | put x", "{ sub }", "put (sub)", "sub &"} {
+		if err := run(src); pwd() != tmpdir {
+			t.Errorf("autocd in %q => %v, pwd %s; want pwd %s", src, err, pwd(), tmpdir)
+		}
+	}
+	os.Chdir(subdir)
+	// Functions take precedence.
+	if err := run("fn .. { }; .."); err != nil || pwd() != subdir {
+		t.Errorf("autocd with function => %v, pwd %s; want nil, pwd %s", err, pwd(), subdir)
+	}
+
+	// Suffix aliases.
+	ioutil.WriteFile("report.pdf", nil, 0644)
+	if err := run("report.pdf"); err == nil {
+		t.Errorf("running a file without a suffix alias succeeded")
+	}
+	if err := run("opened = []; suffix-aliases = [&pdf=[@a]{ opened = $a }]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("report.pdf x; if (not (eq $opened [report.pdf x])) { fail bad }"); err != nil {
+		t.Errorf("suffix alias => %v, want nil", err)
+	}
+	if err := run("nonexistent.pdf"); err == nil {
+		t.Errorf("suffix alias on a nonexistent file succeeded")
+	}
+}
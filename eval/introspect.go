@@ -0,0 +1,115 @@
+package eval
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Introspection builtins. They look at the scope of the caller, which builtin
+// functions share.
+
+// scopeVariables returns the local and upvalue variables visible in ec, with
+// local ones shadowing upvalues.
+func (ec *EvalCtx) scopeVariables() map[string]Variable {
+	variables := make(map[string]Variable, len(ec.local)+len(ec.up))
+	for name, variable := range ec.up {
+		variables[name] = variable
+	}
+	for name, variable := range ec.local {
+		variables[name] = variable
+	}
+	return variables
+}
+
+func sortedNames(variables map[string]Variable, fn bool) []string {
+	var names []string
+	for name := range variables {
+		if strings.HasPrefix(name, FnPrefix) == fn {
+			names = append(names, strings.TrimPrefix(name, FnPrefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// vars outputs a map from the names of the variables in the current scope to
+// their values. Functions are not included.
+func vars(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	TakeNoOpt(opts)
+
+	m := make(map[Value]Value)
+	for name, variable := range ec.scopeVariables() {
+		if !strings.HasPrefix(name, FnPrefix) {
+			m[String(name)] = variable.Get()
+		}
+	}
+	ec.ports[1].Chan <- NewMap(m)
+}
+
+// fns outputs a map for each function defined in the current scope, with its
+// name, and for closures, the names of its parameters and rest parameter.
+func fns(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	TakeNoOpt(opts)
+
+	variables := ec.scopeVariables()
+	out := ec.ports[1].Chan
+	for _, name := range sortedNames(variables, true) {
+		m := map[Value]Value{String("name"): String(name)}
+		if c, ok := variables[FnPrefix+name].Get().(*Closure); ok {
+			params := make([]Value, len(c.ArgNames))
+			for i, arg := range c.ArgNames {
+				params[i] = String(arg)
+			}
+			m[String("params")] = NewList(params...)
+			m[String("rest")] = String(c.RestArg)
+		}
+		out <- NewMap(m)
+	}
+}
+
+// builtins outputs the names of all builtin functions.
+func builtins(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	TakeNoOpt(opts)
+
+	out := ec.ports[1].Chan
+	for _, name := range sortedNames(ec.Builtin, true) {
+		out <- String(name)
+	}
+}
+
+// ErrNotClosure is thrown by fn-source and fn-location when the function is
+// not defined in elvish script.
+var ErrNotClosure = errors.New("not a function defined in elvish script")
+
+// scanClosure scans a closure argument, which may also be given as the name
+// of a function.
+func scanClosure(ec *EvalCtx, args []Value) *Closure {
+	var f Value
+	ScanArgs(args, &f)
+	if name, ok := f.(String); ok {
+		f = resolve(string(name), ec)
+	}
+	c, ok := f.(*Closure)
+	if !ok {
+		throw(ErrNotClosure)
+	}
+	return c
+}
+
+// fnSource prints the definition of a function.
+func fnSource(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	c := scanClosure(ec, args)
+	ec.ports[1].File.WriteString(c.Definition() + "\n")
+}
+
+// fnLocation prints where a function is defined.
+func fnLocation(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	c := scanClosure(ec, args)
+	ec.ports[1].File.WriteString(c.Location() + "\n")
+}
@@ -0,0 +1,102 @@
+// +build !windows
+
+package eval
+
+import (
+	"fmt"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/elves/elvish/sys"
+)
+
+// Job control, which is only supported on Unix.
+
+func fg(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var pids []int
+	ScanArgsVariadic(args, &pids)
+	TakeNoOpt(opts)
+
+	if len(pids) == 0 {
+		throw(ErrArgs)
+	}
+	var thepgid int
+	for i, pid := range pids {
+		pgid, err := syscall.Getpgid(pid)
+		maybeThrow(err)
+		if i == 0 {
+			thepgid = pgid
+		} else if pgid != thepgid {
+			throw(ErrNotInSameGroup)
+		}
+	}
+
+	err := sys.Tcsetpgrp(0, thepgid)
+	maybeThrow(err)
+
+	errors := make([]*Exception, len(pids))
+
+	for i, pid := range pids {
+		err := syscall.Kill(pid, syscall.SIGCONT)
+		if err != nil {
+			errors[i] = &Exception{err, nil}
+		}
+	}
+
+	for i, pid := range pids {
+		if errors[i] != nil {
+			continue
+		}
+		var ws syscall.WaitStatus
+		_, err = syscall.Wait4(pid, &ws, syscall.WUNTRACED, nil)
+		if err != nil {
+			errors[i] = &Exception{err, nil}
+		} else {
+			// TODO find command name
+			errors[i] = &Exception{NewExternalCmdExit(fmt.Sprintf("(pid %d)", pid), ws, pid), nil}
+		}
+	}
+
+	maybeThrow(ComposeExceptionsFromPipeline(errors))
+}
+
+// putSelfInForeground puts elvish in the foreground, in case some command has
+// put it in the background.
+func putSelfInForeground() {
+	// XXX Should probably use fd of /dev/tty instead of 0.
+	if sys.IsATTY(0) {
+		err := sys.Tcsetpgrp(0, syscall.Getpgrp())
+		if err != nil {
+			fmt.Println("failed to put myself in foreground:", err)
+		}
+	}
+}
+
+// ttouIgnorers counts the evaluations that need SIGTTOU to be ignored. Signal
+// dispositions belong to the process, so they are shared by concurrent
+// evaluations, including those of different Evalers.
+var ttouIgnorers struct {
+	sync.Mutex
+	n int
+}
+
+func ignoreTTOU() {
+	ttouIgnorers.Lock()
+	defer ttouIgnorers.Unlock()
+	if ttouIgnorers.n == 0 {
+		signal.Ignore(syscall.SIGTTOU)
+	}
+	ttouIgnorers.n++
+}
+
+// unignoreTTOU restores the default handling of SIGTTOU when the last
+// evaluation that needs it ignored is done.
+func unignoreTTOU() {
+	ttouIgnorers.Lock()
+	defer ttouIgnorers.Unlock()
+	ttouIgnorers.n--
+	if ttouIgnorers.n == 0 {
+		signal.Reset(syscall.SIGTTOU)
+	}
+}
@@ -0,0 +1,18 @@
+package eval
+
+import "errors"
+
+// Job control, which is only supported on Unix.
+
+// ErrNoJobControl is thrown by fg on Windows.
+var ErrNoJobControl = errors.New("job control is not supported on Windows")
+
+func fg(ec *EvalCtx, args []Value, opts map[string]Value) {
+	throw(ErrNoJobControl)
+}
+
+func putSelfInForeground() {}
+
+func ignoreTTOU() {}
+
+func unignoreTTOU() {}
@@ -0,0 +1,383 @@
+package eval
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Errors thrown when an evaluation goes over one of its Limits.
+var (
+	ErrCPULimit    = errors.New("CPU time limit exceeded")
+	ErrValueLimit  = errors.New("value limit exceeded")
+	ErrOutputLimit = errors.New("output limit exceeded")
+)
+
+// Limits caps the resources that each evaluation of an Evaler can use, so that
+// elvish can run code that may not terminate, for instance as a scripting
+// engine in a server. An evaluation that goes over a limit is aborted with an
+// exception. Zero fields impose no limit.
+type Limits struct {
+	// The CPU time that the evaluation can use. The external commands
+	// started by the evaluation are charged the CPU time they use, while they
+	// run and after they exit. The evaluation itself is charged the
+	// wall-clock time during which none of its external commands is running;
+	// this includes builtins that wait, like sleep. Once the limit is
+	// reached, the external commands are killed, the evaluation is
+	// interrupted and every further operation throws, so the exception can't
+	// be caught to keep running.
+	CPUTime time.Duration
+	// The number of values that expressions in the evaluation can produce.
+	// Each element of a list or map counts as a value. Values are counted
+	// when they are produced and are never uncounted, so this is a budget on
+	// the work done rather than a cap on memory: a loop that keeps
+	// reassigning one variable runs out of it eventually. Values that
+	// builtins send through pipes, like those of "range 10 | count", are not
+	// produced by expressions and are not counted.
+	Values int64
+	// The number of bytes that the evaluation can write to its output and
+	// error output combined. A value output counts as the length of its
+	// representation plus one. Output beyond the limit is discarded, and the
+	// external commands are killed; those that keep writing to the output get
+	// SIGPIPE.
+	Output int64
+}
+
+// When there is a CPU time or output limit, external commands are run in
+// process groups of their own, so that they can be killed along with their
+// children. This means that they can't use the terminal. On Windows, only the
+// commands themselves are killed.
+
+// cpuCheckInterval is how often the CPU time is checked.
+const cpuCheckInterval = 10 * time.Millisecond
+
+// limiter tracks the resources used by an evaluation. The counters are
+// accessed atomically.
+type limiter struct {
+	limits Limits
+	values int64
+	output int64
+	// Set to 1 when the CPU time goes over the limit.
+	cpuExceeded int32
+	// Set to 1 when the output goes over the limit.
+	outputExceeded int32
+
+	// Protects the fields below.
+	mutex sync.Mutex
+	// The process groups of the external commands that are running, with the
+	// time they started.
+	children map[int]time.Time
+	// The CPU time of the external commands that have exited.
+	childrenCPU time.Duration
+	// The time charged to the evaluation itself.
+	ownTime time.Duration
+}
+
+func newLimiter(limits Limits) *limiter {
+	return &limiter{limits: limits, children: map[int]time.Time{}}
+}
+
+// killsChildren returns whether external commands need to be killed when a
+// limit is reached, and thus run in process groups of their own.
+func (l *limiter) killsChildren() bool {
+	return l.limits.CPUTime > 0 || l.limits.Output > 0
+}
+
+func (l *limiter) exceeded() bool {
+	return atomic.LoadInt32(&l.cpuExceeded) != 0 ||
+		atomic.LoadInt32(&l.outputExceeded) != 0
+}
+
+// started records that an external command has been started in the process
+// group pid. It is killed right away if a limit has been reached.
+func (l *limiter) started(pid int) {
+	l.mutex.Lock()
+	l.children[pid] = time.Now()
+	l.mutex.Unlock()
+	if l.exceeded() {
+		killGroup(pid)
+	}
+}
+
+// exited records that an external command has exited, along with its resource
+// usage.
+func (l *limiter) exited(pid int, usage *syscall.Rusage) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.children, pid)
+	l.childrenCPU += rusageCPU(usage)
+}
+
+// killChildren kills the process groups of the external commands that are
+// running. It is called after a limit has been reached.
+func (l *limiter) killChildren() {
+	if !l.killsChildren() {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for pid := range l.children {
+		killGroup(pid)
+	}
+}
+
+// clockTicks is the unit of CPU times in /proc/[pid]/stat. It is 100 on all
+// the platforms Linux supports.
+const clockTicks = 100
+
+// groupCPU returns the CPU time used so far by the processes in the process
+// group pgid, including their children that have been waited for. It is read
+// from /proc where available; otherwise the wall-clock time since the group
+// started is used as an estimate.
+func groupCPU(pgid int, start time.Time) time.Duration {
+	names, err := readDirNames("/proc")
+	if err != nil {
+		return time.Since(start)
+	}
+	var ticks int64
+	for _, name := range names {
+		stat, err := ioutil.ReadFile("/proc/" + name + "/stat")
+		if err != nil {
+			continue
+		}
+		// The command name, in parentheses, may contain spaces. After it
+		// come the state, ppid, pgrp and so on; utime, stime, cutime and
+		// cstime are the 12th to 15th fields.
+		fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+		if len(fields) < 15 || fields[2] != strconv.Itoa(pgid) {
+			continue
+		}
+		for _, field := range fields[11:15] {
+			n, _ := strconv.ParseInt(field, 10, 64)
+			ticks += n
+		}
+	}
+	return time.Duration(ticks) * time.Second / clockTicks
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
+// tick charges elapsed to the evaluation itself if none of its external
+// commands is running, and returns the CPU time used so far.
+func (l *limiter) tick(elapsed time.Duration) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.children) == 0 {
+		l.ownTime += elapsed
+	}
+	used := l.ownTime + l.childrenCPU
+	for pid, start := range l.children {
+		used += groupCPU(pid, start)
+	}
+	return used
+}
+
+// check throws if the evaluation has gone over its CPU time or output limit.
+// It is called before every operation.
+func (l *limiter) check() {
+	if atomic.LoadInt32(&l.cpuExceeded) != 0 {
+		throw(ErrCPULimit)
+	}
+	if atomic.LoadInt32(&l.outputExceeded) != 0 {
+		throw(ErrOutputLimit)
+	}
+}
+
+// watchCPU checks the CPU time every cpuCheckInterval until done is closed.
+// When it goes over the limit, the external commands are killed and intCh is
+// closed to interrupt the builtins that are running. User interrupts,
+// signaled by closing userIntCh, are forwarded to intCh.
+func (l *limiter) watchCPU(userIntCh <-chan struct{}, intCh chan<- struct{}, done <-chan struct{}) {
+	ticker := time.NewTicker(cpuCheckInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			used := l.tick(now.Sub(last))
+			last = now
+			if used > l.limits.CPUTime {
+				atomic.StoreInt32(&l.cpuExceeded, 1)
+				l.killChildren()
+				close(intCh)
+				return
+			}
+		case <-userIntCh:
+			close(intCh)
+			// Keep enforcing the limit, which also applies to the cleanup
+			// that follows an interrupt.
+			userIntCh = nil
+		case <-done:
+			return
+		}
+	}
+}
+
+// count counts the values created by an operation, and throws if the
+// evaluation has gone over its value limit.
+func (l *limiter) count(vs []Value) {
+	if l.limits.Values <= 0 {
+		return
+	}
+	n := int64(len(vs))
+	for _, v := range vs {
+		switch v := v.(type) {
+		case List:
+			n += int64(v.Len())
+		case Map:
+			n += int64(v.Len())
+		}
+	}
+	if atomic.AddInt64(&l.values, n) > l.limits.Values {
+		throw(ErrValueLimit)
+	}
+}
+
+// addOutput counts n bytes of output, and returns how many of them are within
+// the limit. The external commands are killed when the limit is first
+// exceeded.
+func (l *limiter) addOutput(n int64) int64 {
+	total := atomic.AddInt64(&l.output, n)
+	if total <= l.limits.Output {
+		return n
+	}
+	if atomic.CompareAndSwapInt32(&l.outputExceeded, 0, 1) {
+		l.killChildren()
+	}
+	if within := l.limits.Output - (total - n); within > 0 {
+		return within
+	}
+	return 0
+}
+
+// limitEvalCtx sets up ec to be limited. When there is a CPU time limit, the
+// interrupt channel of ec is replaced by one that is also closed when the
+// limit is reached. When there is an output limit, the output port is
+// replaced by one that counts what goes through it. The returned function
+// must be called when the evaluation is done; it returns ErrCPULimit or
+// ErrOutputLimit if the evaluation has gone over the limit.
+func limitEvalCtx(ec *EvalCtx, limits Limits) (func() error, error) {
+	l := newLimiter(limits)
+	ec.limiter = l
+	stopWatchingCPU := func() {}
+	if limits.CPUTime > 0 {
+		intCh := make(chan struct{})
+		done := make(chan struct{})
+		go l.watchCPU(ec.intCh, intCh, done)
+		ec.intCh = intCh
+		stopWatchingCPU = func() { close(done) }
+	}
+	exceeded := func() error {
+		stopWatchingCPU()
+		switch {
+		case atomic.LoadInt32(&l.cpuExceeded) != 0:
+			return &Exception{ErrCPULimit, nil}
+		case atomic.LoadInt32(&l.outputExceeded) != 0:
+			return &Exception{ErrOutputLimit, nil}
+		}
+		return nil
+	}
+	if limits.Output <= 0 {
+		return exceeded, nil
+	}
+
+	ports := make([]*Port, len(ec.ports))
+	copy(ports, ec.ports)
+	var closers []func()
+	closeAll := func() {
+		for _, closePort := range closers {
+			closePort()
+		}
+	}
+	for i := 1; i <= 2 && i < len(ports); i++ {
+		if ports[i] == nil {
+			continue
+		}
+		port, closePort, err := l.limitPort(ports[i])
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		ports[i] = port
+		closers = append(closers, closePort)
+	}
+	ec.ports = ports
+
+	return func() error {
+		closeAll()
+		return exceeded()
+	}, nil
+}
+
+// limitPort returns a port that forwards to out what is written to it, as
+// long as the output is within the limit. Once the limit is exceeded, the
+// file of the port stops being read from and is closed, so that external
+// commands writing to it get SIGPIPE. The returned function closes the port
+// and waits until everything written to it has been forwarded.
+func (l *limiter) limitPort(out *Port) (*Port, func(), error) {
+	newOut := &Port{Chan: make(chan Value, outChanSize), ReaderGone: out.ReaderGone}
+	var wg sync.WaitGroup
+	var r *os.File
+	if out.File != nil {
+		var w *os.File
+		var err error
+		r, w, err = os.Pipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		newOut.File = w
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 4096)
+			for {
+				n, err := r.Read(buf)
+				if n > 0 {
+					within := l.addOutput(int64(n))
+					out.File.Write(buf[:within])
+					if within < int64(n) {
+						r.Close()
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := range newOut.Chan {
+			if l.addOutput(int64(len(v.Repr(NoPretty))+1)) > 0 && out.Chan != nil {
+				out.Chan <- v
+			}
+		}
+	}()
+
+	return newOut, func() {
+		if newOut.File != nil {
+			newOut.File.Close()
+		}
+		close(newOut.Chan)
+		wg.Wait()
+		if r != nil {
+			r.Close()
+		}
+	}, nil
+}
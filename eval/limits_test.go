@@ -0,0 +1,83 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestLimits(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+
+	outFile, err := ioutil.TempFile("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(outFile.Name())
+	defer outFile.Close()
+
+	for _, test := range []struct {
+		limits  Limits
+		code    string
+		wantErr error
+	}{
+		{Limits{CPUTime: 50 * time.Millisecond}, "put x", nil},
+		{Limits{CPUTime: 50 * time.Millisecond}, "while $true { }", ErrCPULimit},
+		{Limits{CPUTime: 50 * time.Millisecond},
+			"while $true { try { while $true { } } except _ { } }", ErrCPULimit},
+		{Limits{CPUTime: 50 * time.Millisecond}, "range 300000000 | count", ErrCPULimit},
+		{Limits{CPUTime: 50 * time.Millisecond}, "repeat 300000000 x | each [_]{ }", ErrCPULimit},
+		{Limits{Values: 10}, "put a b c", nil},
+		{Limits{Values: 10}, "x = [(range 20)]", ErrValueLimit},
+		{Limits{Values: 1000}, "while $true { x = a }", ErrValueLimit},
+		{Limits{Output: 10}, "echo abc; put abc", nil},
+		{Limits{Output: 10}, "echo 0123456789", ErrOutputLimit},
+		{Limits{Output: 10}, "while $true { echo a }", ErrOutputLimit},
+		{Limits{Output: 10}, "range 20", ErrOutputLimit},
+		{Limits{Output: 10}, "echo 0123456789 >&2", ErrOutputLimit},
+		{Limits{Output: 10}, "echo abc; echo abc >&2", nil},
+		// External commands are charged their own CPU time, and killed when
+		// they go over a limit.
+		{Limits{CPUTime: 50 * time.Millisecond}, "e:sleep 0.2", nil},
+		{Limits{CPUTime: 200 * time.Millisecond},
+			"e:sh -c 'while :; do :; done'", ErrCPULimit},
+		{Limits{CPUTime: 200 * time.Millisecond},
+			"run sh -c 'while :; do :; done'", ErrCPULimit},
+		{Limits{CPUTime: 200 * time.Millisecond},
+			`e:sh -c 'sh -c "while :; do :; done"; echo x'`, ErrCPULimit},
+		{Limits{Output: 10}, "e:yes", ErrOutputLimit},
+		{Limits{Output: 10}, "e:yes | e:cat", ErrOutputLimit},
+	} {
+		ev.Limits = &test.limits
+		outFile.Truncate(0)
+		outFile.Seek(0, 0)
+		ports := []*Port{
+			{File: os.Stdin, Chan: ClosedChan},
+			{File: outFile, Chan: BlackholeChan},
+			{File: outFile, Chan: BlackholeChan},
+		}
+		err := ev.SourceTextWithPorts(ports, "[test]", test.code)
+		var cause error
+		if err != nil {
+			cause = err.(*Exception).Cause
+			// All the forms of a pipeline go over the same limit.
+			if pe, ok := cause.(PipelineError); ok {
+				cause = pe.Errors[0].Cause
+			}
+		}
+		if cause != test.wantErr {
+			t.Errorf("%s with %v => %v, want %v", test.code, test.limits, err, test.wantErr)
+		}
+		if test.limits.Output > 0 {
+			info, _ := outFile.Stat()
+			if info.Size() > test.limits.Output {
+				t.Errorf("%s wrote %d bytes, over the limit of %d",
+					test.code, info.Size(), test.limits.Output)
+			}
+		}
+	}
+}
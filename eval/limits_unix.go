@@ -0,0 +1,24 @@
+// +build !windows
+
+package eval
+
+import (
+	"syscall"
+	"time"
+)
+
+// newProcessGroupAttr returns the attributes of a process that is put in a
+// process group of its own.
+func newProcessGroupAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killGroup kills the process group pgid.
+func killGroup(pgid int) {
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// rusageCPU returns the CPU time in a resource usage.
+func rusageCPU(usage *syscall.Rusage) time.Duration {
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
@@ -0,0 +1,33 @@
+package eval
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// newProcessGroupAttr returns the attributes of a process that is put in a
+// process group of its own.
+func newProcessGroupAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// killGroup kills the process pgid. Windows has no way to kill the process
+// group as a whole, so the children of the process are left running.
+func killGroup(pgid int) {
+	if p, err := os.FindProcess(pgid); err == nil {
+		p.Kill()
+	}
+}
+
+// rusageCPU returns the CPU time in a resource usage.
+func rusageCPU(usage *syscall.Rusage) time.Duration {
+	return filetimeDuration(usage.KernelTime) + filetimeDuration(usage.UserTime)
+}
+
+// filetimeDuration converts a Filetime that holds a duration, in units of
+// 100 nanoseconds, to a time.Duration. Filetime.Nanoseconds can't be used, as
+// it treats the Filetime as a point in time.
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(int64(ft.HighDateTime)<<32+int64(ft.LowDateTime)) * 100
+}
@@ -28,7 +28,10 @@ type List struct {
 	inner vector.Vector
 }
 
-var _ ListLike = List{}
+var (
+	_ ListLike = List{}
+	_ Assocer  = List{}
+)
 
 // NewList creates a new List.
 func NewList(vs ...Value) List {
@@ -77,6 +80,47 @@ func (l List) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func (l List) Equal(other Value) bool {
+	l2, ok := other.(List)
+	if !ok || l.Len() != l2.Len() {
+		return false
+	}
+	for i, j := l.inner.Iterator(), l2.inner.Iterator(); i.HasElem(); i.Next() {
+		if !Eq(i.Elem().(Value), j.Elem().(Value)) {
+			return false
+		}
+		j.Next()
+	}
+	return true
+}
+
+func (l List) Hash() uint32 {
+	var h uint32 = 1
+	for it := l.inner.Iterator(); it.HasElem(); it.Next() {
+		h = h*31 + Hash(it.Elem().(Value))
+	}
+	return h
+}
+
+// Less compares two lists lexicographically.
+func (l List) Less(other Value) bool {
+	l2, ok := other.(List)
+	if !ok {
+		return l.Repr(NoPretty) < other.Repr(NoPretty)
+	}
+	i, j := l.inner.Iterator(), l2.inner.Iterator()
+	for ; i.HasElem() && j.HasElem(); i.Next() {
+		a, b := i.Elem().(Value), j.Elem().(Value)
+		if Less(a, b) {
+			return true
+		} else if Less(b, a) {
+			return false
+		}
+		j.Next()
+	}
+	return !i.HasElem() && j.HasElem()
+}
+
 func (l List) Len() int {
 	return l.inner.Len()
 }
@@ -98,6 +142,15 @@ func (l List) IndexOne(idx Value) Value {
 	return l.inner.Nth(i).(Value)
 }
 
+// Assoc returns a copy of the list with the element at idx replaced by v.
+func (l List) Assoc(idx Value, v Value) Value {
+	slice, i, _ := ParseAndFixListIndex(ToString(idx), l.Len())
+	if slice {
+		throw(errors.New("cannot assign to a slice"))
+	}
+	return List{l.inner.AssocN(i, v)}
+}
+
 // ParseAndFixListIndex parses a list index and returns whether the index is a
 // slice and "real" (-1 becomes n-1) indicies. It throws errors when the index
 // is invalid or out of range.
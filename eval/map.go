@@ -3,12 +3,16 @@ package eval
 import (
 	"encoding/json"
 	"errors"
+	"sort"
 	"strings"
+
+	"github.com/xiaq/persistent/hashmap"
 )
 
-// Map is a map from string to Value.
+// Map is a persistent map from Value to Value. It is immutable; Assoc returns
+// a modified copy that shares most of its structure with the original.
 type Map struct {
-	inner *map[Value]Value
+	inner hashmap.HashMap
 }
 
 type HasKeyer interface {
@@ -22,11 +26,32 @@ type MapLike interface {
 	IterateKeyer
 }
 
-var _ MapLike = Map{}
+var (
+	_ MapLike = Map{}
+	_ Assocer = Map{}
+)
+
+// mapKey adapts a Value to hashmap.Key, using Hash and Eq.
+type mapKey struct {
+	Value
+}
+
+func (k mapKey) Hash() uint32 {
+	return Hash(k.Value)
+}
+
+func (k mapKey) Equal(other interface{}) bool {
+	k2, ok := other.(mapKey)
+	return ok && Eq(k.Value, k2.Value)
+}
 
-// NewMap creates a new Map.
+// NewMap creates a new Map from a Go map.
 func NewMap(inner map[Value]Value) Map {
-	return Map{&inner}
+	m := hashmap.Empty
+	for k, v := range inner {
+		m = m.Assoc(mapKey{k}, v)
+	}
+	return Map{m}
 }
 
 func (Map) Kind() string {
@@ -36,36 +61,47 @@ func (Map) Kind() string {
 func (m Map) MarshalJSON() ([]byte, error) {
 	// XXX Not the most efficient way.
 	mm := map[string]Value{}
-	for k, v := range *m.inner {
-		mm[ToString(k)] = v
+	for it := m.inner.Iterator(); it.HasElem(); it.Next() {
+		k, v := it.Elem()
+		mm[ToString(k.(mapKey).Value)] = v.(Value)
 	}
 	return json.Marshal(mm)
 }
 
+// Repr writes the pairs of the map, sorted by their keys.
 func (m Map) Repr(indent int) string {
 	var builder MapReprBuilder
 	builder.Indent = indent
-	for k, v := range *m.inner {
-		builder.WritePair(k.Repr(indent+1), indent+2, v.Repr(indent+2))
+	for _, k := range sortedKeys(m) {
+		builder.WritePair(k.Repr(indent+1), indent+2, m.IndexOne(k).Repr(indent+2))
 	}
 	return builder.String()
 }
 
+func (m Map) Equal(other Value) bool {
+	return mapLikeEqual(m, other)
+}
+
+func (m Map) Hash() uint32 {
+	return mapLikeHash(m)
+}
+
 func (m Map) Len() int {
-	return len(*m.inner)
+	return m.inner.Len()
 }
 
 func (m Map) IndexOne(idx Value) Value {
-	v, ok := (*m.inner)[idx]
+	ok, v := m.inner.Get(mapKey{idx})
 	if !ok {
 		throw(errors.New("no such key: " + idx.Repr(NoPretty)))
 	}
-	return v
+	return v.(Value)
 }
 
 func (m Map) IterateKey(f func(Value) bool) {
-	for k := range *m.inner {
-		cont := f(k)
+	for it := m.inner.Iterator(); it.HasElem(); it.Next() {
+		k, _ := it.Elem()
+		cont := f(k.(mapKey).Value)
 		if !cont {
 			break
 		}
@@ -73,12 +109,50 @@ func (m Map) IterateKey(f func(Value) bool) {
 }
 
 func (m Map) HasKey(k Value) bool {
-	_, ok := (*m.inner)[k]
+	ok, _ := m.inner.Get(mapKey{k})
 	return ok
 }
 
-func (m Map) IndexSet(idx Value, v Value) {
-	(*m.inner)[idx] = v
+// Assoc returns a copy of the map with idx associated with v.
+func (m Map) Assoc(idx Value, v Value) Value {
+	return Map{m.inner.Assoc(mapKey{idx}, v)}
+}
+
+// sortedKeys returns the keys of a MapLike, sorted with Less.
+func sortedKeys(m MapLike) []Value {
+	keys := make([]Value, 0, m.Len())
+	m.IterateKey(func(k Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool { return Less(keys[i], keys[j]) })
+	return keys
+}
+
+// mapLikeEqual determines whether a MapLike has the same pairs as another
+// Value.
+func mapLikeEqual(m MapLike, other Value) bool {
+	m2, ok := other.(MapLike)
+	if !ok || m.Len() != m2.Len() {
+		return false
+	}
+	equal := true
+	m.IterateKey(func(k Value) bool {
+		equal = m2.HasKey(k) && Eq(m.IndexOne(k), m2.IndexOne(k))
+		return equal
+	})
+	return equal
+}
+
+// mapLikeHash hashes the pairs of a MapLike in a way that does not depend on
+// their order.
+func mapLikeHash(m MapLike) uint32 {
+	var h uint32
+	m.IterateKey(func(k Value) bool {
+		h += Hash(k)*31 ^ Hash(m.IndexOne(k))
+		return true
+	})
+	return h
 }
 
 // MapReprBuilder helps building the Repr of a Map. It is also useful for
@@ -0,0 +1,352 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Arithmetic builtins. Integers are computed exactly with math/big, so that
+// results that would overflow int64 or lose precision in float64 are still
+// correct; as soon as a non-integer is involved, numbers are computed as
+// float64.
+
+// Errors thrown by the arithmetic builtins.
+var (
+	ErrNotNumber      = errors.New("not a number")
+	ErrNotInteger     = errors.New("not an integer")
+	ErrDivideByZero   = errors.New("division by zero")
+	ErrBadBase        = errors.New("bad base")
+	ErrExponentTooBig = errors.New("exponent too big")
+)
+
+// maxExponent is the largest exponent with which ^ computes integer powers
+// exactly.
+const maxExponent = 1 << 16
+
+// number is a parsed number. It is an integer if i is not nil, and a float64
+// otherwise.
+type number struct {
+	i *big.Int
+	f float64
+}
+
+// toNumber parses a number. Decimal integers and integers with a 0x, 0o or 0b
+// prefix are parsed as integers, and anything else strconv.ParseFloat accepts
+// as floats.
+func toNumber(v Value) (number, error) {
+	if r, ok := v.(Rat); ok {
+		if r.b.IsInt() {
+			return number{i: new(big.Int).Set(r.b.Num())}, nil
+		}
+		f, _ := r.b.Float64()
+		return number{f: f}, nil
+	}
+	s, ok := v.(String)
+	if !ok {
+		return number{}, ErrNotNumber
+	}
+	if i, ok := new(big.Int).SetString(string(s), 10); ok {
+		return number{i: i}, nil
+	}
+	if hasBasePrefix(string(s)) {
+		if i, ok := new(big.Int).SetString(string(s), 0); ok {
+			return number{i: i}, nil
+		}
+	}
+	f, err := strconv.ParseFloat(string(s), 64)
+	if err != nil {
+		return number{}, fmt.Errorf("%s is not a number", s.Repr(NoPretty))
+	}
+	return number{f: f}, nil
+}
+
+func hasBasePrefix(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	if len(s) < 2 || s[0] != '0' {
+		return false
+	}
+	switch s[1] {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	}
+	return false
+}
+
+func mustToNumbers(args []Value) []number {
+	nums := make([]number, len(args))
+	for i, arg := range args {
+		num, err := toNumber(arg)
+		maybeThrow(err)
+		nums[i] = num
+	}
+	return nums
+}
+
+func mustToInteger(v Value) *big.Int {
+	num, err := toNumber(v)
+	maybeThrow(err)
+	if num.i == nil {
+		throw(ErrNotInteger)
+	}
+	return num.i
+}
+
+func allIntegers(nums []number) bool {
+	for _, num := range nums {
+		if num.i == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (n number) float() float64 {
+	if n.i != nil {
+		f, _ := new(big.Float).SetInt(n.i).Float64()
+		return f
+	}
+	return n.f
+}
+
+func intNumber(i *big.Int) Value {
+	return String(i.String())
+}
+
+func floatNumber(f float64) Value {
+	return String(fmt.Sprintf("%g", f))
+}
+
+func plus(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	nums := mustToNumbers(args)
+
+	out := ec.ports[1].Chan
+	if allIntegers(nums) {
+		sum := new(big.Int)
+		for _, num := range nums {
+			sum.Add(sum, num.i)
+		}
+		out <- intNumber(sum)
+		return
+	}
+	sum := 0.0
+	for _, num := range nums {
+		sum += num.float()
+	}
+	out <- floatNumber(sum)
+}
+
+func minus(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	if len(args) == 0 {
+		throw(ErrArgs)
+	}
+	nums := mustToNumbers(args)
+
+	out := ec.ports[1].Chan
+	if allIntegers(nums) {
+		sum := new(big.Int).Set(nums[0].i)
+		if len(nums) == 1 {
+			// Unary -
+			sum.Neg(sum)
+		}
+		for _, num := range nums[1:] {
+			sum.Sub(sum, num.i)
+		}
+		out <- intNumber(sum)
+		return
+	}
+	sum := nums[0].float()
+	if len(nums) == 1 {
+		// Unary -
+		sum = -sum
+	}
+	for _, num := range nums[1:] {
+		sum -= num.float()
+	}
+	out <- floatNumber(sum)
+}
+
+func times(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	nums := mustToNumbers(args)
+
+	out := ec.ports[1].Chan
+	if allIntegers(nums) {
+		prod := big.NewInt(1)
+		for _, num := range nums {
+			prod.Mul(prod, num.i)
+		}
+		out <- intNumber(prod)
+		return
+	}
+	prod := 1.0
+	for _, num := range nums {
+		prod *= num.float()
+	}
+	out <- floatNumber(prod)
+}
+
+func slash(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	if len(args) == 0 {
+		// cd /
+		cdInner("/", ec)
+		return
+	}
+	// Division
+	divide(ec, args, opts)
+}
+
+// divide divides integers exactly, outputting an integer if the quotient is
+// one, and the closest float64 otherwise.
+func divide(ec *EvalCtx, args []Value, opts map[string]Value) {
+	if len(args) == 0 {
+		throw(ErrArgs)
+	}
+	nums := mustToNumbers(args)
+
+	out := ec.ports[1].Chan
+	if allIntegers(nums) && !anyZero(nums[1:]) {
+		quot := new(big.Rat).SetInt(nums[0].i)
+		for _, num := range nums[1:] {
+			quot.Quo(quot, new(big.Rat).SetInt(num.i))
+		}
+		if quot.IsInt() {
+			out <- intNumber(quot.Num())
+		} else {
+			f, _ := quot.Float64()
+			out <- floatNumber(f)
+		}
+		return
+	}
+	quot := nums[0].float()
+	for _, num := range nums[1:] {
+		quot /= num.float()
+	}
+	out <- floatNumber(quot)
+}
+
+func anyZero(nums []number) bool {
+	for _, num := range nums {
+		if num.i.Sign() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func pow(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var b, p Value
+	ScanArgs(args, &b, &p)
+	TakeNoOpt(opts)
+	nums := mustToNumbers([]Value{b, p})
+
+	out := ec.ports[1].Chan
+	if allIntegers(nums) && nums[1].i.Sign() >= 0 {
+		if !nums[1].i.IsInt64() || nums[1].i.Int64() > maxExponent {
+			throw(ErrExponentTooBig)
+		}
+		out <- intNumber(new(big.Int).Exp(nums[0].i, nums[1].i, nil))
+		return
+	}
+	out <- floatNumber(math.Pow(nums[0].float(), nums[1].float()))
+}
+
+func mod(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var a, b Value
+	ScanArgs(args, &a, &b)
+	TakeNoOpt(opts)
+	ia, ib := mustToInteger(a), mustToInteger(b)
+	if ib.Sign() == 0 {
+		throw(ErrDivideByZero)
+	}
+
+	out := ec.ports[1].Chan
+	out <- intNumber(new(big.Int).Rem(ia, ib))
+}
+
+// wrapNumCompare wraps a comparison of float64s into a builtin. Integers are
+// compared exactly, by passing the sign of their difference and 0 to cmp.
+func wrapNumCompare(cmp func(a, b float64) bool) func(*EvalCtx, []Value, map[string]Value) {
+	return func(ec *EvalCtx, args []Value, opts map[string]Value) {
+		TakeNoOpt(opts)
+		nums := mustToNumbers(args)
+		result := true
+		for i := 0; i < len(nums)-1; i++ {
+			a, b := nums[i], nums[i+1]
+			var ok bool
+			if a.i != nil && b.i != nil {
+				ok = cmp(float64(a.i.Cmp(b.i)), 0)
+			} else {
+				ok = cmp(a.float(), b.float())
+			}
+			if !ok {
+				result = false
+				break
+			}
+		}
+		ec.OutputChan() <- Bool(result)
+	}
+}
+
+// base outputs integers in base b, which must be between 2 and 36.
+func base(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		b    int
+		nums []Value
+	)
+	ScanArgsVariadic(args, &b, &nums)
+	TakeNoOpt(opts)
+
+	if b < 2 || b > 36 {
+		throw(ErrBadBase)
+	}
+
+	out := ec.ports[1].Chan
+
+	for _, num := range nums {
+		out <- String(mustToInteger(num).Text(b))
+	}
+}
+
+// formatNum formats a number with &digits digits after the decimal point, or
+// as many as needed if &digits is negative, in scientific notation if &sci is
+// true.
+func formatNum(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		v      Value
+		digits int
+		sci    Bool
+	)
+	ScanArgs(args, &v)
+	ScanOpts(opts,
+		Opt{"digits", &digits, String("-1")},
+		Opt{"sci", &sci, Bool(false)})
+	num, err := toNumber(v)
+	maybeThrow(err)
+
+	verb := byte('f')
+	if sci {
+		verb = 'e'
+	}
+	var s string
+	if num.i != nil {
+		if !sci && digits < 0 {
+			s = num.i.String()
+		} else {
+			prec := uint(num.i.BitLen())
+			if prec < 64 {
+				prec = 64
+			}
+			s = new(big.Float).SetPrec(prec).SetInt(num.i).Text(verb, digits)
+		}
+	} else {
+		s = strconv.FormatFloat(num.f, verb, digits, 64)
+	}
+	ec.ports[1].Chan <- String(s)
+}
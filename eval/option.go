@@ -0,0 +1,160 @@
+package eval
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/elves/elvish/parse"
+)
+
+// Option is a shell option. The global value of an option lives in the
+// builtin variable of the same name, and can be shadowed by local variables,
+// or dynamically by set-option in a closure.
+type Option struct {
+	Name      string
+	Default   Value
+	Validator func(Value) error
+	Doc       string
+}
+
+// ErrNoSuchOption is thrown when getting or setting an unknown option.
+var ErrNoSuchOption = errors.New("no such option")
+
+// options is the registry of all shell options.
+var options = []*Option{
+	{"pipefail", Bool(true), ShouldBeBool,
+		"Whether a pipeline fails when a form other than the last one fails."},
+	{"errexit", Bool(true), ShouldBeBool,
+		"Whether a closure stops at the first failed pipeline."},
+	{"nomatch-ok", Bool(false), ShouldBeBool,
+		"Whether wildcards that match nothing evaluate to nothing instead of failing."},
+	{"trace", Bool(false), ShouldBeBool,
+		"Whether to print commands and their redirections before running them."},
+	{"debug", Bool(false), ShouldBeBool,
+		"Whether breakpoint starts the debugger."},
+	{"autocd", Bool(false), ShouldBeBool,
+		"Whether a command that is just the path of a directory, or -, changes to it."},
+	{"cd-autocorrect", Bool(false), ShouldBeBool,
+		"Whether cd changes to the closest existing directory instead of asking, when the given one doesn't exist."},
+	{"suffix-aliases", NewMap(make(map[Value]Value)), ShouldBeMap,
+		"A map from file extensions to the commands that open files with them when used as commands."},
+	{"max-call-depth", String(strconv.Itoa(defaultMaxCallDepth)), ShouldBePositiveInt,
+		"The maximum number of nested closure calls."},
+}
+
+func findOption(name string) *Option {
+	for _, opt := range options {
+		if opt.Name == name {
+			return opt
+		}
+	}
+	return nil
+}
+
+// optionScope holds the options set with set-option during a closure call.
+// Options not set in a scope are looked up in its parent.
+type optionScope struct {
+	mutex  sync.RWMutex
+	values map[string]Value
+	parent *optionScope
+}
+
+func (s *optionScope) get(name string) (Value, bool) {
+	for ; s != nil; s = s.parent {
+		s.mutex.RLock()
+		v, ok := s.values[name]
+		s.mutex.RUnlock()
+		if ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (s *optionScope) set(name string, v Value) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.values == nil {
+		s.values = make(map[string]Value)
+	}
+	s.values[name] = v
+}
+
+// getOption returns the value of an option. Local variables take precedence,
+// followed by values set with set-option in the active closure calls, and
+// finally the builtin variable.
+func (ec *EvalCtx) getOption(name string) Value {
+	if v := ec.getLocal(name); v != nil {
+		return v.Get()
+	}
+	if v, ok := ec.up[name]; ok {
+		return v.Get()
+	}
+	if v, ok := ec.options.get(name); ok {
+		return v
+	}
+	if v, ok := ec.Builtin[name]; ok {
+		return v.Get()
+	}
+	return findOption(name).Default
+}
+
+// option returns the value of a boolean option.
+func (ec *EvalCtx) option(name string) bool {
+	return ToBool(ec.getOption(name))
+}
+
+func mustFindOption(name String) *Option {
+	opt := findOption(string(name))
+	if opt == nil {
+		throwf("%s: %s", ErrNoSuchOption, parse.Quote(string(name)))
+	}
+	return opt
+}
+
+// setOption sets an option until the enclosing closure returns, or globally
+// at the top level.
+func setOption(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		name String
+		v    Value
+	)
+	ScanArgs(args, &name, &v)
+	TakeNoOpt(opts)
+
+	opt := mustFindOption(name)
+	if opt.Validator != nil {
+		maybeThrow(opt.Validator(v))
+	}
+	if ec.options == nil {
+		ec.Builtin[opt.Name].Set(v)
+	} else {
+		ec.options.set(opt.Name, v)
+	}
+}
+
+func getOption(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var name String
+	ScanArgs(args, &name)
+	TakeNoOpt(opts)
+
+	ec.ports[1].Chan <- ec.getOption(mustFindOption(name).Name)
+}
+
+// optionsFn outputs a map for each option, containing its name, value,
+// default value and documentation.
+func optionsFn(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	TakeNoOpt(opts)
+
+	out := ec.ports[1].Chan
+	for _, opt := range options {
+		out <- NewMap(map[Value]Value{
+			String("name"):    String(opt.Name),
+			String("value"):   ec.getOption(opt.Name),
+			String("default"): opt.Default,
+			String("doc"):     String(opt.Doc),
+		})
+	}
+}
@@ -0,0 +1,9 @@
+package path
+
+import "os"
+
+// ownerAndGroup reports that the owner and group of files are not known, as
+// Windows does not keep them in the metadata that os.Stat returns.
+func ownerAndGroup(info os.FileInfo) (owner, group string, ok bool) {
+	return "", "", false
+}
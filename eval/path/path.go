@@ -0,0 +1,115 @@
+// Package path implements the path: module, which manipulates filesystem
+// paths.
+package path
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+func Namespace() eval.Namespace {
+	ns := eval.Namespace{}
+	eval.AddBuiltinFns(ns, fns...)
+	return ns
+}
+
+var fns = []*eval.BuiltinFn{
+	{"join", join},
+	{"dir", eval.WrapStringToString(filepath.Dir)},
+	{"base", eval.WrapStringToString(filepath.Base)},
+	{"ext", eval.WrapStringToString(filepath.Ext)},
+	{"clean", eval.WrapStringToString(filepath.Clean)},
+	{"abs", eval.WrapStringToStringError(filepath.Abs)},
+	{"real", eval.WrapStringToStringError(realPath)},
+	{"temp-dir", tempDir},
+	{"temp-file", tempFile},
+	{"stat", stat},
+	{"ls", ls},
+}
+
+func join(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var elems []eval.String
+	eval.ScanArgsVariadic(args, &elems)
+	eval.TakeNoOpt(opts)
+
+	ss := make([]string, len(elems))
+	for i, elem := range elems {
+		ss[i] = string(elem)
+	}
+	ec.OutputChan() <- eval.String(filepath.Join(ss...))
+}
+
+// realPath returns the absolute path with all symbolic links resolved, like
+// realpath(3).
+func realPath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(resolved)
+}
+
+// tempDir creates a new temporary directory and outputs its name. The
+// directory is created in &dir, or the default directory for temporary files
+// if &dir is empty, and its name starts with the optional prefix.
+func tempDir(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	prefix := scanPrefix(args)
+	var dir eval.String
+	eval.ScanOpts(opts, eval.Opt{"dir", &dir, eval.String("")})
+
+	checkTempWrite(ec, string(dir))
+	name, err := ioutil.TempDir(string(dir), prefix)
+	maybeThrow(err)
+	ec.OutputChan() <- eval.String(name)
+}
+
+// tempFile is like tempDir, but creates an empty file.
+func tempFile(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	prefix := scanPrefix(args)
+	var dir eval.String
+	eval.ScanOpts(opts, eval.Opt{"dir", &dir, eval.String("")})
+
+	checkTempWrite(ec, string(dir))
+	f, err := ioutil.TempFile(string(dir), prefix)
+	maybeThrow(err)
+	maybeThrow(f.Close())
+	ec.OutputChan() <- eval.String(f.Name())
+}
+
+// checkTempWrite checks that a temporary file can be created in dir, or the
+// default directory for temporary files if dir is empty.
+func checkTempWrite(ec *eval.EvalCtx, dir string) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	ec.CheckWrite(dir)
+}
+
+func scanPrefix(args []eval.Value) string {
+	switch len(args) {
+	case 0:
+		return "elvish-"
+	case 1:
+		var prefix eval.String
+		eval.ScanArgs(args, &prefix)
+		return string(prefix)
+	default:
+		throwf("arity mismatch: want 0 or 1 arguments, got %d", len(args))
+		return ""
+	}
+}
+
+func throwf(format string, args ...interface{}) {
+	util.Throw(fmt.Errorf(format, args...))
+}
+
+func maybeThrow(err error) {
+	if err != nil {
+		util.Throw(err)
+	}
+}
@@ -0,0 +1,179 @@
+package path
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/eval/evaltest"
+)
+
+func evalOutputs(t *testing.T, src string) []eval.Value {
+	return evaltest.EvalOutputs(t, "path", Namespace(), src)
+}
+
+var pathTests = []struct {
+	src  string
+	want []string
+}{
+	{"path:join a b c", []string{filepath.Join("a", "b", "c")}},
+	{"path:join /a ../b", []string{"/b"}},
+	{"path:dir /a/b/c", []string{"/a/b"}},
+	{"path:base /a/b/c.go", []string{"c.go"}},
+	{"path:ext /a/b/c.go", []string{".go"}},
+	{"path:clean a//b/../c", []string{"a/c"}},
+	{"path:abs /a/./b", []string{"/a/b"}},
+}
+
+func TestPath(t *testing.T) {
+	for _, test := range pathTests {
+		want := make([]eval.Value, len(test.want))
+		for i, s := range test.want {
+			want[i] = eval.String(s)
+		}
+		if outs := evalOutputs(t, test.src); !reflect.DeepEqual(outs, want) {
+			t.Errorf("%s outputs %v, want %v", test.src, outs, want)
+		}
+	}
+}
+
+func TestReal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-path-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "target")
+	link := filepath.Join(dir, "link")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	outs := evalOutputs(t, "path:real "+link)
+	if want := []eval.Value{eval.String(target)}; !reflect.DeepEqual(outs, want) {
+		t.Errorf("path:real outputs %v, want %v", outs, want)
+	}
+}
+
+func TestTemp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-path-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		fn    string
+		isDir bool
+	}{{"temp-dir", true}, {"temp-file", false}} {
+		outs := evalOutputs(t, "path:"+test.fn+" &dir="+dir+" foo-")
+		if len(outs) != 1 {
+			t.Errorf("path:%s outputs %v, want one value", test.fn, outs)
+			continue
+		}
+		name := string(outs[0].(eval.String))
+		if filepath.Dir(name) != dir ||
+			!strings.HasPrefix(filepath.Base(name), "foo-") {
+			t.Errorf("path:%s outputs %q, want foo-* in %s", test.fn, name, dir)
+		}
+		info, err := os.Stat(name)
+		if err != nil || info.IsDir() != test.isDir {
+			t.Errorf("path:%s created %v (error %v), want directory %v",
+				test.fn, info, err, test.isDir)
+		}
+	}
+}
+
+func TestStatAndLs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-path-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(file, 0640)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, ".hidden"), nil, 0600)
+
+	for _, test := range []struct {
+		src  string
+		want []string
+	}{
+		{"m = (path:stat " + file + "); put $m[name] $m[type] $m[size] $m[mode]",
+			[]string{"file", "file", "5", "0640"}},
+		{"m = (path:stat " + dir + "/link); put $m[type]", []string{"file"}},
+		{"m = (path:stat &follow=$false " + dir + "/link); put $m[type]",
+			[]string{"symlink"}},
+		{"path:ls " + dir + " | each [m]{ put $m[name] $m[type] }",
+			[]string{"file", "file", "link", "symlink", "sub", "dir"}},
+		{"path:ls &all " + dir + " | each [m]{ put $m[name] }",
+			[]string{".hidden", "file", "link", "sub"}},
+		{"path:ls " + dir + " | each [m]{ put $m[path] } | take 1",
+			[]string{file}},
+	} {
+		want := make([]eval.Value, len(test.want))
+		for i, s := range test.want {
+			want[i] = eval.String(s)
+		}
+		if outs := evalOutputs(t, test.src); !reflect.DeepEqual(outs, want) {
+			t.Errorf("%s outputs %v, want %v", test.src, outs, want)
+		}
+	}
+}
+
+func TestTempRestricted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-path-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ok := filepath.Join(dir, "ok")
+	os.Mkdir(ok, 0700)
+
+	ev := evaltest.NewEvaler("path", Namespace())
+	ev.Restriction = &eval.Restriction{RestrictWrites: true, WritablePaths: []string{ok}}
+	ports := []*eval.Port{
+		eval.DevNullClosedChan,
+		{File: os.Stdout, Chan: eval.BlackholeChan},
+		{File: os.Stderr, Chan: eval.BlackholeChan},
+	}
+	for _, test := range []struct {
+		src        string
+		restricted bool
+	}{
+		{"path:temp-file &dir=" + ok, false},
+		{"path:temp-dir &dir=" + ok, false},
+		{"path:temp-file &dir=" + dir, true},
+		{"path:temp-dir &dir=" + dir, true},
+		{"path:temp-file", true},
+		{"path:temp-dir", true},
+	} {
+		err := ev.SourceTextWithPorts(ports, "[test]", "use path; "+test.src)
+		restricted := err != nil && strings.Contains(err.Error(), eval.ErrRestricted.Error())
+		if restricted != test.restricted {
+			t.Errorf("%s => %v, want restricted = %v", test.src, err, test.restricted)
+		}
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("restricted path:temp-* created files")
+	}
+}
@@ -0,0 +1,111 @@
+package path
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elves/elvish/eval"
+)
+
+// stat outputs a map of the metadata of each path. Symbolic links are
+// followed unless &follow is false.
+func stat(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var paths []eval.String
+	eval.ScanArgsVariadic(args, &paths)
+	var follow eval.Bool
+	eval.ScanOpts(opts, eval.Opt{"follow", &follow, eval.Bool(true)})
+
+	out := ec.OutputChan()
+	for _, path := range paths {
+		var info os.FileInfo
+		var err error
+		if follow {
+			info, err = os.Stat(string(path))
+		} else {
+			info, err = os.Lstat(string(path))
+		}
+		maybeThrow(err)
+		out <- fileInfoMap(string(path), info)
+	}
+}
+
+// ls outputs a map of the metadata of each entry in a directory, which
+// defaults to the working directory, in lexicographical order. Entries whose
+// names start with a dot are skipped unless &all is true. Symbolic links are
+// not followed.
+func ls(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	dir := "."
+	switch len(args) {
+	case 0:
+	case 1:
+		var s eval.String
+		eval.ScanArgs(args, &s)
+		dir = string(s)
+	default:
+		throwf("arity mismatch: want 0 or 1 arguments, got %d", len(args))
+	}
+	var all eval.Bool
+	eval.ScanOpts(opts, eval.Opt{"all", &all, eval.Bool(false)})
+
+	infos, err := ioutil.ReadDir(dir)
+	maybeThrow(err)
+	out := ec.OutputChan()
+	for _, info := range infos {
+		if !bool(all) && strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		out <- fileInfoMap(filepath.Join(dir, info.Name()), info)
+	}
+}
+
+// fileInfoMap converts a FileInfo to a map with the following keys:
+//
+// path: the path, as given;
+// name: the last element of the path;
+// type: one of file, dir, symlink, pipe, socket, device, char-device and
+// other;
+// size: the size in bytes;
+// mode: the permission bits, as an octal number;
+// mtime: the modification time in RFC 3339 format;
+// owner and group: the names of the owner and group, or their ids when the
+// names cannot be found. They are missing on Windows.
+func fileInfoMap(path string, info os.FileInfo) eval.Value {
+	m := map[eval.Value]eval.Value{
+		eval.String("path"):  eval.String(path),
+		eval.String("name"):  eval.String(info.Name()),
+		eval.String("type"):  eval.String(fileType(info.Mode())),
+		eval.String("size"):  eval.String(strconv.FormatInt(info.Size(), 10)),
+		eval.String("mode"):  eval.String("0" + strconv.FormatUint(uint64(info.Mode().Perm()), 8)),
+		eval.String("mtime"): eval.String(info.ModTime().Format(time.RFC3339)),
+	}
+	if owner, group, ok := ownerAndGroup(info); ok {
+		m[eval.String("owner")] = eval.String(owner)
+		m[eval.String("group")] = eval.String(group)
+	}
+	return eval.NewMap(m)
+}
+
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode&os.ModeDir != 0:
+		return "dir"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeNamedPipe != 0:
+		return "pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "char-device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return "other"
+	}
+}
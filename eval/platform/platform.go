@@ -0,0 +1,113 @@
+// Package platform implements the platform: module, which exposes information
+// about the system that elvish runs on.
+package platform
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+func Namespace() eval.Namespace {
+	ns := eval.Namespace{
+		"os":   eval.NewRoVariable(eval.String(runtime.GOOS)),
+		"arch": eval.NewRoVariable(eval.String(runtime.GOARCH)),
+		"ppid": eval.NewRoVariable(eval.String(strconv.Itoa(os.Getppid()))),
+	}
+	eval.AddBuiltinFns(ns, fns...)
+	return ns
+}
+
+var fns = []*eval.BuiltinFn{
+	{"hostname", hostname},
+	{"uname", unameFn},
+	{"user", userFn},
+	{"env", env},
+}
+
+func hostname(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoArg(args)
+	eval.TakeNoOpt(opts)
+
+	name, err := os.Hostname()
+	maybeThrow(err)
+	ec.OutputChan() <- eval.String(name)
+}
+
+// unameFn outputs a map with the same fields as the utsname struct of
+// uname(2): sysname, nodename, release, version and machine.
+func unameFn(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoArg(args)
+	eval.TakeNoOpt(opts)
+
+	u, err := uname()
+	maybeThrow(err)
+	ec.OutputChan() <- eval.NewMap(map[eval.Value]eval.Value{
+		eval.String("sysname"):  eval.String(u.sysname),
+		eval.String("nodename"): eval.String(u.nodename),
+		eval.String("release"):  eval.String(u.release),
+		eval.String("version"):  eval.String(u.version),
+		eval.String("machine"):  eval.String(u.machine),
+	})
+}
+
+type utsname struct {
+	sysname, nodename, release, version, machine string
+}
+
+// userFn outputs a map describing the current user, or the user with the
+// given name: name, uid, gid, home and full-name.
+func userFn(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoOpt(opts)
+
+	var u *user.User
+	var err error
+	switch len(args) {
+	case 0:
+		u, err = user.Current()
+	case 1:
+		var name eval.String
+		eval.ScanArgs(args, &name)
+		u, err = user.Lookup(string(name))
+	default:
+		throwf("arity mismatch: want 0 or 1 arguments, got %d", len(args))
+	}
+	maybeThrow(err)
+	ec.OutputChan() <- eval.NewMap(map[eval.Value]eval.Value{
+		eval.String("name"):      eval.String(u.Username),
+		eval.String("uid"):       eval.String(u.Uid),
+		eval.String("gid"):       eval.String(u.Gid),
+		eval.String("home"):      eval.String(u.HomeDir),
+		eval.String("full-name"): eval.String(u.Name),
+	})
+}
+
+// env outputs a map of all environment variables.
+func env(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.TakeNoArg(args)
+	eval.TakeNoOpt(opts)
+
+	m := make(map[eval.Value]eval.Value)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			m[eval.String(kv[:i])] = eval.String(kv[i+1:])
+		}
+	}
+	ec.OutputChan() <- eval.NewMap(m)
+}
+
+func throwf(format string, args ...interface{}) {
+	util.Throw(fmt.Errorf(format, args...))
+}
+
+func maybeThrow(err error) {
+	if err != nil {
+		util.Throw(err)
+	}
+}
@@ -0,0 +1,43 @@
+package platform
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/eval/evaltest"
+)
+
+func evalOutputs(t *testing.T, src string) []eval.Value {
+	return evaltest.EvalOutputs(t, "platform", Namespace(), src)
+}
+
+func TestPlatform(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip("cannot get hostname:", err)
+	}
+	os.Setenv("ELVISH_PLATFORM_TEST", "value")
+
+	for _, test := range []struct {
+		src  string
+		want []string
+	}{
+		{"put $platform:os $platform:arch",
+			[]string{runtime.GOOS, runtime.GOARCH}},
+		{"platform:hostname", []string{hostname}},
+		{"m = (platform:uname); put $m[nodename]", []string{hostname}},
+		{"m = (platform:env); put $m[ELVISH_PLATFORM_TEST]",
+			[]string{"value"}},
+	} {
+		want := make([]eval.Value, len(test.want))
+		for i, s := range test.want {
+			want[i] = eval.String(s)
+		}
+		if outs := evalOutputs(t, test.src); !reflect.DeepEqual(outs, want) {
+			t.Errorf("%s outputs %v, want %v", test.src, outs, want)
+		}
+	}
+}
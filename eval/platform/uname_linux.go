@@ -0,0 +1,33 @@
+package platform
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func uname() (utsname, error) {
+	var u unix.Utsname
+	if err := unix.Uname(&u); err != nil {
+		return utsname{}, err
+	}
+	// The fields are int8 arrays on some architectures and uint8 arrays on
+	// others; reinterpret them as byte arrays.
+	return utsname{
+		cString((*[65]byte)(unsafe.Pointer(&u.Sysname))[:]),
+		cString((*[65]byte)(unsafe.Pointer(&u.Nodename))[:]),
+		cString((*[65]byte)(unsafe.Pointer(&u.Release))[:]),
+		cString((*[65]byte)(unsafe.Pointer(&u.Version))[:]),
+		cString((*[65]byte)(unsafe.Pointer(&u.Machine))[:]),
+	}, nil
+}
+
+// cString converts a NUL-terminated byte array to a string.
+func cString(bs []byte) string {
+	for i, b := range bs {
+		if b == 0 {
+			return string(bs[:i])
+		}
+	}
+	return string(bs)
+}
@@ -0,0 +1,26 @@
+// +build !linux
+
+package platform
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// uname falls back to the uname command on systems where uname(2) is not
+// available from Go.
+func uname() (utsname, error) {
+	var fields [5]string
+	for i, flag := range []string{"-s", "-n", "-r", "-v", "-m"} {
+		out, err := exec.Command("uname", flag).Output()
+		if err != nil {
+			return utsname{}, err
+		}
+		fields[i] = strings.TrimSpace(string(out))
+	}
+	if fields[1] == "" {
+		fields[1], _ = os.Hostname()
+	}
+	return utsname{fields[0], fields[1], fields[2], fields[3], fields[4]}, nil
+}
@@ -1,6 +1,14 @@
 package eval
 
-import "os"
+import (
+	"errors"
+	"os"
+)
+
+// ErrReaderGone is thrown by writers of value outputs when the reader has
+// stopped reading. Like SIGPIPE in POSIX shells, pipelines ignore it in all
+// but their last form.
+var ErrReaderGone = errors.New("reader gone")
 
 // Port conveys data stream. It always consists of a byte band and a channel band.
 type Port struct {
@@ -8,11 +16,41 @@ type Port struct {
 	Chan      chan Value
 	CloseFile bool
 	CloseChan bool
+	// ReaderGone is closed when the reader of Chan stops reading early. It is
+	// nil when the reader never does; a nil channel is never ready.
+	ReaderGone chan struct{}
+	// Whether this is the reading end of Chan, responsible for closing
+	// ReaderGone and draining Chan when it is closed.
+	CloseReaderGone bool
 }
 
 // Fork returns a copy of a Port with the Close* flags unset.
 func (p *Port) Fork() *Port {
-	return &Port{p.File, p.Chan, false, false}
+	return &Port{p.File, p.Chan, false, false, p.ReaderGone, false}
+}
+
+// Send sends v onto Chan. It returns false without sending when the reader
+// has stopped reading, in which case the writer should stop writing.
+func (p *Port) Send(v Value) bool {
+	select {
+	case <-p.ReaderGone:
+		return false
+	default:
+	}
+	select {
+	case p.Chan <- v:
+		return true
+	case <-p.ReaderGone:
+		return false
+	}
+}
+
+// Put is like Send, but throws ErrReaderGone instead of returning false, which
+// unwinds the writer however deeply it is nested.
+func (p *Port) Put(v Value) {
+	if !p.Send(v) {
+		throw(ErrReaderGone)
+	}
 }
 
 // Close closes a Port.
@@ -27,6 +65,15 @@ func (p *Port) Close() {
 		// Logger.Printf("closing channel %v", p.Chan)
 		close(p.Chan)
 	}
+	if p.CloseReaderGone {
+		close(p.ReaderGone)
+		// Writers that do not check ReaderGone must not block forever. The
+		// writer closes the channel when it is done.
+		go func() {
+			for range p.Chan {
+			}
+		}()
+	}
 }
 
 // ClosePorts closes a list of Ports.
@@ -0,0 +1,141 @@
+package eval
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/elves/elvish/sys"
+)
+
+// SGR sequences used by pprint.
+const (
+	pprintStringStyle = "\033[32m"
+	pprintKeyStyle    = "\033[33m"
+	pprintVarStyle    = "\033[35m"
+	pprintOtherStyle  = "\033[36m"
+	pprintReset       = "\033[m"
+)
+
+func pprint(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var color String
+	ScanOpts(opts, Opt{"color", &color, String("auto")})
+
+	out := ec.ports[1].File
+	colored := useColor(color, out)
+	for _, arg := range args {
+		repr := arg.Repr(0)
+		if colored {
+			repr = colorizeRepr(repr)
+		}
+		out.WriteString(repr)
+		out.WriteString("\n")
+	}
+}
+
+// useColor interprets a &color option, which is one of auto, always and
+// never. Auto means to use colors when out is a terminal.
+func useColor(color String, out *os.File) bool {
+	switch color {
+	case "auto":
+		return sys.IsATTY(int(out.Fd()))
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		throwf("&color must be auto, always or never, got %s", color.Repr(NoPretty))
+		return false
+	}
+}
+
+// colorizeRepr adds colors to the representation of a value. It relies on the
+// lexical structure of representations: map keys follow &, variables like
+// $true start with $, and values without a representation in the language
+// are wrapped in <>. Everything else that is not a bracket or whitespace is
+// a string.
+func colorizeRepr(s string) string {
+	var b bytes.Buffer
+	inKey := false
+	styled := func(style, text string) {
+		if inKey && style == pprintStringStyle {
+			style = pprintKeyStyle
+		}
+		b.WriteString(style)
+		b.WriteString(text)
+		b.WriteString(pprintReset)
+	}
+	for i := 0; i < len(s); {
+		var j int
+		switch c := s[i]; {
+		case c == '\'':
+			j = i + 1
+			for j < len(s) {
+				if s[j] == '\'' {
+					if j+1 < len(s) && s[j+1] == '\'' {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			if j < len(s) {
+				j++
+			}
+			styled(pprintStringStyle, s[i:j])
+		case c == '"':
+			j = i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(s) {
+				j++
+			}
+			styled(pprintStringStyle, s[i:j])
+		case c == '<':
+			depth := 0
+			for j = i; j < len(s); j++ {
+				if s[j] == '<' {
+					depth++
+				} else if s[j] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if j < len(s) {
+				j++
+			}
+			styled(pprintOtherStyle, s[i:j])
+		case c == '&':
+			b.WriteByte(c)
+			inKey = true
+			j = i + 1
+		case c == '=' && inKey:
+			b.WriteByte(c)
+			inKey = false
+			j = i + 1
+		case strings.IndexByte(" \t\n[]", c) != -1:
+			b.WriteByte(c)
+			j = i + 1
+		default:
+			j = i
+			for j < len(s) && strings.IndexByte(" \t\n[]", s[j]) == -1 &&
+				!(inKey && s[j] == '=') {
+				j++
+			}
+			if c == '$' {
+				styled(pprintVarStyle, s[i:j])
+			} else {
+				styled(pprintStringStyle, s[i:j])
+			}
+		}
+		i = j
+	}
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package eval
+
+import "testing"
+
+var colorizeReprTests = []struct {
+	repr, want string
+}{
+	{"foo", "\033[32mfoo\033[m"},
+	{"'a b'", "\033[32m'a b'\033[m"},
+	{`"a\"b"`, "\033[32m\"a\\\"b\"\033[m"},
+	{"[a $true]", "[\033[32ma\033[m \033[35m$true\033[m]"},
+	{"[&k=v]", "[&\033[33mk\033[m=\033[32mv\033[m]"},
+	{"[&'k'=[<closure {}>]]",
+		"[&\033[33m'k'\033[m=[\033[36m<closure {}>\033[m]]"},
+}
+
+func TestColorizeRepr(t *testing.T) {
+	for _, test := range colorizeReprTests {
+		if got := colorizeRepr(test.repr); got != test.want {
+			t.Errorf("colorizeRepr(%q) = %q, want %q", test.repr, got, test.want)
+		}
+	}
+}
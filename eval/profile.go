@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elves/elvish/util"
+)
+
+// Profiling of elvish code. While the profile builtin runs its argument, the
+// time spent in each command is recorded. The time of a command includes the
+// time of the commands it calls, so the time of a function call includes the
+// time of its body. The profiler is kept in the EvalCtx, so only the commands
+// run by the argument are recorded, and not those of concurrent evaluations
+// or background jobs. The -profile flag profiles a whole script instead, with
+// StartProfile and StopProfile.
+
+type profiler struct {
+	mutex   sync.Mutex
+	entries map[profileKey]*profileEntry
+	// Whether the report has been written; later commands are not recorded.
+	stopped bool
+}
+
+type profileKey struct {
+	srcName    string
+	begin, end int
+}
+
+type profileEntry struct {
+	src   string
+	total time.Duration
+	count int
+}
+
+func newProfiler() *profiler {
+	return &profiler{entries: make(map[profileKey]*profileEntry)}
+}
+
+// record adds the duration of one execution of the command at the given
+// position.
+func (p *profiler) record(srcName, src string, begin, end int, d time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.stopped {
+		return
+	}
+	key := profileKey{srcName, begin, end}
+	e, ok := p.entries[key]
+	if !ok {
+		e = &profileEntry{src: src}
+		p.entries[key] = e
+	}
+	e.total += d
+	e.count++
+}
+
+// report writes the recorded commands, slowest first.
+func (p *profiler) report(w io.Writer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	keys := make([]profileKey, 0, len(p.entries))
+	for key := range p.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ei, ej := p.entries[keys[i]], p.entries[keys[j]]
+		if ei.total != ej.total {
+			return ei.total > ej.total
+		}
+		if keys[i].srcName != keys[j].srcName {
+			return keys[i].srcName < keys[j].srcName
+		}
+		return keys[i].begin < keys[j].begin
+	})
+	fmt.Fprintf(w, "%12s %8s  %s\n", "total", "count", "command")
+	for _, key := range keys {
+		e := p.entries[key]
+		fmt.Fprintf(w, "%12s %8d  %s\n", e.total, e.count, describeCommand(key, e.src))
+	}
+}
+
+func describeCommand(key profileKey, src string) string {
+	if key.begin < 0 || key.end > len(src) || key.begin > key.end {
+		return key.srcName
+	}
+	lineno, colno, _ := util.FindContext(src, key.begin)
+	text := src[key.begin:key.end]
+	if i := strings.IndexByte(text, '\n'); i != -1 {
+		text = text[:i] + " ..."
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", key.srcName, lineno+1, colno+1, text)
+}
+
+// profile implements the profile builtin. It calls the function, and then
+// writes how long each command called by it took.
+func profile(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var f CallableValue
+	ScanArgs(args, &f)
+	TakeNoOpt(opts)
+
+	if ec.profiler != nil {
+		// Already profiling; the outer profile builtin reports.
+		f.Call(ec, nil, NoOpts)
+		return
+	}
+	p := newProfiler()
+	ec.profiler = p
+	defer p.report(ec.ports[2].File)
+	f.Call(ec, nil, NoOpts)
+}
+
+// StartProfile starts profiling all code evaluated by ev, except background
+// jobs. It must be called before ev evaluates any code.
+func (ev *Evaler) StartProfile() {
+	ev.profile = newProfiler()
+}
+
+// StopProfile stops the profiling started by StartProfile and writes how long
+// each command took to w.
+func (ev *Evaler) StopProfile(w io.Writer) {
+	if p := ev.profile; p != nil {
+		p.mutex.Lock()
+		p.stopped = true
+		p.mutex.Unlock()
+		p.report(w)
+	}
+}
@@ -0,0 +1,77 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestProfiler(t *testing.T) {
+	src := "fast\nslow a b\n  multi {\n}"
+	p := newProfiler()
+	p.record("a.elv", src, 0, 4, time.Millisecond)
+	p.record("a.elv", src, 5, 13, 2*time.Millisecond)
+	p.record("a.elv", src, 5, 13, 2*time.Millisecond)
+	p.record("a.elv", src, 16, 25, time.Microsecond)
+
+	var b bytes.Buffer
+	p.report(&b)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	want := []string{
+		"       total    count  command",
+		"         4ms        2  a.elv:2:1: slow a b",
+		"         1ms        1  a.elv:1:1: fast",
+		"         1µs        1  a.elv:3:3: multi { ...",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("report has %d lines, want %d:\n%s", len(lines), len(want), b.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("report line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestProfileBuiltin(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	err := ev.SourceText("[test]", "profile { nop } 2>/dev/null")
+	if err != nil {
+		t.Errorf("profile errors: %v", err)
+	}
+}
+
+func TestProfileIsPerEvaluation(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ec := NewTopEvalCtx(ev, "[test]", "", []*Port{
+		DevNullClosedChan, {File: DevNull, Chan: BlackholeChan},
+		{File: DevNull, Chan: BlackholeChan}})
+	var inner *profiler
+	spy := &BuiltinFn{"spy", func(ec *EvalCtx, args []Value, opts map[string]Value) {
+		inner = ec.profiler
+	}}
+	ec.fork("profile").PCall(&BuiltinFn{"profile", profile}, []Value{spy}, NoOpts)
+	if inner == nil {
+		t.Errorf("profiler not active in the profiled function")
+	}
+	if ec.profiler != nil || NewTopEvalCtx(ev, "[test]", "", nil).profiler != nil {
+		t.Errorf("profiler leaks out of the profiled function")
+	}
+}
+
+func TestStartProfile(t *testing.T) {
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.StartProfile()
+	err := ev.SourceText("[profiled]", "nop; nop &; nop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	ev.StopProfile(&b)
+	if n := strings.Count(b.String(), "[profiled]"); n != 2 {
+		t.Errorf("profile has %d commands, want 2 (background job excluded):\n%s", n, b.String())
+	}
+}
@@ -10,6 +10,8 @@ import (
 // directory. Setting it changes the current working directory.
 type PwdVariable struct {
 	daemon *api.Client
+	// The Evaler whose Restriction applies, if any.
+	ev *Evaler
 }
 
 var _ Variable = PwdVariable{}
@@ -25,6 +27,9 @@ func (pwd PwdVariable) Set(v Value) {
 	if !ok {
 		throw(ErrPathMustBeString)
 	}
+	if pwd.ev != nil {
+		checkChdir(pwd.ev.Restriction, string(path))
+	}
 	err := Chdir(string(path), pwd.daemon)
 	maybeThrow(err)
 }
@@ -0,0 +1,75 @@
+package eval
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+)
+
+// cryptoSource is a rand.Source backed by crypto/rand, for random numbers
+// that must be unpredictable.
+type cryptoSource struct{}
+
+func (cryptoSource) Int63() int64 {
+	return int64(cryptoSource{}.Uint64() &^ (1 << 63))
+}
+
+func (cryptoSource) Uint64() uint64 {
+	var buf [8]byte
+	readRandom(buf[:])
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func (cryptoSource) Seed(int64) {}
+
+// rng returns the random number generator to use for the &crypto option.
+func rng(crypto Bool) *rand.Rand {
+	if crypto {
+		return rand.New(cryptoSource{})
+	}
+	return globalRand
+}
+
+// globalRand generates random numbers with the default source of math/rand,
+// which is safe for concurrent use.
+var globalRand = rand.New(globalSource{})
+
+type globalSource struct{}
+
+func (globalSource) Int63() int64 { return rand.Int63() }
+func (globalSource) Seed(int64)   {}
+
+func readRandom(buf []byte) {
+	_, err := crand.Read(buf)
+	maybeThrow(err)
+}
+
+// uuid outputs a random (version 4) UUID.
+func uuid(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	TakeNoOpt(opts)
+
+	var u [16]byte
+	readRandom(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	ec.OutputChan() <- String(fmt.Sprintf("%x-%x-%x-%x-%x",
+		u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]))
+}
+
+// randToken outputs a hexadecimal string of &bytes random bytes from
+// crypto/rand, suitable for secrets.
+func randToken(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	var n int
+	ScanOpts(opts, Opt{"bytes", &n, String("16")})
+	if n <= 0 {
+		throw(ErrArgs)
+	}
+
+	buf := make([]byte, n)
+	readRandom(buf)
+	ec.OutputChan() <- String(hex.EncodeToString(buf))
+}
@@ -0,0 +1,129 @@
+package eval
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/elves/elvish/sys"
+)
+
+// ErrMismatch is thrown by read &confirm when the two inputs differ.
+var ErrMismatch = errors.New("inputs don't match")
+
+// Secret is a string read with read &secret. Its Repr is a placeholder, so
+// that it does not show up in traces, the debugger or the output of the REPL.
+// Builtins that take string arguments, including the string comparison ones,
+// accept it, and echo, print and external commands get its content. It is not
+// a string otherwise: eq and is consider it different from any string, and it
+// can't be indexed. Strings built from it, for instance by concatenating it
+// with an empty string, are ordinary strings and can be used for these.
+type Secret string
+
+func (Secret) Kind() string {
+	return "secret"
+}
+
+func (Secret) Repr(int) string {
+	return "<secret>"
+}
+
+func (s Secret) String() string {
+	return string(s)
+}
+
+// read reads a line and outputs it without the trailing newline. &prompt is
+// shown before reading. When the input is a terminal and the editor is
+// available, the line is read in the editor's minibuffer. With &secret, the
+// input is not echoed and the line is output as a Secret; neither ends up in
+// the command history. With &confirm, the line is read again and must be the
+// same.
+func read(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoArg(args)
+	var (
+		prompt          String
+		secret, confirm Bool
+	)
+	ScanOpts(opts, Opt{"prompt", &prompt, String("")},
+		Opt{"secret", &secret, Bool(false)},
+		Opt{"confirm", &confirm, Bool(false)})
+
+	line := readInput(ec, string(prompt), bool(secret))
+	if confirm {
+		again := readInput(ec, "(again) "+string(prompt), bool(secret))
+		if again != line {
+			throw(ErrMismatch)
+		}
+	}
+	if secret {
+		ec.ports[1].Chan <- Secret(line)
+	} else {
+		ec.ports[1].Chan <- String(line)
+	}
+}
+
+func readInput(ec *EvalCtx, prompt string, secret bool) string {
+	in := ec.ports[0].File
+	tty := sys.IsATTY(int(in.Fd()))
+	if asker, ok := ec.Editor.(Asker); ok && tty {
+		line, err := asker.Ask(prompt, secret)
+		maybeThrow(err)
+		return line
+	}
+
+	errOut := ec.ports[2].File
+	errOut.WriteString(prompt)
+	if secret && tty {
+		// Turn off echoing while reading.
+		restore, err := turnOffEcho(in)
+		maybeThrow(err)
+		defer func() {
+			restore()
+			errOut.WriteString("\n")
+		}()
+	}
+	line, err := readLine(in)
+	if err == io.EOF {
+		if line == "" {
+			throw(ErrNoInput)
+		}
+	} else {
+		maybeThrow(err)
+	}
+	return line
+}
+
+// readLine reads a line from in without the trailing newline. It reads one
+// byte at a time, so that the input after the line is left for the commands
+// that follow. The buffers are zeroed afterwards, so that secrets don't linger
+// in them.
+func readLine(in *os.File) (string, error) {
+	var buf []byte
+	defer func() { wipe(buf) }()
+	b := make([]byte, 1)
+	defer wipe(b)
+	for {
+		n, err := in.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return string(buf), nil
+			}
+			if len(buf) == cap(buf) {
+				grown := make([]byte, len(buf), 2*cap(buf)+64)
+				copy(grown, buf)
+				wipe(buf)
+				buf = grown
+			}
+			buf = append(buf, b[0])
+		}
+		if err != nil {
+			return string(buf), err
+		}
+	}
+}
+
+func wipe(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
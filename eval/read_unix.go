@@ -0,0 +1,25 @@
+// +build !windows
+
+package eval
+
+import (
+	"os"
+
+	"github.com/elves/elvish/sys"
+)
+
+// turnOffEcho turns off echoing of the terminal f, and returns a function that
+// restores it.
+func turnOffEcho(f *os.File) (func(), error) {
+	fd := int(f.Fd())
+	term, err := sys.NewTermiosFromFd(fd)
+	if err != nil {
+		return nil, err
+	}
+	saved := term.Copy()
+	term.SetEcho(false)
+	if err := term.ApplyToFd(fd); err != nil {
+		return nil, err
+	}
+	return func() { saved.ApplyToFd(fd) }, nil
+}
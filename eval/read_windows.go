@@ -0,0 +1,22 @@
+package eval
+
+import (
+	"os"
+
+	"github.com/elves/elvish/sys"
+)
+
+// turnOffEcho turns off echoing of the console f, and returns a function that
+// restores it.
+func turnOffEcho(f *os.File) (func(), error) {
+	handle := f.Fd()
+	mode, err := sys.GetConsoleMode(handle)
+	if err != nil {
+		return nil, err
+	}
+	err = sys.SetConsoleMode(handle, mode&^sys.EnableEchoInput)
+	if err != nil {
+		return nil, err
+	}
+	return func() { sys.SetConsoleMode(handle, mode) }, nil
+}
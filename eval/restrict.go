@@ -0,0 +1,112 @@
+package eval
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/elves/elvish/parse"
+)
+
+// ErrRestricted is thrown when code run by an Evaler with a Restriction tries
+// to do something that the Restriction doesn't allow.
+var ErrRestricted = errors.New("not allowed in restricted mode")
+
+// Restriction limits what code run by an Evaler can do, so that code that is
+// not fully trusted, such as configuration files or snippets from elsewhere,
+// can be evaluated safely. Each kind of operation is unrestricted unless its
+// Restrict field is true; it is then disabled, except for what the
+// accompanying list allows. Note that external commands can do anything, so
+// restricting writes and directory changes is only effective when external
+// commands are restricted too.
+type Restriction struct {
+	// Whether running external commands is restricted, and the commands that
+	// can still be run, by name or path.
+	RestrictExternals bool
+	Externals         []string
+	// Whether writing files, including creating and removing them, is
+	// restricted, and the files and directories under which it is allowed.
+	RestrictWrites bool
+	WritablePaths  []string
+	// Whether changing the working directory is restricted, and the
+	// directories under which it is allowed.
+	RestrictChdir bool
+	Dirs          []string
+}
+
+// CheckExternal throws ErrRestricted unless the external command name can be
+// run. Commands are matched by the name they are invoked with, and by the
+// path they are found at. Builtins, including those of modules, must call it
+// before running an external command.
+func (ec *EvalCtx) CheckExternal(name, path string) {
+	r := ec.Restriction
+	if r == nil || !r.RestrictExternals {
+		return
+	}
+	for _, allowed := range r.Externals {
+		if allowed == name || allowed == path {
+			return
+		}
+	}
+	throwf("%s: running %s", ErrRestricted, parse.Quote(name))
+}
+
+// CheckWrite throws ErrRestricted unless the file at path can be written.
+// Builtins must call it before creating, writing or removing a file.
+func (ec *EvalCtx) CheckWrite(path string) {
+	r := ec.Restriction
+	if r == nil || !r.RestrictWrites || underAny(path, r.WritablePaths) {
+		return
+	}
+	throwf("%s: writing %s", ErrRestricted, parse.Quote(path))
+}
+
+// CheckChdir throws ErrRestricted unless the working directory can be changed
+// to dir.
+func (ec *EvalCtx) CheckChdir(dir string) {
+	checkChdir(ec.Restriction, dir)
+}
+
+func checkChdir(r *Restriction, dir string) {
+	if r == nil || !r.RestrictChdir || underAny(dir, r.Dirs) {
+		return
+	}
+	throwf("%s: changing to %s", ErrRestricted, parse.Quote(dir))
+}
+
+// underAny returns whether path is one of dirs or is under one of them.
+// Symbolic links are resolved when possible, so that they can't be used to
+// get around a restriction.
+func underAny(path string, dirs []string) bool {
+	path = realPath(path)
+	for _, dir := range dirs {
+		dir = realPath(dir)
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) ||
+			dir == string(filepath.Separator) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath returns the absolute path of path with symbolic links resolved.
+// When path doesn't exist, the links in its closest existing parent are
+// resolved instead.
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	var rest []string
+	for {
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return filepath.Join(append([]string{abs}, rest...)...)
+		}
+		rest = append([]string{filepath.Base(abs)}, rest...)
+		abs = parent
+	}
+}
@@ -0,0 +1,73 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+)
+
+func TestRestriction(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "elvishtest.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	tmpdir, _ = filepath.EvalSymlinks(tmpdir)
+	ok := filepath.Join(tmpdir, "ok")
+	os.Mkdir(ok, 0755)
+	os.Symlink(tmpdir, filepath.Join(ok, "link"))
+
+	oldpwd, _ := os.Getwd()
+	defer os.Chdir(oldpwd)
+	os.Chdir(tmpdir)
+
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	ev.Restriction = &Restriction{
+		RestrictExternals: true, Externals: []string{"true"},
+		RestrictWrites: true, WritablePaths: []string{ok},
+		RestrictChdir: true, Dirs: []string{tmpdir},
+	}
+	ports := []*Port{
+		{File: os.Stdin, Chan: ClosedChan},
+		{File: os.Stdout, Chan: BlackholeChan},
+		{File: os.Stderr, Chan: BlackholeChan},
+	}
+
+	for _, test := range []struct {
+		code       string
+		restricted bool
+	}{
+		{"true", false},
+		{"false", true},
+		{"run true", false},
+		{"run touch a", true},
+		{"echo > ok/a", false},
+		{"echo > a", true},
+		{"echo > ok/link/a", true},
+		{"echo >> ok/../a", true},
+		{"cat = (fopen ok/a); fclose $cat", false},
+		{"fopen &write a", true},
+		{"echo a | tee ok/b", false},
+		{"echo a | tee b", true},
+		{"with-temp-file &dir=ok [f]{ }", false},
+		{"with-temp-file [f]{ }", true},
+		{"cd ok; cd ..", false},
+		{"cd /", true},
+		{"pwd = /", true},
+		{"pwd = ok; cd " + tmpdir, false},
+	} {
+		err := ev.SourceTextWithPorts(ports, "[test]", test.code)
+		restricted := err != nil && strings.Contains(err.Error(), ErrRestricted.Error())
+		if restricted != test.restricted {
+			t.Errorf("%s => %v, want restricted = %v", test.code, err, test.restricted)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpdir, "a")); err == nil {
+		t.Errorf("restricted write created a file")
+	}
+}
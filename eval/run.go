@@ -0,0 +1,127 @@
+package eval
+
+import (
+	"bytes"
+	osexec "os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// run runs an external command with explicit settings instead of
+// redirections and environment variables of the shell, and outputs a map
+// describing how it ended. The options are:
+//
+// &env: a map of environment variables to set;
+// &clear-env: whether to start with an empty environment instead of the
+// environment of the shell;
+// &dir: the working directory, which defaults to that of the shell;
+// &stdin: a string to feed as the standard input, instead of the byte input;
+// &capture: whether to capture the standard output and error in the stdout
+// and stderr fields of the result, instead of writing them to the byte
+// outputs.
+//
+// The result also has the pid field, the exit-status field, which is -1 when
+// the command was killed by a signal, and the signal field, which is the name
+// of that signal or empty. Nonzero exit statuses are not errors.
+func run(ec *EvalCtx, args []Value, opts map[string]Value) {
+	if len(args) < 1 {
+		throw(ErrArgs)
+	}
+	var (
+		env      Map
+		clearEnv Bool
+		dir      String
+		stdin    Value
+		capture  Bool
+	)
+	_, hasStdin := opts["stdin"]
+	ScanOpts(opts,
+		Opt{"env", &env, NewMap(map[Value]Value{})},
+		Opt{"clear-env", &clearEnv, Bool(false)},
+		Opt{"dir", &dir, String("")},
+		Opt{"stdin", &stdin, String("")},
+		Opt{"capture", &capture, Bool(false)})
+
+	name := ToString(args[0])
+	path, err := ec.Search(name)
+	maybeThrow(err)
+	ec.CheckExternal(name, path)
+	argv := make([]string, len(args))
+	for i, arg := range args {
+		argv[i] = ToString(arg)
+	}
+	cmd := &osexec.Cmd{Path: path, Args: argv, Dir: string(dir)}
+
+	if !clearEnv {
+		cmd.Env = ec.environ()
+	}
+	env.IterateKey(func(k Value) bool {
+		cmd.Env = setEnv(cmd.Env, ToString(k), ToString(env.IndexOne(k)))
+		return true
+	})
+	if cmd.Env == nil {
+		// A nil Env means the environment of the shell to os/exec.
+		cmd.Env = []string{}
+	}
+
+	if hasStdin {
+		cmd.Stdin = strings.NewReader(ToString(stdin))
+	} else {
+		cmd.Stdin = ec.ports[0].File
+	}
+	var stdout, stderr bytes.Buffer
+	if capture {
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	} else {
+		cmd.Stdout, cmd.Stderr = ec.ports[1].File, ec.ports[2].File
+	}
+
+	l := ec.limiter
+	limited := l != nil && l.killsChildren()
+	if limited {
+		cmd.SysProcAttr = newProcessGroupAttr()
+	}
+	err = cmd.Start()
+	if err != nil {
+		throw(err)
+	}
+	if limited {
+		l.started(cmd.Process.Pid)
+	}
+	err = cmd.Wait()
+	if _, ok := err.(*osexec.ExitError); err != nil && !ok {
+		throw(err)
+	}
+	if limited {
+		l.exited(cmd.Process.Pid, cmd.ProcessState.SysUsage().(*syscall.Rusage))
+		l.check()
+	}
+
+	ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	result := map[Value]Value{
+		String("pid"):         String(strconv.Itoa(cmd.ProcessState.Pid())),
+		String("exit-status"): String(strconv.Itoa(ws.ExitStatus())),
+		String("signal"):      String(""),
+	}
+	if ws.Signaled() {
+		result[String("signal")] = String(ws.Signal().String())
+	}
+	if capture {
+		result[String("stdout")] = String(stdout.String())
+		result[String("stderr")] = String(stderr.String())
+	}
+	ec.ports[1].Chan <- NewMap(result)
+}
+
+// setEnv sets an environment variable in a list of KEY=value pairs.
+func setEnv(env []string, key, value string) []string {
+	prefix := key + "="
+	for i, kv := range env {
+		if strings.HasPrefix(kv, prefix) {
+			env[i] = prefix + value
+			return env
+		}
+	}
+	return append(env, prefix+value)
+}
@@ -2,6 +2,7 @@ package eval
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/elves/elvish/parse"
 	"github.com/elves/elvish/util"
@@ -17,6 +18,20 @@ func (ev *Evaler) Search(exe string) (string, error) {
 	return path, nil
 }
 
+// Search is like Evaler.Search, but resolves the command using $E:PATH as set
+// by temporary assignments of ec, if any.
+func (ec *EvalCtx) Search(exe string) (string, error) {
+	pathEnv, ok := ec.env.get("PATH")
+	if !ok {
+		return ec.Evaler.Search(exe)
+	}
+	path, err := util.Search(filepath.SplitList(pathEnv), exe)
+	if err != nil {
+		return "", fmt.Errorf("search %s: %s", parse.Quote(exe), err.Error())
+	}
+	return path, nil
+}
+
 // EachExternal calls f for each name that can resolve to an external
 // command.
 func (ev *Evaler) EachExternal(f func(string)) {
@@ -7,13 +7,22 @@ type sharedVariable struct {
 	name  string
 }
 
+// Set stores the dump of the value, so that lists and maps survive the round
+// trip through the daemon.
 func (sv sharedVariable) Set(val Value) {
-	err := sv.store.SetSharedVar(sv.name, ToString(val))
+	data, err := Dump(val)
+	maybeThrow(err)
+	err = sv.store.SetSharedVar(sv.name, string(data))
 	maybeThrow(err)
 }
 
+// Get loads the stored dump. Values that are not dumps, like those stored by
+// older versions, are returned as strings.
 func (sv sharedVariable) Get() Value {
 	value, err := sv.store.SharedVar(sv.name)
 	maybeThrow(err)
+	if v, err := Load([]byte(value)); err == nil {
+		return v
+	}
 	return String(value)
 }
@@ -0,0 +1,40 @@
+package eval
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+// dial connects to an address on a network, tcp or unix, and outputs two
+// files, one for reading from and one for writing to the connection, for use
+// in redirections. The connection is closed when both files are closed. A
+// &timeout in seconds applies to connecting; 0 means no timeout.
+func dial(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var network, address String
+	ScanArgs(args, &network, &address)
+	var timeout float64
+	ScanOpts(opts, Opt{"timeout", &timeout, String("0")})
+
+	conn, err := net.DialTimeout(string(network), string(address),
+		time.Duration(timeout*float64(time.Second)))
+	maybeThrow(err)
+	defer conn.Close()
+
+	filer, ok := conn.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		throwf("cannot use %s connection as file", network)
+	}
+	r, err := filer.File()
+	maybeThrow(err)
+	w, err := filer.File()
+	if err != nil {
+		r.Close()
+		throw(err)
+	}
+	out := ec.ports[1].Chan
+	out <- File{r}
+	out <- File{w}
+}
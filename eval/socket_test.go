@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serveUpper accepts one connection, reads a line from it, writes it back in
+// upper case and closes the connection.
+func serveUpper(l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	conn.Write([]byte(strings.ToUpper(line)))
+}
+
+func TestDial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-socket-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, addr := range [][2]string{
+		{"tcp", "127.0.0.1:0"}, {"unix", filepath.Join(dir, "sock")}} {
+		l, err := net.Listen(addr[0], addr[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serveUpper(l)
+
+		src := "r w = (dial " + addr[0] + " " + l.Addr().String() + "); " +
+			"echo hello > $w; from-lines < $r; fclose $r; fclose $w"
+		outs, _, err := evalAndCollect(t, []string{src}, 1)
+		if err != nil {
+			t.Errorf("%s: error %v", src, err)
+		}
+		if want := strs("HELLO"); !reflect.DeepEqual(outs, want) {
+			t.Errorf("%s outputs %v, want %v", src, outs, want)
+		}
+		l.Close()
+	}
+}
@@ -1,9 +1,11 @@
 package eval
 
 import (
+	"errors"
 	"unicode/utf8"
 
 	"github.com/elves/elvish/parse"
+	"github.com/elves/elvish/util"
 )
 
 // String is just a string.
@@ -26,23 +28,22 @@ func (s String) String() string {
 	return string(s)
 }
 
+func (s String) Hash() uint32 {
+	return hashString(string(s))
+}
+
+func (s String) Less(other Value) bool {
+	return string(s) < ToString(other)
+}
+
+// Len returns the number of runes in the string.
 func (s String) Len() int {
-	return len(string(s))
+	return utf8.RuneCountInString(string(s))
 }
 
+// IndexOne indexes the string by runes.
 func (s String) IndexOne(idx Value) Value {
-	slice, i, j := ParseAndFixListIndex(ToString(idx), len(s))
-	var r rune
-	if r, _ = utf8.DecodeRuneInString(string(s[i:])); r == utf8.RuneError {
-		throw(ErrBadIndex)
-	}
-	if slice {
-		if r, _ := utf8.DecodeLastRuneInString(string(s[:j])); r == utf8.RuneError {
-			throw(ErrBadIndex)
-		}
-		return String(s[i:j])
-	}
-	return String(r)
+	return String(indexString(string(s), ToString(idx), "rune"))
 }
 
 func (s String) Iterate(f func(v Value) bool) {
@@ -54,6 +55,94 @@ func (s String) Iterate(f func(v Value) bool) {
 	}
 }
 
+// ErrBadUnit is thrown when a string is indexed with an unknown unit.
+var ErrBadUnit = errors.New("unit must be rune, byte or width")
+
+// walkUnits splits a string into runes or display columns for indexing. It
+// calls f with the index and the byte offset of each unit in turn, and finally
+// with the number of units and len(s), stopping early if f returns false. With
+// the width unit, each unit is a display column; the columns other than the
+// first one covered by a wide rune have an offset of -1, and zero-width runes
+// belong to the column before them.
+func walkUnits(s, unit string, f func(k, offset int) bool) {
+	k := 0
+	switch unit {
+	case "rune":
+		for i := range s {
+			if !f(k, i) {
+				return
+			}
+			k++
+		}
+	case "width":
+		for i, r := range s {
+			w := util.Wcwidth(r)
+			if w == 0 && k > 0 {
+				continue
+			}
+			if !f(k, i) {
+				return
+			}
+			for k++; w > 1; w-- {
+				if !f(k, -1) {
+					return
+				}
+				k++
+			}
+		}
+	default:
+		throw(ErrBadUnit)
+	}
+	f(k, len(s))
+}
+
+// indexString indexes or slices a string with an index like that of a list,
+// counting in the given unit. The string is walked instead of splitting it
+// into units beforehand, so that no memory is allocated for the units.
+func indexString(s, idx, unit string) string {
+	if unit == "byte" {
+		slice, i, j := ParseAndFixListIndex(idx, len(s))
+		if !slice {
+			j = i + 1
+		}
+		return s[i:j]
+	}
+	n := 0
+	walkUnits(s, unit, func(k, _ int) bool {
+		n = k
+		return true
+	})
+	slice, i, j := ParseAndFixListIndex(idx, n)
+	if !slice {
+		j = i + 1
+	}
+	begin, end := -1, -1
+	walkUnits(s, unit, func(k, offset int) bool {
+		if k == i {
+			begin = offset
+		}
+		// A single unit extends over the columns of a wide rune.
+		if k == j && (slice || offset != -1) || k > j && offset != -1 {
+			end = offset
+			return false
+		}
+		return true
+	})
+	if begin == -1 || end == -1 {
+		throw(ErrBadIndex)
+	}
+	return s[begin:end]
+}
+
+// strIndex indexes or slices a string by runes, bytes or display columns.
+func strIndex(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var s, idx, unit String
+	ScanArgs(args, &s, &idx)
+	ScanOpts(opts, Opt{"unit", &unit, String("rune")})
+
+	ec.ports[1].Chan <- String(indexString(string(s), string(idx), string(unit)))
+}
+
 // Call resolves a command name to either a Fn variable or external command and
 // calls it.
 func (s String) Call(ec *EvalCtx, args []Value, opts map[string]Value) {
@@ -35,6 +35,14 @@ func (s *Struct) Repr(indent int) string {
 	return builder.String()
 }
 
+func (s *Struct) Equal(other Value) bool {
+	return mapLikeEqual(s, other)
+}
+
+func (s *Struct) Hash() uint32 {
+	return mapLikeHash(s)
+}
+
 func (s *Struct) Len() int {
 	return len(s.FieldNames)
 }
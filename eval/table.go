@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elves/elvish/util"
+)
+
+// SGR sequence for the header of tables.
+const tableHeaderStyle = "\033[1m"
+
+// toTable writes the value inputs, which are maps or lists, as the rows of a
+// table with aligned columns.
+//
+// The columns are given by &columns, a list of keys. When it is empty, the
+// columns are all the keys of the maps in sorted order, or all the indices of
+// the lists. Missing cells are left blank. A header with the column keys is
+// written unless &header is false; it defaults to false when all inputs are
+// lists.
+func toTable(ec *EvalCtx, args []Value, opts map[string]Value) {
+	iterate := ScanArgsAndOptionalIterate(ec, args)
+	var (
+		columnsOpt List
+		color      String
+	)
+	_, hasHeader := opts["header"]
+	var header Bool
+	ScanOpts(opts,
+		Opt{"columns", &columnsOpt, NewList()},
+		Opt{"color", &color, String("auto")},
+		Opt{"header", &header, Bool(true)})
+	out := ec.ports[1].File
+	colored := useColor(color, out)
+
+	var rows []Value
+	allLists := true
+	iterate(func(v Value) {
+		switch v.(type) {
+		case Map:
+			allLists = false
+		case List:
+		default:
+			throwf("to-table wants map or list input, got %s", v.Kind())
+		}
+		rows = append(rows, v)
+	})
+	if !hasHeader && allLists {
+		header = false
+	}
+
+	var columns []Value
+	if columnsOpt.Len() > 0 {
+		columnsOpt.Iterate(func(v Value) bool {
+			columns = append(columns, v)
+			return true
+		})
+	} else {
+		columns = tableColumns(rows)
+	}
+
+	cells := make([][]string, 0, len(rows)+1)
+	if header {
+		headerCells := make([]string, len(columns))
+		for i, column := range columns {
+			headerCells[i] = ToString(column)
+		}
+		cells = append(cells, headerCells)
+	}
+	for _, row := range rows {
+		rowCells := make([]string, len(columns))
+		for i, column := range columns {
+			rowCells[i] = tableCell(row, column)
+		}
+		cells = append(cells, rowCells)
+	}
+
+	widths := make([]int, len(columns))
+	for _, rowCells := range cells {
+		for i, cell := range rowCells {
+			if w := util.Wcswidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	for i, rowCells := range cells {
+		styled := colored && bool(header) && i == 0
+		var line bytes.Buffer
+		for j, cell := range rowCells {
+			if j > 0 {
+				line.WriteString("  ")
+			}
+			if styled {
+				line.WriteString(tableHeaderStyle + cell + pprintReset)
+			} else {
+				line.WriteString(cell)
+			}
+			if j < len(rowCells)-1 {
+				line.WriteString(strings.Repeat(" ", widths[j]-util.Wcswidth(cell)))
+			}
+		}
+		b.WriteString(strings.TrimRight(line.String(), " "))
+		b.WriteString("\n")
+	}
+	out.Write(b.Bytes())
+}
+
+// tableColumns returns all the keys of the maps among rows in sorted order,
+// followed by the indices of the longest list that are not keys.
+func tableColumns(rows []Value) []Value {
+	keys := make(map[string]bool)
+	var names []string
+	maxLen := 0
+	for _, row := range rows {
+		switch row := row.(type) {
+		case Map:
+			row.IterateKey(func(k Value) bool {
+				name := ToString(k)
+				if !keys[name] {
+					keys[name] = true
+					names = append(names, name)
+				}
+				return true
+			})
+		case List:
+			if row.Len() > maxLen {
+				maxLen = row.Len()
+			}
+		}
+	}
+	sort.Strings(names)
+	columns := make([]Value, len(names))
+	for i, name := range names {
+		columns[i] = String(name)
+	}
+	for i := 0; i < maxLen; i++ {
+		if name := strconv.Itoa(i); !keys[name] {
+			columns = append(columns, String(name))
+		}
+	}
+	return columns
+}
+
+// tableCell returns the content of the cell of a row in a column.
+func tableCell(row, column Value) string {
+	var v Value
+	switch row := row.(type) {
+	case Map:
+		if row.HasKey(column) {
+			v = row.IndexOne(column)
+		}
+	case List:
+		if i, err := toInt(column); err == nil && i >= 0 && i < row.Len() {
+			v = row.IndexOne(column)
+		}
+	}
+	if v == nil {
+		return ""
+	}
+	return ToString(v)
+}
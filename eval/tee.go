@@ -0,0 +1,139 @@
+package eval
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// tee passes its byte and value inputs to its outputs, and also copies them to
+// each target. A target is either a filename or file, to which bytes are
+// copied verbatim and values are written as their representations, one per
+// line; or a function, which is called with a copy of both inputs as its
+// input. Files named by filenames are truncated unless &append is true.
+func tee(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var appendOpt Bool
+	ScanOpts(opts, Opt{"append", &appendOpt, Bool(false)})
+
+	var (
+		files   []io.Writer
+		funcs   []CallableValue
+		toClose []*os.File
+	)
+	defer func() {
+		for _, f := range toClose {
+			f.Close()
+		}
+	}()
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendOpt {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case String:
+			ec.CheckWrite(string(arg))
+			f, err := os.OpenFile(string(arg), flag, defaultFileRedirPerm)
+			maybeThrow(err)
+			toClose = append(toClose, f)
+			files = append(files, f)
+		case File:
+			files = append(files, arg.inner)
+		case CallableValue:
+			funcs = append(funcs, arg)
+		default:
+			throwf("tee target must be string, file or function, got %s", arg.Kind())
+		}
+	}
+
+	// Start the functions, each with its own copy of the input.
+	var (
+		wg     sync.WaitGroup
+		errs   = make([]error, len(funcs))
+		pipes  []*os.File
+		chans  []chan Value
+		closed bool
+	)
+	closeSinks := func() {
+		if closed {
+			return
+		}
+		closed = true
+		for _, w := range pipes {
+			w.Close()
+		}
+		for _, ch := range chans {
+			close(ch)
+		}
+	}
+	defer closeSinks()
+	for i, f := range funcs {
+		r, w, err := os.Pipe()
+		maybeThrow(err)
+		pipes = append(pipes, w)
+		ch := make(chan Value, pipelineChanBufferSize)
+		chans = append(chans, ch)
+
+		newec := ec.fork("tee target")
+		newec.ports[0] = &Port{File: r, Chan: ch, CloseFile: true}
+		wg.Add(1)
+		go func(i int, f CallableValue) {
+			errs[i] = newec.PCall(f, NoArgs, NoOpts)
+			ClosePorts(newec.ports)
+			// Keep draining, so that tee never blocks on a function that has
+			// stopped reading.
+			go func() {
+				for range ch {
+				}
+			}()
+			wg.Done()
+		}(i, f)
+	}
+
+	// Writes to the same file from the byte and value inputs are serialized.
+	var mutex sync.Mutex
+	writeAll := func(ws []io.Writer, bs []byte) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, w := range ws {
+			w.Write(bs)
+		}
+	}
+
+	byteWriters := append([]io.Writer{ec.ports[1].File}, files...)
+	for _, w := range pipes {
+		byteWriters = append(byteWriters, w)
+	}
+	bytesDone := make(chan struct{})
+	go func() {
+		var buf [4096]byte
+		for {
+			n, err := ec.ports[0].File.Read(buf[:])
+			if n > 0 {
+				writeAll(byteWriters, buf[:n])
+			}
+			if err != nil {
+				break
+			}
+		}
+		close(bytesDone)
+	}()
+
+	out := ec.ports[1]
+	for v := range ec.ports[0].Chan {
+		if len(files) > 0 {
+			writeAll(files, []byte(v.Repr(NoPretty)+"\n"))
+		}
+		for _, ch := range chans {
+			ch <- v
+		}
+		out.Put(v)
+	}
+	<-bytesDone
+
+	closeSinks()
+	wg.Wait()
+	for _, err := range errs {
+		maybeThrow(err)
+	}
+}
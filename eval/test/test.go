@@ -0,0 +1,191 @@
+// Package test implements the test module, a small unit-test framework for
+// elvish code.
+//
+// Tests are defined with test:test, which runs a function and records whether
+// it throws; tests can be grouped with test:group, which prefixes the names of
+// the tests defined in it. The assert builtins throw on failure. All results
+// are recorded in a Suite, which can print a summary of them.
+//
+// By convention, tests of a module foo.elv live in foo_test.elv, and
+// "elvish -test" runs all such files in the current directory.
+package test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/util"
+)
+
+// Errors thrown by the test builtins.
+var (
+	ErrAssertion   = errors.New("assertion failed")
+	ErrNoThrow     = errors.New("assertion failed: function did not throw")
+	ErrTestsFailed = errors.New("some tests failed")
+)
+
+// Suite records the results of tests.
+type Suite struct {
+	mutex    sync.Mutex
+	groups   []string
+	passed   int
+	failures []Failure
+}
+
+// Failure is a failed test.
+type Failure struct {
+	Name  string
+	Error error
+}
+
+// NewSuite creates an empty Suite.
+func NewSuite() *Suite {
+	return &Suite{}
+}
+
+// Namespace returns the namespace of the test module. The test:test builtin
+// of the namespace records results to the suite.
+func (s *Suite) Namespace() eval.Namespace {
+	ns := eval.Namespace{}
+	eval.AddBuiltinFns(ns,
+		&eval.BuiltinFn{"assert", assert},
+		&eval.BuiltinFn{"assert-eq", assertEq},
+		&eval.BuiltinFn{"assert-throws", assertThrows},
+		&eval.BuiltinFn{"test", s.test},
+		&eval.BuiltinFn{"group", s.group},
+		&eval.BuiltinFn{"summary", s.summary},
+	)
+	return ns
+}
+
+// Record records the result of a test. A nil error means that the test
+// passed.
+func (s *Suite) Record(name string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err == nil {
+		s.passed++
+	} else {
+		s.failures = append(s.failures, Failure{name, err})
+	}
+}
+
+// Failed returns whether any test has failed.
+func (s *Suite) Failed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.failures) > 0
+}
+
+// Summarize writes the names of the failed tests and the number of passed and
+// failed tests.
+func (s *Suite) Summarize(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, f := range s.failures {
+		fmt.Fprintf(w, "FAIL %s\n", f.Name)
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", s.passed, len(s.failures))
+}
+
+func (s *Suite) qualify(name string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return strings.Join(append(s.groups[:len(s.groups):len(s.groups)], name), "/")
+}
+
+func (s *Suite) test(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var (
+		name eval.String
+		f    eval.CallableValue
+	)
+	eval.ScanArgs(args, &name, &f)
+	eval.TakeNoOpt(opts)
+
+	fullName := s.qualify(string(name))
+	err := ec.PCall(f, nil, eval.NoOpts)
+	s.Record(fullName, err)
+	out := ec.OutputFile()
+	if err == nil {
+		fmt.Fprintf(out, "PASS %s\n", fullName)
+	} else {
+		fmt.Fprintf(out, "FAIL %s\n", fullName)
+		if p, ok := err.(util.Pprinter); ok {
+			fmt.Fprintln(out, p.Pprint("  "))
+		} else {
+			fmt.Fprintln(out, "  "+err.Error())
+		}
+	}
+}
+
+func (s *Suite) group(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var (
+		name eval.String
+		f    eval.CallableValue
+	)
+	eval.ScanArgs(args, &name, &f)
+	eval.TakeNoOpt(opts)
+
+	s.mutex.Lock()
+	s.groups = append(s.groups, string(name))
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.groups = s.groups[:len(s.groups)-1]
+		s.mutex.Unlock()
+	}()
+	f.Call(ec, nil, eval.NoOpts)
+}
+
+func (s *Suite) summary(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	eval.ScanArgs(args)
+	eval.TakeNoOpt(opts)
+	s.Summarize(ec.OutputFile())
+	if s.Failed() {
+		util.Throw(ErrTestsFailed)
+	}
+}
+
+func assert(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var (
+		cond eval.Value
+		msg  eval.String
+	)
+	eval.ScanArgs(args, &cond)
+	eval.ScanOpts(opts, eval.Opt{"msg", &msg, eval.String("")})
+
+	if !eval.ToBool(cond) {
+		if msg == "" {
+			util.Throw(ErrAssertion)
+		}
+		throwf("%s: %s", ErrAssertion, msg)
+	}
+}
+
+func assertEq(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var got, want eval.Value
+	eval.ScanArgs(args, &got, &want)
+	eval.TakeNoOpt(opts)
+
+	if !eval.DeepEq(got, want) {
+		throwf("%s: got %s, want %s", ErrAssertion, got.Repr(eval.NoPretty), want.Repr(eval.NoPretty))
+	}
+}
+
+func assertThrows(ec *eval.EvalCtx, args []eval.Value, opts map[string]eval.Value) {
+	var f eval.CallableValue
+	eval.ScanArgs(args, &f)
+	eval.TakeNoOpt(opts)
+
+	if ec.PCall(f, nil, eval.NoOpts) == nil {
+		util.Throw(ErrNoThrow)
+	}
+}
+
+func throwf(format string, args ...interface{}) {
+	util.Throw(fmt.Errorf(format, args...))
+}
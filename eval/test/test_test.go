@@ -0,0 +1,63 @@
+package test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+)
+
+var src = `use test
+test:test pass { test:assert-eq (+ 1 1) 2 }
+test:group g {
+	test:test fail { test:assert $false &msg=oops }
+	test:test throws { test:assert-throws { fail x } }
+}
+test:test no-throw { test:assert-throws { nop } }
+`
+
+func TestSuite(t *testing.T) {
+	s := NewSuite()
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "",
+		map[string]eval.Namespace{"test": s.Namespace()})
+	ev.Daemon = nil
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outCh := make(chan eval.Value, 1)
+	ports := []*eval.Port{
+		eval.DevNullClosedChan,
+		{File: w, Chan: outCh},
+		{File: w, Chan: eval.BlackholeChan},
+	}
+	err = ev.SourceTextWithPorts(ports, "[test]", src)
+	w.Close()
+	if err != nil {
+		t.Errorf("source errors: %v", err)
+	}
+	var out bytes.Buffer
+	out.ReadFrom(r)
+
+	for _, want := range []string{
+		"PASS pass\n", "FAIL g/fail\n", "assertion failed: oops",
+		"PASS g/throws\n", "FAIL no-throw\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q does not contain %q", out.String(), want)
+		}
+	}
+
+	var summary bytes.Buffer
+	s.Summarize(&summary)
+	wantSummary := "FAIL g/fail\nFAIL no-throw\n2 passed, 2 failed\n"
+	if summary.String() != wantSummary {
+		t.Errorf("summary = %q, want %q", summary.String(), wantSummary)
+	}
+	if !s.Failed() {
+		t.Errorf("Failed() = false, want true")
+	}
+}
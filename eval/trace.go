@@ -0,0 +1,82 @@
+package eval
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+
+	"github.com/elves/elvish/parse"
+)
+
+// Tracing of command execution. When $trace is true, each command is written
+// to stderr before it runs, with its arguments, options and redirections fully
+// evaluated. Like other options, $trace can be set for a block only with
+// "local:trace = $true".
+
+// traceLine formats a command for tracing.
+func traceLine(head Callable, args []Value, opts map[string]Value, redirs []string) string {
+	var b bytes.Buffer
+	b.WriteString("+ ")
+	b.WriteString(traceHead(head))
+	for _, arg := range args {
+		b.WriteByte(' ')
+		b.WriteString(traceValue(arg))
+	}
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(" &" + parse.Quote(k) + "=" + traceValue(opts[k]))
+	}
+	for _, redir := range redirs {
+		b.WriteByte(' ')
+		b.WriteString(redir)
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func traceHead(head Callable) string {
+	switch head := head.(type) {
+	case ExternalCmd:
+		return parse.Quote(head.Name)
+	case *BuiltinFn:
+		return parse.Quote(head.Name)
+	case *Closure:
+		if head.Name != "" {
+			return parse.Quote(head.Name)
+		}
+	}
+	if v, ok := head.(Value); ok {
+		return v.Repr(NoPretty)
+	}
+	return "<callable>"
+}
+
+func traceValue(v Value) string {
+	if s, ok := v.(String); ok {
+		return parse.Quote(string(s))
+	}
+	return v.Repr(NoPretty)
+}
+
+// traceRedir formats an evaluated redirection for tracing.
+func traceRedir(dst int, mode parse.RedirMode, sourceIsFd bool, src Value) string {
+	var op string
+	switch mode {
+	case parse.Read:
+		op = "<"
+	case parse.Write:
+		op = ">"
+	case parse.ReadWrite:
+		op = "<>"
+	case parse.Append:
+		op = ">>"
+	}
+	if sourceIsFd {
+		return strconv.Itoa(dst) + op + "&" + ToString(src)
+	}
+	return strconv.Itoa(dst) + op + traceValue(src)
+}
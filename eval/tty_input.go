@@ -0,0 +1,16 @@
+package eval
+
+import (
+	"os"
+
+	"github.com/elves/elvish/sys"
+)
+
+// isTerminal returns whether f is a terminal. Only character devices are
+// checked with sys.IsATTY, since getting the fd of a file puts it into
+// blocking mode.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 &&
+		sys.IsATTY(int(f.Fd()))
+}
@@ -0,0 +1,55 @@
+package eval
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/kr/pty"
+)
+
+func TestTerminalInputIsNotOverread(t *testing.T) {
+	master, tty, err := pty.Open()
+	if err != nil {
+		t.Skip("cannot open pty:", err)
+	}
+	defer master.Close()
+	defer tty.Close()
+
+	ev := NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+	ev.Daemon = nil
+	master.Write([]byte("a\nb\nc\nd\n"))
+
+	for _, test := range []struct {
+		code string
+		want []Value
+	}{
+		{"take 1", strs("a")},
+		{"each [x]{ put $x; break }", strs("b")},
+	} {
+		outCh := make(chan Value, 10)
+		ports := []*Port{
+			{File: tty, Chan: ClosedChan},
+			{File: os.Stdout, Chan: outCh},
+			{File: os.Stderr, Chan: BlackholeChan},
+		}
+		if err := ev.SourceTextWithPorts(ports, "[test]", test.code); err != nil {
+			t.Errorf("%s => error %v", test.code, err)
+		}
+		close(outCh)
+		var outs []Value
+		for v := range outCh {
+			outs = append(outs, v)
+		}
+		if !reflect.DeepEqual(outs, test.want) {
+			t.Errorf("%s outputs %v, want %v", test.code, outs, test.want)
+		}
+	}
+	// The rest of the input is left on the terminal.
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if line != "c\n" {
+		t.Errorf("read %q (error %v) after the evaluations, want %q", line, err, "c\n")
+	}
+}
@@ -0,0 +1,87 @@
+// +build !windows
+
+package eval
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+
+	"github.com/elves/elvish/sys"
+)
+
+// ttyLinesToChan is like linesToChan, but for terminals, which are shared
+// with the editor. To not consume input meant for someone else, it only reads
+// a line when the previous one has been processed, as signaled by more, and
+// stops as soon as stop is closed, even while waiting for input. It relies on
+// the terminal delivering input one line at a time.
+func ttyLinesToChan(file *os.File, ch chan<- Value, more, stop <-chan struct{}) {
+	rCtrl, wCtrl, err := os.Pipe()
+	if err != nil {
+		logger.Println("cannot create pipe:", err)
+		return
+	}
+	defer rCtrl.Close()
+	defer wCtrl.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-stop:
+			wCtrl.Write([]byte{'q'})
+		case <-done:
+		}
+	}()
+
+	fd, cfd := int(file.Fd()), int(rCtrl.Fd())
+	maxfd := fd
+	if cfd > maxfd {
+		maxfd = cfd
+	}
+	fs := sys.NewFdSet()
+	var buf []byte
+	chunk := make([]byte, 4096)
+	eof := false
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-more:
+			case <-stop:
+				return
+			}
+		}
+		for bytes.IndexByte(buf, '\n') == -1 && !eof {
+			fs.Zero()
+			fs.Set(fd, cfd)
+			err := sys.Select(maxfd+1, fs, nil, nil, nil)
+			if err == syscall.EINTR {
+				continue
+			} else if err != nil {
+				logger.Println("error on waiting for input:", err)
+				return
+			}
+			if fs.IsSet(cfd) {
+				return
+			}
+			n, err := file.Read(chunk)
+			buf = append(buf, chunk[:n]...)
+			eof = n == 0 || err != nil
+		}
+		var line []byte
+		if i := bytes.IndexByte(buf, '\n'); i != -1 {
+			line, buf = buf[:i], buf[i+1:]
+		} else if len(buf) > 0 {
+			line, buf = buf, nil
+		} else {
+			return
+		}
+		select {
+		case ch <- String(line):
+		case <-stop:
+			return
+		}
+		if eof && len(buf) == 0 {
+			return
+		}
+	}
+}
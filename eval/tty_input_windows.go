@@ -0,0 +1,35 @@
+package eval
+
+import (
+	"bytes"
+	"os"
+)
+
+// ttyLinesToChan is like linesToChan, but for consoles, which are shared with
+// the editor. To not consume input meant for someone else, it only reads a
+// line when the previous one has been processed, as signaled by more. A
+// console in line input mode returns at most one line from each read. Unlike
+// on Unix, a read that is waiting for input can't be stopped, so stop only
+// takes effect after the next line arrives.
+func ttyLinesToChan(file *os.File, ch chan<- Value, more, stop <-chan struct{}) {
+	chunk := make([]byte, 4096)
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-more:
+			case <-stop:
+				return
+			}
+		}
+		n, err := file.Read(chunk)
+		if n == 0 || err != nil {
+			return
+		}
+		line := bytes.TrimRight(chunk[:n], "\r\n")
+		select {
+		case ch <- String(line):
+		case <-stop:
+			return
+		}
+	}
+}
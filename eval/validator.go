@@ -7,6 +7,8 @@ var (
 	errShouldBeMap  = errors.New("should be map")
 	errShouldBeFn   = errors.New("should be function")
 	errShouldBeBool = errors.New("should be bool")
+
+	errShouldBePositiveInt = errors.New("should be positive integer")
 )
 
 func ShouldBeList(v Value) error {
@@ -36,3 +38,10 @@ func ShouldBeBool(v Value) error {
 	}
 	return nil
 }
+
+func ShouldBePositiveInt(v Value) error {
+	if n, err := toInt(v); err != nil || n <= 0 {
+		return errShouldBePositiveInt
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math/big"
 	"reflect"
 
@@ -116,6 +117,13 @@ type IndexOneer interface {
 	IndexOne(idx Value) Value
 }
 
+// Assocer is an immutable Value that can make a copy of itself with one
+// element set to a different Value.
+type Assocer interface {
+	IndexOneer
+	Assoc(idx Value, v Value) Value
+}
+
 // IndexSetter is a Value whose elements can be get as well as set.
 type IndexSetter interface {
 	IndexOneer
@@ -179,6 +187,10 @@ func (b Bool) Bool() bool {
 	return bool(b)
 }
 
+func (b Bool) Less(other Value) bool {
+	return !bool(b) && ToBool(other)
+}
+
 // ToBool converts a Value to bool. When the Value type implements Bool(), it
 // is used. Otherwise it is considered true.
 func ToBool(v Value) bool {
@@ -208,6 +220,22 @@ func (r Rat) String() string {
 	return r.b.String()
 }
 
+func (r Rat) Equal(other Value) bool {
+	r2, ok := other.(Rat)
+	return ok && r.b.Cmp(r2.b) == 0
+}
+
+func (r Rat) Hash() uint32 {
+	return hashString(r.b.String())
+}
+
+func (r Rat) Less(other Value) bool {
+	if r2, ok := other.(Rat); ok {
+		return r.b.Cmp(r2.b) < 0
+	}
+	return r.String() < ToString(other)
+}
+
 // ToRat converts a Value to rat. A str can be converted to a rat if it can be
 // parsed. A rat is returned as-is. Other types of values cannot be converted.
 func ToRat(v Value) (Rat, error) {
@@ -252,14 +280,90 @@ func FromJSONInterface(v interface{}) Value {
 		for k, v := range m {
 			mv[String(k)] = FromJSONInterface(v)
 		}
-		return Map{&mv}
+		return NewMap(mv)
 	default:
 		throw(fmt.Errorf("unexpected json type: %T", v))
 		return nil // not reached
 	}
 }
 
-// DeepEq compares two Value's deeply.
+// Equaler is a Value that knows whether it is equal to another Value. Values
+// that are not Equaler's are compared with ==.
+type Equaler interface {
+	Equal(other Value) bool
+}
+
+// Hasher is a Value with a hash. Values that are equal must have the same
+// hash.
+type Hasher interface {
+	Hash() uint32
+}
+
+// Lesser is a Value that can be ordered against other Values of the same
+// kind.
+type Lesser interface {
+	Less(other Value) bool
+}
+
+// Eq determines whether two Values are equal. Lists and maps are compared
+// deeply.
+func Eq(a, b Value) bool {
+	if eq, ok := a.(Equaler); ok {
+		return eq.Equal(b)
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if !reflect.TypeOf(a).Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+// DeepEq compares two Value's deeply. It is the same as Eq.
 func DeepEq(a, b Value) bool {
-	return reflect.DeepEqual(a, b)
+	return Eq(a, b)
+}
+
+// Hash returns the hash of a Value. Values that are not Hasher's are hashed
+// by their kinds and representations.
+func Hash(v Value) uint32 {
+	if h, ok := v.(Hasher); ok {
+		return h.Hash()
+	}
+	return hashString(v.Kind() + " " + v.Repr(NoPretty))
+}
+
+func hashString(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// kindOrder determines the order between Values of different kinds. Kinds not
+// listed here come after all the listed ones, ordered by their names.
+var kindOrder = map[string]int{
+	"bool": 1, "string": 2, "list": 3, "map": 4,
+}
+
+// Less determines whether a comes before b in the total order of Values.
+// Values of different kinds are ordered by their kinds; bools, strings, rats
+// and lists have a natural order, and other Values of the same kind are
+// ordered by their representations.
+func Less(a, b Value) bool {
+	ka, kb := a.Kind(), b.Kind()
+	if ka != kb {
+		oa, ob := kindOrder[ka], kindOrder[kb]
+		if oa == 0 || ob == 0 {
+			if oa != ob {
+				return ob == 0
+			}
+			return ka < kb
+		}
+		return oa < ob
+	}
+	if l, ok := a.(Lesser); ok {
+		return l.Less(b)
+	}
+	return a.Repr(NoPretty) < b.Repr(NoPretty)
 }
@@ -26,8 +26,8 @@ var reprTests = []struct {
 	{&Exception{Return, nil}, "?(return)"},
 	{NewList(), "[]"},
 	{NewList(String("bash"), Bool(false)), "[bash $false]"},
-	{Map{&map[Value]Value{}}, "[&]"},
-	{Map{&map[Value]Value{&Exception{nil, nil}: String("elvish")}}, "[&$ok=elvish]"},
+	{NewMap(map[Value]Value{}), "[&]"},
+	{NewMap(map[Value]Value{&Exception{nil, nil}: String("elvish")}), "[&$ok=elvish]"},
 	// TODO: test maps of more elements
 }
 
@@ -40,6 +40,38 @@ func TestRepr(t *testing.T) {
 	}
 }
 
+// reprRoundTripValues are values whose representations must evaluate back to
+// them.
+var reprRoundTripValues = []Value{
+	String(""), String("a"), String("foo bar"), String("a\nb\t\x01\x7f"),
+	String("é世界"), String("\xff"), String("a=b"), String("a="), String("="),
+	String("a:b"), String("~a"), String("a~"), String("*"), String("?"),
+	String("[a]"), String("a]"), String("&"), String("a&b"), String("$x"),
+	String("#"), String("a#"), String("'"), String(`"`), String(`\`),
+	String("{a,b}"), String("(x)"), String("a|b"), String("a;b"),
+	String("<a"), String(">a"), String("@a"), String("^a"), String("-"),
+	String("--a"), String("%"), String("+1"), String("1.5e3"),
+	Bool(true), Bool(false),
+	NewList(), NewList(String("a"), NewList(String("b c")), NewList()),
+	NewMap(map[Value]Value{}),
+	NewMap(map[Value]Value{
+		String("k"): String("v"), String("a b"): NewList(String("=")),
+		String("="): NewMap(map[Value]Value{String(""): Bool(true)})}),
+}
+
+func TestReprRoundTrip(t *testing.T) {
+	for _, v := range reprRoundTripValues {
+		for _, indent := range []int{NoPretty, 0} {
+			repr := v.Repr(indent)
+			outs, _, err := evalAndCollect(t, []string{"put " + repr}, 1)
+			if err != nil || len(outs) != 1 || !DeepEq(outs[0], v) {
+				t.Errorf("put %s => %v, %v; want %s", repr, outs, err,
+					v.Repr(NoPretty))
+			}
+		}
+	}
+}
+
 var stringToSegmentsTests = []struct {
 	s    string
 	want []glob.Segment
@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"os"
+	"sync"
 )
 
 var (
@@ -99,28 +100,150 @@ func (cv roCbVariable) Get() Value {
 	return cv()
 }
 
-// elemVariable is an element of a IndexSetter.
+// elemVariable is an element of a container in a variable, possibly nested in
+// other containers. The value of the variable is indexed with each of indicies
+// in turn to reach the element.
+//
+// Setting an elemVariable modifies IndexSetter containers in place. Immutable
+// Assocer containers are replaced by modified copies instead, which are in
+// turn set in their own containers, up to the variable if needed.
 type elemVariable struct {
-	container IndexSetter
-	index     Value
+	variable Variable
+	indicies []Value
+}
+
+// containers returns the containers along the way to the element.
+func (ev elemVariable) containers() []Value {
+	containers := make([]Value, len(ev.indicies))
+	value := ev.variable.Get()
+	for i, index := range ev.indicies {
+		containers[i] = value
+		if i == len(ev.indicies)-1 {
+			break
+		}
+		indexOneer, ok := value.(IndexOneer)
+		if !ok {
+			throwf("cannot be indexed (value is %s, type %s)",
+				value.Repr(NoPretty), value.Kind())
+		}
+		value = indexOneer.IndexOne(index)
+	}
+	return containers
 }
 
 func (ev elemVariable) Set(val Value) {
-	ev.container.IndexSet(ev.index, val)
+	containers := ev.containers()
+	for i := len(containers) - 1; i >= 0; i-- {
+		switch container := containers[i].(type) {
+		case Assocer:
+			val = container.Assoc(ev.indicies[i], val)
+		case IndexSetter:
+			container.IndexSet(ev.indicies[i], val)
+			return
+		default:
+			throwf("cannot be indexed for setting (value is %s, type %s)",
+				container.Repr(NoPretty), container.Kind())
+		}
+	}
+	ev.variable.Set(val)
 }
 
 func (ev elemVariable) Get() Value {
-	return ev.container.IndexOne(ev.index)
+	containers := ev.containers()
+	n := len(containers)
+	return containers[n-1].(IndexOneer).IndexOne(ev.indicies[n-1])
 }
 
+// envVariable is an environment variable. Its value in overlay, if any,
+// shadows that in the environment of the process, both when getting and
+// setting it.
 type envVariable struct {
-	name string
+	name    string
+	overlay *envOverlay
 }
 
 func (ev envVariable) Set(val Value) {
-	os.Setenv(ev.name, ToString(val))
+	if !ev.overlay.set(ev.name, ToString(val)) {
+		os.Setenv(ev.name, ToString(val))
+	}
 }
 
 func (ev envVariable) Get() Value {
+	if val, ok := ev.overlay.get(ev.name); ok {
+		return String(val)
+	}
 	return String(os.Getenv(ev.name))
 }
+
+// envOverlay holds the environment variables set by temporary assignments to
+// E: variables, and those of them that have since been deleted. It is shared
+// between forks, so that changing one of the variables is seen by the
+// following forms in the same scope. A nil *envOverlay is empty.
+type envOverlay struct {
+	mutex   sync.RWMutex
+	values  map[string]string
+	deleted map[string]bool
+}
+
+func newEnvOverlay() *envOverlay {
+	return &envOverlay{values: map[string]string{}, deleted: map[string]bool{}}
+}
+
+// get returns the value of a variable and whether it is in the overlay. A
+// deleted variable is in the overlay, and its value is empty.
+func (o *envOverlay) get(name string) (string, bool) {
+	if o == nil {
+		return "", false
+	}
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	val, ok := o.values[name]
+	return val, ok || o.deleted[name]
+}
+
+// set sets a variable if it is in the overlay, and returns whether it is.
+func (o *envOverlay) set(name, val string) bool {
+	if o == nil {
+		return false
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if _, ok := o.values[name]; !ok && !o.deleted[name] {
+		return false
+	}
+	o.values[name] = val
+	delete(o.deleted, name)
+	return true
+}
+
+// del deletes a variable if it is in the overlay, and returns whether it is.
+func (o *envOverlay) del(name string) bool {
+	if o == nil {
+		return false
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if _, ok := o.values[name]; !ok && !o.deleted[name] {
+		return false
+	}
+	delete(o.values, name)
+	o.deleted[name] = true
+	return true
+}
+
+// copy returns a copy of the overlay that is never nil.
+func (o *envOverlay) copy() *envOverlay {
+	c := newEnvOverlay()
+	if o == nil {
+		return c
+	}
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	for name, val := range o.values {
+		c.values[name] = val
+	}
+	for name := range o.deleted {
+		c.deleted[name] = true
+	}
+	return c
+}
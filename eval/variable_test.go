@@ -77,7 +77,7 @@ func TestRoCbVariable(t *testing.T) {
 
 func TestEnvVariable(t *testing.T) {
 	name := "elvish_test"
-	v := envVariable{name}
+	v := envVariable{name, nil}
 	os.Setenv(name, "foo")
 	if v.Get() != String("foo") {
 		t.Errorf("envVariable.Get doesn't return env value")
@@ -86,4 +86,15 @@ func TestEnvVariable(t *testing.T) {
 	if os.Getenv(name) != "bar" {
 		t.Errorf("envVariable.Set doesn't alter env value")
 	}
+	v = envVariable{name, &envOverlay{values: map[string]string{name: "overlay"}}}
+	if v.Get() != String("overlay") {
+		t.Errorf("envVariable.Get doesn't return overlay value")
+	}
+	v.Set(String("new"))
+	if v.Get() != String("new") {
+		t.Errorf("envVariable.Get doesn't return value set in overlay")
+	}
+	if os.Getenv(name) != "bar" {
+		t.Errorf("envVariable.Set alters env value shadowed by overlay")
+	}
 }
@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileStamp is what watch looks at to decide whether a file has changed.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+}
+
+// snapshot stamps the files at paths, descending into directories. Files that
+// cannot be accessed are left out, so that their creation counts as a change.
+func snapshot(paths []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, path := range paths {
+		filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err == nil {
+				stamps[p] = fileStamp{info.ModTime(), info.Size(), info.Mode()}
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for p, stamp := range a {
+		if other, ok := b[p]; !ok || !other.modTime.Equal(stamp.modTime) ||
+			other.size != stamp.size || other.mode != stamp.mode {
+			return false
+		}
+	}
+	return true
+}
+
+// watch calls f, and calls it again whenever a file in paths changes, until f
+// breaks or returns or the user interrupts. As in retry and other looping
+// builtins, a break only stops watching and a continue waits for the next
+// change, while a return is thrown on, so that it also returns from the
+// enclosing function. Directories are watched recursively. Files are polled every
+// &interval, and after a change, f is only called once they have stayed
+// unchanged for &debounce, so that a burst of writes causes a single call.
+// Other exceptions from f are printed and don't stop watching.
+func watch(ec *EvalCtx, args []Value, opts map[string]Value) {
+	var (
+		paths []String
+		f     CallableValue
+	)
+	if len(args) < 2 {
+		throw(ErrArgs)
+	}
+	ScanArgsVariadic(args[:len(args)-1], &paths)
+	ScanArgs(args[len(args)-1:], &f)
+	var intervalOpt, debounceOpt Value
+	ScanOpts(opts, Opt{"interval", &intervalOpt, String("0.5")},
+		Opt{"debounce", &debounceOpt, String("0.1")})
+	interval, debounce := toDuration(intervalOpt), toDuration(debounceOpt)
+	if interval <= 0 {
+		throw(ErrBadDuration)
+	}
+
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = string(path)
+	}
+
+	// wait waits for d, and reports whether the user has interrupted.
+	wait := func(d time.Duration) bool {
+		select {
+		case <-ec.Interrupts():
+			return true
+		case <-time.After(d):
+			return false
+		}
+	}
+
+	stamps := snapshot(names)
+	for {
+		err := ec.PCall(f, NoArgs, NoOpts)
+		if err != nil {
+			switch err.(*Exception).Cause {
+			case Break, ErrInterrupted:
+				return
+			case Return:
+				throw(err)
+			case Continue:
+			default:
+				ec.ports[2].File.WriteString(err.(*Exception).Pprint("") + "\n")
+			}
+		}
+		// Changes made by f itself also count, so that a block that writes
+		// to a watched file is run again.
+		for {
+			if wait(interval) {
+				return
+			}
+			if newStamps := snapshot(names); !sameSnapshot(stamps, newStamps) {
+				stamps = newStamps
+				break
+			}
+		}
+		for {
+			if wait(debounce) {
+				return
+			}
+			newStamps := snapshot(names)
+			if sameSnapshot(stamps, newStamps) {
+				break
+			}
+			stamps = newStamps
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elves/elvish/parse"
+)
+
+// commandCandidate is one way a command name can resolve.
+type commandCandidate struct {
+	kind string
+	// Where the candidate is found, such as the scope or the path of an
+	// external command.
+	where string
+}
+
+func (c commandCandidate) String() string {
+	if c.where == "" {
+		return c.kind
+	}
+	return c.kind + " " + c.where
+}
+
+// commandCandidates returns all the ways a command name can resolve, in the
+// order they are tried. The first candidate is the one that is used; the
+// remaining ones are shadowed by it.
+func commandCandidates(ec *EvalCtx, name string) []commandCandidate {
+	var candidates []commandCandidate
+	explode, ns, bare := ParseAndFixVariable(name)
+	if explode {
+		return nil
+	}
+
+	if ns == "" {
+		if _, ok := builtinSpecials[name]; ok {
+			candidates = append(candidates, commandCandidate{"special form", ""})
+		}
+	}
+
+	fnCandidate := func(v Variable, where string) {
+		if v == nil {
+			return
+		}
+		switch fn := v.Get().(type) {
+		case *Closure:
+			c := commandCandidate{"function", where}
+			if fn.SourceName != "" {
+				c.where += ", defined in " + fn.Location()
+			}
+			candidates = append(candidates, c)
+		case *BuiltinFn:
+			candidates = append(candidates, commandCandidate{"builtin function", where})
+		case CallableValue:
+			candidates = append(candidates, commandCandidate{"callable " + fn.Kind(), where})
+		}
+	}
+
+	switch ns {
+	case "":
+		fnCandidate(ec.getLocal(FnPrefix+bare), "in local scope")
+		fnCandidate(ec.up[FnPrefix+bare], "in upper scope")
+		fnCandidate(ec.Builtin[FnPrefix+bare], "in builtin scope")
+	case "e":
+	default:
+		if v := ec.ResolveVar(ns, FnPrefix+bare); v != nil {
+			fnCandidate(v, "in "+ns+":")
+		}
+	}
+
+	if ns == "" || ns == "e" {
+		if path, err := ec.Search(bare); err == nil {
+			candidates = append(candidates, commandCandidate{"external command", path})
+		} else if ns == "e" || len(candidates) == 0 {
+			candidates = append(candidates, commandCandidate{"external command", "(not found)"})
+		}
+	}
+	return candidates
+}
+
+// which implements the which builtin. For each name, it writes how the name
+// resolves when used as a command, followed by the candidates it shadows.
+func which(ec *EvalCtx, args []Value, opts map[string]Value) {
+	TakeNoOpt(opts)
+	out := ec.ports[1].File
+	for _, arg := range args {
+		name := ToString(arg)
+		candidates := commandCandidates(ec, name)
+		if len(candidates) == 0 {
+			throwf("%s is not a valid command name", parse.Quote(name))
+		}
+		for i, c := range candidates {
+			if i == 0 {
+				fmt.Fprintf(out, "%s: %s\n", name, c)
+			} else {
+				fmt.Fprintf(out, "%s%s (shadowed)\n", strings.Repeat(" ", len(name)+2), c)
+			}
+		}
+	}
+}
@@ -7,12 +7,15 @@ package main
 // interface.
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime/pprof"
 	"strconv"
 	"syscall"
@@ -22,7 +25,14 @@ import (
 	"github.com/elves/elvish/daemon/api"
 	"github.com/elves/elvish/daemon/service"
 	"github.com/elves/elvish/eval"
+	"github.com/elves/elvish/eval/encoding"
+	"github.com/elves/elvish/eval/epm"
+	"github.com/elves/elvish/eval/http"
+	pathmod "github.com/elves/elvish/eval/path"
+	"github.com/elves/elvish/eval/platform"
 	"github.com/elves/elvish/eval/re"
+	"github.com/elves/elvish/eval/test"
+	"github.com/elves/elvish/parse"
 	"github.com/elves/elvish/shell"
 	"github.com/elves/elvish/store/storedefs"
 	"github.com/elves/elvish/util"
@@ -46,10 +56,19 @@ var (
 
 	isdaemon = flag.Bool("daemon", false, "run daemon instead of shell")
 	isweb    = flag.Bool("web", false, "run backend of web interface")
+	isfmt    = flag.Bool("fmt", false, "print scripts in canonical format and quit")
+	dumpAST  = flag.Bool("parse-dump", false, "print parse trees of scripts in JSON and quit")
 	webport  = flag.Int("port", defaultWebPort, "the port of the web backend")
 
 	// Flags for shell and web.
-	cmd = flag.Bool("c", false, "take first argument as a command to execute")
+	cmd     = flag.Bool("c", false, "take first argument as a command to execute")
+	cover   = flag.String("cover", "", "write coverage report of elvish code to file")
+	profile = flag.String("profile", "", "write how long each command of elvish code took to file")
+	tests   = flag.Bool("test", false, "run *_test.elv files in the given directories or the current one")
+
+	maxCPU    = flag.Duration("max-cpu", 0, "abort evaluations that use more CPU time than this")
+	maxValues = flag.Int64("max-values", 0, "abort evaluations whose expressions produce more values than this in total")
+	maxOutput = flag.Int64("max-output", 0, "abort evaluations that write more bytes than this to stdout and stderr")
 
 	// Flags for daemon.
 	forked        = flag.Int("forked", 0, "how many times the daemon has forked")
@@ -111,7 +130,15 @@ func main() {
 	}
 
 	// Pick a sub-program to run.
-	if *isdaemon {
+	if *isfmt {
+		ret = parseScripts(args, func(n *parse.Chunk) {
+			fmt.Print(parse.Fmt(n))
+		})
+	} else if *dumpAST {
+		ret = parseScripts(args, func(n *parse.Chunk) {
+			json.NewEncoder(os.Stdout).Encode(n)
+		})
+	} else if *isdaemon {
 		d := daemon.Daemon{
 			Forked:        *forked,
 			BinPath:       *binpath,
@@ -129,8 +156,14 @@ func main() {
 				fmt.Fprintln(os.Stderr, "warning: failed to close connection to daemon:", err)
 			}
 		}()
+		if *maxCPU > 0 || *maxValues > 0 || *maxOutput > 0 {
+			ev.Limits = &eval.Limits{
+				CPUTime: *maxCPU, Values: *maxValues, Output: *maxOutput}
+		}
 
-		if *isweb {
+		if *tests {
+			ret = runTests(ev, args)
+		} else if *isweb {
 			if *cmd {
 				fmt.Fprintln(os.Stderr, "-c -web not yet supported")
 				ret = 2
@@ -139,12 +172,115 @@ func main() {
 			w := web.NewWeb(ev, *webport)
 			ret = w.Run(args)
 		} else {
+			if *cover != "" {
+				f, err := os.Create(*cover)
+				if err != nil {
+					log.Fatal(err)
+				}
+				ev.StartCoverage()
+				writeCoverage := func() {
+					ev.StopCoverage(f)
+					f.Close()
+				}
+				// exit ends the process without running deferred calls.
+				ev.AtExit(writeCoverage)
+				defer writeCoverage()
+			}
+			if *profile != "" {
+				f, err := os.Create(*profile)
+				if err != nil {
+					log.Fatal(err)
+				}
+				ev.StartProfile()
+				writeProfile := func() {
+					ev.StopProfile(f)
+					f.Close()
+				}
+				ev.AtExit(writeProfile)
+				defer writeProfile()
+			}
 			sh := shell.NewShell(ev, cl, *cmd)
 			ret = sh.Run(args)
 		}
 	}
 }
 
+// runTests sources the given test files, and the *_test.elv files in the given
+// directories, and prints a summary of the tests. Each file is run in an Evaler
+// of its own, so that files don't see each other's variables. It returns the
+// exit code.
+func runTests(ev *eval.Evaler, args []string) int {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var files []string
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(arg, "*_test.elv"))
+			files = append(files, matches...)
+		} else {
+			files = append(files, arg)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test files")
+		return 2
+	}
+	suite := test.NewSuite()
+	for _, fname := range files {
+		err := newTestEvaler(ev, suite).Source(fname)
+		if err != nil {
+			suite.Record(fname, err)
+			if p, ok := err.(util.Pprinter); ok {
+				fmt.Println(p.Pprint(""))
+			} else {
+				fmt.Println(err)
+			}
+		}
+	}
+	suite.Summarize(os.Stdout)
+	if suite.Failed() {
+		return 1
+	}
+	return 0
+}
+
+// newTestEvaler creates an Evaler for running one test file. It is set up like
+// ev, but the test module records results to the given suite.
+func newTestEvaler(ev *eval.Evaler, suite *test.Suite) *eval.Evaler {
+	modules := extraModules()
+	modules["test"] = suite.Namespace()
+	fileEv := eval.NewEvaler(ev.Daemon, ev.ToSpawn, ev.DataDir, modules)
+	fileEv.Limits = ev.Limits
+	return fileEv
+}
+
+// parseScripts parses the given scripts, or stdin when no script is given, and
+// calls f with each successfully parsed script. Errors are printed to stderr.
+// It returns the exit code.
+func parseScripts(args []string, f func(*parse.Chunk)) int {
+	if len(args) == 0 {
+		args = []string{"/dev/stdin"}
+	}
+	ret := 0
+	for _, fname := range args {
+		src, err := ioutil.ReadFile(fname)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ret = 1
+			continue
+		}
+		n, err := parse.Parse(fname, string(src))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.(*parse.Error).Pprint(""))
+			ret = 1
+			continue
+		}
+		f(n)
+	}
+	return ret
+}
+
 const (
 	daemonWaitOneLoop = 10 * time.Millisecond
 	daemonWaitLoops   = 100
@@ -234,12 +370,27 @@ func initRuntime() (*eval.Evaler, *api.Client) {
 		}
 	}
 spawnDaemonEnd:
+	if cl != nil {
+		// Restart the daemon on demand if it goes away later.
+		cl.SetSpawner(toSpawn.Spawn)
+	}
+
+	return eval.NewEvaler(cl, toSpawn, dataDir, extraModules()), cl
+}
 
+// extraModules returns the native modules that are not part of the eval
+// package.
+func extraModules() map[string]eval.Namespace {
 	// TODO(xiaq): This information might belong somewhere else.
-	extraModules := map[string]eval.Namespace{
-		"re": re.Namespace(),
+	return map[string]eval.Namespace{
+		"re":       re.Namespace(),
+		"path":     pathmod.Namespace(),
+		"platform": platform.Namespace(),
+		"encoding": encoding.Namespace(),
+		"epm":      epm.Namespace(),
+		"http":     http.Namespace(),
+		"test":     test.NewSuite().Namespace(),
 	}
-	return eval.NewEvaler(cl, toSpawn, dataDir, extraModules), cl
 }
 
 var (
@@ -1,7 +1,47 @@
 package main
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elves/elvish/daemon/api"
+	"github.com/elves/elvish/eval"
+)
 
 func TestMain(t *testing.T) {
 	// TODO(xiaq): Add tests.
 }
+
+func TestRunTests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elvish-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev := eval.NewEvaler(api.NewClient("/invalid"), nil, "", nil)
+
+	write("a_test.elv", "use test; x = a; test:test a { test:assert $false }")
+	if ret := runTests(ev, []string{dir}); ret != 1 {
+		t.Errorf("runTests with a failing test => %d, want 1", ret)
+	}
+
+	// Results of earlier runs don't count.
+	write("a_test.elv", "use test; x = a; test:test a { }")
+	if ret := runTests(ev, []string{dir}); ret != 0 {
+		t.Errorf("runTests with a passing test => %d, want 0", ret)
+	}
+
+	// Variables of a test file are not visible to other files.
+	write("b_test.elv", "put $x")
+	if ret := runTests(ev, []string{dir}); ret != 1 {
+		t.Errorf("runTests with a file using another's variable => %d, want 1", ret)
+	}
+}
@@ -12,13 +12,27 @@ func GetChunk(n Node) *Chunk {
 	return nil
 }
 
+func (n *Chunk) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newChunk() *Chunk {
+	if len(a.chunkSlab) == 0 {
+		a.chunkSlab = make([]Chunk, arenaSlabSize)
+	}
+	n := &a.chunkSlab[0]
+	a.chunkSlab = a.chunkSlab[1:]
+	return n
+}
+
 func (n *Chunk) addToPipelines(ch *Pipeline) {
 	n.Pipelines = append(n.Pipelines, ch)
 	addChild(n, ch)
 }
 
 func ParseChunk(ps *Parser) *Chunk {
-	n := &Chunk{node: node{begin: ps.pos}}
+	n := ps.arena.newChunk()
+	n.begin = ps.pos
 	n.parse(ps)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -37,13 +51,27 @@ func GetPipeline(n Node) *Pipeline {
 	return nil
 }
 
+func (n *Pipeline) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newPipeline() *Pipeline {
+	if len(a.pipelineSlab) == 0 {
+		a.pipelineSlab = make([]Pipeline, arenaSlabSize)
+	}
+	n := &a.pipelineSlab[0]
+	a.pipelineSlab = a.pipelineSlab[1:]
+	return n
+}
+
 func (n *Pipeline) addToForms(ch *Form) {
 	n.Forms = append(n.Forms, ch)
 	addChild(n, ch)
 }
 
 func ParsePipeline(ps *Parser) *Pipeline {
-	n := &Pipeline{node: node{begin: ps.pos}}
+	n := ps.arena.newPipeline()
+	n.begin = ps.pos
 	n.parse(ps)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -62,6 +90,19 @@ func GetForm(n Node) *Form {
 	return nil
 }
 
+func (n *Form) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newForm() *Form {
+	if len(a.formSlab) == 0 {
+		a.formSlab = make([]Form, arenaSlabSize)
+	}
+	n := &a.formSlab[0]
+	a.formSlab = a.formSlab[1:]
+	return n
+}
+
 func (n *Form) addToAssignments(ch *Assignment) {
 	n.Assignments = append(n.Assignments, ch)
 	addChild(n, ch)
@@ -98,7 +139,8 @@ func (n *Form) setExitusRedir(ch *ExitusRedir) {
 }
 
 func ParseForm(ps *Parser) *Form {
-	n := &Form{node: node{begin: ps.pos}}
+	n := ps.arena.newForm()
+	n.begin = ps.pos
 	n.parse(ps)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -117,6 +159,19 @@ func GetAssignment(n Node) *Assignment {
 	return nil
 }
 
+func (n *Assignment) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newAssignment() *Assignment {
+	if len(a.assignmentSlab) == 0 {
+		a.assignmentSlab = make([]Assignment, arenaSlabSize)
+	}
+	n := &a.assignmentSlab[0]
+	a.assignmentSlab = a.assignmentSlab[1:]
+	return n
+}
+
 func (n *Assignment) setLeft(ch *Indexing) {
 	n.Left = ch
 	addChild(n, ch)
@@ -128,7 +183,8 @@ func (n *Assignment) setRight(ch *Compound) {
 }
 
 func ParseAssignment(ps *Parser) *Assignment {
-	n := &Assignment{node: node{begin: ps.pos}}
+	n := ps.arena.newAssignment()
+	n.begin = ps.pos
 	n.parse(ps)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -147,13 +203,27 @@ func GetExitusRedir(n Node) *ExitusRedir {
 	return nil
 }
 
+func (n *ExitusRedir) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newExitusRedir() *ExitusRedir {
+	if len(a.exitusRedirSlab) == 0 {
+		a.exitusRedirSlab = make([]ExitusRedir, arenaSlabSize)
+	}
+	n := &a.exitusRedirSlab[0]
+	a.exitusRedirSlab = a.exitusRedirSlab[1:]
+	return n
+}
+
 func (n *ExitusRedir) setDest(ch *Compound) {
 	n.Dest = ch
 	addChild(n, ch)
 }
 
 func ParseExitusRedir(ps *Parser) *ExitusRedir {
-	n := &ExitusRedir{node: node{begin: ps.pos}}
+	n := ps.arena.newExitusRedir()
+	n.begin = ps.pos
 	n.parse(ps)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -172,6 +242,19 @@ func GetRedir(n Node) *Redir {
 	return nil
 }
 
+func (n *Redir) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newRedir() *Redir {
+	if len(a.redirSlab) == 0 {
+		a.redirSlab = make([]Redir, arenaSlabSize)
+	}
+	n := &a.redirSlab[0]
+	a.redirSlab = a.redirSlab[1:]
+	return n
+}
+
 func (n *Redir) setLeft(ch *Compound) {
 	n.Left = ch
 	addChild(n, ch)
@@ -183,7 +266,8 @@ func (n *Redir) setRight(ch *Compound) {
 }
 
 func ParseRedir(ps *Parser, dest *Compound) *Redir {
-	n := &Redir{node: node{begin: ps.pos}}
+	n := ps.arena.newRedir()
+	n.begin = ps.pos
 	n.parse(ps, dest)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -202,13 +286,27 @@ func GetCompound(n Node) *Compound {
 	return nil
 }
 
+func (n *Compound) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newCompound() *Compound {
+	if len(a.compoundSlab) == 0 {
+		a.compoundSlab = make([]Compound, arenaSlabSize)
+	}
+	n := &a.compoundSlab[0]
+	a.compoundSlab = a.compoundSlab[1:]
+	return n
+}
+
 func (n *Compound) addToIndexings(ch *Indexing) {
 	n.Indexings = append(n.Indexings, ch)
 	addChild(n, ch)
 }
 
 func ParseCompound(ps *Parser, head bool) *Compound {
-	n := &Compound{node: node{begin: ps.pos}}
+	n := ps.arena.newCompound()
+	n.begin = ps.pos
 	n.parse(ps, head)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -227,6 +325,19 @@ func GetIndexing(n Node) *Indexing {
 	return nil
 }
 
+func (n *Indexing) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newIndexing() *Indexing {
+	if len(a.indexingSlab) == 0 {
+		a.indexingSlab = make([]Indexing, arenaSlabSize)
+	}
+	n := &a.indexingSlab[0]
+	a.indexingSlab = a.indexingSlab[1:]
+	return n
+}
+
 func (n *Indexing) setHead(ch *Primary) {
 	n.Head = ch
 	addChild(n, ch)
@@ -238,7 +349,8 @@ func (n *Indexing) addToIndicies(ch *Array) {
 }
 
 func ParseIndexing(ps *Parser, head bool) *Indexing {
-	n := &Indexing{node: node{begin: ps.pos}}
+	n := ps.arena.newIndexing()
+	n.begin = ps.pos
 	n.parse(ps, head)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -257,13 +369,27 @@ func GetArray(n Node) *Array {
 	return nil
 }
 
+func (n *Array) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newArray() *Array {
+	if len(a.arraySlab) == 0 {
+		a.arraySlab = make([]Array, arenaSlabSize)
+	}
+	n := &a.arraySlab[0]
+	a.arraySlab = a.arraySlab[1:]
+	return n
+}
+
 func (n *Array) addToCompounds(ch *Compound) {
 	n.Compounds = append(n.Compounds, ch)
 	addChild(n, ch)
 }
 
 func ParseArray(ps *Parser, allowSemicolon bool) *Array {
-	n := &Array{node: node{begin: ps.pos}}
+	n := ps.arena.newArray()
+	n.begin = ps.pos
 	n.parse(ps, allowSemicolon)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -282,6 +408,19 @@ func GetPrimary(n Node) *Primary {
 	return nil
 }
 
+func (n *Primary) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newPrimary() *Primary {
+	if len(a.primarySlab) == 0 {
+		a.primarySlab = make([]Primary, arenaSlabSize)
+	}
+	n := &a.primarySlab[0]
+	a.primarySlab = a.primarySlab[1:]
+	return n
+}
+
 func (n *Primary) setList(ch *Array) {
 	n.List = ch
 	addChild(n, ch)
@@ -303,7 +442,8 @@ func (n *Primary) addToBraced(ch *Compound) {
 }
 
 func ParsePrimary(ps *Parser, head bool) *Primary {
-	n := &Primary{node: node{begin: ps.pos}}
+	n := ps.arena.newPrimary()
+	n.begin = ps.pos
 	n.parse(ps, head)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -322,6 +462,19 @@ func GetMapPair(n Node) *MapPair {
 	return nil
 }
 
+func (n *MapPair) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newMapPair() *MapPair {
+	if len(a.mapPairSlab) == 0 {
+		a.mapPairSlab = make([]MapPair, arenaSlabSize)
+	}
+	n := &a.mapPairSlab[0]
+	a.mapPairSlab = a.mapPairSlab[1:]
+	return n
+}
+
 func (n *MapPair) setKey(ch *Compound) {
 	n.Key = ch
 	addChild(n, ch)
@@ -333,7 +486,8 @@ func (n *MapPair) setValue(ch *Compound) {
 }
 
 func ParseMapPair(ps *Parser) *MapPair {
-	n := &MapPair{node: node{begin: ps.pos}}
+	n := ps.arena.newMapPair()
+	n.begin = ps.pos
 	n.parse(ps)
 	n.end = ps.pos
 	n.sourceText = ps.src[n.begin:n.end]
@@ -351,3 +505,31 @@ func GetSep(n Node) *Sep {
 	}
 	return nil
 }
+
+func (n *Sep) MarshalJSON() ([]byte, error) {
+	return marshalNode(n)
+}
+
+func (a *arena) newSep() *Sep {
+	if len(a.sepSlab) == 0 {
+		a.sepSlab = make([]Sep, arenaSlabSize)
+	}
+	n := &a.sepSlab[0]
+	a.sepSlab = a.sepSlab[1:]
+	return n
+}
+
+type arena struct {
+	chunkSlab       []Chunk
+	pipelineSlab    []Pipeline
+	formSlab        []Form
+	assignmentSlab  []Assignment
+	exitusRedirSlab []ExitusRedir
+	redirSlab       []Redir
+	compoundSlab    []Compound
+	indexingSlab    []Indexing
+	arraySlab       []Array
+	primarySlab     []Primary
+	mapPairSlab     []MapPair
+	sepSlab         []Sep
+}
@@ -15,10 +15,17 @@ For every node type T, it generates the following:
 * For each field F of type *U where U is not a slice, it generates a setF
   method that sets this field and adds it to the children list.
 
+* A MarshalJSON method that serializes the node with marshalNode.
+
+* A newT method of arena that allocates a *T from a slab of T's.
+
 * If the type has a parse method that takes a *paser, it genertes a parseT
-  func that takes a *Parser and returns *T. The func creates a new instance of
-  *T, sets its begin field, calls its parse method, and set its end and
-  sourceText fields.
+  func that takes a *Parser and returns *T. The func allocates a new instance
+  of *T from the arena of the parser, sets its begin field, calls its parse
+  method, and set its end and sourceText fields.
+
+Finally, it generates the arena type, which has one slab field for each node
+type.
 
 For example, for the following type:
 
@@ -42,6 +49,10 @@ func GetX(n Node) *X {
     return nil
 }
 
+func (n *X) MarshalJSON() ([]byte, error) {
+    return marshalNode(n)
+}
+
 func (n *X) setF(ch *Y) {
     n.F = ch
     addChild(n, ch)
@@ -52,13 +63,27 @@ func (n *X) addToG(ch *Z) {
     addChild(n, ch)
 }
 
+func (a *arena) newX() *X {
+    if len(a.xSlab) == 0 {
+        a.xSlab = make([]X, arenaSlabSize)
+    }
+    n := &a.xSlab[0]
+    a.xSlab = a.xSlab[1:]
+    return n
+}
+
 func ParseX(ps *Parser) *X {
-    n := &X{node: node{begin: ps.pos}}
+    n := ps.arena.newX()
+    n.begin = ps.pos
     n.parse(ps)
     n.end = ps.pos
     n.sourceText = ps.src[n.begin:n.end]
     return n
 }
+
+type arena struct {
+    xSlab []X
+}
 """
 import re
 import os
@@ -84,6 +109,38 @@ func Get{typename}(n Node) *{typename} {{
 '''.format(typename=typename)
 
 
+def put_marshal(out, typename):
+    print >>out, '''
+func (n *{typename}) MarshalJSON() ([]byte, error) {{
+    return marshalNode(n)
+}}
+'''.format(typename=typename)
+
+
+def slab_name(typename):
+    return typename[0].lower() + typename[1:] + 'Slab'
+
+
+def put_new(out, typename):
+    print >>out, '''
+func (a *arena) new{typename}() *{typename} {{
+    if len(a.{slab}) == 0 {{
+        a.{slab} = make([]{typename}, arenaSlabSize)
+    }}
+    n := &a.{slab}[0]
+    a.{slab} = a.{slab}[1:]
+    return n
+}}
+'''.format(typename=typename, slab=slab_name(typename))
+
+
+def put_arena(out, types):
+    print >>out, 'type arena struct {'
+    for typename in types:
+        print >>out, '%s []%s' % (slab_name(typename), typename)
+    print >>out, '}'
+
+
 def put_set(out, parent, field, child):
     print >>out, '''
 func (n *{parent}) set{field}(ch *{child}) {{
@@ -104,7 +161,8 @@ def put_parse(out, typename, extraargs):
     extranames = ', '.join(a.split(' ')[0] for a in extraargs.split(', ')) if extraargs else ''
     print >>out, '''
 func Parse{typename}(ps *Parser{extraargs}) *{typename} {{
-    n := &{typename}{{node: node{{begin: ps.pos}}}}
+    n := ps.arena.new{typename}()
+    n.begin = ps.pos
     n.parse(ps{extranames})
     n.end = ps.pos
     n.sourceText = ps.src[n.begin:n.end]
@@ -138,12 +196,16 @@ def main():
             in_type = m.group(1)
             put_is(out, in_type)
             put_get(out, in_type)
+            put_marshal(out, in_type)
+            put_new(out, in_type)
+            types.append(in_type)
             continue
         m = re.match(
             r'^func \(.* \*(.*)\) parse\(ps \*Parser(.*?)\) {$', line)
         if m:
             typename, extraargs = m.groups()
             put_parse(out, typename, extraargs)
+    put_arena(out, types)
     out.close()
     os.system('gofmt -w boilerplate.go')
 
@@ -11,6 +11,10 @@ import (
 type ErrorEntry struct {
 	Message string
 	Context util.SourceContext
+	// Incomplete is true when the parser ran out of input in the middle of a
+	// construct, so the error may be fixed by more input. Unterminated strings
+	// and unclosed brackets are typical errors of this kind.
+	Incomplete bool
 }
 
 // Error stores multiple ErrorEntry's and can pretty print them.
@@ -18,8 +22,27 @@ type Error struct {
 	Entries []*ErrorEntry
 }
 
+// Add adds an error that is not caused by incomplete input.
 func (pe *Error) Add(msg string, ctx util.SourceContext) {
-	pe.Entries = append(pe.Entries, &ErrorEntry{msg, ctx})
+	pe.Entries = append(pe.Entries, &ErrorEntry{msg, ctx, false})
+}
+
+// Incomplete returns whether all the errors are caused by incomplete input.
+func (pe *Error) Incomplete() bool {
+	for _, e := range pe.Entries {
+		if !e.Incomplete {
+			return false
+		}
+	}
+	return len(pe.Entries) > 0
+}
+
+// IsIncomplete returns whether err is a parse error caused only by incomplete
+// input. The interactive editor uses it to decide whether to continue reading
+// input instead of reporting the error.
+func IsIncomplete(err error) bool {
+	pe, ok := err.(*Error)
+	return ok && pe.Incomplete()
 }
 
 func (pe *Error) Error() string {
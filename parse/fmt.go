@@ -0,0 +1,260 @@
+package parse
+
+import (
+	"bytes"
+	"strings"
+)
+
+// The indentation for each level of nested blocks.
+const fmtIndent = "    "
+
+// Fmt returns the canonical source form of a node. The node should come from
+// a parse without errors.
+//
+// The canonical form is reached by the following rules:
+//
+// * Pipelines in a chunk are put on separate lines. Comments are kept, and
+// runs of empty lines are collapsed into one.
+//
+// * Bodies of lambdas and output captures are kept on one line when they are
+// written in one line; otherwise they are formatted as blocks, indented by
+// four spaces per level.
+//
+// * All other spacing, including newlines within lists, maps and after pipes,
+// is normalized to a single space or removed.
+//
+// * Quoted strings use single quotes unless they contain unprintable
+// characters. Barewords are left as is. Output captures written with
+// backquotes are rewritten to use parentheses.
+func Fmt(n Node) string {
+	if cn, ok := n.(*Chunk); ok {
+		return fmtBlock(cn, "")
+	}
+	return fmtNode(n, "")
+}
+
+// fmtBlock formats a chunk as a block, where each pipeline is on its own line
+// and prefixed by indent.
+func fmtBlock(cn *Chunk, indent string) string {
+	var buf bytes.Buffer
+	started := false
+	// Number of newlines since the last pipeline or comment.
+	newlines := 0
+	startLine := func() {
+		if started {
+			buf.WriteByte('\n')
+			if newlines > 1 {
+				buf.WriteByte('\n')
+			}
+		}
+		buf.WriteString(indent)
+	}
+	for _, ch := range cn.Children() {
+		switch ch := ch.(type) {
+		case *Pipeline:
+			startLine()
+			buf.WriteString(fmtPipeline(ch, indent))
+		case *Sep:
+			text := ch.SourceText()
+			if !strings.HasPrefix(text, "#") {
+				newlines += strings.Count(text, "\n")
+				continue
+			}
+			if started && newlines == 0 {
+				// Comment at the end of a line.
+				buf.WriteByte(' ')
+			} else {
+				startLine()
+			}
+			buf.WriteString(strings.TrimRight(text, " \t\r"))
+		}
+		started = true
+		newlines = 0
+	}
+	if started {
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// fmtInline formats a chunk on one line.
+func fmtInline(cn *Chunk, indent string) string {
+	pipelines := make([]string, len(cn.Pipelines))
+	for i, pn := range cn.Pipelines {
+		pipelines[i] = fmtPipeline(pn, indent)
+	}
+	return strings.Join(pipelines, "; ")
+}
+
+// fmtBody formats the chunk inside a lambda or an output capture, including
+// the delimiters. When pad is true, inline bodies are padded with spaces.
+func fmtBody(cn *Chunk, indent, open, close string, pad bool) string {
+	if strings.Contains(cn.SourceText(), "\n") {
+		block := fmtBlock(cn, indent+fmtIndent)
+		if block != "" {
+			return open + "\n" + block + indent + close
+		}
+	}
+	inline := fmtInline(cn, indent)
+	if pad {
+		if inline == "" {
+			return open + " " + close
+		}
+		return open + " " + inline + " " + close
+	}
+	return open + inline + close
+}
+
+func fmtNode(n Node, indent string) string {
+	switch n := n.(type) {
+	case *Chunk:
+		return fmtInline(n, indent)
+	case *Pipeline:
+		return fmtPipeline(n, indent)
+	case *Form:
+		return fmtForm(n, indent)
+	case *Assignment:
+		return fmtNode(n.Left, indent) + "=" + fmtNode(n.Right, indent)
+	case *ExitusRedir:
+		return "?> " + fmtNode(n.Dest, indent)
+	case *Redir:
+		return fmtRedir(n, indent)
+	case *Compound:
+		var buf bytes.Buffer
+		for _, in := range n.Indexings {
+			buf.WriteString(fmtNode(in, indent))
+		}
+		return buf.String()
+	case *Indexing:
+		var buf bytes.Buffer
+		buf.WriteString(fmtNode(n.Head, indent))
+		for _, an := range n.Indicies {
+			buf.WriteString("[" + fmtNode(an, indent) + "]")
+		}
+		return buf.String()
+	case *Array:
+		return fmtArray(n, indent)
+	case *Primary:
+		return fmtPrimary(n, indent)
+	case *MapPair:
+		s := "&" + fmtNode(n.Key, indent)
+		if n.Value != nil {
+			s += "=" + fmtNode(n.Value, indent)
+		}
+		return s
+	default:
+		return strings.TrimSpace(n.SourceText())
+	}
+}
+
+func fmtPipeline(pn *Pipeline, indent string) string {
+	forms := make([]string, len(pn.Forms))
+	for i, fn := range pn.Forms {
+		forms[i] = fmtForm(fn, indent)
+	}
+	s := strings.Join(forms, " | ")
+	if pn.Background {
+		s += " &"
+	}
+	return s
+}
+
+func fmtForm(fn *Form, indent string) string {
+	// The children of a form are kept in their original order, since the
+	// order of evaluation matters.
+	var parts []string
+	for _, ch := range fn.Children() {
+		if _, ok := ch.(*Sep); ok {
+			// Either whitespaces or the equal sign of a spacey assignment.
+			if text := strings.TrimSpace(ch.SourceText()); text != "" {
+				parts = append(parts, text)
+			}
+			continue
+		}
+		parts = append(parts, fmtNode(ch, indent))
+	}
+	return strings.Join(parts, " ")
+}
+
+func fmtRedir(rn *Redir, indent string) string {
+	var buf bytes.Buffer
+	if rn.Left != nil {
+		buf.WriteString(fmtNode(rn.Left, indent))
+	}
+	switch rn.Mode {
+	case Read:
+		buf.WriteString("<")
+	case Write:
+		buf.WriteString(">")
+	case ReadWrite:
+		buf.WriteString("<>")
+	case Append:
+		buf.WriteString(">>")
+	}
+	if rn.RightIsFd {
+		buf.WriteString("&")
+	} else {
+		buf.WriteString(" ")
+	}
+	buf.WriteString(fmtNode(rn.Right, indent))
+	return buf.String()
+}
+
+func fmtArray(an *Array, indent string) string {
+	var parts []string
+	semicolons := an.Semicolons
+	addSemicolons := func(i int) {
+		for len(semicolons) > 0 && semicolons[0] == i {
+			if len(parts) > 0 {
+				parts[len(parts)-1] += ";"
+			} else {
+				parts = append(parts, ";")
+			}
+			semicolons = semicolons[1:]
+		}
+	}
+	for i, cn := range an.Compounds {
+		addSemicolons(i)
+		parts = append(parts, fmtNode(cn, indent))
+	}
+	addSemicolons(len(an.Compounds))
+	return strings.Join(parts, " ")
+}
+
+func fmtPrimary(pn *Primary, indent string) string {
+	switch pn.Type {
+	case SingleQuoted, DoubleQuoted:
+		s, _ := QuoteAs(pn.Value, SingleQuoted)
+		return s
+	case ExceptionCapture:
+		return fmtBody(pn.Chunk, indent, "?(", ")", false)
+	case OutputCapture:
+		return fmtBody(pn.Chunk, indent, "(", ")", false)
+	case List:
+		return "[" + fmtArray(pn.List, indent) + "]"
+	case Lambda:
+		s := ""
+		if pn.List != nil {
+			s = "[" + fmtArray(pn.List, indent) + "]"
+		}
+		return s + fmtBody(pn.Chunk, indent, "{", "}", true)
+	case Map:
+		if len(pn.MapPairs) == 0 {
+			return "[&]"
+		}
+		pairs := make([]string, len(pn.MapPairs))
+		for i, mpn := range pn.MapPairs {
+			pairs[i] = fmtNode(mpn, indent)
+		}
+		return "[" + strings.Join(pairs, " ") + "]"
+	case Braced:
+		parts := make([]string, len(pn.Braced))
+		for i, cn := range pn.Braced {
+			parts[i] = fmtNode(cn, indent)
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	default:
+		// Bareword, Variable, Wildcard and Tilde.
+		return pn.SourceText()
+	}
+}
@@ -0,0 +1,58 @@
+package parse
+
+import "testing"
+
+var fmtCases = []struct {
+	src, want string
+}{
+	{"", ""},
+	{"  ;\n\n  ls \t ;\n", "ls\n"},
+	{"a;b\nc", "a\nb\nc\n"},
+	// Empty lines are collapsed.
+	{"a\n\n\n\nb", "a\n\nb\n"},
+	// Comments.
+	{"# head\na  # tail  \n#last", "# head\na # tail\n#last\n"},
+	// Forms.
+	{"k=v  a   b  =  c   d", "k=v a b = c d\n"},
+	{"a  &k=v  x   &b", "a &k=v x &b\n"},
+	{"a >b 2>b 3>&- 4>&1 5<c 6<>d ?>$e", "a > b 2> b 3>&- 4>&1 5< c 6<> d ?> $e\n"},
+	// Pipelines.
+	{"a|b  | \n c  &", "a | b | c &\n"},
+	// Quoting.
+	{`echo "a" "x'y" "\n" 'z' b`, `echo 'a' 'x''y' "\n" 'z' b` + "\n"},
+	// Lists, maps and indexing.
+	{"a [ 1\n 2 ] [&] [ & ] [ &k= v\n&a] $x[ 1 ][a b]", "a [1 2] [&] [&] [&k=v &a] $x[1][a b]\n"},
+	{"a [a b;c;d;]", "a [a b; c; d;]\n"},
+	// Braced.
+	{"a {,a,c\ng\n}", "a {,a,c,g,}\n"},
+	// Output and exception captures.
+	{"a ( b;c ) `d` ?( e )", "a (b; c) (d) ?(e)\n"},
+	// Lambdas.
+	{"a []{} [ $x ]{put $x} { put $1}", "a []{ } [$x]{ put $x } { put $1 }\n"},
+	{"fn f [x]{\nif $x {\n  put a\n} else { put b }\n}",
+		"fn f [x]{\n    if $x {\n        put a\n    } else { put b }\n}\n"},
+	{"a (\nb\nc\n)", "a (\n    b\n    c\n)\n"},
+}
+
+func TestFmt(t *testing.T) {
+	for _, tc := range fmtCases {
+		n, err := Parse("[test]", tc.src)
+		if err != nil {
+			t.Errorf("Parse(%q) returns error: %v", tc.src, err)
+			continue
+		}
+		got := Fmt(n)
+		if got != tc.want {
+			t.Errorf("Fmt(%q) => %q, want %q", tc.src, got, tc.want)
+		}
+		// Formatting should be idempotent.
+		n, err = Parse("[test]", got)
+		if err != nil {
+			t.Errorf("Parse(%q) returns error: %v", got, err)
+			continue
+		}
+		if again := Fmt(n); again != got {
+			t.Errorf("Fmt(%q) => %q, not idempotent", got, again)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// +build gofuzz
+
+package parse
+
+// Fuzz is the entry point for go-fuzz. It panics when any invariant checked by
+// checkParse is violated. The files in testdata/fuzz make a good initial
+// corpus:
+//
+//     go-fuzz-build github.com/elves/elvish/parse
+//     mkdir -p workdir/corpus && cp testdata/fuzz/* workdir/corpus
+//     go-fuzz -bin parse-fuzz.zip -workdir workdir
+func Fuzz(data []byte) int {
+	src := string(data)
+	if err := checkParse(src); err != nil {
+		panic(err)
+	}
+	if _, err := Parse("[fuzz]", src); err != nil {
+		return 0
+	}
+	return 1
+}
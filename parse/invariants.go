@@ -0,0 +1,39 @@
+package parse
+
+import "fmt"
+
+// checkParse parses src with both Parse and ParseTolerant, and checks the
+// invariants that must hold for any input: errors are always of type *Error
+// with contexts inside the source, and successfully parsed chunks cover the
+// whole source. A panic during parsing is turned into an error.
+func checkParse(src string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	for _, parse := range []func(string, string) (*Chunk, error){Parse, ParseTolerant} {
+		n, err := parse("[check]", src)
+		if err == nil {
+			if n.Begin() != 0 || n.End() != len(src) || n.SourceText() != src {
+				return fmt.Errorf("chunk %d-%d does not cover the source", n.Begin(), n.End())
+			}
+			continue
+		}
+		pe, ok := err.(*Error)
+		if !ok {
+			return fmt.Errorf("error has type %T, want *Error", err)
+		}
+		if len(pe.Entries) == 0 {
+			return fmt.Errorf("error has no entries")
+		}
+		for _, e := range pe.Entries {
+			ctx := e.Context
+			if ctx.Begin < 0 || ctx.Begin > ctx.End || ctx.End > len(src) {
+				return fmt.Errorf("error %q has context %d-%d outside the source",
+					e.Message, ctx.Begin, ctx.End)
+			}
+		}
+	}
+	return nil
+}
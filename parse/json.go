@@ -0,0 +1,39 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// marshalNode serializes the AST part of a Node into a JSON object. The object
+// has a "Kind" field containing the name of the node type, "Begin" and "End"
+// fields containing its position, and one field for each of the exported
+// fields of the node. Children are serialized recursively; values of
+// PrimaryType and RedirMode are serialized as their names.
+//
+// The MarshalJSON methods of all node types are implemented with this
+// function; see boilerplate.py.
+func marshalNode(n Node) ([]byte, error) {
+	nt := reflect.TypeOf(n).Elem()
+	nv := reflect.ValueOf(n).Elem()
+
+	obj := map[string]interface{}{
+		"Kind":  nt.Name(),
+		"Begin": n.Begin(),
+		"End":   n.End(),
+	}
+	for i := 0; i < nt.NumField(); i++ {
+		f := nt.Field(i)
+		if f.Anonymous {
+			// embedded node struct, skip
+			continue
+		}
+		value := nv.Field(i).Interface()
+		if s, ok := value.(fmt.Stringer); ok {
+			value = s.String()
+		}
+		obj[f.Name] = value
+	}
+	return json.Marshal(obj)
+}
@@ -0,0 +1,44 @@
+package parse
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var jsonCases = []struct {
+	src  string
+	want string
+}{
+	{"", `{"Begin":0,"End":0,"Kind":"Chunk","Pipelines":null}`},
+	{"a >b", `{"Begin":0,"End":4,"Kind":"Chunk","Pipelines":[` +
+		`{"Background":false,"Begin":0,"End":4,"Forms":[` +
+		`{"Args":null,"Assignments":null,"Begin":0,"End":4,"ExitusRedir":null,` +
+		`"Head":{"Begin":0,"End":1,"Indexings":[` +
+		`{"Begin":0,"End":1,"Head":` +
+		`{"Begin":0,"Braced":null,"Chunk":null,"End":1,"IsRange":null,"Kind":"Primary","List":null,"MapPairs":null,"Type":"Bareword","Value":"a"},` +
+		`"Indicies":null,"Kind":"Indexing"}],"Kind":"Compound"},` +
+		`"Kind":"Form","Opts":null,"Redirs":[` +
+		`{"Begin":2,"End":4,"Kind":"Redir","Left":null,"Mode":"Write","Right":` +
+		`{"Begin":3,"End":4,"Indexings":[` +
+		`{"Begin":3,"End":4,"Head":` +
+		`{"Begin":3,"Braced":null,"Chunk":null,"End":4,"IsRange":null,"Kind":"Primary","List":null,"MapPairs":null,"Type":"Bareword","Value":"b"},` +
+		`"Indicies":null,"Kind":"Indexing"}],"Kind":"Compound"},` +
+		`"RightIsFd":false}],"Vars":null}],"Kind":"Pipeline"}]}`},
+}
+
+func TestMarshalJSON(t *testing.T) {
+	for _, tc := range jsonCases {
+		n, err := Parse("[test]", tc.src)
+		if err != nil {
+			t.Errorf("Parse(%q) returns error: %v", tc.src, err)
+			continue
+		}
+		got, err := json.Marshal(n)
+		if err != nil {
+			t.Errorf("json.Marshal(Parse(%q)) returns error: %v", tc.src, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("json.Marshal(Parse(%q)) =>\n%s\nwant:\n%s", tc.src, got, tc.want)
+		}
+	}
+}
@@ -1,12 +1,26 @@
 package parse
 
 // Node represents a parse tree as well as an AST.
+//
+// The parse tree is source-faithful: every byte of the parsed source, including
+// spaces, comments and quotes, belongs to exactly one leaf node, and the
+// children of a node cover its source range without gaps. As a result, the
+// source text of a node is always the concatenation of the source texts of its
+// children, and the original input can be reconstructed losslessly by
+// concatenating the source texts of all leaves.
 type Node interface {
 	n() *node
+	// Parent returns the parent node, or nil for the root.
 	Parent() Node
+	// Begin returns the byte offset where the node begins.
 	Begin() int
+	// End returns the byte offset right after the node ends.
 	End() int
+	// SourceText returns the original text of the node, which is always
+	// src[Begin():End()] where src is the text being parsed.
 	SourceText() string
+	// Children returns all children of the node in source order, including
+	// Sep nodes.
 	Children() []Node
 }
 
@@ -40,3 +54,30 @@ func (n *node) SourceText() string {
 func (n *node) Children() []Node {
 	return n.children
 }
+
+// NodeAt returns the chain of nodes covering the byte offset p, beginning with
+// n and ending with the innermost node, which is always a leaf. A node covers
+// p if p is between its Begin and End, inclusive; when p is on the boundary of
+// two children, the earlier one is chosen, so that the node right before a
+// cursor at p is found. It returns nil if n itself does not cover p.
+//
+// This is useful for finding the context of a cursor, for instance for
+// completion or documentation lookup.
+func NodeAt(n Node, p int) []Node {
+	if p < n.Begin() || p > n.End() {
+		return nil
+	}
+	path := []Node{n}
+descend:
+	for len(n.Children()) > 0 {
+		for _, ch := range n.Children() {
+			if ch.Begin() <= p && p <= ch.End() {
+				n = ch
+				path = append(path, n)
+				continue descend
+			}
+		}
+		break
+	}
+	return path
+}
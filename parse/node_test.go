@@ -0,0 +1,33 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+var nodeAtTests = []struct {
+	src  string
+	p    int
+	want []string // Types of nodes in the path
+}{
+	{"", 0, []string{"Chunk"}},
+	{"ls", 1, []string{"Chunk", "Pipeline", "Form", "Compound", "Indexing", "Primary"}},
+	{"ls $x", 3, []string{"Chunk", "Pipeline", "Form", "Sep"}},
+	{"ls $x", 5, []string{"Chunk", "Pipeline", "Form", "Compound", "Indexing", "Primary"}},
+	{"a; b", 2, []string{"Chunk", "Sep"}},
+	{"a {b}", 4, []string{"Chunk", "Pipeline", "Form", "Compound", "Indexing", "Primary", "Compound", "Indexing", "Primary"}},
+	{"ls", 3, nil},
+}
+
+func TestNodeAt(t *testing.T) {
+	for _, test := range nodeAtTests {
+		n, _ := Parse("[test]", test.src)
+		var got []string
+		for _, n := range NodeAt(n, test.p) {
+			got = append(got, reflect.TypeOf(n).Elem().Name())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("NodeAt(Parse(%q), %d) => %v, want %v", test.src, test.p, got, test.want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Parse parses Elvish source. If the error is not nil, it always has type
@@ -20,6 +21,19 @@ func Parse(srcname, src string) (*Chunk, error) {
 	return n, ps.Errors()
 }
 
+// ParseTolerant is like Parse, but does not stop at the first unexpected rune
+// at the top level. Instead, it records the error, skips to the next pipeline
+// separator and continues parsing, so that all errors in the source are
+// reported. The skipped text is kept in the parse tree as Sep nodes. If the
+// error is not nil, it always has type *Error.
+func ParseTolerant(srcname, src string) (*Chunk, error) {
+	ps := NewParser(srcname, src)
+	ps.tolerant = true
+	n := ParseChunk(ps)
+	ps.Done()
+	return n, ps.Errors()
+}
+
 // Errors.
 var (
 	errUnexpectedRune         = errors.New("unexpected rune")
@@ -40,9 +54,12 @@ var (
 	errShouldBeFilename       = newError("", "a composite term representing filename")
 	errShouldBeArray          = newError("", "spaced")
 	errStringUnterminated     = newError("string not terminated")
+	errTooDeep                = newError("nesting too deep")
 	errInvalidEscape          = newError("invalid escape sequence")
 	errInvalidEscapeOct       = newError("invalid escape sequence", "octal digit")
 	errInvalidEscapeHex       = newError("invalid escape sequence", "hex digit")
+	errInvalidEscapeHexBrace  = newError("invalid escape sequence", "hex digit", "'}'")
+	errInvalidEscapeCodepoint = newError("invalid escape sequence", "a code point no larger than 0x10FFFF")
 	errInvalidEscapeControl   = newError("invalid control sequence", "a rune between @ (0x40) and _(0x5F)")
 	errShouldBePrimary        = newError("",
 		"single-quoted string", "double-quoted string", "bareword")
@@ -66,14 +83,39 @@ type Chunk struct {
 
 func (bn *Chunk) parse(ps *Parser) {
 	bn.parseSeps(ps)
-	for startsPipeline(ps.peek()) {
-		bn.addToPipelines(ParsePipeline(ps))
-		if bn.parseSeps(ps) == 0 {
+	for {
+		for startsPipeline(ps.peek()) {
+			bn.addToPipelines(ParsePipeline(ps))
+			if bn.parseSeps(ps) == 0 {
+				break
+			}
+		}
+		if !bn.resync(ps) {
 			break
 		}
 	}
 }
 
+// resync is called when a pipeline cannot be started. If the parser is
+// tolerant and the chunk is at the top level, it records an error, skips to
+// the next pipeline separator and returns true. Otherwise it returns false and
+// leaves the error to be reported by the caller.
+func (bn *Chunk) resync(ps *Parser) bool {
+	if !ps.tolerant || len(ps.cutsets) > 1 || ps.peek() == eof {
+		return false
+	}
+	if n := len(ps.errors.Entries); n == 0 || ps.errors.Entries[n-1].Context.Begin != ps.pos {
+		// Don't report the same position twice.
+		ps.error(errUnexpectedRune)
+	}
+	for r := ps.peek(); r != eof && !isPipelineSep(r); r = ps.peek() {
+		ps.next()
+	}
+	addSep(bn, ps)
+	bn.parseSeps(ps)
+	return true
+}
+
 func isPipelineSep(r rune) bool {
 	return r == '\n' || r == ';'
 }
@@ -121,7 +163,7 @@ func (pn *Pipeline) parse(ps *Parser) {
 	for parseSep(pn, ps, '|') {
 		parseSpacesAndNewlines(pn, ps)
 		if !startsForm(ps.peek()) {
-			ps.error(errShouldBeForm)
+			ps.errorUnclosed(errShouldBeForm)
 			return
 		}
 		pn.addToForms(ParseForm(ps))
@@ -201,7 +243,7 @@ func (fn *Form) parse(ps *Parser) {
 			} else if cn.sourceText == "=" {
 				// Spacey assignment.
 				// Turn the equal sign into a Sep.
-				addChild(fn, NewSep(ps.src, cn.begin, cn.end))
+				addChild(fn, ps.newSep(cn.begin, cn.end))
 				// Turn the head and preceding arguments into LHSs.
 				addLHS := func(cn *Compound) {
 					if len(cn.Indexings) == 1 && checkVariableInAssignment(cn.Indexings[0].Head, ps) {
@@ -210,8 +252,13 @@ func (fn *Form) parse(ps *Parser) {
 						ps.errorp(cn.begin, cn.end, errBadLHS)
 					}
 				}
-				addLHS(fn.Head)
-				fn.Head = nil
+				if fn.Head == nil {
+					// Only redirections precede the equal sign.
+					ps.errorp(cn.begin, cn.end, errBadLHS)
+				} else {
+					addLHS(fn.Head)
+					fn.Head = nil
+				}
 				for _, cn := range fn.Args {
 					addLHS(cn)
 				}
@@ -232,23 +279,73 @@ func (fn *Form) parse(ps *Parser) {
 // assignment to fn.Assignments and returns true. Otherwise it rewinds the
 // parser and returns false.
 func (fn *Form) tryAssignment(ps *Parser) bool {
-	if !startsIndexing(ps.peek(), false) || ps.peek() == '=' {
+	if !startsAssignment(ps.peek()) || ps.notAssignment[ps.pos] ||
+		!mayBeAssignment(ps) {
 		return false
 	}
 
-	pos := ps.pos
+	pos, overEOF := ps.pos, ps.overEOF
 	errorEntries := ps.errors.Entries
 	an := ParseAssignment(ps)
+	if ps.tooDeep {
+		// The source has been abandoned; there is nothing to revert to.
+		fn.addToAssignments(an)
+		return true
+	}
 	// If errors were added, revert
 	if len(ps.errors.Entries) > len(errorEntries) {
 		ps.errors.Entries = errorEntries
-		ps.pos = pos
+		ps.pos, ps.overEOF = pos, overEOF
+		ps.notAssignment[pos] = true
 		return false
 	}
 	fn.addToAssignments(an)
 	return true
 }
 
+// startsAssignment returns whether r may start an assignment. Only the types
+// of primaries accepted by checkVariableInAssignment qualify, so that the
+// speculative parse is skipped for other constructs.
+func startsAssignment(r rune) bool {
+	return r != '=' && (allowedInBareword(r, false) ||
+		r == '\'' || r == '"' || r == '{')
+}
+
+// mayBeAssignment looks ahead for the '=' of an assignment without parsing. It
+// returns false only when the text at the current position is certainly not
+// an assignment: a bareword, quoted string or braced list, possibly followed
+// by indices, that is not followed by '='. This avoids parsing the nested
+// constructs in the indices twice.
+func mayBeAssignment(ps *Parser) bool {
+	src := ps.src
+	i := ps.pos
+	switch src[i] {
+	case '\'', '"':
+		i = skipQuoted(src, i)
+	case '{':
+		i = ps.matchBracket(i)
+		if i != -1 {
+			i++
+		}
+	default:
+		for i < len(src) {
+			r, size := utf8.DecodeRuneInString(src[i:])
+			if r == '=' || !allowedInBareword(r, false) {
+				break
+			}
+			i += size
+		}
+	}
+	for i != -1 && i < len(src) && src[i] == '[' {
+		i = ps.matchBracket(i)
+		if i != -1 {
+			i++
+		}
+	}
+	// i is -1 when there is an unterminated string or unclosed bracket.
+	return i != -1 && i < len(src) && src[i] == '='
+}
+
 func startsForm(r rune) bool {
 	return IsSpace(r) || startsCompound(r, true)
 }
@@ -394,8 +491,10 @@ func (cn *Compound) tilde(ps *Parser) {
 	if ps.peek() == '~' {
 		ps.next()
 		base := node{nil, ps.pos - 1, ps.pos, "~", nil}
-		pn := &Primary{node: base, Type: Tilde, Value: "~"}
-		in := &Indexing{node: base}
+		pn := ps.arena.newPrimary()
+		pn.node, pn.Type, pn.Value = base, Tilde, "~"
+		in := ps.arena.newIndexing()
+		in.node = base
 		in.setHead(pn)
 		cn.addToIndexings(in)
 	}
@@ -424,7 +523,7 @@ func (in *Indexing) parse(ps *Parser, head bool) {
 		ps.popCutset()
 
 		if !parseSep(in, ps, ']') {
-			ps.error(errShouldBeRBracket)
+			ps.errorUnclosed(errShouldBeRBracket)
 			return
 		}
 	}
@@ -510,6 +609,10 @@ func (pn *Primary) parse(ps *Parser, head bool) {
 		ps.error(errShouldBePrimary)
 		return
 	}
+	if !ps.enter() {
+		return
+	}
+	defer ps.leave()
 
 	// Try bareword early, since it has precedence over wildcard on *
 	// when head is true.
@@ -545,15 +648,22 @@ func (pn *Primary) parse(ps *Parser, head bool) {
 	}
 }
 
+// singleQuoted parses a single-quoted string. Single-quoted strings are raw:
+// there are no escape sequences except for two consecutive single quotes, which
+// stand for one single quote. They may span multiple lines, which makes them
+// suitable for embedding scripts or configurations inline.
 func (pn *Primary) singleQuoted(ps *Parser) {
 	pn.Type = SingleQuoted
 	ps.next()
+	// Cuts only apply to unquoted text.
+	ps.pushCutset()
+	defer ps.popCutset()
 	var buf bytes.Buffer
 	defer func() { pn.Value = buf.String() }()
 	for {
 		switch r := ps.next(); r {
 		case eof:
-			ps.error(errStringUnterminated)
+			ps.errorUnclosed(errStringUnterminated)
 			return
 		case '\'':
 			if ps.peek() == '\'' {
@@ -573,75 +683,123 @@ func (pn *Primary) singleQuoted(ps *Parser) {
 func (pn *Primary) doubleQuoted(ps *Parser) {
 	pn.Type = DoubleQuoted
 	ps.next()
+	// Cuts only apply to unquoted text.
+	ps.pushCutset()
+	defer ps.popCutset()
 	var buf bytes.Buffer
 	defer func() { pn.Value = buf.String() }()
 	for {
 		switch r := ps.next(); r {
 		case eof:
-			ps.error(errStringUnterminated)
+			ps.errorUnclosed(errStringUnterminated)
 			return
 		case '"':
 			return
 		case '\\':
-			switch r := ps.next(); r {
-			case 'c', '^':
-				// Control sequence
+			escape(ps, &buf)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// escape parses an escape sequence in a double-quoted string. The backslash
+// has been seen. The rune the sequence represents is written to buf. Errors
+// span from the backslash to the offending rune, so that they point at the
+// bad escape sequence instead of the whole string.
+func escape(ps *Parser, buf *bytes.Buffer) {
+	begin := ps.pos - 1
+	// bad reports an error at the last rune read, and unreads it.
+	bad := func(e error) {
+		ps.backup()
+		end := ps.pos
+		if r := ps.peek(); r != eof {
+			end += utf8.RuneLen(r)
+		}
+		ps.errorp(begin, end, e)
+	}
+	switch r := ps.next(); r {
+	case 'c', '^':
+		// Control sequence
+		r := ps.next()
+		if r < 0x40 || r >= 0x60 {
+			bad(errInvalidEscapeControl)
+			ps.next()
+		}
+		buf.WriteByte(byte(r - 0x40))
+	case 'u':
+		if ps.peek() == '{' {
+			// Variable-length code point like \u{1F600}
+			ps.next()
+			var rr rune
+			for ndigits := 0; ; ndigits++ {
 				r := ps.next()
-				if r < 0x40 || r >= 0x60 {
-					ps.backup()
-					ps.error(errInvalidEscapeControl)
-					ps.next()
-				}
-				buf.WriteByte(byte(r - 0x40))
-			case 'x', 'u', 'U':
-				var n int
-				switch r {
-				case 'x':
-					n = 2
-				case 'u':
-					n = 4
-				case 'U':
-					n = 8
-				}
-				var rr rune
-				for i := 0; i < n; i++ {
-					d, ok := hexToDigit(ps.next())
-					if !ok {
-						ps.backup()
-						ps.error(errInvalidEscapeHex)
-						break
-					}
-					rr = rr*16 + d
+				if r == '}' && ndigits > 0 {
+					break
 				}
-				buf.WriteRune(rr)
-			case '0', '1', '2', '3', '4', '5', '6', '7':
-				// 2 more octal digits
-				rr := r - '0'
-				for i := 0; i < 2; i++ {
-					r := ps.next()
-					if r < '0' || r > '7' {
-						ps.backup()
-						ps.error(errInvalidEscapeOct)
-						break
-					}
-					rr = rr*8 + (r - '0')
-				}
-				buf.WriteRune(rr)
-			default:
-				if rr, ok := doubleEscape[r]; ok {
-					buf.WriteRune(rr)
-				} else {
-					ps.backup()
-					ps.error(errInvalidEscape)
-					ps.next()
+				d, ok := hexToDigit(r)
+				if !ok || ndigits == 6 {
+					bad(errInvalidEscapeHexBrace)
+					return
 				}
+				rr = rr*16 + d
 			}
-		default:
-			buf.WriteRune(r)
+			writeCodepoint(ps, buf, begin, rr)
+			return
+		}
+		fallthrough
+	case 'x', 'U':
+		var n int
+		switch r {
+		case 'x':
+			n = 2
+		case 'u':
+			n = 4
+		case 'U':
+			n = 8
+		}
+		var rr rune
+		for i := 0; i < n; i++ {
+			d, ok := hexToDigit(ps.next())
+			if !ok {
+				bad(errInvalidEscapeHex)
+				return
+			}
+			rr = rr*16 + d
+		}
+		writeCodepoint(ps, buf, begin, rr)
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		// 2 more octal digits
+		rr := r - '0'
+		for i := 0; i < 2; i++ {
+			r := ps.next()
+			if r < '0' || r > '7' {
+				bad(errInvalidEscapeOct)
+				return
+			}
+			rr = rr*8 + (r - '0')
+		}
+		buf.WriteRune(rr)
+	default:
+		if rr, ok := doubleEscape[r]; ok {
+			buf.WriteRune(rr)
+		} else {
+			bad(errInvalidEscape)
+			ps.next()
 		}
 	}
 }
 
+// writeCodepoint writes rr to buf if it is a valid code point, and reports an
+// error covering the escape sequence starting at begin otherwise.
+func writeCodepoint(ps *Parser, buf *bytes.Buffer, begin int, rr rune) {
+	if rr < 0 || rr > unicode.MaxRune {
+		ps.errorp(begin, ps.pos, errInvalidEscapeCodepoint)
+		return
+	}
+	buf.WriteRune(rr)
+}
+
 // a table for the simple double-quote escape sequences.
 var doubleEscape = map[rune]rune{
 	// same as golang
@@ -723,7 +881,7 @@ func (pn *Primary) exitusCapture(ps *Parser) {
 	ps.popCutset()
 
 	if !parseSep(pn, ps, ')') {
-		ps.error(errShouldBeRParen)
+		ps.errorUnclosed(errShouldBeRParen)
 	}
 }
 
@@ -757,7 +915,7 @@ func (pn *Primary) outputCapture(ps *Parser) {
 	ps.popCutset()
 
 	if !parseSep(pn, ps, closer) {
-		ps.error(shouldBeCloser)
+		ps.errorUnclosed(shouldBeCloser)
 	}
 }
 
@@ -795,14 +953,14 @@ func (pn *Primary) lbracket(ps *Parser) {
 		}
 		ps.popCutset()
 		if !parseSep(pn, ps, ']') {
-			ps.error(errShouldBeRBracket)
+			ps.errorUnclosed(errShouldBeRBracket)
 		}
 	default:
 		pn.setList(ParseArray(ps, true))
 		ps.popCutset()
 
 		if !parseSep(pn, ps, ']') {
-			ps.error(errShouldBeRBracket)
+			ps.errorUnclosed(errShouldBeRBracket)
 		}
 		if parseSep(pn, ps, '{') {
 			if len(pn.List.Semicolons) > 0 {
@@ -822,7 +980,7 @@ func (pn *Primary) lambda(ps *Parser) {
 	pn.setChunk(ParseChunk(ps))
 	ps.popCutset()
 	if !parseSep(pn, ps, '}') {
-		ps.error(errShouldBeRBrace)
+		ps.errorUnclosed(errShouldBeRBrace)
 	}
 }
 
@@ -858,7 +1016,7 @@ func (pn *Primary) lbrace(ps *Parser) {
 		ps.uncut(',')
 	}
 	if !parseSep(pn, ps, '}') {
-		ps.error(errShouldBeBraceSepOrRBracket)
+		ps.errorUnclosed(errShouldBeBraceSepOrRBracket)
 	}
 }
 
@@ -930,6 +1088,13 @@ func NewSep(src string, begin, end int) *Sep {
 	return &Sep{node{nil, begin, end, src[begin:end], nil}}
 }
 
+// newSep is like NewSep, but allocates the Sep from the arena of the parser.
+func (ps *Parser) newSep(begin, end int) *Sep {
+	n := ps.arena.newSep()
+	n.node = node{nil, begin, end, ps.src[begin:end], nil}
+	return n
+}
+
 func addSep(n Node, ps *Parser) {
 	var begin int
 	ch := n.Children()
@@ -938,7 +1103,7 @@ func addSep(n Node, ps *Parser) {
 	} else {
 		begin = n.Begin()
 	}
-	addChild(n, NewSep(ps.src, begin, ps.pos))
+	addChild(n, ps.newSep(begin, ps.pos))
 }
 
 func parseSep(n Node, ps *Parser, sep rune) bool {
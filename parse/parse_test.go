@@ -2,7 +2,11 @@ package parse
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -42,6 +46,10 @@ var goodCases = []struct {
 	// Assignments.
 	{"k=v k[a][b]=v {a,b[1]}=(ha)", ast{"Chunk/Pipeline/Form", fs{
 		"Assignments": []string{"k=v", "k[a][b]=v", "{a,b[1]}=(ha)"}}}},
+	// Indices containing brackets, quotes and variables.
+	{`k[']['][(x)]=v k["]\"["][$]][[a]]=v a`, ast{"Chunk/Pipeline/Form", fs{
+		"Assignments": []string{`k[']['][(x)]=v`, `k["]\"["][$]][[a]]=v`},
+		"Head":        "a"}}},
 	// Temporary assignment.
 	{"k=v k[a][b]=v a", ast{"Chunk/Pipeline/Form", fs{
 		"Assignments": []string{"k=v", "k[a][b]=v"},
@@ -96,12 +104,22 @@ var goodCases = []struct {
 	{"a '''x''y'''", a(ast{"Compound/Indexing/Primary", fs{
 		"Type": SingleQuoted, "Value": "'x'y'",
 	}})},
+	// Single-quoted strings are raw and may span multiple lines
+	{"a 'x\n\\n\n  y\n'", a(ast{"Compound/Indexing/Primary", fs{
+		"Type": SingleQuoted, "Value": "x\n\\n\n  y\n",
+	}})},
 	// Double quote
 	{`a "b\^[\x1b\u548c\U0002CE23\123\n\t\\"`,
 		a(ast{"Compound/Indexing/Primary", fs{
 			"Type":  DoubleQuoted,
 			"Value": "b\x1b\x1b\u548c\U0002CE23\123\n\t\\",
 		}})},
+	// Double quote with braced code points
+	{`a "\u{41}\u{1F600}\u{10ffff}"`,
+		a(ast{"Compound/Indexing/Primary", fs{
+			"Type":  DoubleQuoted,
+			"Value": "A\U0001F600\U0010FFFF",
+		}})},
 	// Wildcard
 	{"a * ?", a(
 		ast{"Compound/Indexing/Primary", fs{"Type": Wildcard, "Value": "*"}},
@@ -308,6 +326,12 @@ var badCases = []struct {
 	{"a (", 3}, {"a [", 3}, {"a {", 3},
 	// Bogus ampersand.
 	{"a & &", 4}, {"a [&", 4},
+	// Bad escape sequences are pointed at precisely.
+	{`a "x\qy"`, 4}, {`a "xy\xZZ"`, 5}, {`a "\^a"`, 3},
+	{`a "\u{}"`, 3}, {`a "\u{1234567}"`, 3}, {`a "\u{110000}"`, 3},
+	{`a "\UFFFFFFFF"`, 3},
+	// Too deep nesting.
+	{strings.Repeat("(", MaxDepth+1), MaxDepth},
 }
 
 func TestParseError(t *testing.T) {
@@ -323,3 +347,150 @@ func TestParseError(t *testing.T) {
 		}
 	}
 }
+
+var tolerantCases = []struct {
+	src  string
+	poss []int // expected Begin positions of all errors
+}{
+	// Recovery at newlines and semicolons.
+	{"a\n)\nb\n]\nc", []int{2, 6}},
+	{"a; } x; b", []int{3}},
+	// Errors within pipelines are still reported.
+	{"a|\n) b; c (", []int{3, 11}},
+}
+
+func TestParseTolerant(t *testing.T) {
+	for _, tc := range tolerantCases {
+		bn, err := ParseTolerant("[test]", tc.src)
+		if err == nil {
+			t.Errorf("ParseTolerant(%q) returns no error", tc.src)
+			continue
+		}
+		var poss []int
+		for _, e := range err.(*Error).Entries {
+			poss = append(poss, e.Context.Begin)
+		}
+		if !reflect.DeepEqual(poss, tc.poss) {
+			t.Errorf("ParseTolerant(%q) errors begin at %v, want %v. Errors are: %s", tc.src, poss, tc.poss, err)
+		}
+		if err := checkParseTree(bn); err != nil {
+			t.Errorf("ParseTolerant(%q) returns bad parse tree: %v", tc.src, err)
+		}
+	}
+}
+
+// leafText concatenates the source texts of all leaves under n.
+func leafText(n Node) string {
+	if len(n.Children()) == 0 {
+		return n.SourceText()
+	}
+	text := ""
+	for _, ch := range n.Children() {
+		text += leafText(ch)
+	}
+	return text
+}
+
+func TestParseTreeIsLossless(t *testing.T) {
+	srcs := []string{"a b # comment\n  c 'x''y' \"z\\n\" ; d\t[&k= v] | e ?>$e"}
+	for _, tc := range goodCases {
+		srcs = append(srcs, tc.src)
+	}
+	for _, src := range srcs {
+		bn, err := Parse("[test]", src)
+		if err != nil {
+			t.Errorf("Parse(%q) returns error: %v", src, err)
+			continue
+		}
+		if bn.Begin() != 0 || bn.End() != len(src) || bn.SourceText() != src {
+			t.Errorf("Parse(%q) returns Chunk %s, not covering the source", src, summary(bn))
+		}
+		if text := leafText(bn); text != src {
+			t.Errorf("leaves of Parse(%q) reconstruct %q", src, text)
+		}
+	}
+}
+
+var incompleteCases = []struct {
+	src        string
+	incomplete bool
+}{
+	// Unterminated strings.
+	{"a 'x", true}, {"a \"x\ny", true},
+	// Unclosed brackets and parens.
+	{"a (b", true}, {"a [b", true}, {"a {\nb", true}, {"a [&k=v", true},
+	{"a `b", true}, {"a $x[0", true},
+	// Dangling pipe.
+	{"a |", true},
+	// Errors in the middle are not caused by incomplete input.
+	{")", false}, {"a & &", false}, {"a \"\\q\" (", false},
+	// Errors at the end that more input cannot fix.
+	{"a >", false}, {"a [b}", false}, {"a (b ]", false},
+}
+
+func TestIncomplete(t *testing.T) {
+	for _, tc := range incompleteCases {
+		_, err := Parse("[test]", tc.src)
+		if err == nil {
+			t.Errorf("Parse(%q) returns no error", tc.src)
+			continue
+		}
+		if IsIncomplete(err) != tc.incomplete {
+			t.Errorf("IsIncomplete(Parse(%q)) => %v, want %v. Errors are: %s", tc.src, !tc.incomplete, tc.incomplete, err)
+		}
+	}
+	if IsIncomplete(nil) {
+		t.Errorf("IsIncomplete(nil) => true")
+	}
+}
+
+// The parser is a synchronous recursive-descent parser that works directly on
+// the source, without a separate lexer. This benchmark measures the cost of
+// parsing a typical interactive command, which the editor does on every
+// keystroke.
+func BenchmarkParse(b *testing.B) {
+	src := `put [&k=v] | each [x]{ echo $x[k] >> out.txt; e:ls -l ~/src } 2>&1`
+	for i := 0; i < b.N; i++ {
+		Parse("[bench]", src)
+	}
+}
+
+// Inputs that used to crash the parser, or make it take exponential time.
+var crashCases = []string{
+	">a = b",
+	">>$@&k= =&-'if;&k=@\t=&`\t\xff>>@",
+	strings.Repeat("(", 400) + strings.Repeat(")", 400),
+	strings.Repeat("[]{", 400),
+	strings.Repeat("a[(", 400) + strings.Repeat(")]", 400),
+	strings.Repeat("a[('x'", 400),
+	strings.Repeat("$", 100000),
+}
+
+func TestParseInvariants(t *testing.T) {
+	for _, src := range crashCases {
+		if err := checkParse(src); err != nil {
+			t.Errorf("checkParse(%q): %v", src, err)
+		}
+	}
+
+	var srcs []string
+	files, err := filepath.Glob("testdata/fuzz/*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no fuzz corpus: %v", err)
+	}
+	for _, fname := range files {
+		content, err := ioutil.ReadFile(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srcs = append(srcs, string(content))
+	}
+	for _, src := range srcs {
+		// Also check every prefix, which covers unterminated constructs.
+		for i := 0; i <= len(src); i++ {
+			if err := checkParse(src[:i]); err != nil {
+				t.Errorf("checkParse(%q): %v", src[:i], err)
+			}
+		}
+	}
+}
@@ -19,11 +19,35 @@ type Parser struct {
 	overEOF int
 	cutsets []map[rune]int
 	errors  Error
+	// Whether to recover from errors at the top level; see ParseTolerant.
+	tolerant bool
+	arena    arena
+	// Current nesting depth of primaries, and whether MaxDepth has been
+	// exceeded.
+	depth   int
+	tooDeep bool
+	// Positions at which tryAssignment has failed. Remembering them keeps
+	// nested speculative parses from taking exponential time.
+	notAssignment map[int]bool
+	// Positions of matching brackets, built lazily by matchBracket.
+	brackets map[int]int
 }
 
+// MaxDepth is the maximum nesting depth of primaries, such as output captures,
+// lists and lambdas. Deeper input is rejected with an error instead of
+// exhausting the stack.
+const MaxDepth = 500
+
+// Nodes are allocated from slabs of arenaSlabSize nodes of the same type,
+// instead of individually. This greatly reduces the number of allocations,
+// which matters since the editor reparses the buffer on every keystroke. All
+// the nodes in a slab are freed together when none of them is referenced.
+const arenaSlabSize = 16
+
 // NewParser creates a new parser from a piece of source text and its name.
 func NewParser(srcname, src string) *Parser {
-	return &Parser{srcname, src, 0, 0, []map[rune]int{{}}, Error{}}
+	return &Parser{srcname, src, 0, 0, []map[rune]int{{}}, Error{}, false, arena{},
+		0, false, map[int]bool{}, nil}
 }
 
 // Done tells the parser that parsing has completed.
@@ -114,7 +138,7 @@ func (ps *Parser) advance(c int) {
 }
 
 func (ps *Parser) errorp(begin, end int, e error) {
-	ps.errors.Add(e.Error(), util.SourceContext{ps.srcName, ps.src, begin, end, nil})
+	ps.addError(begin, end, e, false)
 }
 
 func (ps *Parser) error(e error) {
@@ -125,6 +149,115 @@ func (ps *Parser) error(e error) {
 	ps.errorp(ps.pos, end, e)
 }
 
+// errorUnclosed is like error, but for a construct that is still open, like
+// an unterminated string or an unclosed bracket. If the parser has run out of
+// input, the error is marked as incomplete.
+func (ps *Parser) errorUnclosed(e error) {
+	end := ps.pos
+	if end < len(ps.src) {
+		end++
+	}
+	ps.addError(ps.pos, end, e, ps.pos == len(ps.src))
+}
+
+func (ps *Parser) addError(begin, end int, e error, incomplete bool) {
+	if ps.tooDeep {
+		// The rest of the source has been skipped; errors about unclosed
+		// constructs would only be noise.
+		return
+	}
+	ps.errors.Entries = append(ps.errors.Entries, &ErrorEntry{e.Error(),
+		util.SourceContext{ps.srcName, util.ContextSource(ps.srcName, ps.src),
+			begin, end, nil, ""}, incomplete})
+}
+
+// enter increases the nesting depth. If the depth exceeds MaxDepth, it
+// records an error, skips the rest of the source and returns false.
+func (ps *Parser) enter() bool {
+	ps.depth++
+	if ps.depth > MaxDepth {
+		if !ps.tooDeep {
+			ps.error(errTooDeep)
+			ps.tooDeep = true
+			ps.pos = len(ps.src)
+		}
+		return false
+	}
+	return true
+}
+
+// matchBracket returns the position of the ']' or '}' matching the '[' or '{'
+// at pos, or -1 if there is none. The brackets are matched lexically in one
+// pass over the source, skipping quoted strings, comments and the first rune
+// of variable names; this agrees with the parser on all input without parse
+// errors.
+func (ps *Parser) matchBracket(pos int) int {
+	if ps.brackets == nil {
+		ps.brackets = map[int]int{}
+		stacks := map[byte][]int{}
+		src := ps.src
+		for i := 0; i < len(src); i++ {
+			switch c := src[i]; c {
+			case '[', '{':
+				stacks[c] = append(stacks[c], i)
+			case ']', '}':
+				opener := byte('[')
+				if c == '}' {
+					opener = '{'
+				}
+				if stack := stacks[opener]; len(stack) > 0 {
+					ps.brackets[stack[len(stack)-1]] = i
+					stacks[opener] = stack[:len(stack)-1]
+				}
+			case '$':
+				_, size := utf8.DecodeRuneInString(src[i+1:])
+				i += size
+			case '\'', '"':
+				end := skipQuoted(src, i)
+				if end == -1 {
+					// Unterminated string.
+					i = len(src)
+				} else {
+					i = end - 1
+				}
+			case '#':
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			}
+		}
+	}
+	if end, ok := ps.brackets[pos]; ok {
+		return end
+	}
+	return -1
+}
+
+// skipQuoted returns the position after the quoted string starting at pos, or
+// -1 if the string is unterminated.
+func skipQuoted(src string, pos int) int {
+	quote := src[pos]
+	for i := pos + 1; i < len(src); i++ {
+		switch {
+		case quote == '"' && src[i] == '\\':
+			i++
+		case src[i] == quote:
+			if quote == '\'' && i+1 < len(src) && src[i+1] == '\'' {
+				// Two single quotes in a single-quoted string.
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// leave decreases the nesting depth.
+func (ps *Parser) leave() {
+	ps.depth--
+}
+
 func (ps *Parser) pushCutset(rs ...rune) {
 	ps.cutsets = append(ps.cutsets, map[rune]int{})
 	ps.cut(rs...)
@@ -32,14 +32,17 @@ func QuoteAs(s string, q PrimaryType) (string, PrimaryType) {
 		return "''", SingleQuoted
 	}
 
-	// Keep track of whether it is a valid bareword.
+	// Keep track of whether it is a valid bareword. Although = is allowed in
+	// barewords, it is not allowed in map keys, and a lone = is parsed as part
+	// of an assignment; always quote it so that the result is valid in all
+	// contexts.
 	bare := s[0] != '~'
 	for _, r := range s {
 		if !unicode.IsPrint(r) {
 			// Contains unprintable character; force double quote.
 			return quoteDouble(s), DoubleQuoted
 		}
-		if !allowedInBareword(r, false) {
+		if !allowedInBareword(r, false) || r == '=' {
 			bare = false
 		}
 	}
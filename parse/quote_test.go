@@ -14,6 +14,9 @@ var quoteTests = []struct {
 	// Tilde needs quoting only when appearing at the beginning
 	{"~x", "'~x'"},
 	{"x~", "x~"},
+	// Equal sign always needs quoting.
+	{"=", "'='"},
+	{"a=b", "'a=b'"},
 	// Double quote when there is unprintable char.
 	{"a\nb", `"a\nb"`},
 	{"\x1b\"\\", `"\e\"\\"`},
@@ -0,0 +1,131 @@
+package parse
+
+import (
+	"bufio"
+	"io"
+)
+
+// Reader parses Elvish source read incrementally from an io.Reader. Instead of
+// requiring the whole source up front, it reads the source line by line, and
+// parses it one piece at a time, where a piece is the smallest run of whole
+// lines that can be parsed without errors caused by incomplete input (see
+// IsIncomplete). For instance, the lines "a |" and "b" form one piece.
+//
+// Parsing a long piece after each of its lines would take quadratic time, so
+// lines are first scanned lexically for brackets and strings left open, and
+// the piece is only parsed when none is certainly open. As a result, an error
+// inside an unclosed bracket, such as a missing redirection target, is only
+// reported together with the rest of the bracket.
+//
+// Positions in each parsed Chunk, as well as in parse errors, are relative to
+// the piece; the Offset method can be used to translate them into positions
+// in the whole source.
+type Reader struct {
+	srcName string
+	r       *bufio.Reader
+	offset  int
+	next    int
+}
+
+// NewReader creates a new Reader from the name of the source and an
+// io.Reader.
+func NewReader(srcname string, r io.Reader) *Reader {
+	return &Reader{srcname, bufio.NewReader(r), 0, 0}
+}
+
+// Next reads and parses the next piece of source. It returns the parsed Chunk
+// and the parse error, if any. When there is no more input, it returns nil and
+// io.EOF. Other errors from the underlying io.Reader are returned as is.
+func (rd *Reader) Next() (*Chunk, error) {
+	var buf []byte
+	var lex lexState
+	for {
+		line, err := rd.r.ReadString('\n')
+		buf = append(buf, line...)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(buf) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
+		lex.scan(line)
+		if err != io.EOF && lex.open() {
+			continue
+		}
+		src := string(buf)
+		n, parseErr := Parse(rd.srcName, src)
+		if err == io.EOF || !IsIncomplete(parseErr) {
+			rd.offset = rd.next
+			rd.next += len(src)
+			return n, parseErr
+		}
+	}
+}
+
+// Offset returns the byte offset of the piece last returned by Next in the
+// whole source.
+func (rd *Reader) Offset() int {
+	return rd.offset
+}
+
+// lexState tracks the brackets and strings left open by the source scanned so
+// far. The scan is lexical and much cheaper than parsing, but may disagree
+// with the parser; when it cannot tell, such as on mismatched brackets, it
+// gives up and reports nothing open.
+type lexState struct {
+	// Closers of the open brackets, innermost last.
+	closers []byte
+	// Quote of the open string, or 0.
+	quote  byte
+	unsure bool
+}
+
+func (ls *lexState) scan(line string) {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch ls.quote {
+		case '\'':
+			if c == '\'' {
+				ls.quote = 0
+			}
+			continue
+		case '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				ls.quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			ls.quote = c
+		case '(':
+			ls.closers = append(ls.closers, ')')
+		case '[':
+			ls.closers = append(ls.closers, ']')
+		case '{':
+			ls.closers = append(ls.closers, '}')
+		case ')', ']', '}':
+			if n := len(ls.closers); n > 0 && ls.closers[n-1] == c {
+				ls.closers = ls.closers[:n-1]
+			} else {
+				ls.unsure = true
+			}
+		case '`':
+			// Backquotes both open and close output captures.
+			ls.unsure = true
+		case '$':
+			// The first rune of a variable name can be anything.
+			i++
+		case '#':
+			// The rest of the line is a comment.
+			i = len(line)
+		}
+	}
+}
+
+// open returns whether a bracket or string is certainly open.
+func (ls *lexState) open() bool {
+	return !ls.unsure && (ls.quote != 0 || len(ls.closers) > 0)
+}
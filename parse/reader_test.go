@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	src := "a b\nc |\n  d\n\ne 'x\ny' (\nf\n) \nbad )\ng ("
+	// The parse tree of an erroneous piece may not cover the whole piece.
+	wantTexts := []string{"a b\n", "c |\n  d\n", "\n", "e 'x\ny' (\nf\n) \n", "bad ", "g ("}
+	wantOffsets := []int{0, 4, 12, 13, 28, 34}
+	wantErrors := []bool{false, false, false, false, true, true}
+
+	rd := NewReader("[test]", strings.NewReader(src))
+	var texts []string
+	var offsets []int
+	var errors []bool
+	for {
+		n, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		texts = append(texts, n.SourceText())
+		offsets = append(offsets, rd.Offset())
+		errors = append(errors, err != nil)
+	}
+	if !reflect.DeepEqual(texts, wantTexts) {
+		t.Errorf("Reader parses %q, want %q", texts, wantTexts)
+	}
+	if !reflect.DeepEqual(offsets, wantOffsets) {
+		t.Errorf("Reader has offsets %v, want %v", offsets, wantOffsets)
+	}
+	if !reflect.DeepEqual(errors, wantErrors) {
+		t.Errorf("Reader has errors %v, want %v", errors, wantErrors)
+	}
+}
+
+var lexStateTests = []struct {
+	lines []string
+	open  bool
+}{
+	{[]string{"a b\n"}, false},
+	{[]string{"a (\n", "b\n"}, true},
+	{[]string{"a (\n", "b)\n"}, false},
+	{[]string{"a {\n", "x = [\n"}, true},
+	{[]string{"a 'x\n", "y\n"}, true},
+	{[]string{"a 'x\n", "y' b\n"}, false},
+	{[]string{"a \"\\\"\n"}, true},
+	{[]string{"a ')' \"(\" $( $[\n"}, false},
+	{[]string{"a # ( '\n"}, false},
+	// Mismatched brackets and backquotes make the scan give up.
+	{[]string{"a (]\n"}, false},
+	{[]string{"a ( `b`\n"}, false},
+}
+
+func TestLexState(t *testing.T) {
+	for _, test := range lexStateTests {
+		var ls lexState
+		for _, line := range test.lines {
+			ls.scan(line)
+		}
+		if ls.open() != test.open {
+			t.Errorf("scanning %q: open() => %v, want %v", test.lines, !test.open, test.open)
+		}
+	}
+}
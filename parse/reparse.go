@@ -0,0 +1,74 @@
+package parse
+
+// Reparse parses src, reusing the result of parsing a previous version of the
+// source when possible. The old Chunk and error must be the return values of
+// a previous call to Parse or Reparse with the same source name.
+//
+// Top-level pipelines that lie entirely before the first change in the source
+// are reused, along with the errors in them; only the rest of the source is
+// parsed again. For an interactive editor, where changes usually happen near
+// the end of the buffer, this keeps the cost of reparsing proportional to the
+// size of the last pipeline instead of the whole buffer.
+//
+// The result is the same as that of Parse(srcname, src). The old Chunk is
+// taken apart in the process, and must not be used afterwards.
+func Reparse(srcname string, old *Chunk, oldErr error, src string) (*Chunk, error) {
+	if old == nil {
+		return Parse(srcname, src)
+	}
+	changed := commonPrefixLen(old.SourceText(), src)
+	// Find the first top-level pipeline that may be affected by the change.
+	// Since a change right at the beginning of a pipeline may also affect its
+	// preceding pipeline (for instance, deleting the separator), pipelines
+	// that begin at the change are considered affected too.
+	restart := 0
+	keep := 0
+	for i, ch := range old.Children() {
+		if IsPipeline(ch) {
+			if ch.Begin() >= changed {
+				break
+			}
+			restart = ch.Begin()
+			keep = i
+		}
+	}
+
+	ps := NewParser(srcname, src)
+	if oldErr, ok := oldErr.(*Error); ok {
+		for _, e := range oldErr.Entries {
+			if e.Context.Begin < restart {
+				ctx := e.Context
+				ctx.Source = src
+				ps.errors.Add(e.Message, ctx)
+			}
+		}
+	}
+
+	n := &Chunk{}
+	for _, ch := range old.Children()[:keep] {
+		if pn, ok := ch.(*Pipeline); ok {
+			n.addToPipelines(pn)
+		} else {
+			addChild(n, ch)
+		}
+	}
+	ps.pos = restart
+	n.parse(ps)
+	n.end = ps.pos
+	n.sourceText = src[:n.end]
+	ps.Done()
+	return n, ps.Errors()
+}
+
+func commonPrefixLen(a, b string) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package parse
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var reparseCases = []struct {
+	old, new string
+}{
+	{"", "a"},
+	{"a", "ab"},
+	{"echo a; echo b", "echo a; echo bc"},
+	{"echo a; echo b", "echo a; ech"},
+	// Deleting a separator joins pipelines.
+	{"a;b", "ab"},
+	{"a\nb\nc", "a\nb c"},
+	// Changes in the middle.
+	{"a\nb\nc", "a\nx\nc"},
+	{"a\nb\nc", "x\nb\nc"},
+	// Errors in reused pipelines are kept.
+	{"a [&k v]; b", "a [&k v]; b c"},
+	{"a (; b", "a (; b)"},
+	{"a [&k v]; b", "a [&k=v]; b"},
+	// Unexpected runes.
+	{"a\n)", "a\n) b"},
+	{"a\n) b", "a\nb"},
+	// Comments and spaces.
+	{"  # x\na # y\n  b", "  # x\na # y\n  b # z"},
+}
+
+func TestReparse(t *testing.T) {
+	for _, tc := range reparseCases {
+		old, oldErr := Parse("[test]", tc.old)
+		got, gotErr := Reparse("[test]", old, oldErr, tc.new)
+		want, wantErr := Parse("[test]", tc.new)
+
+		var gotTree, wantTree bytes.Buffer
+		PprintParseTree(got, &gotTree)
+		PprintParseTree(want, &wantTree)
+		if gotTree.String() != wantTree.String() {
+			t.Errorf("Reparse(%q -> %q) returns tree:\n%s\nwant:\n%s", tc.old, tc.new, gotTree.String(), wantTree.String())
+		}
+		if err := checkParseTree(got); err != nil {
+			t.Errorf("Reparse(%q -> %q) returns bad parse tree: %v", tc.old, tc.new, err)
+		}
+		if !reflect.DeepEqual(gotErr, wantErr) {
+			t.Errorf("Reparse(%q -> %q) returns error %v, want %v", tc.old, tc.new, gotErr, wantErr)
+		}
+	}
+}
+
+func BenchmarkReparse(b *testing.B) {
+	src := ""
+	for i := 0; i < 100; i++ {
+		src += "put [&k=v] | each [x]{ echo $x[k] >> out.txt }\n"
+	}
+	old, oldErr := Parse("[bench]", src)
+	for i := 0; i < b.N; i++ {
+		// Alternately append and remove one rune at the end.
+		if i%2 == 0 {
+			old, oldErr = Reparse("[bench]", old, oldErr, src+"x")
+		} else {
+			old, oldErr = Reparse("[bench]", old, oldErr, src)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package parse
+
+import "fmt"
+
+// Visitor is implemented by values that want to be notified of every node in
+// a parse tree during Walk. Each method is called with the node of the
+// corresponding type; if it returns true, Walk continues into the children of
+// that node.
+//
+// Types that are only interested in some node types can embed NopVisitor and
+// override the relevant methods.
+type Visitor interface {
+	VisitChunk(*Chunk) bool
+	VisitPipeline(*Pipeline) bool
+	VisitForm(*Form) bool
+	VisitAssignment(*Assignment) bool
+	VisitExitusRedir(*ExitusRedir) bool
+	VisitRedir(*Redir) bool
+	VisitCompound(*Compound) bool
+	VisitIndexing(*Indexing) bool
+	VisitArray(*Array) bool
+	VisitPrimary(*Primary) bool
+	VisitMapPair(*MapPair) bool
+	VisitSep(*Sep) bool
+}
+
+// NopVisitor implements Visitor. All of its methods do nothing but return
+// true, so that Walk visits the entire tree.
+type NopVisitor struct{}
+
+func (NopVisitor) VisitChunk(*Chunk) bool             { return true }
+func (NopVisitor) VisitPipeline(*Pipeline) bool       { return true }
+func (NopVisitor) VisitForm(*Form) bool               { return true }
+func (NopVisitor) VisitAssignment(*Assignment) bool   { return true }
+func (NopVisitor) VisitExitusRedir(*ExitusRedir) bool { return true }
+func (NopVisitor) VisitRedir(*Redir) bool             { return true }
+func (NopVisitor) VisitCompound(*Compound) bool       { return true }
+func (NopVisitor) VisitIndexing(*Indexing) bool       { return true }
+func (NopVisitor) VisitArray(*Array) bool             { return true }
+func (NopVisitor) VisitPrimary(*Primary) bool         { return true }
+func (NopVisitor) VisitMapPair(*MapPair) bool         { return true }
+func (NopVisitor) VisitSep(*Sep) bool                 { return true }
+
+// Walk traverses the parse tree rooted at n in depth-first order, calling the
+// method of v that corresponds to the type of each node. Children are visited
+// in source order, Sep nodes included.
+func Walk(n Node, v Visitor) {
+	if visit(n, v) {
+		for _, ch := range n.Children() {
+			Walk(ch, v)
+		}
+	}
+}
+
+func visit(n Node, v Visitor) bool {
+	switch n := n.(type) {
+	case *Chunk:
+		return v.VisitChunk(n)
+	case *Pipeline:
+		return v.VisitPipeline(n)
+	case *Form:
+		return v.VisitForm(n)
+	case *Assignment:
+		return v.VisitAssignment(n)
+	case *ExitusRedir:
+		return v.VisitExitusRedir(n)
+	case *Redir:
+		return v.VisitRedir(n)
+	case *Compound:
+		return v.VisitCompound(n)
+	case *Indexing:
+		return v.VisitIndexing(n)
+	case *Array:
+		return v.VisitArray(n)
+	case *Primary:
+		return v.VisitPrimary(n)
+	case *MapPair:
+		return v.VisitMapPair(n)
+	case *Sep:
+		return v.VisitSep(n)
+	default:
+		panic(fmt.Sprintf("unknown node type %T", n))
+	}
+}
@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+// variableCollector collects the names of all variables, and does not
+// descend into lambdas.
+type variableCollector struct {
+	NopVisitor
+	names []string
+}
+
+func (vc *variableCollector) VisitPrimary(pn *Primary) bool {
+	if pn.Type == Variable {
+		vc.names = append(vc.names, pn.Value)
+	}
+	return pn.Type != Lambda
+}
+
+var walkCases = []struct {
+	src   string
+	names []string
+}{
+	{"echo $a $b[$c]", []string{"a", "b", "c"}},
+	{"x = (put $y) >$z; put { echo $w }", []string{"y", "z"}},
+	{"put [&$k=[$v]]", []string{"k", "v"}},
+}
+
+func TestWalk(t *testing.T) {
+	for _, tc := range walkCases {
+		n, err := Parse("[test]", tc.src)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		vc := &variableCollector{}
+		Walk(n, vc)
+		if !reflect.DeepEqual(vc.names, tc.names) {
+			t.Errorf("Walk(%q) collects variables %v, want %v", tc.src, vc.names, tc.names)
+		}
+	}
+}
+
+type nodeCounter struct {
+	NopVisitor
+	nseps int
+}
+
+func (nc *nodeCounter) VisitSep(*Sep) bool {
+	nc.nseps++
+	return true
+}
+
+func TestWalkVisitsSeps(t *testing.T) {
+	n, _ := Parse("[test]", "a b;c")
+	nc := &nodeCounter{}
+	Walk(n, nc)
+	if nc.nseps != 2 {
+		t.Errorf("Walk visited %d Seps, want 2", nc.nseps)
+	}
+}
@@ -139,7 +139,9 @@ func interact(ev *eval.Evaler, daemon *api.Client) {
 
 	for {
 		cmdNum++
-		// name := fmt.Sprintf("<tty %d>", cmdNum)
+		name := fmt.Sprintf("[tty %d]", cmdNum)
+
+		ev.UpdateDirEnv()
 
 		line, err := readLine()
 
@@ -165,7 +167,9 @@ func interact(ev *eval.Evaler, daemon *api.Client) {
 		// No error; reset cooldown.
 		cooldown = time.Second
 
-		sourceTextAndPrintError(ev, "[interactive]", line)
+		start := time.Now()
+		ok := sourceTextAndPrintError(ev, name, line)
+		ed.RecordCmdResult(time.Since(start), ok)
 	}
 }
 
@@ -70,6 +70,32 @@ func (s *Store) Cmds(from, upto int) ([]string, error) {
 	return cmds, err
 }
 
+// CmdsWithSeq returns all commands within the specified range, along with
+// their sequence numbers.
+func (s *Store) CmdsWithSeq(from, upto int) ([]storedefs.Cmd, error) {
+	rows, err := s.db.Query(`SELECT rowid, content FROM cmd WHERE rowid >= ? AND rowid < ?`, from, upto)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cmds []storedefs.Cmd
+	for rows.Next() {
+		var cmd storedefs.Cmd
+		err = rows.Scan(&cmd.Seq, &cmd.Text)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, rows.Err()
+}
+
+// DelCmd deletes a command and its metadata from the command history.
+func (s *Store) DelCmd(seq int) error {
+	_, err := s.db.Exec(`DELETE FROM cmd WHERE rowid = ?; DELETE FROM cmd_meta WHERE seq = ?`, seq, seq)
+	return err
+}
+
 // NextCmd finds the first command after the given sequence number (inclusive)
 // with the given prefix.
 func (s *Store) NextCmd(from int, prefix string) (int, string, error) {
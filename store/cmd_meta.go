@@ -0,0 +1,41 @@
+package store
+
+import (
+	"database/sql"
+
+	"github.com/elves/elvish/store/storedefs"
+)
+
+func init() {
+	initDB["initialize command metadata table"] = func(db *sql.DB) error {
+		_, err := db.Exec(`CREATE TABLE IF NOT EXISTS cmd_meta (seq integer PRIMARY KEY, time integer, dir text, duration real, ok integer)`)
+		return err
+	}
+}
+
+// SetCmdMeta sets the metadata of a command in the command history.
+func (s *Store) SetCmdMeta(m storedefs.CmdMeta) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO cmd_meta (seq, time, dir, duration, ok) VALUES(?, ?, ?, ?, ?)`,
+		m.Seq, m.Time, m.Dir, m.Duration, m.OK)
+	return err
+}
+
+// CmdMetas returns the metadata of all commands within the specified range
+// that have metadata, ordered by sequence number.
+func (s *Store) CmdMetas(from, upto int) ([]storedefs.CmdMeta, error) {
+	rows, err := s.db.Query(`SELECT seq, time, dir, duration, ok FROM cmd_meta WHERE seq >= ? AND seq < ? ORDER BY seq`, from, upto)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var metas []storedefs.CmdMeta
+	for rows.Next() {
+		var m storedefs.CmdMeta
+		err = rows.Scan(&m.Seq, &m.Time, &m.Dir, &m.Duration, &m.OK)
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, m)
+	}
+	return metas, rows.Err()
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/store/storedefs"
+)
+
+var cmdMetas = []storedefs.CmdMeta{
+	{Seq: 1001, Time: 1500000000, Dir: "/home", Duration: 0.5, OK: true},
+	{Seq: 1003, Time: 1500000010, Dir: "/tmp", Duration: 2, OK: false},
+}
+
+func TestCmdMeta(t *testing.T) {
+	for _, m := range cmdMetas {
+		if err := tStore.SetCmdMeta(m); err != nil {
+			t.Errorf("tStore.SetCmdMeta(%v) => %v, want nil", m, err)
+		}
+	}
+	metas, err := tStore.CmdMetas(1000, 1004)
+	if !reflect.DeepEqual(metas, cmdMetas) || err != nil {
+		t.Errorf("tStore.CmdMetas(1000, 1004) => (%v, %v), want (%v, nil)",
+			metas, err, cmdMetas)
+	}
+	metas, err = tStore.CmdMetas(1002, 1003)
+	if len(metas) != 0 || err != nil {
+		t.Errorf("tStore.CmdMetas(1002, 1003) => (%v, %v), want (nil, nil)",
+			metas, err)
+	}
+
+	// Setting the metadata again replaces it.
+	m := cmdMetas[1]
+	m.OK = true
+	if err := tStore.SetCmdMeta(m); err != nil {
+		t.Errorf("tStore.SetCmdMeta(%v) => %v, want nil", m, err)
+	}
+	metas, err = tStore.CmdMetas(1003, 1004)
+	if len(metas) != 1 || metas[0] != m || err != nil {
+		t.Errorf("tStore.CmdMetas(1003, 1004) => (%v, %v), want ([%v], nil)",
+			metas, err, m)
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/elves/elvish/store/storedefs"
@@ -72,3 +73,26 @@ func TestCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestDelCmd(t *testing.T) {
+	startSeq, _ := tStore.NextCmdSeq()
+	for _, cmd := range []string{"first", "second", "third"} {
+		tStore.AddCmd(cmd)
+	}
+	tStore.SetCmdMeta(storedefs.CmdMeta{Seq: startSeq + 1, Dir: "/"})
+
+	err := tStore.DelCmd(startSeq + 1)
+	if err != nil {
+		t.Errorf("tStore.DelCmd(%v) => %v, want nil", startSeq+1, err)
+	}
+	wantCmds := []storedefs.Cmd{{"first", startSeq}, {"third", startSeq + 2}}
+	cmds, err := tStore.CmdsWithSeq(startSeq, startSeq+3)
+	if !reflect.DeepEqual(cmds, wantCmds) || err != nil {
+		t.Errorf("tStore.CmdsWithSeq => (%v, %v), want (%v, nil)",
+			cmds, err, wantCmds)
+	}
+	metas, err := tStore.CmdMetas(startSeq, startSeq+3)
+	if len(metas) != 0 || err != nil {
+		t.Errorf("tStore.CmdMetas => (%v, %v), want no metadata", metas, err)
+	}
+}
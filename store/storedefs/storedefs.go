@@ -15,3 +15,22 @@ type Dir struct {
 	Path  string
 	Score float64
 }
+
+// Cmd is an entry in the command history.
+type Cmd struct {
+	Text string
+	Seq  int
+}
+
+// CmdMeta is the metadata of an entry in the command history.
+type CmdMeta struct {
+	Seq int
+	// Time when the command was accepted, in seconds since the Unix epoch.
+	Time int64
+	// Working directory when the command was accepted.
+	Dir string
+	// How long the command took to run, in seconds.
+	Duration float64
+	// Whether the command finished without an exception.
+	OK bool
+}
@@ -0,0 +1,39 @@
+package sys
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Console input modes, as documented for SetConsoleMode.
+const (
+	EnableProcessedInput       = 0x1
+	EnableLineInput            = 0x2
+	EnableEchoInput            = 0x4
+	EnableVirtualTerminalInput = 0x200
+)
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+)
+
+// GetConsoleMode returns the mode of the console with the given handle.
+func GetConsoleMode(handle uintptr) (uint32, error) {
+	var mode uint32
+	r, _, err := procGetConsoleMode.Call(handle, uintptr(unsafe.Pointer(&mode)))
+	if r == 0 {
+		return 0, err
+	}
+	return mode, nil
+}
+
+// SetConsoleMode sets the mode of the console with the given handle.
+func SetConsoleMode(handle uintptr, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(handle, uintptr(mode))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
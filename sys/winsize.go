@@ -1,3 +1,5 @@
+// +build !windows
+
 // Copyright 2015 go-termios Author. All Rights Reserved.
 // https://github.com/go-termios/termios
 // Author: John Lenton <chipaca@github.com>
@@ -26,6 +26,28 @@ func (es *Errors) Error() string {
 	}
 }
 
+// Pprint pretty-prints all the errors, using their own Pprint methods when
+// available.
+func (es *Errors) Pprint(indent string) string {
+	if len(es.Errors) == 1 {
+		return pprintError(es.Errors[0], indent)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("Multiple errors:")
+	for _, e := range es.Errors {
+		buf.WriteString("\n" + indent + "  ")
+		buf.WriteString(pprintError(e, indent+"  "))
+	}
+	return buf.String()
+}
+
+func pprintError(e error, indent string) string {
+	if pprinter, ok := e.(Pprinter); ok {
+		return pprinter.Pprint(indent)
+	}
+	return e.Error()
+}
+
 func (es *Errors) Append(e error) {
 	es.Errors = append(es.Errors, e)
 }
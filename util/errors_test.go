@@ -0,0 +1,28 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+type prettyError struct{}
+
+func (prettyError) Error() string        { return "plain" }
+func (prettyError) Pprint(string) string { return "pretty" }
+
+var errorsPprintTests = []struct {
+	errs   []error
+	wanted string
+}{
+	{[]error{prettyError{}}, "pretty"},
+	{[]error{errors.New("a"), prettyError{}}, "Multiple errors:\n  a\n  pretty"},
+}
+
+func TestErrorsPprint(t *testing.T) {
+	for _, tt := range errorsPprintTests {
+		es := &Errors{tt.errs}
+		if got := es.Pprint(""); got != tt.wanted {
+			t.Errorf("Pprint of %v => %q, want %q", tt.errs, got, tt.wanted)
+		}
+	}
+}
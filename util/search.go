@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -22,9 +23,10 @@ func Search(paths []string, exe string) (string, error) {
 		return "", ErrNotExecutable
 	}
 	for _, p := range paths {
-		full := p + "/" + exe
-		if IsExecutable(full) {
-			return full, nil
+		for _, full := range executableCandidates(filepath.Join(p, exe)) {
+			if IsExecutable(full) {
+				return full, nil
+			}
 		}
 	}
 	return "", ErrNotFound
@@ -36,7 +38,7 @@ func EachExecutable(paths []string, f func(string)) {
 		// XXX Ignore error
 		infos, _ := ioutil.ReadDir(dir)
 		for _, info := range infos {
-			if !info.IsDir() && (info.Mode()&0111 != 0) {
+			if !info.IsDir() && isExecutableInfo(info) {
 				f(info.Name())
 			}
 		}
@@ -46,7 +48,8 @@ func EachExecutable(paths []string, f func(string)) {
 // DontSearch determines whether the path to an external command should be
 // taken literally and not searched.
 func DontSearch(exe string) bool {
-	return exe == ".." || strings.ContainsRune(exe, '/')
+	return exe == ".." || strings.ContainsRune(exe, '/') ||
+		strings.ContainsRune(exe, filepath.Separator)
 }
 
 // IsExecutable determines whether path refers to an executable file.
@@ -55,6 +58,5 @@ func IsExecutable(path string) bool {
 	if err != nil {
 		return false
 	}
-	fm := fi.Mode()
-	return !fm.IsDir() && (fm&0111 != 0)
+	return !fi.IsDir() && isExecutableInfo(fi)
 }
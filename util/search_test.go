@@ -0,0 +1,24 @@
+package util
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	InTempDir(func(dir string) {
+		ioutil.WriteFile("exe", nil, 0700)
+		ioutil.WriteFile("nonexe", nil, 0600)
+
+		if path, err := Search([]string{dir}, "exe"); err != nil || path != filepath.Join(dir, "exe") {
+			t.Errorf("Search(exe) => (%q, %v), want (%q, nil)", path, err, filepath.Join(dir, "exe"))
+		}
+		if _, err := Search([]string{dir}, "nonexe"); err != ErrNotFound {
+			t.Errorf("Search(nonexe) => %v, want %v", err, ErrNotFound)
+		}
+		if _, err := Search(nil, "./nonexe"); err != ErrNotExecutable {
+			t.Errorf("Search(./nonexe) => %v, want %v", err, ErrNotExecutable)
+		}
+	})
+}
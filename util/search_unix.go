@@ -0,0 +1,16 @@
+// +build !windows
+
+package util
+
+import "os"
+
+// executableCandidates returns the paths to try when searching for an
+// executable at path.
+func executableCandidates(path string) []string {
+	return []string{path}
+}
+
+// isExecutableInfo determines whether a file is executable from its mode bits.
+func isExecutableInfo(fi os.FileInfo) bool {
+	return fi.Mode()&0111 != 0
+}
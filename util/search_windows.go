@@ -0,0 +1,50 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pathExts returns the extensions of executable files, taken from the
+// PATHEXT environment variable.
+func pathExts() []string {
+	pathext := os.Getenv("PATHEXT")
+	if pathext == "" {
+		pathext = ".COM;.EXE;.BAT;.CMD"
+	}
+	return strings.Split(strings.ToLower(pathext), ";")
+}
+
+// executableCandidates returns the paths to try when searching for an
+// executable at path. Unless path already has an executable extension, the
+// extensions in PATHEXT are tried in turn.
+func executableCandidates(path string) []string {
+	if hasExecutableExt(path) {
+		return []string{path}
+	}
+	exts := pathExts()
+	candidates := make([]string, len(exts))
+	for i, ext := range exts {
+		candidates[i] = path + ext
+	}
+	return candidates
+}
+
+// isExecutableInfo determines whether a file is executable from its extension.
+func isExecutableInfo(fi os.FileInfo) bool {
+	return hasExecutableExt(fi.Name())
+}
+
+func hasExecutableExt(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return false
+	}
+	for _, e := range pathExts() {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
@@ -12,12 +12,26 @@ type SourceContext struct {
 	Begin  int
 	End    int
 	Next   *SourceContext
+	// Name of the function the position is in. Empty at the top level.
+	Function string
 }
 
 var CulpritStyle = "1;4"
 
+// Pprint pretty-prints the position with an excerpt of the source. If Source
+// is empty, the text is looked up in the source registry by Name.
 func (sc *SourceContext) Pprint(w io.Writer, sourceIndent string) {
-	if sc.Begin == -1 {
+	if sc.Source == "" {
+		if text, ok := LookupSource(sc.Name); ok {
+			copied := *sc
+			copied.Source = text
+			sc = &copied
+		}
+	}
+	if sc.Source == "" && sc.End > 0 {
+		fmt.Fprintf(w, "%s, source no longer available", sc.Name)
+		return
+	} else if sc.Begin == -1 {
 		fmt.Fprintf(w, "%s, unknown position", sc.Name)
 		return
 	} else if sc.Begin < 0 || sc.End > len(sc.Source) || sc.Begin > sc.End {
@@ -43,11 +57,18 @@ func (sc *SourceContext) Pprint(w io.Writer, sourceIndent string) {
 	// Find on which line and column the culprit ends.
 	endLine := beginLine + strings.Count(culprit, "\n")
 
+	// Find on which column the culprit begins, counting runes.
+	beginCol := countRunes(lineBefore) + 1
+
 	if beginLine == endLine {
-		fmt.Fprintf(w, "%s, line %d:\n", sc.Name, beginLine)
+		fmt.Fprintf(w, "%s:%d:%d", sc.Name, beginLine, beginCol)
 	} else {
-		fmt.Fprintf(w, "%s, line %d-%d:\n", sc.Name, beginLine, endLine)
+		fmt.Fprintf(w, "%s:%d:%d-%d", sc.Name, beginLine, beginCol, endLine)
+	}
+	if sc.Function != "" {
+		fmt.Fprintf(w, ", in %s", sc.Function)
 	}
+	fmt.Fprintf(w, ":\n")
 
 	fmt.Fprintf(w, "%s%s", sourceIndent, lineBefore)
 
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+var sourceContextPprintTests = []struct {
+	sc     SourceContext
+	header string
+}{
+	{SourceContext{"a.elv", "echo\nfoo bar", 9, 12, nil, ""}, "a.elv:2:5:\n"},
+	{SourceContext{"a.elv", "echo\nfoo bar", 9, 12, nil, "f"}, "a.elv:2:5, in f:\n"},
+	{SourceContext{"a.elv", "é foo\nbar", 3, 9, nil, ""}, "a.elv:1:3-2:\n"},
+}
+
+func TestSourceContextPprint(t *testing.T) {
+	for _, tt := range sourceContextPprintTests {
+		var buf bytes.Buffer
+		tt.sc.Pprint(&buf, "")
+		out := buf.String()
+		if len(out) < len(tt.header) || out[:len(tt.header)] != tt.header {
+			t.Errorf("Pprint of %v => %q, want header %q", tt.sc, out, tt.header)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"container/list"
+	"sync"
+)
+
+// The source registry maps names of loaded sources, such as "[tty 3]" or the
+// path of an rc file, to their text. It allows a SourceContext without the
+// source text to be rendered.
+//
+// Interactive sessions register a source for every line, so the registry only
+// keeps the maxSources most recently registered or looked up sources.
+var (
+	sourcesMutex sync.Mutex
+	sources      = map[string]*list.Element{}
+	// Entries of type *source, most recently used first.
+	sourcesLRU = list.New()
+)
+
+// maxSources is the maximum number of sources kept in the registry.
+const maxSources = 256
+
+type source struct {
+	name, text string
+}
+
+// RegisterSource records the text of a source under the given name, replacing
+// any text previously registered under the same name. The least recently
+// used source is dropped if the registry is full.
+func RegisterSource(name, text string) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+	if e, ok := sources[name]; ok {
+		e.Value.(*source).text = text
+		sourcesLRU.MoveToFront(e)
+		return
+	}
+	sources[name] = sourcesLRU.PushFront(&source{name, text})
+	if sourcesLRU.Len() > maxSources {
+		oldest := sourcesLRU.Back()
+		sourcesLRU.Remove(oldest)
+		delete(sources, oldest.Value.(*source).name)
+	}
+}
+
+// ContextSource returns the source text to store in a SourceContext for the
+// source with the given name and text. It is empty if the same text is
+// registered under the name, so that the context does not keep a copy of it.
+func ContextSource(name, text string) string {
+	if registered, ok := LookupSource(name); ok && registered == text {
+		return ""
+	}
+	return text
+}
+
+// LookupSource returns the text of a source registered under the given name.
+func LookupSource(name string) (string, bool) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+	e, ok := sources[name]
+	if !ok {
+		return "", false
+	}
+	sourcesLRU.MoveToFront(e)
+	return e.Value.(*source).text, true
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSourceRegistry(t *testing.T) {
+	RegisterSource("[test source]", "echo foo")
+	text, ok := LookupSource("[test source]")
+	if !ok || text != "echo foo" {
+		t.Errorf("LookupSource => (%q, %v), want (%q, true)", text, ok, "echo foo")
+	}
+	if _, ok := LookupSource("[nonexistent]"); ok {
+		t.Errorf("LookupSource of unregistered name => ok")
+	}
+
+	// A SourceContext without source text is rendered from the registry.
+	var buf bytes.Buffer
+	sc := SourceContext{Name: "[test source]", Begin: 5, End: 8}
+	sc.Pprint(&buf, "")
+	if !strings.Contains(buf.String(), "foo") {
+		t.Errorf("Pprint => %q, want excerpt from registered source", buf.String())
+	}
+}
+
+func TestSourceRegistryIsBounded(t *testing.T) {
+	RegisterSource("[kept]", "kept")
+	for i := 0; i < maxSources; i++ {
+		RegisterSource(fmt.Sprintf("[tty %d]", i), "echo")
+		// Looking up a source keeps it.
+		LookupSource("[kept]")
+	}
+	if len(sources) != maxSources || sourcesLRU.Len() != maxSources {
+		t.Errorf("registry has %d sources, want %d", len(sources), maxSources)
+	}
+	if _, ok := LookupSource("[tty 0]"); ok {
+		t.Errorf("least recently used source is kept")
+	}
+	if text, ok := LookupSource("[kept]"); !ok || text != "kept" {
+		t.Errorf("recently used source => (%q, %v), want (%q, true)", text, ok, "kept")
+	}
+}